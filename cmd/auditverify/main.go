@@ -0,0 +1,51 @@
+/*
+auditverify verifies the hash chain of the audit log in the database of the API.
+
+every entry of the audit log contains the hash of the previous entry, so a changed, inserted or deleted entry breaks the chain.
+the command recomputes the hash of every entry from the first entry and reports the first entry which does not match.
+
+	go run ./cmd/auditverify
+	go run ./cmd/auditverify -expect <hash> -expect-entry <entryId>
+
+with -expect a valid entry must have a hash which was kept outside of the database, e.g. the latest hash of a daily report,
+so the entries up to it can not be removed from the end of the log unnoticed. The entries which were added since are verified
+by the chain. -expect-entry is the entry of the kept hash, the hash is looked up from this entry on. The exit code is 1 if the
+audit log was changed
+*/
+package main
+
+import (
+	"context"
+	"eBikeApi/services/implementation"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	expectedHash := flag.String("expect", "", "hash of an entry which was kept outside of the database")
+	expectedEntryId := flag.Int64("expect-entry", 0, "id of the entry of the kept hash")
+	flag.Parse()
+
+	verification, verifyError := implementation.VerifyAuditLog(context.Background(), *expectedHash, *expectedEntryId)
+	if verifyError != nil {
+		fmt.Printf("could not verify the audit log. %v\n", verifyError)
+		os.Exit(2)
+	}
+
+	if !verification.Valid {
+		fmt.Printf("the audit log was changed: %v\n", verification.Error)
+		fmt.Printf("%d entries before entry %d are valid\n", verification.Entries, *verification.FirstInvalidEntryId)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d entries are valid, the hash of the latest entry %d is %v\n", verification.Entries, verification.LastEntryId, verification.LastHash)
+	if *expectedHash == "" {
+		return
+	}
+	if verification.ExpectedHashEntryId == nil {
+		fmt.Printf("no entry from entry %d on has the expected hash %v, entries were removed from the end\n", *expectedEntryId, *expectedHash)
+		os.Exit(1)
+	}
+	fmt.Printf("entry %d has the expected hash, the log continues up to entry %d\n", *verification.ExpectedHashEntryId, verification.LastEntryId)
+}
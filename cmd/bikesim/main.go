@@ -0,0 +1,332 @@
+/*
+bikesim simulates the device of a bike, so the command channel and the telemetry can be tried locally.
+
+the device polls the API for commands, executes them and acknowledges them. While the bike is unlocked it rides around
+its start position and reports its telemetry. Every request is signed with the secret of the device.
+
+	go run ./cmd/bikesim -bike 1 -operator operatorOne
+	go run ./cmd/bikesim -bike 1 -device sim-1 -secret <secret>
+
+with -operator the device is registered first, which generates a new secret.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// meters per degree of latitude
+	METERS_PER_DEGREE = 111320
+	// the battery drains by this percentage per kilometer
+	BATTERY_PERCENT_PER_KM = 1.5
+	// time the simulator waits after a failed request
+	RETRY_DELAY = 5 * time.Second
+	// the long poll waits at most this long, the http client waits a bit longer
+	POLL_WAIT_SECONDS = 25
+)
+
+// a command which the API delivers with the long poll
+type command struct {
+	CommandId int64  `json:"commandId"`
+	Command   string `json:"command"`
+}
+
+// the acknowledgement of a command
+type commandAck struct {
+	SentAt    time.Time `json:"sentAt"`
+	Success   bool      `json:"success"`
+	Message   string    `json:"message"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+}
+
+// a telemetry record as it is sent to the API
+type telemetryRecord struct {
+	RecordedAt     time.Time `json:"recordedAt"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	BatteryPercent int       `json:"batteryPercent"`
+	Locked         bool      `json:"locked"`
+	OdometerMeters int64     `json:"odometerMeters"`
+}
+
+// the simulated state of the bike
+type bike struct {
+	mutex          sync.Mutex
+	latitude       float64
+	longitude      float64
+	heading        float64 // direction of the ride in radians
+	batteryPercent float64
+	locked         bool
+	odometerMeters float64
+}
+
+// the connection of the simulator to the API
+type device struct {
+	api      string
+	deviceId string
+	secret   string
+	client   *http.Client
+}
+
+func main() {
+	api := flag.String("api", "http://localhost:8080", "base url of the API")
+	bikeId := flag.Int("bike", 0, "id of the simulated bike")
+	deviceId := flag.String("device", "", "id of the device, default sim-<bike>")
+	secret := flag.String("secret", "", "secret of the device")
+	operator := flag.String("operator", "", "operator who registers the device and generates its secret, instead of -secret")
+	latitude := flag.Float64("lat", 50.119504, "start latitude")
+	longitude := flag.Float64("lon", 8.638137, "start longitude")
+	battery := flag.Float64("battery", 100, "start battery percentage")
+	odometer := flag.Int64("odometer", 0, "start odometer in meters, must not be lower than the last reported odometer")
+	speed := flag.Float64("speed", 5, "speed of the unlocked bike in meters per second")
+	interval := flag.Duration("interval", 10*time.Second, "interval of the telemetry")
+	noAckRate := flag.Float64("noack", 0, "probability (0 - 1) that a command is not acknowledged, to try timeouts")
+	failRate := flag.Float64("fail", 0, "probability (0 - 1) that a command fails")
+	flag.Parse()
+
+	if *deviceId == "" {
+		*deviceId = fmt.Sprintf("sim-%d", *bikeId)
+	}
+
+	simulatedDevice := &device{api: *api, deviceId: *deviceId, secret: *secret, client: &http.Client{Timeout: (POLL_WAIT_SECONDS + 10) * time.Second}}
+	if *operator != "" {
+		registerError := simulatedDevice.register(*bikeId, *operator)
+		if registerError != nil {
+			log.Fatalf("could not register device. %v", registerError)
+		}
+		fmt.Printf("registered device %v of bike %d, secret %v\n", simulatedDevice.deviceId, *bikeId, simulatedDevice.secret)
+	}
+	if simulatedDevice.secret == "" {
+		log.Fatal("either -secret or -operator is required")
+	}
+
+	simulatedBike := &bike{latitude: *latitude, longitude: *longitude, heading: rand.Float64() * 2 * math.Pi,
+		batteryPercent: *battery, locked: true, odometerMeters: float64(*odometer)}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// report the telemetry in the interval, the bike moves while it is unlocked
+	go func() {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		simulatedDevice.sendTelemetry(simulatedBike.record())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				simulatedBike.move(*speed * interval.Seconds())
+				simulatedDevice.sendTelemetry(simulatedBike.record())
+			}
+		}
+	}()
+
+	fmt.Printf("bike %d is locked at %.6f, %.6f and waits for commands\n", *bikeId, *latitude, *longitude)
+	for ctx.Err() == nil {
+		commands, pollError := simulatedDevice.poll(ctx)
+		if pollError != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("could not poll commands. %v\n", pollError)
+				sleep(ctx, RETRY_DELAY)
+			}
+			continue
+		}
+
+		for _, receivedCommand := range commands {
+			fmt.Printf("received command %d: %v\n", receivedCommand.CommandId, receivedCommand.Command)
+			if rand.Float64() < *noAckRate {
+				fmt.Printf("not acknowledging command %d\n", receivedCommand.CommandId)
+				continue
+			}
+			ack := simulatedBike.execute(receivedCommand.Command)
+			if ack.Success && rand.Float64() < *failRate {
+				ack = commandAck{Success: false, Message: "simulated failure"}
+			}
+			simulatedDevice.acknowledge(receivedCommand.CommandId, ack)
+			// the new lock state is reported immediately
+			if ack.Success && (receivedCommand.Command == "unlock" || receivedCommand.Command == "lock") {
+				simulatedDevice.sendTelemetry(simulatedBike.record())
+			}
+		}
+	}
+	fmt.Println("bike simulator stopped")
+}
+
+// executes a command and returns its acknowledgement
+func (b *bike) execute(commandName string) commandAck {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch commandName {
+	case "unlock":
+		b.locked = false
+		fmt.Println("*click* bike unlocked")
+		return commandAck{Success: true}
+	case "lock":
+		b.locked = true
+		fmt.Println("*click* bike locked")
+		return commandAck{Success: true}
+	case "ring":
+		fmt.Println("*ring ring*")
+		return commandAck{Success: true}
+	case "locate":
+		latitude, longitude := b.latitude, b.longitude
+		return commandAck{Success: true, Latitude: &latitude, Longitude: &longitude}
+	}
+	return commandAck{Success: false, Message: "unknown command " + commandName}
+}
+
+// moves an unlocked bike by the distance in meters, the direction changes a little with every move
+func (b *bike) move(meters float64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.locked || b.batteryPercent <= 0 {
+		return
+	}
+	b.heading += (rand.Float64() - 0.5) * math.Pi / 4
+	b.latitude += meters * math.Cos(b.heading) / METERS_PER_DEGREE
+	b.longitude += meters * math.Sin(b.heading) / (METERS_PER_DEGREE * math.Cos(b.latitude*math.Pi/180))
+	b.odometerMeters += meters
+	b.batteryPercent = math.Max(b.batteryPercent-meters/1000*BATTERY_PERCENT_PER_KM, 0)
+}
+
+// returns the current state of the bike as telemetry record
+func (b *bike) record() telemetryRecord {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return telemetryRecord{
+		RecordedAt:     time.Now().UTC(),
+		Latitude:       math.Round(b.latitude*1e6) / 1e6,
+		Longitude:      math.Round(b.longitude*1e6) / 1e6,
+		BatteryPercent: int(math.Round(b.batteryPercent)),
+		Locked:         b.locked,
+		OdometerMeters: int64(b.odometerMeters),
+	}
+}
+
+// registers the device with an operator account and stores the generated secret
+func (d *device) register(bikeId int, operator string) error {
+	body, _ := json.Marshal(map[string]string{"deviceId": d.deviceId})
+	request, requestError := http.NewRequest(http.MethodPost, fmt.Sprintf("%v/bikes/%d/device", d.api, bikeId), bytes.NewReader(body))
+	if requestError != nil {
+		return requestError
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Username", operator)
+
+	var registered struct {
+		Secret string `json:"secret"`
+	}
+	sendError := d.send(request, &registered)
+	if sendError != nil {
+		return sendError
+	}
+	d.secret = registered.Secret
+	return nil
+}
+
+// waits for the next commands of the device
+func (d *device) poll(ctx context.Context) ([]command, error) {
+	body, _ := json.Marshal(map[string]interface{}{"sentAt": time.Now().UTC(), "waitSeconds": POLL_WAIT_SECONDS})
+	request, requestError := d.signedRequest(ctx, "/commands/poll", body)
+	if requestError != nil {
+		return nil, requestError
+	}
+
+	commands := []command{}
+	sendError := d.send(request, &commands)
+	return commands, sendError
+}
+
+// acknowledges a command, a failed acknowledgement is only reported since the command times out anyway
+func (d *device) acknowledge(commandId int64, ack commandAck) {
+	ack.SentAt = time.Now().UTC()
+	body, _ := json.Marshal(ack)
+	request, requestError := d.signedRequest(context.Background(), fmt.Sprintf("/commands/%d/ack", commandId), body)
+	if requestError == nil {
+		requestError = d.send(request, nil)
+	}
+	if requestError != nil {
+		fmt.Printf("could not acknowledge command %d. %v\n", commandId, requestError)
+		return
+	}
+	fmt.Printf("acknowledged command %d, success %v\n", commandId, ack.Success)
+}
+
+// reports a telemetry record, a failed report is only printed
+func (d *device) sendTelemetry(record telemetryRecord) {
+	body, _ := json.Marshal(record)
+	request, requestError := d.signedRequest(context.Background(), "/telemetry", body)
+	if requestError == nil {
+		requestError = d.send(request, nil)
+	}
+	if requestError != nil {
+		fmt.Printf("could not send telemetry. %v\n", requestError)
+	}
+}
+
+// returns a POST request which is signed with the secret of the device
+func (d *device) signedRequest(ctx context.Context, path string, body []byte) (*http.Request, error) {
+	request, requestError := http.NewRequestWithContext(ctx, http.MethodPost, d.api+path, bytes.NewReader(body))
+	if requestError != nil {
+		return nil, requestError
+	}
+
+	mac := hmac.New(sha256.New, []byte(d.secret))
+	mac.Write(body)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Device-Id", d.deviceId)
+	request.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	return request, nil
+}
+
+// sends a request and decodes the JSON response into result, if result is not nil
+func (d *device) send(request *http.Request, result interface{}) error {
+	response, sendError := d.client.Do(request)
+	if sendError != nil {
+		return sendError
+	}
+	defer response.Body.Close()
+
+	responseBody, readError := io.ReadAll(response.Body)
+	if readError != nil {
+		return readError
+	}
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("%v: %s", response.Status, bytes.TrimSpace(responseBody))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(responseBody, result)
+}
+
+// waits for the duration or until the simulator is stopped
+func sleep(ctx context.Context, duration time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(duration):
+	}
+}
@@ -0,0 +1,165 @@
+/*
+webhookreceiver receives the webhooks of the API locally, so a subscription can be tried without a partner.
+
+it verifies the signature of every request with the secret of the subscription and prints the events.
+a fail rate lets requests fail, to watch the retries and the dead deliveries in the delivery log.
+
+	go run ./cmd/webhookreceiver -secret <secret>
+	go run ./cmd/webhookreceiver -secret <secret> -fail 0.5 -addr :9090
+
+the subscription is created with the url of the receiver, e.g. http://localhost:9090/webhooks
+*/
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maximal size of a webhook request
+	MAX_BODY_BYTES = 1 << 20
+	// requests which were signed longer ago are rejected, so a recorded request can not be sent again
+	SIGNATURE_TOLERANCE = 5 * time.Minute
+)
+
+// the payload of a webhook
+type event struct {
+	EventId    string          `json:"eventId"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// the receiver remembers the ids of the received events, since an event can be delivered more than once
+type receiver struct {
+	secret   string
+	failRate float64
+	mutex    sync.Mutex
+	received map[string]bool
+}
+
+func main() {
+	addr := flag.String("addr", ":9090", "address the receiver listens on")
+	secret := flag.String("secret", "", "secret of the webhook subscription, the signature is not verified without it")
+	failRate := flag.Float64("fail", 0, "probability (0 - 1) that a request fails with status 500, to try the retries")
+	flag.Parse()
+
+	if *secret == "" {
+		fmt.Println("WARNING! no -secret provided, the signatures are not verified")
+	}
+
+	webhookReceiver := &receiver{secret: *secret, failRate: *failRate, received: map[string]bool{}}
+	http.Handle("/", webhookReceiver)
+
+	fmt.Printf("receiving webhooks on %v\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// receives a webhook, verifies it and prints the event
+func (receiver *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, readError := io.ReadAll(io.LimitReader(r.Body, MAX_BODY_BYTES))
+	if readError != nil {
+		http.Error(w, readError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if receiver.secret != "" {
+		verifyError := verifySignature(receiver.secret, r.Header.Get("X-Webhook-Signature"), body, time.Now())
+		if verifyError != nil {
+			fmt.Printf("rejected delivery %v: %v\n", r.Header.Get("X-Webhook-Delivery"), verifyError)
+			http.Error(w, verifyError.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var receivedEvent event
+	unmarshalError := json.Unmarshal(body, &receivedEvent)
+	if unmarshalError != nil {
+		http.Error(w, unmarshalError.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if rand.Float64() < receiver.failRate {
+		fmt.Printf("failing delivery %v of event %v on purpose\n", r.Header.Get("X-Webhook-Delivery"), receivedEvent.EventId)
+		http.Error(w, "simulated failure", http.StatusInternalServerError)
+		return
+	}
+
+	receiver.mutex.Lock()
+	duplicate := receiver.received[receivedEvent.EventId]
+	receiver.received[receivedEvent.EventId] = true
+	receiver.mutex.Unlock()
+
+	if duplicate {
+		fmt.Printf("received event %v again (%v), ignoring it\n", receivedEvent.EventId, receivedEvent.Type)
+	} else {
+		fmt.Printf("%v %v delivery %v event %v\n  %s\n", receivedEvent.OccurredAt.Local().Format(time.RFC3339), receivedEvent.Type,
+			r.Header.Get("X-Webhook-Delivery"), receivedEvent.EventId, receivedEvent.Data)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+verifies the signature header "t=<unix time>,v1=<signature>". The signature is the hex encoded HMAC-SHA256 of
+"<unix time>.<body>" with the secret of the subscription
+*/
+func verifySignature(secret string, header string, body []byte, now time.Time) error {
+	var timestamp int64
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, isPair := strings.Cut(strings.TrimSpace(part), "=")
+		if !isPair {
+			continue
+		}
+		switch key {
+		case "t":
+			parsedTimestamp, parseError := strconv.ParseInt(value, 10, 64)
+			if parseError != nil {
+				return fmt.Errorf("invalid timestamp %v", value)
+			}
+			timestamp = parsedTimestamp
+		case "v1":
+			signature, decodeError := hex.DecodeString(value)
+			if decodeError == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return fmt.Errorf("missing or invalid signature header")
+	}
+
+	signedAt := time.Unix(timestamp, 0)
+	if now.Sub(signedAt) > SIGNATURE_TOLERANCE || signedAt.Sub(now) > SIGNATURE_TOLERANCE {
+		return fmt.Errorf("signature timestamp %v is too old", signedAt.Format(time.RFC3339))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature does not match")
+}
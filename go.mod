@@ -6,5 +6,6 @@ require github.com/gorilla/mux v1.8.0
 
 require (
 	github.com/google/uuid v1.3.0
+	github.com/gorilla/websocket v1.5.0
 	github.com/lib/pq v1.10.7
 )
@@ -3,17 +3,26 @@ package main
 
 /// Go fmt import
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"eBikeApi/services/handler"
+	"eBikeApi/services/implementation"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
 
+	// the API exits after the deferred functions stopped the background jobs and closed the database
+	exitCode := 0
+	defer func() { os.Exit(exitCode) }()
+
 	SERVERPORT := "8080"
 	// Initialize router
 	router := mux.NewRouter()
@@ -32,7 +41,297 @@ func main() {
 	// Delete reservation for a specific bike
 	router.HandleFunc("/reservation/bike/{bikeId}", handler.DeleteBikeReservation).Methods("DELETE")
 
+	// Attach a promotion code to a running reservation
+	router.HandleFunc("/reservation/{reservationId}/promotion", handler.AttachPromotionToReservation).Methods("POST")
+
+	// Get all finished rides of a user
+	router.HandleFunc("/rides", handler.GetRides).Queries("user", "{username}").Methods("GET")
+
+	// Get the receipt of a ride as JSON or printable HTML
+	router.HandleFunc("/rides/{rideId}/receipt", handler.GetRideReceipt).Methods("GET")
+
+	// Get a receipt as JSON or printable HTML
+	router.HandleFunc("/receipts/{receiptId}", handler.GetReceipt).Methods("GET")
+
+	// Get the monthly statement of a user as JSON or CSV
+	router.HandleFunc("/users/{username}/statements/{month}", handler.GetMonthlyStatement).Methods("GET")
+
+	// Get all promotion codes (operators only)
+	router.HandleFunc("/promotions/", handler.GetAllPromotions).Methods("GET")
+
+	// Create a promotion code (operators only)
+	router.HandleFunc("/promotions/", handler.CreatePromotion).Methods("POST")
+
+	// Get all subscription plans
+	router.HandleFunc("/plans/", handler.GetAllPlans).Methods("GET")
+
+	// Create a subscription plan (operators only)
+	router.HandleFunc("/plans/", handler.CreatePlan).Methods("POST")
+
+	// Get the active subscription of a user with the usage of the included minutes
+	router.HandleFunc("/users/{username}/subscription", handler.GetSubscription).Methods("GET")
+
+	// Subscribe a user to a plan
+	router.HandleFunc("/users/{username}/subscription", handler.CreateSubscription).Methods("POST")
+
+	// Cancel the subscription of a user at the end of the current period
+	router.HandleFunc("/users/{username}/subscription", handler.CancelSubscription).Methods("DELETE")
+
+	// Create an organization with a billing account (operators only)
+	router.HandleFunc("/organizations/", handler.CreateOrganization).Methods("POST")
+
+	// Get an organization (operators and managers of the organization)
+	router.HandleFunc("/organizations/{organizationId}", handler.GetOrganization).Methods("GET")
+
+	// Get all members of an organization (operators and managers of the organization)
+	router.HandleFunc("/organizations/{organizationId}/members", handler.GetOrganizationMembers).Methods("GET")
+
+	// Add a member to an organization or update the membership (operators and managers of the organization)
+	router.HandleFunc("/organizations/{organizationId}/members", handler.AddOrganizationMember).Methods("POST")
+
+	// Remove a member from an organization (operators and managers of the organization)
+	router.HandleFunc("/organizations/{organizationId}/members/{username}", handler.RemoveOrganizationMember).Methods("DELETE")
+
+	// Get the invoice report of an organization (operators and managers of the organization)
+	router.HandleFunc("/organizations/{organizationId}/invoice", handler.GetOrganizationInvoice).Methods("GET")
+
+	// Get all penalty rules (operators only)
+	router.HandleFunc("/penalties/rules", handler.GetPenaltyRules).Methods("GET")
+
+	// Create or update a penalty rule (operators only)
+	router.HandleFunc("/penalties/rules/{ruleId}", handler.SavePenaltyRule).Methods("PUT")
+
+	// Get the itemized penalties of a ride
+	router.HandleFunc("/rides/{rideId}/penalties", handler.GetRidePenalties).Methods("GET")
+
+	// Waive a penalty of a ride with a reason, the amount is refunded with a credit note (operators only)
+	router.HandleFunc("/rides/{rideId}/penalties/{penaltyId}/waive", handler.WaivePenalty).Methods("POST")
+
+	// Get the active zones as GeoJSON FeatureCollection, optionally filtered by kind and bbox
+	router.HandleFunc("/zones", handler.GetZones).Methods("GET")
+
+	// Import zones from a GeoJSON FeatureCollection (operators only)
+	router.HandleFunc("/zones/import", handler.ImportZones).Methods("POST")
+
+	// Evaluate a position against the zones
+	router.HandleFunc("/zones/evaluate", handler.EvaluatePosition).Methods("GET")
+
+	// Get a zone as GeoJSON Feature
+	router.HandleFunc("/zones/{zoneId}", handler.GetZone).Methods("GET")
+
+	// Deactivate a zone (operators only)
+	router.HandleFunc("/zones/{zoneId}", handler.DeactivateZone).Methods("DELETE")
+
+	// Store the position which a bike reports and evaluate it against the zones (operators only)
+	router.HandleFunc("/bikes/{bikeId}/position", handler.ReportBikePosition).Methods("POST")
+
+	// Get all docking stations with available bikes and free docks
+	router.HandleFunc("/stations", handler.GetStations).Methods("GET")
+
+	// Create a docking station (operators only)
+	router.HandleFunc("/stations", handler.CreateStation).Methods("POST")
+
+	// Get a docking station with available bikes and free docks
+	router.HandleFunc("/stations/{stationId}", handler.GetStation).Methods("GET")
+
+	// Ingest telemetry of a bike, single records, JSON arrays or NDJSON (devices only, signed with HMAC)
+	router.HandleFunc("/telemetry", handler.IngestTelemetry).Methods("POST")
+
+	// Register the device of a bike and generate its secret (operators only)
+	router.HandleFunc("/bikes/{bikeId}/device", handler.RegisterDevice).Methods("POST")
+
+	// Get the telemetry history of a bike (operators only)
+	router.HandleFunc("/bikes/{bikeId}/telemetry", handler.GetTelemetryHistory).Methods("GET")
+
+	// Change the operational status of a bike with a reason (operators only)
+	router.HandleFunc("/bikes/{bikeId}/status", handler.ChangeBikeStatus).Methods("PUT")
+
+	// Get the status history of a bike (operators only)
+	router.HandleFunc("/bikes/{bikeId}/status/history", handler.GetBikeStatusHistory).Methods("GET")
+
+	// Report a damage of a bike with photos (multipart/form-data)
+	router.HandleFunc("/bikes/{bikeId}/damage-reports", handler.CreateDamageReport).Methods("POST")
+
+	// Get the damage reports to triage them, filtered by status and bike (operators only)
+	router.HandleFunc("/damage-reports", handler.GetDamageReports).Methods("GET")
+
+	// Get a damage report with its photos (operators only)
+	router.HandleFunc("/damage-reports/{reportId}", handler.GetDamageReport).Methods("GET")
+
+	// Triage a damage report and optionally move the bike into maintenance (operators only)
+	router.HandleFunc("/damage-reports/{reportId}/triage", handler.TriageDamageReport).Methods("PUT")
+
+	// Get a photo of a damage report (operators only)
+	router.HandleFunc("/damage-reports/{reportId}/photos/{photoId}", handler.GetDamagePhoto).Methods("GET")
+
+	// Create a maintenance work order manually or from a damage report (operators only)
+	router.HandleFunc("/workorders", handler.CreateWorkOrder).Methods("POST")
+
+	// List work orders by bike, assignee and status (operators only)
+	router.HandleFunc("/workorders", handler.GetWorkOrders).Methods("GET")
+
+	// Get a work order with its parts and labour notes (operators only)
+	router.HandleFunc("/workorders/{orderId}", handler.GetWorkOrder).Methods("GET")
+
+	// Change the status or the assignment of a work order (operators only)
+	router.HandleFunc("/workorders/{orderId}", handler.UpdateWorkOrder).Methods("PUT")
+
+	// Add a parts or labour note to a work order (operators only)
+	router.HandleFunc("/workorders/{orderId}/notes", handler.AddWorkOrderNote).Methods("POST")
+
+	// List the maintenance plans of the bike types (operators only)
+	router.HandleFunc("/maintenance/plans", handler.GetMaintenancePlans).Methods("GET")
+
+	// Create or update a maintenance plan (operators only)
+	router.HandleFunc("/maintenance/plans/{planId}", handler.SaveMaintenancePlan).Methods("PUT")
+
+	// Get the weekly report of upcoming preventive maintenance (operators only)
+	router.HandleFunc("/maintenance/upcoming", handler.GetUpcomingMaintenance).Methods("GET")
+
+	// Run the maintenance scheduler immediately (operators only)
+	router.HandleFunc("/maintenance/run", handler.RunMaintenanceScheduler).Methods("POST")
+
+	// Get the usage of a bike since its last service (operators only)
+	router.HandleFunc("/bikes/{bikeId}/maintenance", handler.GetBikeMaintenance).Methods("GET")
+
+	// Send an unlock, lock, ring or locate command to the device of a bike (operators only)
+	router.HandleFunc("/bikes/{bikeId}/commands", handler.SendBikeCommand).Methods("POST")
+
+	// Get the latest commands of a bike (operators only)
+	router.HandleFunc("/bikes/{bikeId}/commands", handler.GetBikeCommands).Methods("GET")
+
+	// Get the unlock and lock commands of a reservation (rider of the reservation or operators)
+	router.HandleFunc("/reservation/{reservationId}/commands", handler.GetReservationCommands).Methods("GET")
+
+	// Long poll of a device for its commands (devices only, signed with HMAC)
+	router.HandleFunc("/commands/poll", handler.PollBikeCommands).Methods("POST")
+
+	// Acknowledge a command (devices only, signed with HMAC)
+	router.HandleFunc("/commands/{commandId}/ack", handler.AcknowledgeBikeCommand).Methods("POST")
+
+	// Get the feed of theft alerts (operators only)
+	router.HandleFunc("/alerts", handler.GetTheftAlerts).Methods("GET")
+
+	// Acknowledge or resolve a theft alert (operators only)
+	router.HandleFunc("/alerts/{alertId}", handler.UpdateTheftAlert).Methods("PUT")
+
+	// Stream the changes of bikes as server-sent events
+	router.HandleFunc("/bikes/stream", handler.StreamBikes).Methods("GET")
+
+	// Stream the changes of bikes over a websocket
+	router.HandleFunc("/bikes/ws", handler.StreamBikesWebSocket).Methods("GET")
+
+	// Create a webhook subscription (operators only)
+	router.HandleFunc("/webhooks", handler.CreateWebhookSubscription).Methods("POST")
+
+	// Get all webhook subscriptions (operators only)
+	router.HandleFunc("/webhooks", handler.GetWebhookSubscriptions).Methods("GET")
+
+	// Change a webhook subscription (operators only)
+	router.HandleFunc("/webhooks/{subscriptionId}", handler.UpdateWebhookSubscription).Methods("PUT")
+
+	// Delete a webhook subscription (operators only)
+	router.HandleFunc("/webhooks/{subscriptionId}", handler.DeleteWebhookSubscription).Methods("DELETE")
+
+	// Get the delivery log of a webhook subscription (operators only)
+	router.HandleFunc("/webhooks/{subscriptionId}/deliveries", handler.GetWebhookDeliveries).Methods("GET")
+
+	// Send deliveries of a webhook subscription again (operators only)
+	router.HandleFunc("/webhooks/{subscriptionId}/replay", handler.ReplayWebhookDeliveries).Methods("POST")
+
+	// Send a test event to a webhook subscription (operators only)
+	router.HandleFunc("/webhooks/{subscriptionId}/ping", handler.PingWebhookSubscription).Methods("POST")
+
+	// Read the domain events of the outbox (operators only)
+	router.HandleFunc("/events", handler.GetDomainEvents).Methods("GET")
+
+	// Query the audit log of the state-changing requests (admins only)
+	router.HandleFunc("/audit", handler.GetAuditEntries).Methods("GET")
+
+	// every state-changing request is recorded in the audit log
+	router.Use(handler.AuditMutations)
+
+	// a state-changing request with an Idempotency-Key is run once, its retries receive the first response
+	router.Use(handler.IdempotentMutations)
+
+	// the queries of a request are cancelled after the timeout of its route or when the client goes away
+	router.Use(handler.RouteTimeouts)
+
+	// unknown routes and methods are answered with problem details like every other error
+	router.NotFoundHandler = http.HandlerFunc(handler.RouteNotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(handler.MethodNotAllowed)
+
+	// ------------------------ BACKGROUND JOBS --------------------------------
+
+	// the database is closed after the background jobs stopped
+	defer func() {
+		if closeError := implementation.CloseDB(); closeError != nil {
+			fmt.Printf("WARNING! could not close the database. %v\n", closeError)
+		}
+	}()
+
+	// the domain events are published to the subscribers in the API, the webhooks and, if enabled, to the event log
+	implementation.SubscribeDomainEvents("low battery work orders", implementation.CreateLowBatteryWorkOrders, implementation.DOMAIN_EVENT_BIKE_STATUS_CHANGED)
+	implementation.RegisterDomainEventSink(implementation.NewWebhookEventSink())
+	if implementation.EventLogEnabled() {
+		implementation.RegisterDomainEventSink(implementation.NewLogEventSink(os.Stdout))
+	}
+
+	// publish the domain events of the outbox to the sinks
+	stopOutboxDispatcher := implementation.StartOutboxDispatcher(implementation.OutboxDispatchInterval())
+	defer stopOutboxDispatcher()
+
+	// create preventive work orders for bikes which are due
+	stopMaintenanceScheduler := implementation.StartMaintenanceScheduler(implementation.MaintenanceSchedulerInterval())
+	defer stopMaintenanceScheduler()
+
+	// raise theft alerts for parked bikes which went silent
+	stopTheftMonitor := implementation.StartTheftMonitor(implementation.TheftMonitorInterval())
+	defer stopTheftMonitor()
+
+	// send the queued webhook deliveries to the partners
+	stopWebhookDispatcher := implementation.StartWebhookDispatcher(implementation.WebhookDispatchInterval())
+	defer stopWebhookDispatcher()
+
+	// delete the expired idempotency keys with their responses
+	stopIdempotencyKeyPurge := implementation.StartIdempotencyKeyPurge(implementation.IDEMPOTENCY_PURGE_INTERVAL)
+	defer stopIdempotencyKeyPurge()
+
+	// ------------------------ SERVER -----------------------------------------
+
+	server := newServer(":"+SERVERPORT, router)
+	// the streams and the long polls of the devices end when the server shuts down, so they do not delay it
+	server.RegisterOnShutdown(implementation.CloseBikeStreams)
+	server.RegisterOnShutdown(implementation.EndBikeCommandPolls)
+
 	// serve the app
-	fmt.Println("Listening on Localhost at %v", SERVERPORT)
-	log.Fatal(http.ListenAndServe(":"+SERVERPORT, router))
+	serveErrors := make(chan error, 1)
+	go func() {
+		serveErrors <- serve(server)
+	}()
+	fmt.Printf("Listening on Localhost at %v\n", SERVERPORT)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case serveError := <-serveErrors:
+		fmt.Printf("ERROR! could not serve the API. %v\n", serveError)
+		exitCode = 1
+		return
+	case receivedSignal := <-signals:
+		fmt.Printf("received %v, shutting down\n", receivedSignal)
+	}
+
+	// new connections are refused, the running requests have until the shutdown timeout to finish
+	ctx, cancel := context.WithTimeout(context.Background(), implementation.ShutdownTimeout())
+	defer cancel()
+	if shutdownError := server.Shutdown(ctx); shutdownError != nil {
+		fmt.Printf("WARNING! the running requests did not finish in time, their connections are closed. %v\n", shutdownError)
+		server.Close()
+		exitCode = 1
+	}
+	if serveError := <-serveErrors; !errors.Is(serveError, http.ErrServerClosed) {
+		fmt.Printf("ERROR! could not serve the API. %v\n", serveError)
+		exitCode = 1
+	}
 }
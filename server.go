@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/tls"
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// time a client has to send the headers of a request
+	SERVER_READ_HEADER_TIMEOUT = 10 * time.Second
+	// maximal size of the headers of a request
+	SERVER_MAX_HEADER_BYTES = 64 << 10
+	// interval in which the files of the certificate are checked for a renewal
+	CERTIFICATE_CHECK_INTERVAL = time.Minute
+)
+
+/*
+returns the server of the API with the timeouts of the configuration.
+the streams of the bikes outlive the write timeout: the server-sent events end shortly before it and the browser resumes,
+a websocket sets the deadline of every write itself
+*/
+func newServer(address string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: SERVER_READ_HEADER_TIMEOUT,
+		ReadTimeout:       implementation.ServerReadTimeout(),
+		WriteTimeout:      implementation.ServerWriteTimeout(),
+		IdleTimeout:       implementation.ServerIdleTimeout(),
+		MaxHeaderBytes:    SERVER_MAX_HEADER_BYTES,
+	}
+}
+
+/*
+serves HTTPS if a certificate is configured, otherwise HTTP. It returns http.ErrServerClosed after the server was shut down
+*/
+func serve(server *http.Server) error {
+	certFile, keyFile := implementation.TLSFiles()
+	if certFile == "" && keyFile == "" {
+		return server.ListenAndServe()
+	}
+	if certFile == "" || keyFile == "" {
+		return fmt.Errorf("%v and %v must be set both to serve HTTPS", implementation.ENV_TLS_CERT_FILE, implementation.ENV_TLS_KEY_FILE)
+	}
+
+	reloader, loadError := newCertificateReloader(certFile, keyFile)
+	if loadError != nil {
+		return loadError
+	}
+	server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+	return server.ListenAndServeTLS("", "")
+}
+
+/*
+loads the certificate of the API again when its files changed, e.g. after a renewal, so the API does not need a restart.
+a renewed certificate which can not be loaded, e.g. because only one of the files was replaced yet, is reported
+and the previous certificate is kept until the next check
+*/
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time // the latest modification of the files of the certificate
+	checkedAt   time.Time
+}
+
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	reloader := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	modTime, statError := reloader.filesModTime()
+	if statError != nil {
+		return nil, statError
+	}
+	loadError := reloader.load(modTime)
+	if loadError != nil {
+		return nil, loadError
+	}
+	return reloader, nil
+}
+
+// returns the certificate for a TLS handshake, the files are checked at most once per CERTIFICATE_CHECK_INTERVAL
+func (reloader *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	if time.Since(reloader.checkedAt) >= CERTIFICATE_CHECK_INTERVAL {
+		reloader.checkedAt = time.Now()
+		modTime, statError := reloader.filesModTime()
+		if statError != nil {
+			fmt.Printf("WARNING! could not check the certificate for a renewal. %v\n", statError)
+		} else if !modTime.Equal(reloader.modTime) {
+			if loadError := reloader.load(modTime); loadError != nil {
+				fmt.Printf("WARNING! could not load the renewed certificate, the previous certificate is used. %v\n", loadError)
+			} else {
+				fmt.Printf("loaded the renewed certificate %v\n", reloader.certFile)
+			}
+		}
+	}
+	return reloader.certificate, nil
+}
+
+// loads the certificate from its files, the mutex must be locked unless the reloader is not used yet
+func (reloader *certificateReloader) load(modTime time.Time) error {
+	certificate, loadError := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if loadError != nil {
+		return fmt.Errorf("could not load certificate %v with key %v. %w", reloader.certFile, reloader.keyFile, loadError)
+	}
+	reloader.certificate = &certificate
+	reloader.modTime = modTime
+	reloader.checkedAt = time.Now()
+	return nil
+}
+
+// returns the latest modification of the certificate file and the key file
+func (reloader *certificateReloader) filesModTime() (time.Time, error) {
+	latest := time.Time{}
+	for _, file := range []string{reloader.certFile, reloader.keyFile} {
+		info, statError := os.Stat(file)
+		if statError != nil {
+			return time.Time{}, fmt.Errorf("could not read %v. %w", file, statError)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
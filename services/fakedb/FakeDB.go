@@ -0,0 +1,168 @@
+/*
+Package fakedb is a database/sql driver for the tests of the API. Every statement is answered by a function of the test,
+so a test can return rows, fail a statement or block a query until its context is cancelled, without a PostgreSQL server
+*/
+package fakedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+const (
+	// the statements which end a transaction, they are passed to the function of the test like any other statement
+	COMMIT   = "COMMIT"
+	ROLLBACK = "ROLLBACK"
+)
+
+// a statement which the API sends to the database
+type Statement struct {
+	Query         string
+	Args          []driver.Value
+	InTransaction bool
+}
+
+// the result of a statement: the rows of a query or the number of rows which an exec changed
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+}
+
+// answers a statement, the context is the context of the query
+type Handler func(ctx context.Context, statement Statement) (*Result, error)
+
+// returns a database whose statements are answered by the handler
+func Open(handler Handler) *sql.DB {
+	return sql.OpenDB(connector{handler: handler})
+}
+
+/*
+a handler which blocks every statement until its context is done and returns the error of the context,
+like a database which hangs, e.g. because a row is locked. The end of a transaction is not blocked,
+so the transaction of a cancelled statement can be rolled back
+*/
+func Block(ctx context.Context, statement Statement) (*Result, error) {
+	if statement.Query == COMMIT || statement.Query == ROLLBACK {
+		return &Result{}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type connector struct {
+	handler Handler
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{handler: c.handler}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakedb: use fakedb.Open")
+}
+
+type conn struct {
+	handler       Handler
+	inTransaction bool
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	c.inTransaction = true
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, statementError := c.run(ctx, query, args)
+	if statementError != nil {
+		return nil, statementError
+	}
+	return &rows{result: result}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, statementError := c.run(ctx, query, args)
+	if statementError != nil {
+		return nil, statementError
+	}
+	return driver.RowsAffected(result.RowsAffected), nil
+}
+
+func (c *conn) run(ctx context.Context, query string, args []driver.NamedValue) (*Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	result, statementError := c.handler(ctx, Statement{Query: query, Args: values, InTransaction: c.inTransaction})
+	if statementError != nil {
+		return nil, statementError
+	}
+	if result == nil {
+		result = &Result{}
+	}
+	return result, nil
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	return t.end(COMMIT)
+}
+
+func (t *tx) Rollback() error {
+	return t.end(ROLLBACK)
+}
+
+func (t *tx) end(query string) error {
+	_, endError := t.conn.handler(context.Background(), Statement{Query: query, InTransaction: true})
+	t.conn.inTransaction = false
+	return endError
+}
+
+type rows struct {
+	result *Result
+	next   int
+}
+
+func (r *rows) Columns() []string {
+	return r.result.Columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.Rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"eBikeApi/services/implementation"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// the response of a request is recorded in the audit log up to this size
+	AUDIT_MAX_RESPONSE_BYTES = 64 << 10
+	// a request id of the client which is longer is replaced by a generated id
+	MAX_REQUEST_ID_LENGTH = 128
+)
+
+/*
+routes which are not recorded in the audit log: the devices of the bikes call them every few seconds and their changes
+are recorded in the telemetry and the command log
+*/
+var unauditedRoutes = map[string]bool{
+	"/telemetry":                true,
+	"/commands/poll":            true,
+	"/commands/{commandId}/ack": true,
+}
+
+/*
+the targets of the routes by their path variables, the first variable of the route in this order is the target.
+a route without one of these variables is recorded with the first segment of its path as target type
+*/
+var auditTargetVariables = []struct {
+	variable   string
+	targetType string
+}{
+	{"organizationId", implementation.AUDIT_TARGET_ORGANIZATION},
+	{"rideId", implementation.AUDIT_TARGET_RIDE_PENALTIES},
+	{"bikeId", implementation.AUDIT_TARGET_BIKE},
+	{"alertId", implementation.AUDIT_TARGET_THEFT_ALERT},
+	{"orderId", implementation.AUDIT_TARGET_WORK_ORDER},
+	{"subscriptionId", implementation.AUDIT_TARGET_WEBHOOK},
+	{"zoneId", implementation.AUDIT_TARGET_ZONE},
+	{"reportId", implementation.AUDIT_TARGET_DAMAGE_REPORT},
+	{"username", implementation.AUDIT_TARGET_USER_SUBSCRIPTION},
+	{"ruleId", implementation.AUDIT_TARGET_PENALTY_RULE},
+	{"planId", implementation.AUDIT_TARGET_MAINTENANCE_PLAN},
+	{"reservationId", implementation.AUDIT_TARGET_RESERVATION},
+}
+
+/*
+middleware which gives every request a request id and records every state-changing request in the audit log:
+the caller, the route, the target with its state before and after the request and the status of the response.
+the state is recorded for the targets which have a snapshot, otherwise the response of a successful request is recorded as after
+*/
+func AuditMutations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		requestId := r.Header.Get(REQUEST_ID_HEADER)
+		if requestId == "" || len(requestId) > MAX_REQUEST_ID_LENGTH {
+			requestId = uuid.New().String()
+		}
+		w.Header().Set(REQUEST_ID_HEADER, requestId)
+
+		route := mux.CurrentRoute(r)
+		if !isMutation(r.Method) || route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		pathTemplate, templateError := route.GetPathTemplate()
+		if templateError != nil || unauditedRoutes[pathTemplate] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entry := implementation.AuditEntryImpl{
+			RequestId:    requestId,
+			Actor:        r.Header.Get(USERNAME_HEADER),
+			SourceIp:     sourceIp(r),
+			ForwardedFor: r.Header.Get(FORWARDED_FOR_HEADER),
+			Action:       r.Method + " " + pathTemplate,
+		}
+		entry.TargetType, entry.TargetId = auditTarget(pathTemplate, mux.Vars(r))
+		hasSnapshot := entry.TargetId != "" && implementation.AuditTargetHasSnapshot(entry.TargetType)
+		if hasSnapshot {
+			entry.Before = auditSnapshot(r.Context(), entry.TargetType, entry.TargetId)
+		}
+
+		recorder := newResponseRecorder(w, AUDIT_MAX_RESPONSE_BYTES)
+		next.ServeHTTP(recorder, r)
+
+		// the request is recorded even if the client went away, the change of the request may be committed
+		ctx, cancel := bookkeepingContext()
+		defer cancel()
+
+		entry.StatusCode = recorder.statusCode
+		if hasSnapshot {
+			entry.After = auditSnapshot(ctx, entry.TargetType, entry.TargetId)
+		} else if recorder.statusCode < 300 && !recorder.truncated && json.Valid(recorder.body.Bytes()) {
+			entry.After = recorder.body.Bytes()
+		}
+
+		// the response is already written, a failure can only be reported
+		_, recordError := implementation.RecordAuditEntry(ctx, entry)
+		if recordError != nil {
+			fmt.Printf("WARNING! could not record %v of request %v in the audit log. %v\n", entry.Action, requestId, recordError)
+		}
+	})
+}
+
+/*
+	 handler method to query the audit log, the latest entry first. Only allowed for admins
+		optional query parameters:
+		- actor, action (e.g. DELETE /reservation/bike/{bikeId}), targetType, targetId and requestId
+		- from and to: RFC 3339 times
+		- beforeId: only entries before this entry, to read the log page by page
+		- limit: maximal number of entries, default 100
+*/
+func GetAuditEntries(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting audit entries")
+
+	if _, isAdmin := requireRole(w, r, implementation.ROLE_ADMIN); !isAdmin {
+		return
+	}
+
+	query := readQueryParameters(r)
+	filter := implementation.AuditFilterImpl{
+		Actor:      query.text("actor"),
+		Action:     query.text("action"),
+		TargetType: query.text("targetType"),
+		TargetId:   query.text("targetId"),
+		RequestId:  query.text("requestId"),
+		From:       query.time("from", time.RFC3339, "a RFC 3339 time"),
+		To:         query.time("to", time.RFC3339, "a RFC 3339 time"),
+		BeforeId:   query.id("beforeId"),
+		Limit:      query.integer("limit", 0, 1, implementation.AUDIT_MAX_LIMIT),
+	}
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	entries, getEntriesError := implementation.GetAuditEntries(r.Context(), filter)
+	if getEntriesError != nil {
+		getEntriesErrMsg := fmt.Errorf("could not retrieve audit entries. %w", getEntriesError)
+		JSONError(w, r, getEntriesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, entries)
+}
+
+// returns true, if the method changes the state
+func isMutation(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete
+}
+
+// returns the type and the id of the target of a route
+func auditTarget(pathTemplate string, vars map[string]string) (string, string) {
+	for _, target := range auditTargetVariables {
+		if value, isSet := vars[target.variable]; isSet {
+			return target.targetType, value
+		}
+	}
+
+	// e.g. "stations" for POST /stations
+	for _, segment := range strings.Split(pathTemplate, "/") {
+		if segment != "" {
+			return segment, ""
+		}
+	}
+	return "", ""
+}
+
+// returns the state of the target, null if it can not be retrieved
+func auditSnapshot(ctx context.Context, targetType string, targetId string) json.RawMessage {
+	snapshot, snapshotError := implementation.GetAuditSnapshot(ctx, targetType, targetId)
+	if snapshotError != nil {
+		fmt.Printf("WARNING! could not retrieve the state of %v %v for the audit log. %v\n", targetType, targetId, snapshotError)
+		return nil
+	}
+	return snapshot
+}
+
+// returns the address of the client which connected to the API, without the port
+func sourceIp(r *http.Request) string {
+	host, _, splitError := net.SplitHostPort(r.RemoteAddr)
+	if splitError != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
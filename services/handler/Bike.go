@@ -11,15 +11,34 @@ import (
 	_ "github.com/lib/pq"
 )
 
-// returns all available bikes from the database
+/*
+	 returns all available bikes from the database
+		optional query parameters:
+		- minBattery: only bikes with at least this battery percentage (0 - 100)
+		- includeUnavailable: true also returns bikes which can not be rented, e.g. in maintenance. Only allowed for operators
+*/
 func GetAllBikes(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Println("Getting all eBikes from the database")
 
-	allBikes, getAllBikesError := implementation.GetAllBikes()
+	query := readQueryParameters(r)
+	minBattery := query.integer("minBattery", 0, 0, 100)
+	includeUnavailable := query.boolean("includeUnavailable")
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	if includeUnavailable {
+		if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+			return
+		}
+	}
+
+	allBikes, getAllBikesError := implementation.GetAllBikes(r.Context(), minBattery, includeUnavailable)
 	if getAllBikesError != nil {
-		getAllBikesErrMsg := fmt.Errorf("could not retrieve all bikes. %v", getAllBikesError)
-		JSONError(w, getAllBikesErrMsg, http.StatusInternalServerError)
+		getAllBikesErrMsg := fmt.Errorf("could not retrieve all bikes. %w", getAllBikesError)
+		JSONError(w, r, getAllBikesErrMsg, http.StatusInternalServerError)
 		return
 	}
 
@@ -42,23 +61,18 @@ func GetBikeReservation(w http.ResponseWriter, r *http.Request) {
 	// if username is not provided throw error
 	if username == "" {
 		bikeIdMissingMsg := fmt.Errorf("mandatory username not provided")
-		JSONError(w, bikeIdMissingMsg, http.StatusBadRequest)
+		JSONError(w, r, bikeIdMissingMsg, http.StatusBadRequest)
 		return
 	}
 
 	// call GetBikeReservation implementation
-	bikeReservations, getBikeReservationError := implementation.GetBikeReservation(username)
+	bikeReservations, getBikeReservationError := implementation.GetBikeReservation(r.Context(), username)
 	if getBikeReservationError != nil {
-		getBikeReservationErrMsg := fmt.Errorf("could not get bike reservation. %v", getBikeReservationError)
-		JSONError(w, getBikeReservationErrMsg, http.StatusInternalServerError)
+		getBikeReservationErrMsg := fmt.Errorf("could not get bike reservation. %w", getBikeReservationError)
+		JSONError(w, r, getBikeReservationErrMsg, http.StatusInternalServerError)
 		return
 	}
-	// todo: improve
-	tempArray := []implementation.BikeImpl{}
-
-	tempArray = append(tempArray, *bikeReservations)
-
-	bikeReservationResponse := transformBikeImplToGetBikeResponse(&tempArray)
+	bikeReservationResponse := transformBikeImplToGetBikeResponse(bikeReservations)
 
 	json.NewEncoder(w).Encode(bikeReservationResponse)
 }
@@ -79,18 +93,18 @@ func CreateBikeReservation(w http.ResponseWriter, r *http.Request) {
 	var bikeReservationRequest implementation.BikeReservationImpl
 
 	// read the request body and parse it into the struct
-	readRequestError := ReadRequestBody(r.Body, &bikeReservationRequest)
+	readRequestError := ReadRequestBody(w, r, &bikeReservationRequest)
 	if readRequestError != nil {
-		readRequestErrorMsg := fmt.Errorf("error while reading request. %v", readRequestError)
-		JSONError(w, readRequestErrorMsg, http.StatusInternalServerError)
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
 		return
 	}
 
 	// call the implementation to reserve a bike (create bike reservation)
-	reserveBikeResponse, reserveBikeError := implementation.ReserveBike(bikeReservationRequest)
+	reserveBikeResponse, reserveBikeError := implementation.ReserveBike(r.Context(), bikeReservationRequest)
 	if reserveBikeError != nil {
-		reserveBikeErrMsg := fmt.Errorf("could not create bike reservation. %v", reserveBikeError)
-		JSONError(w, reserveBikeErrMsg, http.StatusInternalServerError)
+		reserveBikeErrMsg := fmt.Errorf("could not create bike reservation. %w", reserveBikeError)
+		JSONError(w, r, reserveBikeErrMsg, http.StatusInternalServerError)
 		return
 	}
 
@@ -101,38 +115,167 @@ func CreateBikeReservation(w http.ResponseWriter, r *http.Request) {
 	 handler method to delete a bike reservation
 		parmameters required:
 		- bikeId
+		- X-Username header: the rider of the reservation or an operator
 */
 func DeleteBikeReservation(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Println("Deleting bike reservation")
 
 	vars := mux.Vars(r)
-	//username := vars["username"] // TODO: retrieve username through keycloak
 	bikeIdAsString := vars["bikeId"]
 
+	// the rider of the reservation or an operator returns the bike, the username is recorded in the audit log
+	username := r.Header.Get(USERNAME_HEADER) // TODO: retrieve username through keycloak
+	if username == "" {
+		JSONError(w, r, fmt.Errorf("mandatory header %v not provided", USERNAME_HEADER), http.StatusUnauthorized)
+		return
+	}
+
 	// if the bikeId is not provided, throw error
 	if bikeIdAsString == "" {
 		bikeIdMissingMsg := fmt.Errorf("mandatory bikeId not provided")
-		JSONError(w, bikeIdMissingMsg, http.StatusBadRequest)
+		JSONError(w, r, bikeIdMissingMsg, http.StatusBadRequest)
 		return
 	}
 
 	// parse the bikeId string to an Integer
 	bikeId, parseErr := strconv.Atoi(bikeIdAsString)
 	if parseErr != nil {
-		stringToIntParseErr := fmt.Errorf("error parsing string to int. %v", parseErr)
-		JSONError(w, stringToIntParseErr, http.StatusInternalServerError)
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
 		return
 	}
 
 	// call implementation method to delete a bike reservation
-	deleteBikeReservationError := implementation.DeleteBikeReservation(bikeId)
+	finishedRide, deleteBikeReservationError := implementation.DeleteBikeReservation(r.Context(), bikeId, username)
 	if deleteBikeReservationError != nil {
-		deleteBikeReservationErrMsg := fmt.Errorf("could not return bike. %v", deleteBikeReservationError)
-		JSONError(w, deleteBikeReservationErrMsg, http.StatusInternalServerError)
+		deleteBikeReservationErrMsg := fmt.Errorf("could not return bike. %w", deleteBikeReservationError)
+		JSONError(w, r, deleteBikeReservationErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// return message that the deletion was successful together with the fare of the ride
+	returnBikeResponse := ReturnBikeResponse{
+		Type:     SUCCESS,
+		Message:  "Successfully deleted bike reservation",
+		Ride:     transformRideImplToGetRideResponse(*finishedRide),
+		Warnings: finishedRide.Warnings,
+	}
+	JsonObjectResponse(w, http.StatusOK, returnBikeResponse)
+}
+
+/*
+	 handler method to store the position which a bike reports. Only allowed for operators, e.g. the gateway of the bikes
+		parmameters required:
+		- bikeId in the path
+		takes a http body with following values
+		"latitude" : 50.119504
+		"longitude" : 8.638137
+*/
+func ReportBikePosition(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Reporting bike position")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	// parse the bikeId string to an Integer
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	var positionRequest implementation.BikePositionImpl
+
+	readRequestError := ReadRequestBody(w, r, &positionRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	evaluation, reportPositionError := implementation.ReportBikePosition(r.Context(), bikeId, positionRequest)
+	if reportPositionError != nil {
+		reportPositionErrMsg := fmt.Errorf("could not store bike position. %w", reportPositionError)
+		JSONError(w, r, reportPositionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, transformPositionEvaluationImplToResponse(*evaluation))
+}
+
+/*
+	 handler method to change the operational status of a bike. Only allowed for operators
+		parameters required:
+		- bikeId in the path
+		takes a http body with following values
+		"status" : available, low_battery, maintenance, out_of_service, missing or retired
+		"reason" : why the status is changed, stored in the status history
+*/
+func ChangeBikeStatus(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Changing status of a bike")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	var statusRequest implementation.BikeStatusRequestImpl
+
+	readRequestError := ReadRequestBody(w, r, &statusRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	change, changeStatusError := implementation.ChangeBikeStatus(r.Context(), bikeId, statusRequest.Status, statusRequest.Reason, username)
+	if changeStatusError != nil {
+		changeStatusErrMsg := fmt.Errorf("could not change status of bike. %w", changeStatusError)
+		JSONError(w, r, changeStatusErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, change)
+}
+
+/*
+	 handler method to get the status history of a bike, the latest change first. Only allowed for operators
+		parameters required:
+		- bikeId in the path
+*/
+func GetBikeStatusHistory(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting status history of a bike")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	history, getHistoryError := implementation.GetBikeStatusHistory(r.Context(), bikeId)
+	if getHistoryError != nil {
+		getHistoryErrMsg := fmt.Errorf("could not retrieve status history. %w", getHistoryError)
+		JSONError(w, r, getHistoryErrMsg, http.StatusInternalServerError)
 		return
 	}
 
-	// return message that the deletion was successful
-	JsonSuccessResponse(w, "Successfully deleted bike reservation")
+	JsonObjectResponse(w, http.StatusOK, history)
 }
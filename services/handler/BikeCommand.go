@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// maximal size of a poll or an acknowledgement of a device
+const BIKE_COMMAND_MAX_BODY_BYTES = 4 << 10
+
+/*
+	 handler method for operators to send a command to the device of a bike.
+	 the command is queued and delivered with the next poll of the device
+		parameters required:
+		- bikeId in the path
+		takes a http body with following values
+		"command" : unlock, lock, ring or locate
+*/
+func SendBikeCommand(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Sending bike command")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	var commandRequest implementation.BikeCommandRequestImpl
+
+	readRequestError := ReadRequestBody(w, r, &commandRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	command, sendCommandError := implementation.SendBikeCommand(r.Context(), bikeId, commandRequest, username)
+	if sendCommandError != nil {
+		sendCommandErrMsg := fmt.Errorf("could not send command. %w", sendCommandError)
+		JSONError(w, r, sendCommandErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusAccepted, command)
+}
+
+// handler method to get the latest commands of a bike. Only allowed for operators
+func GetBikeCommands(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting bike commands")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	commands, getCommandsError := implementation.GetBikeCommands(r.Context(), bikeId)
+	if getCommandsError != nil {
+		getCommandsErrMsg := fmt.Errorf("could not retrieve commands. %w", getCommandsError)
+		JSONError(w, r, getCommandsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, commands)
+}
+
+/*
+	 handler method for riders to see whether the bike of their reservation was unlocked and locked.
+	 the caller is identified by the X-Username header, operators can see the commands of every reservation
+		parameters required:
+		- reservationId in the path
+*/
+func GetReservationCommands(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting reservation commands")
+
+	username := r.Header.Get(USERNAME_HEADER)
+	if username == "" {
+		JSONError(w, r, fmt.Errorf("mandatory header %v not provided", USERNAME_HEADER), http.StatusUnauthorized)
+		return
+	}
+
+	commands, getCommandsError := implementation.GetReservationCommands(r.Context(), mux.Vars(r)["reservationId"], username)
+	if getCommandsError != nil {
+		getCommandsErrMsg := fmt.Errorf("could not retrieve commands. %w", getCommandsError)
+		JSONError(w, r, getCommandsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, commands)
+}
+
+/*
+	 handler method for the long poll of a device for its commands.
+	 the device is authenticated with the headers X-Device-Id and X-Signature (hex encoded HMAC-SHA256 of the body).
+	 the response is sent as soon as a command is queued, or with an empty list when the wait time is over
+		takes a http body with following values
+		"sentAt" : time of the request (RFC 3339)
+		"waitSeconds" : optional time to wait for a command, at most 30 seconds
+*/
+func PollBikeCommands(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Polling bike commands")
+
+	device, body, authenticateOk := authenticateDeviceRequest(w, r)
+	if !authenticateOk {
+		return
+	}
+
+	var poll implementation.BikeCommandPollImpl
+
+	unmarshalError := unmarshalRequestBody(body, &poll)
+	if unmarshalError != nil {
+		unmarshalErrorMsg := fmt.Errorf("error while reading request. %w", unmarshalError)
+		JSONError(w, r, unmarshalErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	commands, pollError := implementation.PollBikeCommands(r.Context(), device, poll)
+	if pollError != nil {
+		pollErrMsg := fmt.Errorf("could not poll commands. %w", pollError)
+		JSONError(w, r, pollErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, commands)
+}
+
+/*
+	 handler method for a device to acknowledge a command, it is signed like the poll.
+		parameters required:
+		- commandId in the path
+		takes a http body with following values
+		"sentAt" : time of the request (RFC 3339)
+		"success" : whether the command was executed
+		"message" : optional, e.g. the reason why the command failed
+		"latitude", "longitude" : optional position of the bike, e.g. for locate
+*/
+func AcknowledgeBikeCommand(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Acknowledging bike command")
+
+	commandId, parseErr := strconv.ParseInt(mux.Vars(r)["commandId"], 10, 64)
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	device, body, authenticateOk := authenticateDeviceRequest(w, r)
+	if !authenticateOk {
+		return
+	}
+
+	var ack implementation.BikeCommandAckImpl
+
+	unmarshalError := unmarshalRequestBody(body, &ack)
+	if unmarshalError != nil {
+		unmarshalErrorMsg := fmt.Errorf("error while reading request. %w", unmarshalError)
+		JSONError(w, r, unmarshalErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	command, acknowledgeError := implementation.AcknowledgeBikeCommand(r.Context(), device, commandId, ack)
+	if acknowledgeError != nil {
+		acknowledgeErrMsg := fmt.Errorf("could not acknowledge command. %w", acknowledgeError)
+		JSONError(w, r, acknowledgeErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, command)
+}
+
+/*
+	 function which reads the body of a signed device request and authenticates the device.
+		returns the device and the body, or writes an error response and returns false
+*/
+func authenticateDeviceRequest(w http.ResponseWriter, r *http.Request) (*implementation.DeviceImpl, []byte, bool) {
+
+	body, readRequestError := readRequest(http.MaxBytesReader(w, r.Body, BIKE_COMMAND_MAX_BODY_BYTES))
+	if readRequestError != nil {
+		// a body which is too large keeps its error, so JSONError answers it with 413
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	device, authenticateError := implementation.AuthenticateDevice(r.Context(), r.Header.Get(DEVICE_ID_HEADER), body, r.Header.Get(SIGNATURE_HEADER))
+	if authenticateError != nil {
+		JSONError(w, r, authenticateError, http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return device, body, true
+}
@@ -12,6 +12,13 @@ type GetBikesResponse struct {
 	Latitude  string `json:"latitude"`
 	Longitude string `json:"longitude"`
 	Rented    bool   `json:"rented"`
+	Status    string `json:"status"`
+	StationId *int64 `json:"stationId,omitempty"`
+	// the battery is unknown until the bike reported telemetry
+	BatteryPercent   *int64   `json:"batteryPercent"`
+	EstimatedRangeKm *float64 `json:"estimatedRangeKm"`
+	// the bike is due for preventive maintenance soon
+	MaintenanceDueSoon bool `json:"maintenanceDueSoon"`
 }
 
 /*
@@ -21,7 +28,7 @@ This function transforms the struct from the implementation layer to the struct
 */
 func transformBikeImplToGetBikeResponse(bikeArray *[]implementation.BikeImpl) *[]GetBikesResponse {
 
-	var getBikeResponse []GetBikesResponse
+	getBikeResponse := []GetBikesResponse{}
 
 	for _, bike := range *bikeArray {
 
@@ -33,11 +40,22 @@ func transformBikeImplToGetBikeResponse(bikeArray *[]implementation.BikeImpl) *[
 		}
 
 		tempBike := GetBikesResponse{
-			BikeId:    bike.BikeId,
-			Name:      bike.Name,
-			Latitude:  bike.Latitude,
-			Longitude: bike.Longitude,
-			Rented:    rented,
+			BikeId:             bike.BikeId,
+			Name:               bike.Name,
+			Latitude:           bike.Latitude,
+			Longitude:          bike.Longitude,
+			Rented:             rented,
+			Status:             bike.Status,
+			MaintenanceDueSoon: bike.MaintenanceDueSoon,
+		}
+		if bike.StationId.Valid {
+			tempBike.StationId = &bike.StationId.Int64
+		}
+		if bike.BatteryPercent.Valid {
+			tempBike.BatteryPercent = &bike.BatteryPercent.Int64
+		}
+		if bike.EstimatedRangeKm.Valid {
+			tempBike.EstimatedRangeKm = &bike.EstimatedRangeKm.Float64
 		}
 
 		getBikeResponse = append(getBikeResponse, tempBike)
@@ -60,11 +78,22 @@ func transformBikeImplObjectToGetBikeResponse(bikeImplObject *implementation.Bik
 			}
 
 			tempBike := GetBikesResponse{
-				BikeId:    bikeImplObject.BikeId,
-				Name:      bikeImplObject.Name,
-				Latitude:  bikeImplObject.Latitude,
-				Longitude: bikeImplObject.Longitude,
-				Rented:    rented,
+				BikeId:             bikeImplObject.BikeId,
+				Name:               bikeImplObject.Name,
+				Latitude:           bikeImplObject.Latitude,
+				Longitude:          bikeImplObject.Longitude,
+				Rented:             rented,
+				Status:             bikeImplObject.Status,
+				MaintenanceDueSoon: bikeImplObject.MaintenanceDueSoon,
+			}
+			if bikeImplObject.StationId.Valid {
+				tempBike.StationId = &bikeImplObject.StationId.Int64
+			}
+			if bikeImplObject.BatteryPercent.Valid {
+				tempBike.BatteryPercent = &bikeImplObject.BatteryPercent.Int64
+			}
+			if bikeImplObject.EstimatedRangeKm.Valid {
+				tempBike.EstimatedRangeKm = &bikeImplObject.EstimatedRangeKm.Float64
 			}
 
 			getBikeResponse = tempBike
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// time a browser waits before it reconnects to the server-sent event stream
+	BIKE_STREAM_RETRY_MILLISECONDS = 3000
+	// interval of the keepalive comments and pings, so proxies do not close an idle stream
+	BIKE_STREAM_KEEPALIVE = 15 * time.Second
+	// time a websocket client has to answer a ping
+	BIKE_STREAM_PONG_WAIT = 60 * time.Second
+	// time to write a message to a websocket client
+	BIKE_STREAM_WRITE_WAIT = 10 * time.Second
+)
+
+var bikeStreamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// every origin is allowed, only for dev purposes like the Access-Control-Allow-Origin header
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+/*
+	 handler method to stream the changes of bikes as server-sent events.
+	 the client receives a snapshot of the bikes first and then an event for every change of a bike.
+	 the id of every event can be used to resume the stream, the browser does this automatically when it reconnects
+		optional query parameters:
+		- bbox: only bikes in the map section minLongitude,minLatitude,maxLongitude,maxLatitude
+		- lastEventId: id of the last received event, if the Last-Event-ID header can not be set
+*/
+func StreamBikes(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Streaming bikes as server-sent events")
+
+	flusher, canFlush := w.(http.Flusher)
+	if !canFlush {
+		JSONError(w, r, fmt.Errorf("streaming is not supported"), http.StatusInternalServerError)
+		return
+	}
+
+	subscription, snapshot, isSubscribed := subscribeBikeStream(w, r)
+	if !isSubscribed {
+		return
+	}
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")          // nginx must not buffer the stream
+	w.Header().Set("Access-Control-Allow-Origin", "*") // only for dev purposes
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", BIKE_STREAM_RETRY_MILLISECONDS)
+	if snapshot != nil {
+		if writeServerSentEvent(w, *snapshot) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(BIKE_STREAM_KEEPALIVE)
+	defer keepAlive.Stop()
+
+	// the write timeout of the server can not be extended for a stream, so the stream ends before it and the browser resumes
+	var end <-chan time.Time
+	if lifetime := serverSentEventLifetime(); lifetime > 0 {
+		endTimer := time.NewTimer(lifetime)
+		defer endTimer.Stop()
+		end = endTimer.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-end:
+			return
+		case event, isOpen := <-subscription.Events():
+			if !isOpen {
+				// the client was too slow or the API shuts down, the browser reconnects with the id of the last event it received
+				return
+			}
+			if writeServerSentEvent(w, event) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, writeError := fmt.Fprint(w, ": keepalive\n\n"); writeError != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+/*
+	 handler method to stream the changes of bikes over a websocket.
+	 every message is a JSON event, the first message is the snapshot of the bikes unless the stream was resumed.
+	 a client which is too slow is disconnected with close code 1013 and can resume with the id of the last event
+		optional query parameters:
+		- bbox: only bikes in the map section minLongitude,minLatitude,maxLongitude,maxLatitude
+		- lastEventId: id of the last received event, to resume the stream
+*/
+func StreamBikesWebSocket(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Streaming bikes over websocket")
+
+	subscription, snapshot, isSubscribed := subscribeBikeStream(w, r)
+	if !isSubscribed {
+		return
+	}
+	defer subscription.Close()
+
+	connection, upgradeError := bikeStreamUpgrader.Upgrade(w, r, nil)
+	if upgradeError != nil {
+		// the upgrader already responded with an error
+		return
+	}
+	defer connection.Close()
+
+	// the client does not send messages, but reading processes the pongs and notices when the client closes the connection
+	closed := make(chan struct{})
+	connection.SetReadLimit(512)
+	connection.SetReadDeadline(time.Now().Add(BIKE_STREAM_PONG_WAIT))
+	connection.SetPongHandler(func(string) error {
+		return connection.SetReadDeadline(time.Now().Add(BIKE_STREAM_PONG_WAIT))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, readError := connection.ReadMessage(); readError != nil {
+				return
+			}
+		}
+	}()
+
+	if snapshot != nil {
+		if writeWebSocketEvent(connection, *snapshot) != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(BIKE_STREAM_KEEPALIVE)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, isOpen := <-subscription.Events():
+			if !isOpen {
+				// the client was too slow or the API shuts down
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "resume with the id of the last event")
+				connection.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(BIKE_STREAM_WRITE_WAIT))
+				return
+			}
+			if writeWebSocketEvent(connection, event) != nil {
+				return
+			}
+		case <-ping.C:
+			if connection.WriteControl(websocket.PingMessage, nil, time.Now().Add(BIKE_STREAM_WRITE_WAIT)) != nil {
+				return
+			}
+		}
+	}
+}
+
+/*
+subscribes to the bike stream with the bounding box and the id of the last event of the request.
+writes the error response and returns false if the subscription fails
+*/
+func subscribeBikeStream(w http.ResponseWriter, r *http.Request) (*implementation.BikeStreamSubscriptionImpl, *implementation.BikeEventImpl, bool) {
+
+	query := readQueryParameters(r)
+	boundingBox := query.boundingBox("bbox")
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	// a browser sends the header when it reconnects, other clients can use the query parameter
+	lastEventId := r.Header.Get(LAST_EVENT_ID_HEADER)
+	if lastEventId == "" {
+		lastEventId = query.text("lastEventId")
+	}
+
+	subscription, snapshot, subscribeError := implementation.SubscribeBikeStream(r.Context(), boundingBox, lastEventId)
+	if subscribeError != nil {
+		subscribeErrMsg := fmt.Errorf("could not subscribe to the bike stream. %w", subscribeError)
+		JSONError(w, r, subscribeErrMsg, http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	return subscription, snapshot, true
+}
+
+/*
+returns the time after which a server-sent event stream ends, shortly before the write timeout of the server would
+cut it off in the middle of an event. 0 if the server has no write timeout.
+a websocket does not need this: it sets the deadline of every write itself after the connection is taken over
+*/
+func serverSentEventLifetime() time.Duration {
+	writeTimeout := implementation.ServerWriteTimeout()
+	if writeTimeout <= 0 {
+		return 0
+	}
+	if writeTimeout > 2*BIKE_STREAM_WRITE_WAIT {
+		return writeTimeout - BIKE_STREAM_WRITE_WAIT
+	}
+	return writeTimeout / 2
+}
+
+// writes an event in the format of server-sent events, the JSON of the event is on one line
+func writeServerSentEvent(w http.ResponseWriter, event implementation.BikeEventImpl) error {
+	eventJson, marshalError := json.Marshal(event)
+	if marshalError != nil {
+		return marshalError
+	}
+	_, writeError := fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", event.EventId, event.Type, eventJson)
+	return writeError
+}
+
+// writes an event as JSON message to a websocket client
+func writeWebSocketEvent(connection *websocket.Conn, event implementation.BikeEventImpl) error {
+	connection.SetWriteDeadline(time.Now().Add(BIKE_STREAM_WRITE_WAIT))
+	return connection.WriteJSON(event)
+}
@@ -1,6 +1,36 @@
 package handler
 
 const (
-	FAIL    = "FAIL"
 	SUCCESS = "SUCCESS"
+
+	// header which identifies the caller of operator endpoints
+	USERNAME_HEADER = "X-Username"
+
+	// headers which authenticate the device of a bike. The signature is the HMAC-SHA256 of the body with the secret of the device
+	DEVICE_ID_HEADER = "X-Device-Id"
+	SIGNATURE_HEADER = "X-Signature"
+
+	// maximal size of a JSON request body, larger bodies are rejected with 413
+	REQUEST_MAX_BODY_BYTES = 1 << 20
+
+	// content type of telemetry batches with one JSON record per line
+	CONTENT_TYPE_NDJSON = "application/x-ndjson"
+
+	// content type of the errors, they are problem details (RFC 7807)
+	CONTENT_TYPE_PROBLEM_JSON = "application/problem+json"
+	// prefix of the type of a problem, followed by its error code, e.g. /problems/bike_not_found
+	PROBLEM_TYPE_PREFIX = "/problems/"
+
+	// header which identifies a request, e.g. in the audit log. A request id of the client is kept, otherwise one is generated
+	REQUEST_ID_HEADER = "X-Request-Id"
+	// header in which a proxy sends the address of the client
+	FORWARDED_FOR_HEADER = "X-Forwarded-For"
+
+	// header in which a browser sends the id of the last event it received, when it reconnects to a server-sent event stream
+	LAST_EVENT_ID_HEADER = "Last-Event-ID"
+
+	// header with which a client retries a state-changing request safely, the first response is replayed for a retry with the same key
+	IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+	// header which marks a replayed response
+	IDEMPOTENT_REPLAYED_HEADER = "Idempotent-Replayed"
 )
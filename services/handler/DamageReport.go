@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maximal size of the form values of a damage report, the photos are added to it
+	DAMAGE_REPORT_MAX_FORM_BYTES = 1 << 20
+	// size of a damage report which is kept in memory, the rest is buffered in temporary files
+	DAMAGE_REPORT_MAX_MEMORY_BYTES = 8 << 20
+)
+
+/*
+	 handler method for a rider to report a damage of a bike.
+		parameters required:
+		- bikeId in the path
+		takes a multipart/form-data body with following values
+		"username" : the rider who reports the damage
+		"category" : brakes, tires, chain, lights, battery, lock, frame or other
+		"description" : free text
+		"photos" : optional photos (jpeg, png or webp), the field can be repeated
+*/
+func CreateDamageReport(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Creating damage report")
+
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	// larger requests are refused before they are read completely
+	maxPhotoBytes := implementation.DamagePhotoMaxBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, damageReportMaxBytes())
+
+	parseFormError := r.ParseMultipartForm(DAMAGE_REPORT_MAX_MEMORY_BYTES)
+	if parseFormError != nil {
+		parseFormErrorMsg := fmt.Errorf("error while reading request. %w", parseFormError)
+		JSONError(w, r, parseFormErrorMsg, http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	report := implementation.DamageReportImpl{
+		BikeId:      bikeId,
+		Username:    r.FormValue("username"),
+		Category:    r.FormValue("category"),
+		Description: r.FormValue("description"),
+	}
+
+	photos := []implementation.DamagePhotoUploadImpl{}
+	for _, fileHeader := range r.MultipartForm.File["photos"] {
+		file, openError := fileHeader.Open()
+		if openError != nil {
+			JSONError(w, r, fmt.Errorf("could not read photo %v. %w", fileHeader.Filename, openError), http.StatusBadRequest)
+			return
+		}
+		// one byte more than allowed is read, so that a too large photo is detected
+		data, readError := io.ReadAll(io.LimitReader(file, int64(maxPhotoBytes)+1))
+		file.Close()
+		if readError != nil {
+			JSONError(w, r, fmt.Errorf("could not read photo %v. %w", fileHeader.Filename, readError), http.StatusBadRequest)
+			return
+		}
+		photos = append(photos, implementation.DamagePhotoUploadImpl{FileName: fileHeader.Filename, Data: data})
+	}
+
+	createdReport, createReportError := implementation.CreateDamageReport(r.Context(), report, photos)
+	if createReportError != nil {
+		createReportErrMsg := fmt.Errorf("could not create damage report. %w", createReportError)
+		JSONError(w, r, createReportErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, createdReport)
+}
+
+/*
+	 handler method for operators to triage damage reports, the latest report first. Only allowed for operators
+		optional query parameters:
+		- status: open, confirmed, rejected or resolved
+		- bikeId: only the reports of this bike
+*/
+func GetDamageReports(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting damage reports")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	query := readQueryParameters(r)
+	bikeId := query.optionalInteger("bikeId", 0, math.MaxInt32)
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	reports, getReportsError := implementation.GetDamageReports(r.Context(), query.text("status"), bikeId)
+	if getReportsError != nil {
+		getReportsErrMsg := fmt.Errorf("could not retrieve damage reports. %w", getReportsError)
+		JSONError(w, r, getReportsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, reports)
+}
+
+// handler method to get a single damage report with its photos. Only allowed for operators
+func GetDamageReport(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting damage report")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	report, getReportError := implementation.GetDamageReport(r.Context(), mux.Vars(r)["reportId"])
+	if getReportError != nil {
+		getReportErrMsg := fmt.Errorf("could not retrieve damage report. %w", getReportError)
+		JSONError(w, r, getReportErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, report)
+}
+
+/*
+	 handler method to triage a damage report. Only allowed for operators
+		parameters required:
+		- reportId in the path
+		takes a http body with following values
+		"status" : confirmed, rejected or resolved
+		"note" : optional note of the operator
+		"moveToMaintenance" : true moves the bike of a confirmed report into maintenance
+*/
+func TriageDamageReport(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Triaging damage report")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	var triageRequest implementation.DamageReportTriageImpl
+
+	readRequestError := ReadRequestBody(w, r, &triageRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	report, triageError := implementation.TriageDamageReport(r.Context(), mux.Vars(r)["reportId"], triageRequest, username)
+	if triageError != nil {
+		triageErrMsg := fmt.Errorf("could not triage damage report. %w", triageError)
+		JSONError(w, r, triageErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, report)
+}
+
+// handler method to get a photo of a damage report. Only allowed for operators
+func GetDamagePhoto(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting photo of damage report")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	vars := mux.Vars(r)
+	photo, content, getPhotoError := implementation.GetDamagePhoto(r.Context(), vars["reportId"], vars["photoId"])
+	if getPhotoError != nil {
+		getPhotoErrMsg := fmt.Errorf("could not retrieve photo. %w", getPhotoError)
+		JSONError(w, r, getPhotoErrMsg, http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", photo.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(photo.SizeBytes, 10))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, content)
+}
+
+// returns the maximal size of a damage report, every photo can have the maximal size
+func damageReportMaxBytes() int64 {
+	return int64(implementation.DamageMaxPhotos())*int64(implementation.DamagePhotoMaxBytes()) + DAMAGE_REPORT_MAX_FORM_BYTES
+}
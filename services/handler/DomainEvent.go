@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+)
+
+/*
+	 handler method to read the domain events of the outbox, the oldest event first. Only allowed for operators
+		optional query parameters:
+		- type: e.g. reservation.created
+		- aggregateType and aggregateId: only the events of e.g. one bike
+		- after: only events after this sequence, to read the events page by page
+		- pending: true shows only the events which were not published yet
+		- limit: maximal number of events, default 100
+*/
+func GetDomainEvents(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting domain events")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	query := readQueryParameters(r)
+	filter := implementation.DomainEventFilterImpl{
+		Type:          query.text("type"),
+		AggregateType: query.text("aggregateType"),
+		AggregateId:   query.text("aggregateId"),
+		Pending:       query.boolean("pending"),
+		AfterSequence: query.id("after"),
+		Limit:         query.integer("limit", 0, 1, implementation.OUTBOX_EVENT_MAX_LIMIT),
+	}
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	events, getEventsError := implementation.GetDomainEvents(r.Context(), filter)
+	if getEventsError != nil {
+		getEventsErrMsg := fmt.Errorf("could not retrieve domain events. %w", getEventsError)
+		JSONError(w, r, getEventsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, events)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+an error of the API as problem details (RFC 7807). The type and the code identify the problem, the request id
+identifies the request in the log of the API
+*/
+type ProblemDetails struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance"`
+	Code      string `json:"code"` // stable code of the error, e.g. bike_not_found
+	RequestId string `json:"requestId"`
+	// the values of the request which are not allowed
+	Errors []implementation.FieldErrorImpl `json:"errors,omitempty"`
+}
+
+// the HTTP status codes of the kinds of domain errors
+var statusCodesOfErrorKinds = []struct {
+	kind       error
+	statusCode int
+}{
+	{implementation.ErrBadRequest, http.StatusBadRequest},
+	{implementation.ErrValidation, http.StatusUnprocessableEntity},
+	{implementation.ErrNotFound, http.StatusNotFound},
+	{implementation.ErrConflict, http.StatusConflict},
+	{implementation.ErrUnauthorized, http.StatusUnauthorized},
+	{implementation.ErrForbidden, http.StatusForbidden},
+}
+
+// the codes of the errors without domain error, by their HTTP status code
+var errorCodesOfStatusCodes = map[int]string{
+	http.StatusBadRequest:          implementation.ERROR_CODE_BAD_REQUEST,
+	http.StatusUnprocessableEntity: implementation.ERROR_CODE_VALIDATION,
+	http.StatusNotFound:            implementation.ERROR_CODE_NOT_FOUND,
+	http.StatusConflict:            implementation.ERROR_CODE_CONFLICT,
+	http.StatusUnauthorized:        implementation.ERROR_CODE_UNAUTHORIZED,
+	http.StatusForbidden:           implementation.ERROR_CODE_FORBIDDEN,
+	http.StatusInternalServerError: implementation.ERROR_CODE_INTERNAL,
+}
+
+/*
+maps an error to its HTTP status code and its error code. A domain error of the implementation decides by its kind,
+also when a handler wrapped it. Any other error keeps the status code of the handler, e.g. 500 for a failed database
+*/
+func errorStatusAndCode(err error, defaultStatusCode int) (int, string) {
+	// a body which is larger than the handler allows, e.g. REQUEST_MAX_BODY_BYTES
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		defaultStatusCode = http.StatusRequestEntityTooLarge
+	}
+
+	var domainError *implementation.DomainError
+	if errors.As(err, &domainError) {
+		for _, kind := range statusCodesOfErrorKinds {
+			if errors.Is(domainError, kind.kind) {
+				return kind.statusCode, domainError.Code
+			}
+		}
+	}
+
+	if code, isKnown := errorCodesOfStatusCodes[defaultStatusCode]; isKnown {
+		return defaultStatusCode, code
+	}
+	// e.g. request_entity_too_large
+	return defaultStatusCode, strings.ReplaceAll(strings.ToLower(http.StatusText(defaultStatusCode)), " ", "_")
+}
+
+// returns the error of a caller whose role can not be verified, a caller who is not a user is unauthorized
+func unverifiedUserError(username string, err error) error {
+	if errors.Is(err, implementation.ErrNotFound) {
+		return implementation.UnauthorizedError(implementation.ERROR_CODE_UNAUTHORIZED, "could not verify user %v. %v", username, err)
+	}
+	return fmt.Errorf("could not verify user %v. %w", username, err)
+}
+
+/*
+handler method for a route which does not exist, it returns the error as problem details like every other error
+*/
+func RouteNotFound(w http.ResponseWriter, r *http.Request) {
+	JSONError(w, r, fmt.Errorf("route %v does not exist", r.URL.Path), http.StatusNotFound)
+}
+
+/*
+handler method for a route which exists, but not with the method of the request
+*/
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	JSONError(w, r, fmt.Errorf("method %v is not allowed for route %v", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+}
@@ -1,10 +1,18 @@
 package handler
 
 import (
+	"bytes"
+	"context"
+	"eBikeApi/services/implementation"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
 )
 
 type JsonResponse struct {
@@ -12,20 +20,91 @@ type JsonResponse struct {
 	Message string `json:"message"`
 }
 
-/* helper function to read the body of a request and parse it into a given struct */
-func ReadRequestBody(req io.ReadCloser, dataInterface interface{}) error {
-	body, readErr := readRequest(req)
+// records the status and the beginning of the response of a request, e.g. for the audit log, while it is written
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode   int
+	body         bytes.Buffer
+	maxBodyBytes int
+	truncated    bool
+}
+
+/*
+	 helper function to read the body of a request and parse it into a given struct.
+		the body must not be larger than REQUEST_MAX_BODY_BYTES and must not contain fields which the struct does not have
+*/
+func ReadRequestBody(w http.ResponseWriter, r *http.Request, dataInterface interface{}) error {
+	body, readErr := readRequest(http.MaxBytesReader(w, r.Body, REQUEST_MAX_BODY_BYTES))
 	if readErr != nil {
-		readErrorMessage := fmt.Errorf("could not read request. %v", readErr)
+		// a body which is too large keeps its error, so it is answered with 413
+		var maxBytesError *http.MaxBytesError
+		if errors.As(readErr, &maxBytesError) {
+			return fmt.Errorf("request body must not be larger than %d bytes. %w", maxBytesError.Limit, readErr)
+		}
+		readErrorMessage := implementation.BadRequestError(implementation.ERROR_CODE_BAD_REQUEST, "could not read request. %v", readErr)
 		return readErrorMessage
 	}
 
-	unmarshalError := json.Unmarshal(body, dataInterface)
-	if unmarshalError != nil {
-		unmarshalErrorMessage := fmt.Errorf("could not unmarshal request body into given struct. %v", unmarshalError)
-		return unmarshalErrorMessage
+	return unmarshalRequestBody(body, dataInterface)
+}
+
+/*
+parses a JSON body into a given struct. A body which is no JSON is a bad request, an unknown field
+or a value of the wrong type is returned as error of the field, e.g. a text for a number
+*/
+func unmarshalRequestBody(body []byte, dataInterface interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	decodeError := decoder.Decode(dataInterface)
+	if decodeError == nil {
+		// the body must only contain one JSON value
+		if _, tokenError := decoder.Token(); tokenError != io.EOF {
+			return implementation.BadRequestError(implementation.ERROR_CODE_BAD_REQUEST, "request body must only contain one JSON value")
+		}
+		return nil
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(decodeError, &typeError) {
+		field := typeError.Field
+		if field == "" {
+			field = "body"
+		}
+		return implementation.FieldValidationError([]implementation.FieldErrorImpl{
+			{Field: field, Message: fmt.Sprintf("must be %v, not %v", jsonTypeName(typeError.Type), typeError.Value)},
+		})
+	}
+	// the json package has no type for an unknown field, its message is `json: unknown field "name"`
+	if unknownField := strings.TrimPrefix(decodeError.Error(), "json: unknown field "); unknownField != decodeError.Error() {
+		return implementation.FieldValidationError([]implementation.FieldErrorImpl{
+			{Field: strings.Trim(unknownField, `"`), Message: "is not a known field"},
+		})
+	}
+
+	unmarshalErrorMessage := implementation.BadRequestError(implementation.ERROR_CODE_BAD_REQUEST, "could not unmarshal request body into given struct. %v", decodeError)
+	return unmarshalErrorMessage
+}
+
+// returns the name of the JSON type of a value of the given type, e.g. number for an int
+func jsonTypeName(valueType reflect.Type) string {
+	switch valueType.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Pointer:
+		return jsonTypeName(valueType.Elem())
+	default:
+		return "an object"
 	}
-	return nil
 }
 
 // reads a request and returns the body
@@ -38,20 +117,55 @@ func readRequest(request io.ReadCloser) ([]byte, error) {
 }
 
 /*
-	 function to return an error in JSON format
+	 function to return an error as problem details (RFC 7807) in JSON format
 		1st param: the http Reponse writer
-		2nd param: the error we want to return
-		3rd param: the httpStatuscode we want to return
+		2nd param: the request of the error, its path is the instance of the problem
+		3rd param: the error we want to return
+		4th param: the httpStatuscode we want to return, if the error is not a domain error.
+		the kind of a domain error decides the httpStatuscode, e.g. 404 for implementation.ErrNotFound.
+		the message of an internal error is only logged with the request id, the client gets the request id to report it
 */
-func JSONError(w http.ResponseWriter, err error, httpStatusCode int) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+func JSONError(w http.ResponseWriter, r *http.Request, err error, httpStatusCode int) {
+	httpStatusCode, errorCode := errorStatusAndCode(err, httpStatusCode)
+
+	// a query which the timeout of the route cancelled fails with the error of the database, so the context tells the timeout
+	isTimeout := httpStatusCode >= http.StatusInternalServerError && r.Context().Err() == context.DeadlineExceeded
+	if isTimeout {
+		httpStatusCode, errorCode = http.StatusServiceUnavailable, implementation.ERROR_CODE_TIMEOUT
+	}
+
+	// the audit middleware already set the request id, but not e.g. for a route which does not exist
+	requestId := w.Header().Get(REQUEST_ID_HEADER)
+	if requestId == "" {
+		requestId = uuid.New().String()
+		w.Header().Set(REQUEST_ID_HEADER, requestId)
+	}
+
+	problem := ProblemDetails{
+		Type:      PROBLEM_TYPE_PREFIX + errorCode,
+		Title:     http.StatusText(httpStatusCode),
+		Status:    httpStatusCode,
+		Detail:    err.Error(),
+		Instance:  r.URL.RequestURI(),
+		Code:      errorCode,
+		RequestId: requestId,
+	}
+	var domainError *implementation.DomainError
+	if errors.As(err, &domainError) {
+		problem.Errors = domainError.Fields
+	}
+	if httpStatusCode >= http.StatusInternalServerError {
+		fmt.Printf("ERROR! request %v %v %v failed with %d. %v\n", requestId, r.Method, r.URL.RequestURI(), httpStatusCode, err)
+		problem.Detail = fmt.Sprintf("an internal error occurred, please report the request id %v", requestId)
+		if isTimeout {
+			problem.Detail = fmt.Sprintf("the request did not finish in time, please retry later and report the request id %v if it fails again", requestId)
+		}
+	}
+
+	w.Header().Set("Content-Type", CONTENT_TYPE_PROBLEM_JSON)
 	w.Header().Set("Access-Control-Allow-Origin", "*") // only for dev purposes
 	w.WriteHeader(httpStatusCode)
-	errorResponse := JsonResponse{
-		Type:    FAIL,
-		Message: err.Error(),
-	}
-	json.NewEncoder(w).Encode(errorResponse)
+	json.NewEncoder(w).Encode(problem)
 }
 
 /*
@@ -69,3 +183,75 @@ func JsonSuccessResponse(w http.ResponseWriter, msg string) {
 	}
 	json.NewEncoder(w).Encode(response)
 }
+
+/*
+	 function to return an object in JSON format
+		1st param: the http Reponse writer
+		2nd param: the httpStatuscode we want to return
+		3rd param: the object we want to return
+*/
+func JsonObjectResponse(w http.ResponseWriter, httpStatusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", "*") // only for dev purposes
+	w.WriteHeader(httpStatusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+/*
+	 function which verifies that the caller has one of the given roles.
+		since an id provider like keycloak does not exist, the caller is identified by the X-Username header.
+		returns the username of the caller, or writes an error response and returns false
+*/
+func requireRole(w http.ResponseWriter, r *http.Request, roles ...string) (string, bool) {
+	username := r.Header.Get(USERNAME_HEADER)
+	if username == "" {
+		JSONError(w, r, fmt.Errorf("mandatory header %v not provided", USERNAME_HEADER), http.StatusUnauthorized)
+		return "", false
+	}
+
+	role, getUserRoleError := implementation.GetUserRole(r.Context(), username)
+	if getUserRoleError != nil {
+		JSONError(w, r, unverifiedUserError(username, getUserRoleError), http.StatusInternalServerError)
+		return "", false
+	}
+
+	for _, allowedRole := range roles {
+		if role == allowedRole {
+			return username, true
+		}
+	}
+
+	JSONError(w, r, fmt.Errorf("user %v is not allowed to perform this operation", username), http.StatusForbidden)
+	return "", false
+}
+
+/*
+	 function which verifies that the caller is the given user or has one of the given roles.
+		returns the username of the caller, or writes an error response and returns false
+*/
+func requireUserOrRole(w http.ResponseWriter, r *http.Request, username string, roles ...string) (string, bool) {
+	if caller := r.Header.Get(USERNAME_HEADER); caller != "" && caller == username {
+		return caller, true
+	}
+	return requireRole(w, r, roles...)
+}
+
+// returns a recorder which keeps the first maxBodyBytes of the response
+func newResponseRecorder(w http.ResponseWriter, maxBodyBytes int) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK, maxBodyBytes: maxBodyBytes}
+}
+
+func (recorder *responseRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	if remaining := recorder.maxBodyBytes - recorder.body.Len(); len(data) > remaining {
+		recorder.body.Write(data[:remaining])
+		recorder.truncated = true
+	} else {
+		recorder.body.Write(data)
+	}
+	return recorder.ResponseWriter.Write(data)
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"eBikeApi/services/implementation"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maximal length of an Idempotency-Key, e.g. a uuid of the client
+	MAX_IDEMPOTENCY_KEY_LENGTH = 255
+	// a response which is longer is not stored, a retry runs the request again
+	IDEMPOTENCY_MAX_RESPONSE_BYTES = 1 << 20
+)
+
+// the headers of a response which are stored with it and replayed for the retries
+var idempotentReplayHeaders = []string{"Content-Type", "Content-Disposition", "Location", "Retry-After"}
+
+/*
+the maximal size of the body of the routes which do not read REQUEST_MAX_BODY_BYTES, by their path template.
+the body of a request with an Idempotency-Key is read before its handler, so it is limited like the handler limits it
+*/
+var routeBodyLimits = map[string]func() int64{
+	"/telemetry":                     func() int64 { return TELEMETRY_MAX_BODY_BYTES },
+	"/commands/poll":                 func() int64 { return BIKE_COMMAND_MAX_BODY_BYTES },
+	"/commands/{commandId}/ack":      func() int64 { return BIKE_COMMAND_MAX_BODY_BYTES },
+	"/bikes/{bikeId}/damage-reports": damageReportMaxBytes,
+}
+
+/*
+middleware which makes the state-changing requests with an Idempotency-Key header safe to retry, e.g. a reservation of a mobile
+client which did not receive the response. The first response of a key is stored for the user and replayed for every retry
+with the same method, path and body. A retry with another request is rejected with 422, a retry while the first request
+is still running with 409. A server error is not stored, so a retry runs the request again. The keys expire after EBIKE_IDEMPOTENCY_KEY_HOURS
+*/
+func IdempotentMutations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		idempotencyKey := r.Header.Get(IDEMPOTENCY_KEY_HEADER)
+		if idempotencyKey == "" || !isMutation(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(idempotencyKey) > MAX_IDEMPOTENCY_KEY_LENGTH {
+			JSONError(w, r, fmt.Errorf("%v must not be longer than %d characters", IDEMPOTENCY_KEY_HEADER, MAX_IDEMPOTENCY_KEY_LENGTH), http.StatusBadRequest)
+			return
+		}
+
+		// the body is read to compare it with the first request and given to the handler again
+		body, readError := io.ReadAll(http.MaxBytesReader(w, r.Body, routeBodyLimit(r)))
+		if readError != nil {
+			JSONError(w, r, fmt.Errorf("error while reading request. %w", readError), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		username := idempotencyUser(r, body)
+		fingerprint := idempotencyFingerprint(r, body)
+
+		storedRequest, isFirstRequest, beginError := implementation.BeginIdempotentRequest(r.Context(), username, idempotencyKey, fingerprint)
+		if beginError != nil {
+			JSONError(w, r, fmt.Errorf("could not check %v. %w", IDEMPOTENCY_KEY_HEADER, beginError), http.StatusInternalServerError)
+			return
+		}
+		if !isFirstRequest {
+			replayIdempotentResponse(w, r, idempotencyKey, fingerprint, storedRequest)
+			return
+		}
+
+		// the key is released if the handler does not finish, e.g. because it panics
+		isStored := false
+		defer func() {
+			if !isStored {
+				releaseIdempotencyKey(username, idempotencyKey)
+			}
+		}()
+
+		recorder := newResponseRecorder(w, IDEMPOTENCY_MAX_RESPONSE_BYTES)
+		next.ServeHTTP(recorder, r)
+
+		if recorder.statusCode >= http.StatusInternalServerError || recorder.truncated {
+			return
+		}
+		// the response is stored even if the client went away, so its retry receives it
+		ctx, cancel := bookkeepingContext()
+		defer cancel()
+		completeError := implementation.CompleteIdempotentRequest(ctx, username, idempotencyKey, recorder.statusCode,
+			replayHeaders(recorder.Header()), recorder.body.Bytes())
+		if completeError != nil {
+			fmt.Printf("WARNING! could not store the response of %v %v. %v\n", IDEMPOTENCY_KEY_HEADER, idempotencyKey, completeError)
+			return
+		}
+		isStored = true
+	})
+}
+
+// returns the maximal size of the body of the route of a request, REQUEST_MAX_BODY_BYTES if the route has no own limit
+func routeBodyLimit(r *http.Request) int64 {
+	if route := mux.CurrentRoute(r); route != nil {
+		if pathTemplate, templateError := route.GetPathTemplate(); templateError == nil {
+			if limit, hasLimit := routeBodyLimits[pathTemplate]; hasLimit {
+				return limit()
+			}
+		}
+	}
+	return REQUEST_MAX_BODY_BYTES
+}
+
+// writes the stored response of the first request with the key, or an error if the retry does not match it
+func replayIdempotentResponse(w http.ResponseWriter, r *http.Request, idempotencyKey string, fingerprint string, storedRequest *implementation.IdempotencyRecordImpl) {
+	if storedRequest.Fingerprint != fingerprint {
+		JSONError(w, r, implementation.ValidationError(implementation.ERROR_CODE_IDEMPOTENCY_KEY_REUSED, "%v %v was already used for another request",
+			IDEMPOTENCY_KEY_HEADER, idempotencyKey), http.StatusUnprocessableEntity)
+		return
+	}
+	if storedRequest.StatusCode == nil {
+		JSONError(w, r, implementation.ConflictError(implementation.ERROR_CODE_IDEMPOTENCY_KEY_IN_PROGRESS, "the request with %v %v is still in progress, please retry later",
+			IDEMPOTENCY_KEY_HEADER, idempotencyKey), http.StatusConflict)
+		return
+	}
+
+	for name, value := range storedRequest.Headers {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*") // only for dev purposes
+	w.Header().Set(IDEMPOTENT_REPLAYED_HEADER, "true")
+	w.WriteHeader(*storedRequest.StatusCode)
+	w.Write(storedRequest.ResponseBody)
+}
+
+// returns the headers of a response which are replayed, see idempotentReplayHeaders
+func replayHeaders(header http.Header) map[string]string {
+	headers := map[string]string{}
+	for _, name := range idempotentReplayHeaders {
+		if value := header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// releases the key of a request whose response is not stored, so a retry runs the request again
+func releaseIdempotencyKey(username string, idempotencyKey string) {
+	ctx, cancel := bookkeepingContext()
+	defer cancel()
+
+	releaseError := implementation.ReleaseIdempotencyKey(ctx, username, idempotencyKey)
+	if releaseError != nil {
+		fmt.Printf("WARNING! could not release %v %v, it is blocked until it expires. %v\n", IDEMPOTENCY_KEY_HEADER, idempotencyKey, releaseError)
+	}
+}
+
+/*
+returns the user of the key: the X-Username header, or the username in the body for the requests of riders
+which send it there, e.g. POST /reservation/
+*/
+func idempotencyUser(r *http.Request, body []byte) string {
+	if username := r.Header.Get(USERNAME_HEADER); username != "" {
+		return username
+	}
+
+	var bodyWithUsername struct {
+		Username string `json:"username"`
+	}
+	if json.Unmarshal(body, &bodyWithUsername) == nil && bodyWithUsername.Username != "" {
+		return bodyWithUsername.Username
+	}
+	return implementation.AUDIT_ACTOR_ANONYMOUS
+}
+
+// returns the hash of the method, the path with the query and the body of a request
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	digest := sha256.New()
+	fmt.Fprintf(digest, "%s %s\n", r.Method, r.URL.RequestURI())
+	digest.Write(body)
+	return hex.EncodeToString(digest.Sum(nil))
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"eBikeApi/services/implementation"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+returns a router with the routes of the body limits behind the idempotency middleware. The database fails every query,
+so a request whose body is accepted fails with 500 when its key is checked
+*/
+func idempotencyRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	db := fakedb.Open(func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		return nil, errors.New("database unavailable")
+	})
+	implementation.UseDB(db)
+	t.Cleanup(func() {
+		db.Close()
+		implementation.UseDB(nil)
+	})
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the handler of %v must not run", r.URL.Path)
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/users/", handler).Methods("POST")
+	router.HandleFunc("/commands/poll", handler).Methods("POST")
+	router.HandleFunc("/bikes/{bikeId}/damage-reports", handler).Methods("POST")
+	router.Use(IdempotentMutations)
+	return router
+}
+
+func postWithIdempotencyKey(router *mux.Router, path string, bodyBytes int64) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(make([]byte, bodyBytes)))
+	request.Header.Set(IDEMPOTENCY_KEY_HEADER, "key-1")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyKeyRejectsBodyLargerThanRouteLimit(t *testing.T) {
+	router := idempotencyRouter(t)
+
+	for path, limit := range map[string]int64{
+		"/users/":        REQUEST_MAX_BODY_BYTES,
+		"/commands/poll": BIKE_COMMAND_MAX_BODY_BYTES,
+	} {
+		recorder := postWithIdempotencyKey(router, path, limit+1)
+		if recorder.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected 413 for a body of %v bytes to %v, got %v", limit+1, path, recorder.Code)
+		}
+	}
+}
+
+func TestIdempotencyKeyAcceptsDamageReportLargerThanRequestLimit(t *testing.T) {
+	router := idempotencyRouter(t)
+
+	recorder := postWithIdempotencyKey(router, "/bikes/1/damage-reports", REQUEST_MAX_BODY_BYTES+1)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected the body to be read and the key to be checked, got %v", recorder.Code)
+	}
+
+	recorder = postWithIdempotencyKey(router, "/bikes/1/damage-reports", damageReportMaxBytes()+1)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a damage report larger than its limit, got %v", recorder.Code)
+	}
+}
+
+// the idempotencykey table in memory, by the username and the key
+type fakeIdempotencyTable struct {
+	mutex   sync.Mutex
+	records map[string][]driver.Value // the columns of idempotencyRecordColumns
+}
+
+func (table *fakeIdempotencyTable) handle(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	query := statement.Query
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO "+implementation.DB_TABLE_IDEMPOTENCYKEY):
+		key := statement.Args[0].(string) + "/" + statement.Args[1].(string)
+		if _, exists := table.records[key]; exists {
+			return &fakedb.Result{}, nil
+		}
+		table.records[key] = []driver.Value{statement.Args[0], statement.Args[1], statement.Args[2], nil, "{}", nil, statement.Args[3], statement.Args[4]}
+		return &fakedb.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "SELECT "):
+		record := table.records[statement.Args[0].(string)+"/"+statement.Args[1].(string)]
+		columns := []string{"username", "idempotencykey", "fingerprint", "statuscode", "headers", "responsebody", "createdat", "expiresat"}
+		return &fakedb.Result{Columns: columns, Rows: [][]driver.Value{record}}, nil
+	case strings.HasPrefix(query, "UPDATE "+implementation.DB_TABLE_IDEMPOTENCYKEY):
+		record := table.records[statement.Args[3].(string)+"/"+statement.Args[4].(string)]
+		record[3], record[4], record[5] = statement.Args[0], statement.Args[1], statement.Args[2]
+		return &fakedb.Result{RowsAffected: 1}, nil
+	case strings.HasSuffix(query, "statuscode IS NULL;"):
+		key := statement.Args[0].(string) + "/" + statement.Args[1].(string)
+		if record, exists := table.records[key]; exists && record[3] == nil {
+			delete(table.records, key)
+		}
+		return &fakedb.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "DELETE FROM "+implementation.DB_TABLE_IDEMPOTENCYKEY):
+		// the keys of the tests do not expire
+		return &fakedb.Result{}, nil
+	}
+	return nil, errors.New("unexpected statement: " + query)
+}
+
+// returns a router whose reservation route is behind the idempotency middleware with the keys in memory
+func idempotencyTableRouter(t *testing.T, reserve http.HandlerFunc) *mux.Router {
+	t.Helper()
+	table := &fakeIdempotencyTable{records: map[string][]driver.Value{}}
+	db := fakedb.Open(table.handle)
+	implementation.UseDB(db)
+	t.Cleanup(func() {
+		db.Close()
+		implementation.UseDB(nil)
+	})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/reservation/", reserve).Methods("POST")
+	router.Use(IdempotentMutations)
+	return router
+}
+
+func postReservation(router *mux.Router, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/reservation/", strings.NewReader(body))
+	request.Header.Set(IDEMPOTENCY_KEY_HEADER, "key-1")
+	request.Header.Set(USERNAME_HEADER, "userOne")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyKeyReplaysResponseWithHeaders(t *testing.T) {
+	runs := 0
+	router := idempotencyTableRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.Header().Set("Location", "/reservation/bike/7")
+		JsonObjectResponse(w, http.StatusCreated, map[string]int{"bikeId": 7})
+	})
+
+	first := postReservation(router, `{"bikeId": 7}`)
+	retry := postReservation(router, `{"bikeId": 7}`)
+	if runs != 1 {
+		t.Fatalf("expected the reservation to run once, it ran %v times", runs)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get(IDEMPOTENT_REPLAYED_HEADER) != "true" {
+		t.Fatalf("expected the replayed response %v %q, got %v %q", first.Code, first.Body.String(), retry.Code, retry.Body.String())
+	}
+	for _, name := range []string{"Location", "Content-Type"} {
+		if retry.Header().Get(name) != first.Header().Get(name) {
+			t.Errorf("expected the replayed header %v %q, got %q", name, first.Header().Get(name), retry.Header().Get(name))
+		}
+	}
+}
+
+func TestIdempotencyKeyRejectsAnotherBody(t *testing.T) {
+	router := idempotencyTableRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		JsonObjectResponse(w, http.StatusCreated, map[string]int{"bikeId": 7})
+	})
+
+	postReservation(router, `{"bikeId": 7}`)
+	recorder := postReservation(router, `{"bikeId": 8}`)
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for another body with the same key, got %v", recorder.Code)
+	}
+}
+
+func TestIdempotencyKeyRejectsRetryWhileFirstRequestRuns(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router := idempotencyTableRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		JsonObjectResponse(w, http.StatusCreated, map[string]int{"bikeId": 7})
+	})
+
+	first := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		first <- postReservation(router, `{"bikeId": 7}`)
+	}()
+	<-started
+	recorder := postReservation(router, `{"bikeId": 7}`)
+	close(release)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while the first request runs, got %v", recorder.Code)
+	}
+
+	select {
+	case response := <-first:
+		if response.Code != http.StatusCreated {
+			t.Fatalf("expected the first request to succeed, got %v", response.Code)
+		}
+	case <-time.After(testAbortWait):
+		t.Fatalf("the first request did not finish within %v", testAbortWait)
+	}
+}
+
+func TestIdempotencyKeyIsReleasedAfterServerErrorOrPanic(t *testing.T) {
+	for _, failure := range []string{"server error", "panic"} {
+		runs := 0
+		router := idempotencyTableRouter(t, func(w http.ResponseWriter, r *http.Request) {
+			runs++
+			if runs > 1 {
+				JsonObjectResponse(w, http.StatusCreated, map[string]int{"bikeId": 7})
+				return
+			}
+			if failure == "panic" {
+				panic("reservation failed")
+			}
+			JSONError(w, r, errors.New("reservation failed"), http.StatusInternalServerError)
+		})
+
+		func() {
+			defer func() { recover() }()
+			postReservation(router, `{"bikeId": 7}`)
+		}()
+		recorder := postReservation(router, `{"bikeId": 7}`)
+		if runs != 2 || recorder.Code != http.StatusCreated {
+			t.Errorf("expected the retry after a %v to run again, it ran %v times with %v", failure, runs, recorder.Code)
+		}
+	}
+}
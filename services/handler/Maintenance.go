@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// handler method to list the maintenance plans of all bike types. Only allowed for operators
+func GetMaintenancePlans(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting maintenance plans")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	plans, getPlansError := implementation.GetMaintenancePlans(r.Context())
+	if getPlansError != nil {
+		getPlansErrMsg := fmt.Errorf("could not retrieve maintenance plans. %w", getPlansError)
+		JSONError(w, r, getPlansErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, plans)
+}
+
+/*
+	 handler method to create or update a maintenance plan. Only allowed for operators
+		parameters required:
+		- planId in the path
+		takes a http body with the definition of the plan, e.g.
+		"bikeType" : "standard"
+		"name" : "Standard service"
+		"intervalKm" : 500
+		"intervalDays" : 90
+		"dueSoonKm" : 50
+		"dueSoonDays" : 7
+		"active" : true (default)
+*/
+func SaveMaintenancePlan(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Saving maintenance plan")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var planRequest implementation.MaintenancePlanImpl
+	// plans are active unless the request says otherwise
+	planRequest.Active = true
+
+	readRequestError := ReadRequestBody(w, r, &planRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+	planRequest.PlanId = mux.Vars(r)["planId"]
+
+	savedPlan, savePlanError := implementation.SaveMaintenancePlan(r.Context(), planRequest)
+	if savePlanError != nil {
+		savePlanErrMsg := fmt.Errorf("could not save maintenance plan. %w", savePlanError)
+		JSONError(w, r, savePlanErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, savedPlan)
+}
+
+// handler method to get the usage of a bike since its last service of each maintenance plan. Only allowed for operators
+func GetBikeMaintenance(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting maintenance of bike")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	usages, getUsagesError := implementation.GetBikeMaintenanceUsage(r.Context(), bikeId)
+	if getUsagesError != nil {
+		getUsagesErrMsg := fmt.Errorf("could not retrieve maintenance of bike. %w", getUsagesError)
+		JSONError(w, r, getUsagesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, usages)
+}
+
+/*
+	 handler method to get the weekly report of upcoming preventive maintenance. Only allowed for operators
+		optional query parameters:
+		- weeks: number of weeks of the report, 1 to 52, default 8
+*/
+func GetUpcomingMaintenance(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting upcoming maintenance")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	query := readQueryParameters(r)
+	weeks := query.integer("weeks", implementation.MAINTENANCE_REPORT_DEFAULT_WEEKS, 1, implementation.MAINTENANCE_REPORT_MAX_WEEKS)
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	report, getReportError := implementation.GetUpcomingMaintenance(r.Context(), weeks)
+	if getReportError != nil {
+		getReportErrMsg := fmt.Errorf("could not retrieve upcoming maintenance. %w", getReportError)
+		JSONError(w, r, getReportErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, report)
+}
+
+// handler method to run the maintenance scheduler immediately instead of waiting for its next run. Only allowed for operators
+func RunMaintenanceScheduler(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Running maintenance scheduler")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	run, runError := implementation.RunMaintenanceScheduler(r.Context())
+	if runError != nil {
+		runErrMsg := fmt.Errorf("could not run maintenance scheduler. %w", runError)
+		JSONError(w, r, runErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, run)
+}
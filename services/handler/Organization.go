@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// layout of the dates of the invoice period, e.g. 2023-04-30
+const INVOICE_DATE_LAYOUT = "2006-01-02"
+
+/*
+	 handler method to create an organization. Only allowed for operators
+		takes a http body with following values
+		"name" : name of the company
+		"billingEmail" : where the invoice is sent to
+		"billingReference" : optional reference, e.g. a purchase order number
+*/
+func CreateOrganization(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Creating organization")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var organizationRequest implementation.OrganizationImpl
+
+	readRequestError := ReadRequestBody(w, r, &organizationRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	createdOrganization, createOrganizationError := implementation.CreateOrganization(r.Context(), organizationRequest)
+	if createOrganizationError != nil {
+		createOrganizationErrMsg := fmt.Errorf("could not create organization. %w", createOrganizationError)
+		JSONError(w, r, createOrganizationErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, createdOrganization)
+}
+
+// handler method to get an organization. Only allowed for operators and managers of the organization
+func GetOrganization(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting organization")
+
+	organizationId := mux.Vars(r)["organizationId"]
+	if !requireOrganizationManager(w, r, organizationId) {
+		return
+	}
+
+	organization, getOrganizationError := implementation.GetOrganization(r.Context(), organizationId)
+	if getOrganizationError != nil {
+		getOrganizationErrMsg := fmt.Errorf("could not get organization. %w", getOrganizationError)
+		JSONError(w, r, getOrganizationErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, organization)
+}
+
+// handler method to get all members of an organization. Only allowed for operators and managers of the organization
+func GetOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting members of organization")
+
+	organizationId := mux.Vars(r)["organizationId"]
+	if !requireOrganizationManager(w, r, organizationId) {
+		return
+	}
+
+	members, getMembersError := implementation.GetOrganizationMembers(r.Context(), organizationId)
+	if getMembersError != nil {
+		getMembersErrMsg := fmt.Errorf("could not get members. %w", getMembersError)
+		JSONError(w, r, getMembersErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, members)
+}
+
+/*
+	 handler method to add a user to an organization or to update the membership.
+	 Only allowed for operators and managers of the organization
+		takes a http body with following values
+		"username" : the employee
+		"memberRole" : "member" (default) or "manager"
+		"monthlySpendingLimitCents" : optional limit per month, no limit if omitted
+*/
+func AddOrganizationMember(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Adding member to organization")
+
+	organizationId := mux.Vars(r)["organizationId"]
+	if !requireOrganizationManager(w, r, organizationId) {
+		return
+	}
+
+	var memberRequest implementation.OrganizationMemberImpl
+
+	readRequestError := ReadRequestBody(w, r, &memberRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+	memberRequest.OrganizationId = organizationId
+
+	member, addMemberError := implementation.AddOrganizationMember(r.Context(), memberRequest)
+	if addMemberError != nil {
+		addMemberErrMsg := fmt.Errorf("could not add member. %w", addMemberError)
+		JSONError(w, r, addMemberErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, member)
+}
+
+// handler method to remove a user from an organization. Only allowed for operators and managers of the organization
+func RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Removing member from organization")
+
+	vars := mux.Vars(r)
+	organizationId := vars["organizationId"]
+	if !requireOrganizationManager(w, r, organizationId) {
+		return
+	}
+
+	removeMemberError := implementation.RemoveOrganizationMember(r.Context(), organizationId, vars["username"])
+	if removeMemberError != nil {
+		removeMemberErrMsg := fmt.Errorf("could not remove member. %w", removeMemberError)
+		JSONError(w, r, removeMemberErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonSuccessResponse(w, "Successfully removed member from organization")
+}
+
+/*
+	 handler method to get the invoice report of an organization. Only allowed for operators and managers of the organization
+		optional query parameters:
+		- from: first day of the period (YYYY-MM-DD), default is the first day of the current month
+		- to: last day of the period (YYYY-MM-DD), default is the last day of the month of "from"
+*/
+func GetOrganizationInvoice(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting invoice report of organization")
+
+	organizationId := mux.Vars(r)["organizationId"]
+	if !requireOrganizationManager(w, r, organizationId) {
+		return
+	}
+
+	query := readQueryParameters(r)
+	from := query.time("from", INVOICE_DATE_LAYOUT, "a date in the format YYYY-MM-DD")
+	to := query.time("to", INVOICE_DATE_LAYOUT, "a date in the format YYYY-MM-DD")
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	periodStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	if from != nil {
+		periodStart = *from
+	}
+
+	// the period ends at the end of the last day
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	if to != nil {
+		periodEnd = to.AddDate(0, 0, 1)
+	}
+
+	invoiceReport, getInvoiceReportError := implementation.GetInvoiceReport(r.Context(), organizationId, periodStart, periodEnd)
+	if getInvoiceReportError != nil {
+		getInvoiceReportErrMsg := fmt.Errorf("could not get invoice report. %w", getInvoiceReportError)
+		JSONError(w, r, getInvoiceReportErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, invoiceReport)
+}
+
+/*
+function which verifies that the caller is an operator or a manager of the given organization.
+writes an error response and returns false otherwise
+*/
+func requireOrganizationManager(w http.ResponseWriter, r *http.Request, organizationId string) bool {
+	username := r.Header.Get(USERNAME_HEADER)
+	if username == "" {
+		JSONError(w, r, fmt.Errorf("mandatory header %v not provided", USERNAME_HEADER), http.StatusUnauthorized)
+		return false
+	}
+
+	role, getUserRoleError := implementation.GetUserRole(r.Context(), username)
+	if getUserRoleError != nil {
+		JSONError(w, r, unverifiedUserError(username, getUserRoleError), http.StatusInternalServerError)
+		return false
+	}
+	if role == implementation.ROLE_OPERATOR || role == implementation.ROLE_ADMIN {
+		return true
+	}
+
+	isManager, isManagerError := implementation.IsOrganizationManager(r.Context(), organizationId, username)
+	if isManagerError != nil {
+		JSONError(w, r, fmt.Errorf("could not verify user %v. %w", username, isManagerError), http.StatusInternalServerError)
+		return false
+	}
+	if !isManager {
+		JSONError(w, r, fmt.Errorf("user %v is not allowed to manage organization %v", username, organizationId), http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
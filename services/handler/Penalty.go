@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handler method to get all penalty rules. Only allowed for operators
+func GetPenaltyRules(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting all penalty rules")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	penaltyRules, getPenaltyRulesError := implementation.GetPenaltyRules(r.Context())
+	if getPenaltyRulesError != nil {
+		getPenaltyRulesErrMsg := fmt.Errorf("could not retrieve penalty rules. %w", getPenaltyRulesError)
+		JSONError(w, r, getPenaltyRulesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, penaltyRules)
+}
+
+/*
+	 handler method to create or update a penalty rule. Only allowed for operators
+		parameters required:
+		- ruleId in the path
+		takes a http body with the definition of the rule, e.g.
+		"kind" : "over_duration", "outside_operating_zone", "no_parking_zone" or "reported_damage"
+		"description" : shown on the receipt
+		"feeCents" : 2000
+		"thresholdMinutes" : 1440 (only over_duration)
+		"additionalCentsPerHour" : 500 (only over_duration)
+*/
+func SavePenaltyRule(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Saving penalty rule")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var ruleRequest implementation.PenaltyRuleImpl
+	// rules are active unless the request says otherwise
+	ruleRequest.Active = true
+
+	readRequestError := ReadRequestBody(w, r, &ruleRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+	ruleRequest.RuleId = mux.Vars(r)["ruleId"]
+
+	savedRule, savePenaltyRuleError := implementation.SavePenaltyRule(r.Context(), ruleRequest)
+	if savePenaltyRuleError != nil {
+		savePenaltyRuleErrMsg := fmt.Errorf("could not save penalty rule. %w", savePenaltyRuleError)
+		JSONError(w, r, savePenaltyRuleErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, savedRule)
+}
+
+/*
+	 handler method to get the itemized penalties of a ride
+		parameters required:
+		- rideId in the path
+*/
+func GetRidePenalties(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting penalties of ride")
+
+	penalties, getRidePenaltiesError := implementation.GetRidePenalties(r.Context(), mux.Vars(r)["rideId"])
+	if getRidePenaltiesError != nil {
+		getRidePenaltiesErrMsg := fmt.Errorf("could not retrieve penalties. %w", getRidePenaltiesError)
+		JSONError(w, r, getRidePenaltiesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, penalties)
+}
+
+/*
+	 handler method to waive a penalty of a ride. Only allowed for operators.
+	 The waived amount is refunded with a credit note.
+		parameters required:
+		- rideId and penaltyId in the path
+		takes a http body with following values
+		"reason" : why the penalty is waived
+*/
+func WaivePenalty(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Waiving penalty")
+
+	operator, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	var waiveRequest implementation.WaivePenaltyImpl
+
+	readRequestError := ReadRequestBody(w, r, &waiveRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	waiveRequest.RideId = vars["rideId"]
+	waiveRequest.PenaltyId = vars["penaltyId"]
+	waiveRequest.Operator = operator
+
+	waivedPenalty, waivePenaltyError := implementation.WaivePenalty(r.Context(), waiveRequest)
+	if waivePenaltyError != nil {
+		waivePenaltyErrMsg := fmt.Errorf("could not waive penalty. %w", waivePenaltyError)
+		JSONError(w, r, waivePenaltyErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, waivedPenalty)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handler method to get all promotion codes. Only allowed for operators
+func GetAllPromotions(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting all promotions")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	allPromotions, getAllPromotionsError := implementation.GetAllPromotions(r.Context())
+	if getAllPromotionsError != nil {
+		getAllPromotionsErrMsg := fmt.Errorf("could not retrieve all promotions. %w", getAllPromotionsError)
+		JSONError(w, r, getAllPromotionsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, allPromotions)
+}
+
+/*
+	 handler method to create a promotion code. Only allowed for operators
+		takes a http body with the definition of the promotion, e.g.
+		"code" : "FIRSTRIDE"
+		"discountType" : "none", "percent" or "fixed"
+		"discountValue" : percent or cents depending on the discountType
+		"freeMinutes" : 10
+*/
+func CreatePromotion(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Creating promotion")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var promotionRequest implementation.PromotionImpl
+	// promotions are active unless the request says otherwise
+	promotionRequest.Active = true
+
+	readRequestError := ReadRequestBody(w, r, &promotionRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	createdPromotion, createPromotionError := implementation.CreatePromotion(r.Context(), promotionRequest)
+	if createPromotionError != nil {
+		createPromotionErrMsg := fmt.Errorf("could not create promotion. %w", createPromotionError)
+		JSONError(w, r, createPromotionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, createdPromotion)
+}
+
+/*
+	 handler method to attach a promotion code to a running reservation
+		parameters required:
+		- reservationId in the path
+		takes a http body with following values
+		"username" : username
+		"code" : promotion code
+*/
+func AttachPromotionToReservation(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Attaching promotion code to reservation")
+
+	vars := mux.Vars(r)
+
+	var attachPromotionRequest implementation.AttachPromotionImpl
+
+	readRequestError := ReadRequestBody(w, r, &attachPromotionRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+	attachPromotionRequest.ReservationId = vars["reservationId"]
+
+	attachPromotionError := implementation.AttachPromotionToReservation(r.Context(), attachPromotionRequest)
+	if attachPromotionError != nil {
+		attachPromotionErrMsg := fmt.Errorf("could not attach promotion code. %w", attachPromotionError)
+		JSONError(w, r, attachPromotionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonSuccessResponse(w, "Successfully attached promotion code to reservation")
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+/*
+reads the query parameters of a request. A parameter which is not allowed does not stop the reading,
+so the client receives all parameters which are not allowed at once with err
+*/
+type queryParameters struct {
+	values      url.Values
+	fieldErrors []implementation.FieldErrorImpl
+}
+
+func readQueryParameters(r *http.Request) *queryParameters {
+	return &queryParameters{values: r.URL.Query()}
+}
+
+// adds a parameter which is not allowed
+func (parameters *queryParameters) add(name string, format string, arguments ...interface{}) {
+	parameters.fieldErrors = append(parameters.fieldErrors, implementation.FieldErrorImpl{Field: name, Message: fmt.Sprintf(format, arguments...)})
+}
+
+// returns the value of a parameter, or an empty string if it is not provided
+func (parameters *queryParameters) text(name string) string {
+	return parameters.values.Get(name)
+}
+
+// returns an integer parameter between min and max, or the default value if it is not provided
+func (parameters *queryParameters) integer(name string, defaultValue int, min int, max int) int {
+	value := parameters.optionalInteger(name, min, max)
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
+
+// returns an integer parameter between min and max, or nil if it is not provided
+func (parameters *queryParameters) optionalInteger(name string, min int, max int) *int {
+	text := parameters.values.Get(name)
+	if text == "" {
+		return nil
+	}
+	value, parseError := strconv.Atoi(text)
+	if parseError != nil || value < min || value > max {
+		parameters.add(name, "%v must be an integer between %d and %d", text, min, max)
+		return nil
+	}
+	return &value
+}
+
+// returns an id parameter which is at least 0, e.g. the id after which a page starts, or 0 if it is not provided
+func (parameters *queryParameters) id(name string) int64 {
+	text := parameters.values.Get(name)
+	if text == "" {
+		return 0
+	}
+	value, parseError := strconv.ParseInt(text, 10, 64)
+	if parseError != nil || value < 0 {
+		parameters.add(name, "%v must be an integer which is not negative", text)
+		return 0
+	}
+	return value
+}
+
+// returns a mandatory number parameter between min and max
+func (parameters *queryParameters) requiredNumber(name string, min float64, max float64) float64 {
+	text := parameters.values.Get(name)
+	if text == "" {
+		parameters.add(name, "is required")
+		return 0
+	}
+	value, parseError := strconv.ParseFloat(text, 64)
+	if parseError != nil || value < min || value > max {
+		parameters.add(name, "%v must be a number between %v and %v", text, min, max)
+		return 0
+	}
+	return value
+}
+
+// returns a boolean parameter, false if it is not provided
+func (parameters *queryParameters) boolean(name string) bool {
+	text := parameters.values.Get(name)
+	if text == "" {
+		return false
+	}
+	value, parseError := strconv.ParseBool(text)
+	if parseError != nil {
+		parameters.add(name, "%v must be true or false", text)
+		return false
+	}
+	return value
+}
+
+/*
+returns a time parameter in the given layout, or nil if it is not provided.
+the description of the layout is part of the error, e.g. "a RFC 3339 time"
+*/
+func (parameters *queryParameters) time(name string, layout string, layoutDescription string) *time.Time {
+	text := parameters.values.Get(name)
+	if text == "" {
+		return nil
+	}
+	value, parseError := time.ParseInLocation(layout, text, time.Local)
+	if parseError != nil {
+		parameters.add(name, "%v must be %v", text, layoutDescription)
+		return nil
+	}
+	return &value
+}
+
+// returns a bounding box parameter, see parseBoundingBox, or nil if it is not provided
+func (parameters *queryParameters) boundingBox(name string) *implementation.BoundingBoxImpl {
+	text := parameters.values.Get(name)
+	if text == "" {
+		return nil
+	}
+	boundingBox, parseError := parseBoundingBox(text)
+	if parseError != nil {
+		parameters.add(name, "%v", parseError)
+		return nil
+	}
+	return boundingBox
+}
+
+// returns a validation error with all parameters which are not allowed, or nil if all parameters are allowed
+func (parameters *queryParameters) err() error {
+	return implementation.FieldValidationError(parameters.fieldErrors)
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// ---------- formats of receipts and statements ---------
+	FORMAT_JSON = "json"
+	FORMAT_HTML = "html"
+	FORMAT_CSV  = "csv"
+)
+
+// handler method to get a receipt as JSON or as printable HTML (?format=html)
+func GetReceipt(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting receipt")
+
+	receiptId := mux.Vars(r)["receiptId"]
+
+	receipt, getReceiptError := implementation.GetReceipt(r.Context(), receiptId)
+	if getReceiptError != nil {
+		getReceiptErrMsg := fmt.Errorf("could not get receipt. %w", getReceiptError)
+		JSONError(w, r, getReceiptErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	writeReceipt(w, r, receipt)
+}
+
+// handler method to get the receipt of a ride as JSON or as printable HTML (?format=html)
+func GetRideReceipt(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting receipt of a ride")
+
+	rideId := mux.Vars(r)["rideId"]
+
+	receipt, getReceiptError := implementation.GetReceiptForRide(r.Context(), rideId)
+	if getReceiptError != nil {
+		getReceiptErrMsg := fmt.Errorf("could not get receipt. %w", getReceiptError)
+		JSONError(w, r, getReceiptErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	writeReceipt(w, r, receipt)
+}
+
+/*
+	 handler method to get the monthly statement of a user as JSON or as CSV (?format=csv)
+		parameters required:
+		- username
+		- month in the format YYYY-MM
+*/
+func GetMonthlyStatement(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting monthly statement")
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+	month := vars["month"]
+
+	statement, getStatementError := implementation.GetMonthlyStatement(r.Context(), username, month)
+	if getStatementError != nil {
+		getStatementErrMsg := fmt.Errorf("could not get statement. %w", getStatementError)
+		JSONError(w, r, getStatementErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	statementResponse := transformStatementImplToGetStatementResponse(*statement)
+
+	if responseFormat(r, FORMAT_CSV) == FORMAT_CSV {
+		writeStatementCsv(w, statementResponse)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, statementResponse)
+}
+
+// writes a receipt in the format the client asked for
+func writeReceipt(w http.ResponseWriter, r *http.Request, receipt *implementation.ReceiptImpl) {
+
+	receiptResponse := transformReceiptImplToGetReceiptResponse(*receipt)
+
+	if responseFormat(r, FORMAT_HTML) == FORMAT_HTML {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		receiptTemplate.Execute(w, receiptResponse)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, receiptResponse)
+}
+
+// writes all receipts of a statement as CSV, followed by a line with the totals
+func writeStatementCsv(w http.ResponseWriter, statement GetStatementResponse) {
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%v-%v.csv"`, statement.Username, statement.Month))
+	w.Header().Set("Access-Control-Allow-Origin", "*") // only for dev purposes
+	w.WriteHeader(http.StatusOK)
+
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Write([]string{"receiptNumber", "kind", "receiptId", "rideId", "issuedAt", "bike", "startedAt", "endedAt", "durationMinutes",
+		"subtotal", "discount", "total", "vat", "currency"})
+
+	for _, receipt := range statement.Receipts {
+		csvWriter.Write([]string{
+			strconv.FormatInt(receipt.ReceiptNumber, 10),
+			receipt.Kind,
+			receipt.ReceiptId,
+			receipt.RideId,
+			receipt.IssuedAt.Format(time.RFC3339),
+			receipt.BikeName,
+			receipt.StartedAt.Format(time.RFC3339),
+			receipt.EndedAt.Format(time.RFC3339),
+			strconv.Itoa(receipt.DurationMinutes),
+			formatCentsAsDecimal(receipt.SubtotalCents),
+			formatCentsAsDecimal(receipt.DiscountCents),
+			formatCentsAsDecimal(receipt.TotalCents),
+			formatCentsAsDecimal(receipt.VatCents),
+			receipt.Currency,
+		})
+	}
+
+	csvWriter.Write([]string{"total", "", "", "", "", "", "", "", "",
+		formatCentsAsDecimal(statement.SubtotalCents),
+		formatCentsAsDecimal(statement.DiscountCents),
+		formatCentsAsDecimal(statement.TotalCents),
+		formatCentsAsDecimal(statement.VatCents),
+		statement.Currency,
+	})
+
+	csvWriter.Flush()
+}
+
+/*
+returns the format the client asked for.
+The format can be requested with the query parameter "format" or with the Accept header.
+alternativeFormat is the only format which is offered besides JSON
+*/
+func responseFormat(r *http.Request, alternativeFormat string) string {
+	requestedFormat := strings.ToLower(r.URL.Query().Get("format"))
+	if requestedFormat == alternativeFormat {
+		return alternativeFormat
+	}
+	if requestedFormat == "" && strings.Contains(r.Header.Get("Accept"), "text/"+alternativeFormat) {
+		return alternativeFormat
+	}
+	return FORMAT_JSON
+}
+
+// formats cents as decimal number without currency, e.g. 125 -> 1.25
+func formatCentsAsDecimal(amountCents int) string {
+	return strconv.FormatFloat(float64(amountCents)/100, 'f', 2, 64)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"time"
+)
+
+/* struct used to describe the start or end place of a ride in a JSON response
+ */
+type PlaceResponse struct {
+	Latitude  string `json:"latitude"`
+	Longitude string `json:"longitude"`
+}
+
+/* struct used to return a receipt as JSON response
+ */
+type GetReceiptResponse struct {
+	ReceiptId       string                        `json:"receiptId"`
+	ReceiptNumber   int64                         `json:"receiptNumber"`
+	Kind            string                        `json:"kind"`
+	RideId          string                        `json:"rideId"`
+	Username        string                        `json:"username"`
+	BikeId          int                           `json:"bikeId"`
+	BikeName        string                        `json:"bikeName"`
+	StartedAt       time.Time                     `json:"startedAt"`
+	EndedAt         time.Time                     `json:"endedAt"`
+	StartPlace      *PlaceResponse                `json:"startPlace"`
+	EndPlace        PlaceResponse                 `json:"endPlace"`
+	DurationMinutes int                           `json:"durationMinutes"`
+	Lines           []implementation.FareLineImpl `json:"lines"`
+	SubtotalCents   int                           `json:"subtotalCents"`
+	DiscountCents   int                           `json:"discountCents"`
+	TotalCents      int                           `json:"totalCents"`
+	VatRatePercent  int                           `json:"vatRatePercent"`
+	VatCents        int                           `json:"vatCents"`
+	Currency        string                        `json:"currency"`
+	IssuedAt        time.Time                     `json:"issuedAt"`
+	OrganizationId  string                        `json:"organizationId,omitempty"`
+}
+
+/* struct used to return a monthly statement as JSON response
+ */
+type GetStatementResponse struct {
+	Username      string               `json:"username"`
+	Month         string               `json:"month"`
+	PeriodStart   time.Time            `json:"periodStart"`
+	PeriodEnd     time.Time            `json:"periodEnd"`
+	RideCount     int                  `json:"rideCount"`
+	SubtotalCents int                  `json:"subtotalCents"`
+	DiscountCents int                  `json:"discountCents"`
+	TotalCents    int                  `json:"totalCents"`
+	VatCents      int                  `json:"vatCents"`
+	Currency      string               `json:"currency"`
+	Receipts      []GetReceiptResponse `json:"receipts"`
+}
+
+/*
+transforms a receipt from the implementation layer to the struct for the JSON Response
+*/
+func transformReceiptImplToGetReceiptResponse(receipt implementation.ReceiptImpl) GetReceiptResponse {
+
+	// the start place is unknown for reservations which were created before the start position was stored
+	var startPlace *PlaceResponse
+	if receipt.StartLatitude.Valid && receipt.StartLongitude.Valid {
+		startPlace = &PlaceResponse{Latitude: receipt.StartLatitude.String, Longitude: receipt.StartLongitude.String}
+	}
+
+	return GetReceiptResponse{
+		ReceiptId:       receipt.ReceiptId,
+		ReceiptNumber:   receipt.ReceiptNumber,
+		Kind:            receipt.Kind,
+		RideId:          receipt.RideId,
+		Username:        receipt.Username,
+		BikeId:          receipt.BikeId,
+		BikeName:        receipt.BikeName,
+		StartedAt:       receipt.StartedAt,
+		EndedAt:         receipt.EndedAt,
+		StartPlace:      startPlace,
+		EndPlace:        PlaceResponse{Latitude: receipt.EndLatitude, Longitude: receipt.EndLongitude},
+		DurationMinutes: receipt.DurationMinutes,
+		Lines:           receipt.Lines,
+		SubtotalCents:   receipt.SubtotalCents,
+		DiscountCents:   receipt.DiscountCents,
+		TotalCents:      receipt.TotalCents,
+		VatRatePercent:  receipt.VatRatePercent,
+		VatCents:        receipt.VatCents,
+		Currency:        receipt.Currency,
+		IssuedAt:        receipt.IssuedAt,
+		OrganizationId:  receipt.OrganizationId.String,
+	}
+}
+
+/*
+transforms a statement from the implementation layer to the struct for the JSON Response
+*/
+func transformStatementImplToGetStatementResponse(statement implementation.StatementImpl) GetStatementResponse {
+
+	receipts := []GetReceiptResponse{}
+	for _, receipt := range statement.Receipts {
+		receipts = append(receipts, transformReceiptImplToGetReceiptResponse(receipt))
+	}
+
+	return GetStatementResponse{
+		Username:      statement.Username,
+		Month:         statement.Month,
+		PeriodStart:   statement.PeriodStart,
+		PeriodEnd:     statement.PeriodEnd,
+		RideCount:     statement.RideCount,
+		SubtotalCents: statement.SubtotalCents,
+		DiscountCents: statement.DiscountCents,
+		TotalCents:    statement.TotalCents,
+		VatCents:      statement.VatCents,
+		Currency:      statement.Currency,
+		Receipts:      receipts,
+	}
+}
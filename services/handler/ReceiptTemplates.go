@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"html/template"
+	"time"
+)
+
+// functions which can be used in the receipt template
+var receiptTemplateFunctions = template.FuncMap{
+	"price": implementation.FormatCents,
+	"datetime": func(t time.Time) string {
+		return t.Local().Format("02.01.2006 15:04")
+	},
+}
+
+// printable HTML version of a receipt
+var receiptTemplate = template.Must(template.New("receipt").Funcs(receiptTemplateFunctions).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{if eq .Kind "credit"}}Credit note{{else}}Receipt{{end}} {{.ReceiptNumber}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; }
+table { width: 100%; border-collapse: collapse; }
+td { padding: 0.3em 0; }
+td.amount { text-align: right; }
+tr.total td { border-top: 1px solid #000; font-weight: bold; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>eBike {{if eq .Kind "credit"}}credit note{{else}}receipt{{end}}</h1>
+<p>
+{{if eq .Kind "credit"}}Credit note{{else}}Receipt{{end}} no. {{.ReceiptNumber}}<br>
+Issued {{datetime .IssuedAt}}<br>
+Ride {{.RideId}}<br>
+Rider {{.Username}}{{with .OrganizationId}}<br>
+Billed to organization {{.}}{{end}}
+</p>
+<h2>Ride</h2>
+<table>
+<tr><td>Bike</td><td class="amount">{{.BikeName}} ({{.BikeId}})</td></tr>
+<tr><td>Start</td><td class="amount">{{datetime .StartedAt}}{{with .StartPlace}} at {{.Latitude}}, {{.Longitude}}{{end}}</td></tr>
+<tr><td>End</td><td class="amount">{{datetime .EndedAt}} at {{.EndPlace.Latitude}}, {{.EndPlace.Longitude}}</td></tr>
+<tr><td>Duration</td><td class="amount">{{.DurationMinutes}} min</td></tr>
+</table>
+<h2>Charges</h2>
+<table>
+{{range .Lines}}<tr><td>{{.Description}}</td><td class="amount">{{price .AmountCents}}</td></tr>
+{{end}}<tr class="total"><td>Total</td><td class="amount">{{price .TotalCents}}</td></tr>
+<tr><td>included VAT {{.VatRatePercent}}%</td><td class="amount">{{price .VatCents}}</td></tr>
+</table>
+</body>
+</html>
+`))
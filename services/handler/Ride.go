@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handler method to get all finished rides of a specific user
+func GetRides(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting rides for a specific user")
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	// if username is not provided throw error
+	if username == "" {
+		usernameMissingMsg := fmt.Errorf("mandatory username not provided")
+		JSONError(w, r, usernameMissingMsg, http.StatusBadRequest)
+		return
+	}
+
+	rides, getRidesError := implementation.GetRidesForUser(r.Context(), username)
+	if getRidesError != nil {
+		getRidesErrMsg := fmt.Errorf("could not get rides. %w", getRidesError)
+		JSONError(w, r, getRidesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(transformRideImplsToGetRideResponses(rides))
+}
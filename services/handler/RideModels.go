@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"time"
+)
+
+/* struct used to return a finished ride with its fare as JSON response
+ */
+type GetRideResponse struct {
+	RideId          string                        `json:"rideId"`
+	BikeId          int                           `json:"bikeId"`
+	StartedAt       time.Time                     `json:"startedAt"`
+	EndedAt         time.Time                     `json:"endedAt"`
+	DurationMinutes int                           `json:"durationMinutes"`
+	FareLines       []implementation.FareLineImpl `json:"fareLines"`
+	SubtotalCents   int                           `json:"subtotalCents"`
+	DiscountCents   int                           `json:"discountCents"`
+	TotalCents      int                           `json:"totalCents"`
+	Currency        string                        `json:"currency"`
+	PromoCode       string                        `json:"promoCode,omitempty"`
+	ReceiptId       string                        `json:"receiptId,omitempty"`
+	EndStationId    *int64                        `json:"endStationId,omitempty"`
+}
+
+/* struct used as response when a bike is returned. It extends the JsonResponse with the finished ride
+ */
+type ReturnBikeResponse struct {
+	Type    string          `json:"type"`
+	Message string          `json:"message"`
+	Ride    GetRideResponse `json:"ride"`
+	// e.g. that the bike could not be docked because the station was full
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+/*
+transforms a ride from the implementation layer to the struct for the JSON Response
+*/
+func transformRideImplToGetRideResponse(ride implementation.RideImpl) GetRideResponse {
+
+	var endStationId *int64
+	if ride.EndStationId.Valid {
+		endStationId = &ride.EndStationId.Int64
+	}
+
+	return GetRideResponse{
+		RideId:          ride.RideId,
+		BikeId:          ride.BikeId,
+		StartedAt:       ride.StartedAt,
+		EndedAt:         ride.EndedAt,
+		DurationMinutes: ride.DurationMinutes,
+		FareLines:       ride.FareLines,
+		SubtotalCents:   ride.SubtotalCents,
+		DiscountCents:   ride.DiscountCents,
+		TotalCents:      ride.TotalCents,
+		Currency:        implementation.TARIFF_CURRENCY,
+		PromoCode:       ride.PromoCode.String,
+		ReceiptId:       ride.ReceiptId,
+		EndStationId:    endStationId,
+	}
+}
+
+/*
+transforms an array of rides from the implementation layer to the structs for the JSON Response
+*/
+func transformRideImplsToGetRideResponses(rideArray *[]implementation.RideImpl) *[]GetRideResponse {
+
+	getRideResponses := []GetRideResponse{}
+
+	for _, ride := range *rideArray {
+		getRideResponses = append(getRideResponses, transformRideImplToGetRideResponse(ride))
+	}
+	return &getRideResponses
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// handler method to get all active docking stations with available bikes and free docks
+func GetStations(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting all stations")
+
+	stations, getStationsError := implementation.GetStations(r.Context())
+	if getStationsError != nil {
+		getStationsErrMsg := fmt.Errorf("could not retrieve stations. %w", getStationsError)
+		JSONError(w, r, getStationsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, stations)
+}
+
+/*
+	 handler method to get a docking station with available bikes and free docks
+		parameters required:
+		- stationId in the path
+*/
+func GetStation(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting station")
+
+	stationId, parseErr := strconv.Atoi(mux.Vars(r)["stationId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	station, getStationError := implementation.GetStation(r.Context(), stationId)
+	if getStationError != nil {
+		getStationErrMsg := fmt.Errorf("could not retrieve station. %w", getStationError)
+		JSONError(w, r, getStationErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, station)
+}
+
+/*
+	 handler method to create a docking station. Only allowed for operators
+		takes a http body with following values
+		"name" : name of the station
+		"latitude", "longitude" : position of the station
+		"capacity" : number of docks
+		"returnRadiusMeters" : optional, bikes returned within this radius are docked at the station (default 30)
+		"fullPolicy" : optional, "refuse" (default) or "warn" when a bike is returned to a full station
+*/
+func CreateStation(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Creating station")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var stationRequest implementation.StationImpl
+	// stations are active unless the request says otherwise
+	stationRequest.Active = true
+
+	readRequestError := ReadRequestBody(w, r, &stationRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	createdStation, createStationError := implementation.CreateStation(r.Context(), stationRequest)
+	if createStationError != nil {
+		createStationErrMsg := fmt.Errorf("could not create station. %w", createStationError)
+		JSONError(w, r, createStationErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, createdStation)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// returns all subscription plans
+func GetAllPlans(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting all subscription plans")
+
+	allPlans, getAllPlansError := implementation.GetAllPlans(r.Context())
+	if getAllPlansError != nil {
+		getAllPlansErrMsg := fmt.Errorf("could not retrieve all plans. %w", getAllPlansError)
+		JSONError(w, r, getAllPlansErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, allPlans)
+}
+
+// handler method to create a subscription plan. Only allowed for operators
+func CreatePlan(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Creating subscription plan")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var planRequest implementation.PlanImpl
+	// plans are active unless the request says otherwise
+	planRequest.Active = true
+
+	readRequestError := ReadRequestBody(w, r, &planRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	createdPlan, createPlanError := implementation.CreatePlan(r.Context(), planRequest)
+	if createPlanError != nil {
+		createPlanErrMsg := fmt.Errorf("could not create plan. %w", createPlanError)
+		JSONError(w, r, createPlanErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, createdPlan)
+}
+
+// handler method to get the active subscription of a user with the usage of the included minutes
+func GetSubscription(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting subscription of a specific user")
+
+	username := mux.Vars(r)["username"]
+
+	subscriptionStatus, getSubscriptionError := implementation.GetSubscriptionStatus(r.Context(), username)
+	if getSubscriptionError != nil {
+		getSubscriptionErrMsg := fmt.Errorf("could not get subscription. %w", getSubscriptionError)
+		JSONError(w, r, getSubscriptionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, subscriptionStatus)
+}
+
+/*
+	 handler method to subscribe a user to a plan
+		takes a http body with following values
+		"planId" : the plan to subscribe to
+*/
+func CreateSubscription(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Creating subscription")
+
+	// a user subscribes themselves, an operator can subscribe every user
+	if _, isAllowed := requireUserOrRole(w, r, mux.Vars(r)["username"], implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isAllowed {
+		return
+	}
+
+	var subscribeRequest implementation.SubscribeImpl
+
+	readRequestError := ReadRequestBody(w, r, &subscribeRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+	subscribeRequest.Username = mux.Vars(r)["username"]
+
+	subscriptionStatus, subscribeError := implementation.Subscribe(r.Context(), subscribeRequest)
+	if subscribeError != nil {
+		subscribeErrMsg := fmt.Errorf("could not create subscription. %w", subscribeError)
+		JSONError(w, r, subscribeErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, subscriptionStatus)
+}
+
+// handler method to cancel the active subscription of a user at the end of the current period
+func CancelSubscription(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Cancelling subscription")
+
+	username := mux.Vars(r)["username"]
+
+	// a user cancels their own subscription, an operator can cancel every subscription
+	if _, isAllowed := requireUserOrRole(w, r, username, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isAllowed {
+		return
+	}
+
+	subscriptionStatus, cancelSubscriptionError := implementation.CancelSubscription(r.Context(), username)
+	if cancelSubscriptionError != nil {
+		cancelSubscriptionErrMsg := fmt.Errorf("could not cancel subscription. %w", cancelSubscriptionError)
+		JSONError(w, r, cancelSubscriptionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, subscriptionStatus)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"eBikeApi/services/implementation"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// returns a router with the subscription routes. The database knows the roles of the users and fails every other query
+func subscriptionRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	roles := map[string]string{"userOne": implementation.ROLE_RIDER, "userTwo": implementation.ROLE_RIDER, "operatorOne": implementation.ROLE_OPERATOR}
+	db := fakedb.Open(func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		if strings.HasPrefix(statement.Query, "SELECT "+implementation.DB_TABLE_USER_COLUMN_ROLE+" ") {
+			return &fakedb.Result{Columns: []string{"role"}, Rows: [][]driver.Value{{roles[statement.Args[0].(string)]}}}, nil
+		}
+		return nil, errors.New("database unavailable")
+	})
+	implementation.UseDB(db)
+	t.Cleanup(func() {
+		db.Close()
+		implementation.UseDB(nil)
+	})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{username}/subscription", CreateSubscription).Methods("POST")
+	router.HandleFunc("/users/{username}/subscription", CancelSubscription).Methods("DELETE")
+	return router
+}
+
+func TestSubscriptionOfUserRequiresUserOrOperator(t *testing.T) {
+	router := subscriptionRouter(t)
+
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		for caller, expectedStatus := range map[string]int{
+			"":            http.StatusUnauthorized,
+			"userTwo":     http.StatusForbidden,
+			"userOne":     http.StatusInternalServerError,
+			"operatorOne": http.StatusInternalServerError,
+		} {
+			request := httptest.NewRequest(method, "/users/userOne/subscription", strings.NewReader(`{"planId": "daily30"}`))
+			if caller != "" {
+				request.Header.Set(USERNAME_HEADER, caller)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			// an allowed caller reaches the database, which fails
+			if recorder.Code != expectedStatus {
+				t.Errorf("expected %v for %v of the subscription of userOne by %q, got %v", expectedStatus, method, caller, recorder.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maximal size of a telemetry request
+const TELEMETRY_MAX_BODY_BYTES = 1 << 20
+
+/*
+	 handler method for the devices of the bikes to report telemetry.
+	 the device is authenticated with the headers X-Device-Id and X-Signature (hex encoded HMAC-SHA256 of the body).
+		takes a http body with a single record, a JSON array of records or, with the content type application/x-ndjson, one record per line
+		"recordedAt" : time of the measurement (RFC 3339)
+		"latitude", "longitude" : position of the bike
+		"batteryPercent" : 0 - 100
+		"locked" : lock state
+		"odometerMeters" : total distance of the bike
+*/
+func IngestTelemetry(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Ingesting telemetry")
+
+	body, readRequestError := readRequest(http.MaxBytesReader(w, r.Body, TELEMETRY_MAX_BODY_BYTES))
+	if readRequestError != nil {
+		// a body which is too large keeps its error, so JSONError answers it with 413
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	// the signature is checked before the body is parsed
+	device, authenticateError := implementation.AuthenticateDevice(r.Context(), r.Header.Get(DEVICE_ID_HEADER), body, r.Header.Get(SIGNATURE_HEADER))
+	if authenticateError != nil {
+		JSONError(w, r, authenticateError, http.StatusInternalServerError)
+		return
+	}
+
+	records, parseError := parseTelemetryRecords(body, r.Header.Get("Content-Type"))
+	if parseError != nil {
+		parseErrorMsg := fmt.Errorf("error while reading request. %w", parseError)
+		JSONError(w, r, parseErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	ingestion, ingestTelemetryError := implementation.IngestTelemetry(r.Context(), device, records)
+	if ingestTelemetryError != nil {
+		ingestTelemetryErrMsg := fmt.Errorf("could not ingest telemetry. %w", ingestTelemetryError)
+		JSONError(w, r, ingestTelemetryErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, ingestion)
+}
+
+/*
+	 handler method to register the device of a bike. Only allowed for operators.
+	 the response contains the secret of the device, it is not returned again
+		parameters required:
+		- bikeId in the path
+		takes a http body with following values
+		"deviceId" : the id of the device, e.g. its serial number
+*/
+func RegisterDevice(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Registering device")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	var deviceRequest implementation.DeviceImpl
+
+	readRequestError := ReadRequestBody(w, r, &deviceRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+	deviceRequest.BikeId = bikeId
+
+	device, registerDeviceError := implementation.RegisterDevice(r.Context(), deviceRequest)
+	if registerDeviceError != nil {
+		registerDeviceErrMsg := fmt.Errorf("could not register device. %w", registerDeviceError)
+		JSONError(w, r, registerDeviceErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, device)
+}
+
+/*
+	 handler method to get the telemetry history of a bike. Only allowed for operators
+		parameters required:
+		- bikeId in the path
+		optional query parameters:
+		- from, to: period (RFC 3339), default is the last 24 hours
+		- limit: maximal number of records, the latest first
+*/
+func GetTelemetryHistory(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting telemetry history")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	bikeId, parseErr := strconv.Atoi(mux.Vars(r)["bikeId"])
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	query := readQueryParameters(r)
+	fromParameter := query.time("from", time.RFC3339, "a RFC 3339 time")
+	toParameter := query.time("to", time.RFC3339, "a RFC 3339 time")
+	limit := query.integer("limit", 0, 1, implementation.TELEMETRY_HISTORY_MAX_LIMIT)
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now()
+	if toParameter != nil {
+		to = *toParameter
+	}
+	from := to.Add(-24 * time.Hour)
+	if fromParameter != nil {
+		from = *fromParameter
+	}
+
+	history, getHistoryError := implementation.GetTelemetryHistory(r.Context(), bikeId, from, to, limit)
+	if getHistoryError != nil {
+		getHistoryErrMsg := fmt.Errorf("could not retrieve telemetry. %w", getHistoryError)
+		JSONError(w, r, getHistoryErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, history)
+}
+
+/*
+parses the telemetry records of a request.
+NDJSON has one record per line, JSON is either a single record or an array of records
+*/
+func parseTelemetryRecords(body []byte, contentType string) ([]implementation.TelemetryRecordImpl, error) {
+
+	records := []implementation.TelemetryRecordImpl{}
+
+	if strings.HasPrefix(contentType, CONTENT_TYPE_NDJSON) {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 0, 64*1024), TELEMETRY_MAX_BODY_BYTES)
+		lineNumber := 0
+		for scanner.Scan() {
+			lineNumber++
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var record implementation.TelemetryRecordImpl
+			unmarshalError := unmarshalRequestBody(line, &record)
+			if unmarshalError != nil {
+				return nil, fmt.Errorf("line %d is not a valid record. %w", lineNumber, unmarshalError)
+			}
+			records = append(records, record)
+		}
+		return records, scanner.Err()
+	}
+
+	trimmedBody := bytes.TrimSpace(body)
+	if len(trimmedBody) > 0 && trimmedBody[0] == '[' {
+		unmarshalError := unmarshalRequestBody(trimmedBody, &records)
+		if unmarshalError != nil {
+			return nil, fmt.Errorf("could not unmarshal records. %w", unmarshalError)
+		}
+		return records, nil
+	}
+
+	var record implementation.TelemetryRecordImpl
+	unmarshalError := unmarshalRequestBody(trimmedBody, &record)
+	if unmarshalError != nil {
+		return nil, fmt.Errorf("could not unmarshal record. %w", unmarshalError)
+	}
+	return append(records, record), nil
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// a body whose connection breaks while it is read
+type brokenBody struct{}
+
+func (brokenBody) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestDeviceRequestIsOnlyTooLargeWhenBodyExceedsLimit(t *testing.T) {
+	for name, test := range map[string]struct {
+		handler http.HandlerFunc
+		limit   int64
+	}{
+		"telemetry":    {IngestTelemetry, TELEMETRY_MAX_BODY_BYTES},
+		"command poll": {PollBikeCommands, BIKE_COMMAND_MAX_BODY_BYTES},
+	} {
+		for body, expectedStatus := range map[io.Reader]int{
+			bytes.NewReader(make([]byte, test.limit+1)): http.StatusRequestEntityTooLarge,
+			brokenBody{}: http.StatusBadRequest,
+		} {
+			recorder := httptest.NewRecorder()
+			test.handler(recorder, httptest.NewRequest(http.MethodPost, "/", body))
+			if recorder.Code != expectedStatus {
+				t.Errorf("expected %v from the %v for a body which can not be read, got %v", expectedStatus, name, recorder.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+	 handler method to get the feed of theft alerts, the latest alert first. Only allowed for operators
+		optional query parameters:
+		- status: open, acknowledged or resolved
+		- kind: moved_while_parked, left_operating_area or silent
+		- bikeId: only the alerts of this bike
+		- afterId: only alerts which were raised after this alert, to poll for new alerts
+		- limit: maximal number of alerts, default 100
+*/
+func GetTheftAlerts(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting theft alerts")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	query := readQueryParameters(r)
+	filter := implementation.TheftAlertFilterImpl{
+		Status:  query.text("status"),
+		Kind:    query.text("kind"),
+		BikeId:  query.optionalInteger("bikeId", 0, math.MaxInt32),
+		AfterId: query.id("afterId"),
+		Limit:   query.integer("limit", 0, 1, implementation.THEFT_ALERT_MAX_LIMIT),
+	}
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	alerts, getAlertsError := implementation.GetTheftAlerts(r.Context(), filter)
+	if getAlertsError != nil {
+		getAlertsErrMsg := fmt.Errorf("could not retrieve theft alerts. %w", getAlertsError)
+		JSONError(w, r, getAlertsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, alerts)
+}
+
+/*
+	 handler method to acknowledge or resolve a theft alert. Only allowed for operators
+		parameters required:
+		- alertId in the path
+		takes a http body with following values
+		"status" : acknowledged or resolved
+		"note" : optional, e.g. where the bike was found
+*/
+func UpdateTheftAlert(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Updating theft alert")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	alertId, parseErr := strconv.ParseInt(mux.Vars(r)["alertId"], 10, 64)
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	var updateRequest implementation.TheftAlertUpdateImpl
+
+	readRequestError := ReadRequestBody(w, r, &updateRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	alert, updateAlertError := implementation.UpdateTheftAlert(r.Context(), alertId, updateRequest, username)
+	if updateAlertError != nil {
+		updateAlertErrMsg := fmt.Errorf("could not update theft alert. %w", updateAlertError)
+		JSONError(w, r, updateAlertErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, alert)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"eBikeApi/services/implementation"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+the timeouts of the routes which do not have the timeout of a request, by their path template.
+a timeout of 0 disables it, e.g. for the streams which stay open as long as the client is connected
+*/
+var routeTimeouts = map[string]func() time.Duration{
+	"/bikes/stream": noTimeout,
+	"/bikes/ws":     noTimeout,
+	// the long poll waits for a command before its queries run
+	"/commands/poll": func() time.Duration {
+		return addTimeout(implementation.BIKE_COMMAND_MAX_WAIT, implementation.RequestTimeout())
+	},
+	// reports and exports which read many rows
+	"/organizations/{organizationId}/invoice": implementation.ReportTimeout,
+	"/users/{username}/statements/{month}":    implementation.ReportTimeout,
+	"/maintenance/upcoming":                   implementation.ReportTimeout,
+	"/maintenance/run":                        implementation.ReportTimeout,
+	"/audit":                                  implementation.ReportTimeout,
+	"/events":                                 implementation.ReportTimeout,
+	"/zones/import":                           implementation.ReportTimeout,
+	"/telemetry":                              implementation.ReportTimeout,
+	"/webhooks/{subscriptionId}/replay":       implementation.ReportTimeout,
+}
+
+/*
+middleware which cancels the context of a request after the timeout of its route, so a query of the request
+which hangs, e.g. because the database is locked, is cancelled and the request fails with 503. The context is also
+cancelled when the client goes away, then the queries of the request are cancelled as well
+*/
+func RouteTimeouts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := routeTimeout(r)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// returns the timeout of the route of a request, the timeout of a request if the route has no own timeout
+func routeTimeout(r *http.Request) time.Duration {
+	if route := mux.CurrentRoute(r); route != nil {
+		if pathTemplate, templateError := route.GetPathTemplate(); templateError == nil {
+			if timeout, hasTimeout := routeTimeouts[pathTemplate]; hasTimeout {
+				return timeout()
+			}
+		}
+	}
+	return implementation.RequestTimeout()
+}
+
+func noTimeout() time.Duration {
+	return 0
+}
+
+// adds a timeout to a wait time, a disabled timeout stays disabled
+func addTimeout(wait time.Duration, timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return 0
+	}
+	return wait + timeout
+}
+
+/*
+returns the context of the bookkeeping of a request after its handler, e.g. its audit entry or its stored response.
+it is not cancelled when the client goes away, because the change of the request is already committed, but it has the timeout of a request
+*/
+func bookkeepingContext() (context.Context, context.CancelFunc) {
+	timeout := implementation.RequestTimeout()
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
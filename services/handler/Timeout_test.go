@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"eBikeApi/services/fakedb"
+	"eBikeApi/services/implementation"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// the time a test waits for a query which should abort, a query which ignores its context blocks forever
+const testAbortWait = 2 * time.Second
+
+/*
+replaces the database with a database which blocks every query until its context is done.
+the returned channel receives the error of every aborted query
+*/
+func useBlockingDB(t *testing.T) <-chan error {
+	t.Helper()
+	aborted := make(chan error, 10)
+	db := fakedb.Open(func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		result, blockError := fakedb.Block(ctx, statement)
+		if blockError != nil {
+			aborted <- blockError
+		}
+		return result, blockError
+	})
+	implementation.UseDB(db)
+	t.Cleanup(func() {
+		db.Close()
+		implementation.UseDB(nil)
+	})
+	return aborted
+}
+
+// returns a router with GET /bikes/ behind the route timeouts, the route times out after the timeout
+func timeoutRouter(t *testing.T, timeout time.Duration) *mux.Router {
+	t.Helper()
+	routeTimeouts["/bikes/"] = func() time.Duration { return timeout }
+	t.Cleanup(func() { delete(routeTimeouts, "/bikes/") })
+
+	router := mux.NewRouter()
+	router.HandleFunc("/bikes/", GetAllBikes).Methods("GET")
+	router.Use(RouteTimeouts)
+	return router
+}
+
+// returns the error of the next aborted query, or fails the test if no query aborts in time
+func abortedQuery(t *testing.T, aborted <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-aborted:
+		return err
+	case <-time.After(testAbortWait):
+		t.Fatalf("the query did not abort within %v", testAbortWait)
+		return nil
+	}
+}
+
+func TestRouteTimeoutAbortsQueryWith503(t *testing.T) {
+	aborted := useBlockingDB(t)
+	router := timeoutRouter(t, 50*time.Millisecond)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/bikes/", nil))
+
+	if err := abortedQuery(t, aborted); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the query to abort with context.DeadlineExceeded, got %v", err)
+	}
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d: %v", recorder.Code, recorder.Body.String())
+	}
+	var problem ProblemDetails
+	if decodeError := json.NewDecoder(recorder.Body).Decode(&problem); decodeError != nil {
+		t.Fatalf("could not decode the problem details. %v", decodeError)
+	}
+	if problem.Code != implementation.ERROR_CODE_TIMEOUT {
+		t.Fatalf("expected code %v, got %v", implementation.ERROR_CODE_TIMEOUT, problem.Code)
+	}
+}
+
+func TestQueryAbortsWhenClientGoesAway(t *testing.T) {
+	aborted := useBlockingDB(t)
+	router := timeoutRouter(t, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/bikes/", nil).WithContext(ctx))
+	}()
+
+	if err := abortedQuery(t, aborted); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the query to abort with context.Canceled, got %v", err)
+	}
+	select {
+	case <-served:
+	case <-time.After(testAbortWait):
+		t.Fatalf("the handler did not return within %v after the client went away", testAbortWait)
+	}
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+	 handler method to create a webhook subscription. Only allowed for operators.
+	 the response contains the secret to verify the signatures, it is not returned again
+		takes a http body with following values
+		"url" : http or https url which receives the events
+		"eventTypes" : optional, e.g. ["ride.started", "ride.finished"], all event types if empty
+		"description" : optional, e.g. the name of the partner
+		"active" : optional, default true
+*/
+func CreateWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Creating webhook subscription")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	var subscriptionRequest implementation.WebhookSubscriptionRequestImpl
+
+	readRequestError := ReadRequestBody(w, r, &subscriptionRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	createdSubscription, createSubscriptionError := implementation.CreateWebhookSubscription(r.Context(), subscriptionRequest, username)
+	if createSubscriptionError != nil {
+		createSubscriptionErrMsg := fmt.Errorf("could not create webhook subscription. %w", createSubscriptionError)
+		JSONError(w, r, createSubscriptionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, createdSubscription)
+}
+
+// handler method to get all webhook subscriptions. Only allowed for operators
+func GetWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting webhook subscriptions")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	subscriptions, getSubscriptionsError := implementation.GetWebhookSubscriptions(r.Context())
+	if getSubscriptionsError != nil {
+		getSubscriptionsErrMsg := fmt.Errorf("could not retrieve webhook subscriptions. %w", getSubscriptionsError)
+		JSONError(w, r, getSubscriptionsErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, subscriptions)
+}
+
+/*
+	 handler method to change a webhook subscription. Only allowed for operators
+		parameters required:
+		- subscriptionId in the path
+		takes a http body with the same values as the creation, an omitted "active" keeps the state
+*/
+func UpdateWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Updating webhook subscription")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var subscriptionRequest implementation.WebhookSubscriptionRequestImpl
+
+	readRequestError := ReadRequestBody(w, r, &subscriptionRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	subscription, updateSubscriptionError := implementation.UpdateWebhookSubscription(r.Context(), mux.Vars(r)["subscriptionId"], subscriptionRequest)
+	if updateSubscriptionError != nil {
+		updateSubscriptionErrMsg := fmt.Errorf("could not update webhook subscription. %w", updateSubscriptionError)
+		JSONError(w, r, updateSubscriptionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, subscription)
+}
+
+// handler method to delete a webhook subscription with its delivery log. Only allowed for operators
+func DeleteWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Deleting webhook subscription")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	deleteSubscriptionError := implementation.DeleteWebhookSubscription(r.Context(), mux.Vars(r)["subscriptionId"])
+	if deleteSubscriptionError != nil {
+		deleteSubscriptionErrMsg := fmt.Errorf("could not delete webhook subscription. %w", deleteSubscriptionError)
+		JSONError(w, r, deleteSubscriptionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonSuccessResponse(w, "Successfully deleted webhook subscription")
+}
+
+/*
+	 handler method to get the delivery log of a webhook subscription with the attempts of every delivery. Only allowed for operators
+		parameters required:
+		- subscriptionId in the path
+		optional query parameters:
+		- status: pending, delivered or dead
+		- limit: maximal number of deliveries, default 50
+*/
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting webhook deliveries")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	query := readQueryParameters(r)
+	limit := query.integer("limit", 0, 1, implementation.WEBHOOK_DELIVERY_MAX_LIMIT)
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	deliveries, getDeliveriesError := implementation.GetWebhookDeliveries(r.Context(), mux.Vars(r)["subscriptionId"], query.text("status"), limit)
+	if getDeliveriesError != nil {
+		getDeliveriesErrMsg := fmt.Errorf("could not retrieve webhook deliveries. %w", getDeliveriesError)
+		JSONError(w, r, getDeliveriesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, deliveries)
+}
+
+/*
+	 handler method to send deliveries of a webhook subscription again. Only allowed for operators
+		parameters required:
+		- subscriptionId in the path
+		takes a http body with following values
+		"deliveryIds" : the deliveries to replay, or
+		"status" : dead or delivered, replays all deliveries with this status
+		"since" : optional, only deliveries which were created since this time
+*/
+func ReplayWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Replaying webhook deliveries")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var replayRequest implementation.WebhookReplayRequestImpl
+
+	readRequestError := ReadRequestBody(w, r, &replayRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	replayed, replayError := implementation.ReplayWebhookDeliveries(r.Context(), mux.Vars(r)["subscriptionId"], replayRequest)
+	if replayError != nil {
+		replayErrMsg := fmt.Errorf("could not replay webhook deliveries. %w", replayError)
+		JSONError(w, r, replayErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, map[string]int64{"replayed": replayed})
+}
+
+/*
+	 handler method to send a webhook.ping event to a subscription, to test the receiver. Only allowed for operators
+		parameters required:
+		- subscriptionId in the path
+*/
+func PingWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Pinging webhook subscription")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	delivery, pingError := implementation.PingWebhookSubscription(r.Context(), mux.Vars(r)["subscriptionId"], username)
+	if pingError != nil {
+		pingErrMsg := fmt.Errorf("could not ping webhook subscription. %w", pingError)
+		JSONError(w, r, pingErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusAccepted, delivery)
+}
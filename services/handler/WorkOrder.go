@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+	 handler method to create a work order manually or from a damage report. Only allowed for operators
+		takes a http body with following values
+		"bikeId" : the bike to repair, optional for orders from a damage report
+		"damageReportId" : optional damage report which the order repairs
+		"title" : short description, optional for orders from a damage report
+		"description" : optional details
+		"assignedTo" : optional operator who does the work
+*/
+func CreateWorkOrder(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Creating work order")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	var workOrderRequest implementation.WorkOrderRequestImpl
+
+	readRequestError := ReadRequestBody(w, r, &workOrderRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	order, createOrderError := implementation.CreateWorkOrder(r.Context(), workOrderRequest, username)
+	if createOrderError != nil {
+		createOrderErrMsg := fmt.Errorf("could not create work order. %w", createOrderError)
+		JSONError(w, r, createOrderErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, order)
+}
+
+/*
+	 handler method to list work orders, the latest order first. Only allowed for operators
+		optional query parameters:
+		- bikeId: only the orders of this bike
+		- assignee: only the orders of this operator
+		- status: open, in_progress, blocked or done
+*/
+func GetWorkOrders(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting work orders")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	query := readQueryParameters(r)
+	filter := implementation.WorkOrderFilterImpl{
+		AssignedTo: query.text("assignee"),
+		Status:     query.text("status"),
+		BikeId:     query.optionalInteger("bikeId", 0, math.MaxInt32),
+	}
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	orders, getOrdersError := implementation.GetWorkOrders(r.Context(), filter)
+	if getOrdersError != nil {
+		getOrdersErrMsg := fmt.Errorf("could not retrieve work orders. %w", getOrdersError)
+		JSONError(w, r, getOrdersErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, orders)
+}
+
+// handler method to get a work order with its notes. Only allowed for operators
+func GetWorkOrder(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting work order")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	orderId, parseErr := strconv.ParseInt(mux.Vars(r)["orderId"], 10, 64)
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	order, getOrderError := implementation.GetWorkOrder(r.Context(), orderId)
+	if getOrderError != nil {
+		getOrderErrMsg := fmt.Errorf("could not retrieve work order. %w", getOrderError)
+		JSONError(w, r, getOrderErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, order)
+}
+
+/*
+	 handler method to change the status or the assignment of a work order. Only allowed for operators
+		parameters required:
+		- orderId in the path
+		takes a http body with following values, at least one is required
+		"status" : open, in_progress, blocked or done
+		"assignedTo" : the operator who does the work
+*/
+func UpdateWorkOrder(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Updating work order")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	orderId, parseErr := strconv.ParseInt(mux.Vars(r)["orderId"], 10, 64)
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	var updateRequest implementation.WorkOrderUpdateImpl
+
+	readRequestError := ReadRequestBody(w, r, &updateRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	order, updateOrderError := implementation.UpdateWorkOrder(r.Context(), orderId, updateRequest, username)
+	if updateOrderError != nil {
+		updateOrderErrMsg := fmt.Errorf("could not update work order. %w", updateOrderError)
+		JSONError(w, r, updateOrderErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, order)
+}
+
+/*
+	 handler method to add a note about the parts or the labour of a work order. Only allowed for operators
+		parameters required:
+		- orderId in the path
+		takes a http body with following values
+		"kind" : parts, labour or comment
+		"text" : e.g. the parts which were used
+		"labourMinutes" : optional time which was spent, only for labour notes
+*/
+func AddWorkOrderNote(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Adding note to work order")
+
+	username, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN)
+	if !isOperator {
+		return
+	}
+
+	orderId, parseErr := strconv.ParseInt(mux.Vars(r)["orderId"], 10, 64)
+	if parseErr != nil {
+		stringToIntParseErr := fmt.Errorf("error parsing string to int. %w", parseErr)
+		JSONError(w, r, stringToIntParseErr, http.StatusBadRequest)
+		return
+	}
+
+	var noteRequest implementation.WorkOrderNoteImpl
+
+	readRequestError := ReadRequestBody(w, r, &noteRequest)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	note, addNoteError := implementation.AddWorkOrderNote(r.Context(), orderId, noteRequest, username)
+	if addNoteError != nil {
+		addNoteErrMsg := fmt.Errorf("could not add note. %w", addNoteError)
+		JSONError(w, r, addNoteErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, note)
+}
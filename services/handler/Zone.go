@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+	 handler method to get the active zones as GeoJSON FeatureCollection
+		optional query parameters:
+		- kind: operating_area, no_parking, slow_speed or preferred_parking
+		- bbox: only zones which overlap the map section minLongitude,minLatitude,maxLongitude,maxLatitude
+*/
+func GetZones(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting zones")
+
+	query := readQueryParameters(r)
+	boundingBox := query.boundingBox("bbox")
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	zones, getZonesError := implementation.GetZones(r.Context(), query.text("kind"), boundingBox)
+	if getZonesError != nil {
+		getZonesErrMsg := fmt.Errorf("could not retrieve zones. %w", getZonesError)
+		JSONError(w, r, getZonesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, transformZoneImplsToGetZonesResponse(*zones))
+}
+
+// handler method to get a single zone as GeoJSON Feature
+func GetZone(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Getting zone")
+
+	zone, getZoneError := implementation.GetZone(r.Context(), mux.Vars(r)["zoneId"])
+	if getZoneError != nil {
+		getZoneErrMsg := fmt.Errorf("could not retrieve zone. %w", getZoneError)
+		JSONError(w, r, getZoneErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, transformZoneImplToGetZoneFeatureResponse(*zone))
+}
+
+/*
+	 handler method to import zones from a GeoJSON FeatureCollection. Only allowed for operators
+		every feature needs a Polygon or MultiPolygon geometry and the properties
+		"name" : name of the zone
+		"kind" : operating_area, no_parking, slow_speed or preferred_parking
+		"speedLimitKmh" : only for slow_speed
+*/
+func ImportZones(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Importing zones")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	var featureCollection implementation.GeoJsonFeatureCollectionImpl
+
+	readRequestError := ReadRequestBody(w, r, &featureCollection)
+	if readRequestError != nil {
+		readRequestErrorMsg := fmt.Errorf("error while reading request. %w", readRequestError)
+		JSONError(w, r, readRequestErrorMsg, http.StatusBadRequest)
+		return
+	}
+
+	importedZones, importZonesError := implementation.ImportZones(r.Context(), featureCollection)
+	if importZonesError != nil {
+		importZonesErrMsg := fmt.Errorf("could not import zones. %w", importZonesError)
+		JSONError(w, r, importZonesErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusCreated, transformZoneImplsToGetZonesResponse(*importedZones))
+}
+
+// handler method to deactivate a zone. Only allowed for operators
+func DeactivateZone(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Deactivating zone")
+
+	if _, isOperator := requireRole(w, r, implementation.ROLE_OPERATOR, implementation.ROLE_ADMIN); !isOperator {
+		return
+	}
+
+	deactivateZoneError := implementation.DeactivateZone(r.Context(), mux.Vars(r)["zoneId"])
+	if deactivateZoneError != nil {
+		deactivateZoneErrMsg := fmt.Errorf("could not deactivate zone. %w", deactivateZoneError)
+		JSONError(w, r, deactivateZoneErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonSuccessResponse(w, "Successfully deactivated zone")
+}
+
+/*
+	 handler method to evaluate a position against the zones
+		query parameters required:
+		- latitude
+		- longitude
+*/
+func EvaluatePosition(w http.ResponseWriter, r *http.Request) {
+
+	fmt.Println("Evaluating position")
+
+	query := readQueryParameters(r)
+	latitude := query.requiredNumber("latitude", -90, 90)
+	longitude := query.requiredNumber("longitude", -180, 180)
+	if queryError := query.err(); queryError != nil {
+		JSONError(w, r, queryError, http.StatusBadRequest)
+		return
+	}
+
+	evaluation, evaluatePositionError := implementation.EvaluatePosition(r.Context(), latitude, longitude)
+	if evaluatePositionError != nil {
+		evaluatePositionErrMsg := fmt.Errorf("could not evaluate position. %w", evaluatePositionError)
+		JSONError(w, r, evaluatePositionErrMsg, http.StatusInternalServerError)
+		return
+	}
+
+	JsonObjectResponse(w, http.StatusOK, transformPositionEvaluationImplToResponse(*evaluation))
+}
+
+// parses a bounding box in GeoJSON order minLongitude,minLatitude,maxLongitude,maxLatitude
+func parseBoundingBox(bbox string) (*implementation.BoundingBoxImpl, error) {
+	parts := strings.Split(bbox, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox must have the format minLongitude,minLatitude,maxLongitude,maxLatitude")
+	}
+
+	values := [4]float64{}
+	for index, part := range parts {
+		value, parseError := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if parseError != nil {
+			return nil, fmt.Errorf("bbox value %v is not a number", part)
+		}
+		values[index] = value
+	}
+
+	if values[0] > values[2] || values[1] > values[3] {
+		return nil, fmt.Errorf("bbox minimum must not be greater than maximum")
+	}
+
+	return &implementation.BoundingBoxImpl{
+		MinLongitude: values[0],
+		MinLatitude:  values[1],
+		MaxLongitude: values[2],
+		MaxLatitude:  values[3],
+	}, nil
+}
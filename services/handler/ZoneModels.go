@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"eBikeApi/services/implementation"
+	"time"
+)
+
+/* struct used to return zones as GeoJSON FeatureCollection, which can be shown by map clients directly
+ */
+type GetZonesResponse struct {
+	Type     string                   `json:"type"`
+	Features []GetZoneFeatureResponse `json:"features"`
+}
+
+/* struct used to return a zone as GeoJSON Feature
+ */
+type GetZoneFeatureResponse struct {
+	Type        string                 `json:"type"`
+	Id          string                 `json:"id"`
+	Properties  ZonePropertiesResponse `json:"properties"`
+	Geometry    ZoneGeometryResponse   `json:"geometry"`
+	BoundingBox [4]float64             `json:"bbox"`
+}
+
+/* struct used to describe a zone in the properties of a GeoJSON Feature
+ */
+type ZonePropertiesResponse struct {
+	Name          string    `json:"name"`
+	Kind          string    `json:"kind"`
+	SpeedLimitKmh *int      `json:"speedLimitKmh,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+/* struct used to return the polygons of a zone as GeoJSON geometry
+ */
+type ZoneGeometryResponse struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+/* struct used to return the evaluation of a position against the zones
+ */
+type GetPositionEvaluationResponse struct {
+	Latitude            float64               `json:"latitude"`
+	Longitude           float64               `json:"longitude"`
+	InsideOperatingArea bool                  `json:"insideOperatingArea"`
+	InNoParkingZone     bool                  `json:"inNoParkingZone"`
+	InPreferredParking  bool                  `json:"inPreferredParking"`
+	SpeedLimitKmh       *int                  `json:"speedLimitKmh,omitempty"`
+	Zones               []ZoneSummaryResponse `json:"zones"`
+}
+
+/* struct used to list the zones which contain a position
+ */
+type ZoneSummaryResponse struct {
+	ZoneId string `json:"zoneId"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+}
+
+/*
+transforms zones from the implementation layer to a GeoJSON FeatureCollection
+*/
+func transformZoneImplsToGetZonesResponse(zones []implementation.ZoneImpl) GetZonesResponse {
+
+	features := []GetZoneFeatureResponse{}
+	for _, zone := range zones {
+		features = append(features, transformZoneImplToGetZoneFeatureResponse(zone))
+	}
+
+	return GetZonesResponse{Type: "FeatureCollection", Features: features}
+}
+
+/*
+transforms a zone from the implementation layer to a GeoJSON Feature.
+a zone with one polygon is returned as Polygon, otherwise as MultiPolygon
+*/
+func transformZoneImplToGetZoneFeatureResponse(zone implementation.ZoneImpl) GetZoneFeatureResponse {
+
+	geometry := ZoneGeometryResponse{Type: "MultiPolygon", Coordinates: zone.Polygons}
+	if len(zone.Polygons) == 1 {
+		geometry = ZoneGeometryResponse{Type: "Polygon", Coordinates: zone.Polygons[0]}
+	}
+
+	return GetZoneFeatureResponse{
+		Type: "Feature",
+		Id:   zone.ZoneId,
+		Properties: ZonePropertiesResponse{
+			Name:          zone.Name,
+			Kind:          zone.Kind,
+			SpeedLimitKmh: zone.SpeedLimitKmh,
+			CreatedAt:     zone.CreatedAt,
+		},
+		Geometry:    geometry,
+		BoundingBox: [4]float64{zone.MinLongitude, zone.MinLatitude, zone.MaxLongitude, zone.MaxLatitude},
+	}
+}
+
+/*
+transforms the evaluation of a position from the implementation layer to the struct for the JSON Response
+*/
+func transformPositionEvaluationImplToResponse(evaluation implementation.PositionEvaluationImpl) GetPositionEvaluationResponse {
+
+	zones := []ZoneSummaryResponse{}
+	for _, zone := range evaluation.Zones {
+		zones = append(zones, ZoneSummaryResponse{ZoneId: zone.ZoneId, Name: zone.Name, Kind: zone.Kind})
+	}
+
+	return GetPositionEvaluationResponse{
+		Latitude:            evaluation.Latitude,
+		Longitude:           evaluation.Longitude,
+		InsideOperatingArea: evaluation.InsideOperatingArea,
+		InNoParkingZone:     evaluation.InNoParkingZone,
+		InPreferredParking:  evaluation.InPreferredParking,
+		SpeedLimitKmh:       evaluation.SpeedLimitKmh,
+		Zones:               zones,
+	}
+}
@@ -0,0 +1,485 @@
+package implementation
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DB_TABLE_AUDITLOG = "auditlog"
+
+	// key of the advisory lock which appends to the audit log one entry after the other, so the hash chain has no branches
+	AUDIT_APPEND_LOCK_KEY = 4404
+	// the previous hash of the first entry
+	AUDIT_GENESIS_HASH = "0000000000000000000000000000000000000000000000000000000000000000"
+	// default and maximal number of entries which are returned to an admin
+	AUDIT_DEFAULT_LIMIT = 100
+	AUDIT_MAX_LIMIT     = 1000
+	// number of entries which are verified per query
+	AUDIT_VERIFY_BATCH_SIZE = 1000
+	// the fields which the client sends are stored up to the length of their column
+	AUDIT_MAX_ACTOR_LENGTH         = 50
+	AUDIT_MAX_FORWARDED_FOR_LENGTH = 500
+	AUDIT_MAX_TARGET_ID_LENGTH     = 100
+)
+
+// the columns of the auditlog table in the order they are scanned by scanAuditEntry
+const auditEntryColumns = `entryid, requestid, actor, sourceip, forwardedfor, action, targettype, targetid, statuscode, before, after, createdat, previoushash, hash`
+
+// the functions which return the state of a target by its id, nil if the target does not exist
+var auditSnapshots = map[string]func(ctx context.Context, targetId string) (interface{}, error){
+	AUDIT_TARGET_BIKE: getBikeAuditSnapshot,
+	AUDIT_TARGET_THEFT_ALERT: func(ctx context.Context, targetId string) (interface{}, error) {
+		alertId, parseError := strconv.ParseInt(targetId, 10, 64)
+		if parseError != nil {
+			return nil, nil
+		}
+		return getFirstAuditSnapshot(func(db dbQueryer) (interface{}, int, error) {
+			alerts, getAlertsError := getTheftAlertsWhere(ctx, db, `alertid=$1`, alertId)
+			return alerts, len(alerts), getAlertsError
+		})
+	},
+	AUDIT_TARGET_WORK_ORDER: func(ctx context.Context, targetId string) (interface{}, error) {
+		orderId, parseError := strconv.ParseInt(targetId, 10, 64)
+		if parseError != nil {
+			return nil, nil
+		}
+		return getFirstAuditSnapshot(func(db dbQueryer) (interface{}, int, error) {
+			orders, getOrdersError := getWorkOrdersWhere(ctx, db, `workorder.orderid=$1`, orderId)
+			return orders, len(orders), getOrdersError
+		})
+	},
+	AUDIT_TARGET_WEBHOOK: func(ctx context.Context, targetId string) (interface{}, error) {
+		if _, parseError := uuid.Parse(targetId); parseError != nil {
+			return nil, nil
+		}
+		return getFirstAuditSnapshot(func(db dbQueryer) (interface{}, int, error) {
+			subscriptions, getSubscriptionsError := getWebhookSubscriptionsWhere(ctx, db, `subscriptionid=$1`, targetId)
+			return subscriptions, len(subscriptions), getSubscriptionsError
+		})
+	},
+	AUDIT_TARGET_ZONE: func(ctx context.Context, targetId string) (interface{}, error) {
+		if _, parseError := uuid.Parse(targetId); parseError != nil {
+			return nil, nil
+		}
+		return getFirstAuditSnapshot(func(db dbQueryer) (interface{}, int, error) {
+			zones, getZonesError := getZonesWhere(ctx, db, `zoneid=$1`, targetId)
+			return zones, len(zones), getZonesError
+		})
+	},
+	AUDIT_TARGET_DAMAGE_REPORT: func(ctx context.Context, targetId string) (interface{}, error) {
+		if _, parseError := uuid.Parse(targetId); parseError != nil {
+			return nil, nil
+		}
+		return getFirstAuditSnapshot(func(db dbQueryer) (interface{}, int, error) {
+			reports, getReportsError := getDamageReportsWhere(ctx, db, `damagereport.reportid=$1`, targetId)
+			return reports, len(reports), getReportsError
+		})
+	},
+	AUDIT_TARGET_USER_SUBSCRIPTION: func(ctx context.Context, targetId string) (interface{}, error) {
+		return GetSubscriptionStatus(ctx, targetId)
+	},
+	AUDIT_TARGET_ORGANIZATION: func(ctx context.Context, targetId string) (interface{}, error) {
+		if _, parseError := uuid.Parse(targetId); parseError != nil {
+			return nil, nil
+		}
+		organization, getOrganizationError := GetOrganization(ctx, targetId)
+		if getOrganizationError != nil {
+			return nil, getOrganizationError
+		}
+		members, getMembersError := GetOrganizationMembers(ctx, targetId)
+		if getMembersError != nil {
+			return nil, getMembersError
+		}
+		return map[string]interface{}{"organization": organization, "members": members}, nil
+	},
+	AUDIT_TARGET_RIDE_PENALTIES: func(ctx context.Context, targetId string) (interface{}, error) {
+		return GetRidePenalties(ctx, targetId)
+	},
+	AUDIT_TARGET_PENALTY_RULE: func(ctx context.Context, targetId string) (interface{}, error) {
+		rules, getRulesError := GetPenaltyRules(ctx)
+		if getRulesError != nil {
+			return nil, getRulesError
+		}
+		for _, rule := range *rules {
+			if rule.RuleId == targetId {
+				return rule, nil
+			}
+		}
+		return nil, nil
+	},
+	AUDIT_TARGET_MAINTENANCE_PLAN: func(ctx context.Context, targetId string) (interface{}, error) {
+		plans, getPlansError := GetMaintenancePlans(ctx)
+		if getPlansError != nil {
+			return nil, getPlansError
+		}
+		for _, plan := range *plans {
+			if plan.PlanId == targetId {
+				return plan, nil
+			}
+		}
+		return nil, nil
+	},
+}
+
+/*
+Implementation method which appends an entry to the audit log. The entry is linked to the latest entry by its hash,
+the entries are appended one after the other. Before and after are JSON, null if the state is unknown
+*/
+func RecordAuditEntry(ctx context.Context, entry AuditEntryImpl) (*AuditEntryImpl, error) {
+
+	if entry.Actor == "" {
+		entry.Actor = AUDIT_ACTOR_ANONYMOUS
+	}
+	entry.Actor = truncateAuditField(entry.Actor, AUDIT_MAX_ACTOR_LENGTH)
+	entry.ForwardedFor = truncateAuditField(entry.ForwardedFor, AUDIT_MAX_FORWARDED_FOR_LENGTH)
+	entry.TargetId = truncateAuditField(entry.TargetId, AUDIT_MAX_TARGET_ID_LENGTH)
+	before, compactError := compactAuditSnapshot(entry.Before)
+	if compactError != nil {
+		return nil, compactError
+	}
+	after, compactError := compactAuditSnapshot(entry.After)
+	if compactError != nil {
+		return nil, compactError
+	}
+	entry.Before, entry.After = before, after
+	// postgres stores microseconds, the hash is computed from the time as it is read again
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	_, dbLockError := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1);`, AUDIT_APPEND_LOCK_KEY)
+	if dbLockError != nil {
+		return nil, fmt.Errorf("could not lock the audit log. %w", dbLockError)
+	}
+
+	dbQueryError := tx.QueryRowContext(ctx, `SELECT hash FROM `+DB_TABLE_AUDITLOG+` ORDER BY entryid DESC LIMIT 1;`).Scan(&entry.PreviousHash)
+	if dbQueryError == sql.ErrNoRows {
+		entry.PreviousHash = AUDIT_GENESIS_HASH
+	} else if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve the latest entry of the audit log. %w", dbQueryError)
+	}
+	entry.Hash = auditEntryHash(entry)
+
+	insertStatement := getInsertStmt(DB_TABLE_AUDITLOG, "requestid", "actor", "sourceip", "forwardedfor", "action", "targettype", "targetid",
+		"statuscode", "before", "after", "createdat", "previoushash", "hash")
+	dbInsertError := tx.QueryRowContext(ctx, insertStatement+` RETURNING entryid`, entry.RequestId, entry.Actor, entry.SourceIp, entry.ForwardedFor, entry.Action,
+		entry.TargetType, entry.TargetId, entry.StatusCode, string(entry.Before), string(entry.After), entry.CreatedAt, entry.PreviousHash,
+		entry.Hash).Scan(&entry.EntryId)
+	if dbInsertError != nil {
+		return nil, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_AUDITLOG, dbInsertError)
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not record audit entry. %w", commitError)
+	}
+
+	return &entry, nil
+}
+
+/*
+Implementation method for admins to query the audit log, the latest entry first
+*/
+func GetAuditEntries(ctx context.Context, filter AuditFilterImpl) (*[]AuditEntryImpl, error) {
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = AUDIT_DEFAULT_LIMIT
+	}
+	if limit < 0 || limit > AUDIT_MAX_LIMIT {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "limit must be between 1 and %d", AUDIT_MAX_LIMIT)
+	}
+
+	conditions := []string{"true"}
+	arguments := []interface{}{}
+	addCondition := func(column string, value interface{}) {
+		arguments = append(arguments, value)
+		conditions = append(conditions, fmt.Sprintf("%v$%d", column, len(arguments)))
+	}
+	if filter.Actor != "" {
+		addCondition("actor=", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCondition("action=", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("targettype=", filter.TargetType)
+	}
+	if filter.TargetId != "" {
+		addCondition("targetid=", filter.TargetId)
+	}
+	if filter.RequestId != "" {
+		addCondition("requestid=", filter.RequestId)
+	}
+	if filter.From != nil {
+		addCondition("createdat>=", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("createdat<", *filter.To)
+	}
+	if filter.BeforeId > 0 {
+		addCondition("entryid<", filter.BeforeId)
+	}
+	arguments = append(arguments, limit)
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	entries, getEntriesError := getAuditEntriesWhere(ctx, db, strings.Join(conditions, " AND ")+fmt.Sprintf(" ORDER BY entryid DESC LIMIT $%d", len(arguments)), arguments...)
+	if getEntriesError != nil {
+		return nil, getEntriesError
+	}
+
+	return &entries, nil
+}
+
+/*
+Implementation method which verifies the hash chain of the audit log from the first entry.
+the verification stops at the first entry whose hash does not match its fields or which is not linked to the previous entry,
+e.g. because an entry was changed or deleted.
+if expectedHash is set, the valid entry with this hash and an entryId of at least expectedFromEntryId is looked up,
+so entries which were removed from the end of the log are noticed, while entries which were added since are not
+*/
+func VerifyAuditLog(ctx context.Context, expectedHash string, expectedFromEntryId int64) (*AuditVerificationImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	verification := AuditVerificationImpl{Valid: true, LastHash: AUDIT_GENESIS_HASH}
+	var lastEntryId int64
+	for {
+		entries, getEntriesError := getAuditEntriesWhere(ctx, db, `entryid>$1 ORDER BY entryid LIMIT $2`, lastEntryId, AUDIT_VERIFY_BATCH_SIZE)
+		if getEntriesError != nil {
+			return nil, getEntriesError
+		}
+
+		for _, entry := range entries {
+			verifyError := verifyAuditEntry(entry, verification.LastHash)
+			if verifyError != nil {
+				verification.Valid = false
+				verification.FirstInvalidEntryId = &entry.EntryId
+				verification.Error = verifyError.Error()
+				return &verification, nil
+			}
+			verification.Entries++
+			verification.LastEntryId = entry.EntryId
+			verification.LastHash = entry.Hash
+			lastEntryId = entry.EntryId
+			if expectedHash != "" && entry.Hash == expectedHash && entry.EntryId >= expectedFromEntryId && verification.ExpectedHashEntryId == nil {
+				expectedEntryId := entry.EntryId
+				verification.ExpectedHashEntryId = &expectedEntryId
+			}
+		}
+
+		if len(entries) < AUDIT_VERIFY_BATCH_SIZE {
+			return &verification, nil
+		}
+	}
+}
+
+/*
+Implementation method which returns the state of the target of a request as JSON, e.g. the bike with its reservation.
+it returns null if the target type has no snapshot or the target does not exist
+*/
+func GetAuditSnapshot(ctx context.Context, targetType string, targetId string) (json.RawMessage, error) {
+	getSnapshot, hasSnapshot := auditSnapshots[targetType]
+	if !hasSnapshot {
+		return nil, nil
+	}
+
+	snapshot, snapshotError := getSnapshot(ctx, targetId)
+	if snapshotError != nil {
+		return nil, snapshotError
+	}
+	if snapshot == nil {
+		return nil, nil
+	}
+	return json.Marshal(snapshot)
+}
+
+// returns true, if the state of the targets of the type is recorded before and after a request
+func AuditTargetHasSnapshot(targetType string) bool {
+	_, hasSnapshot := auditSnapshots[targetType]
+	return hasSnapshot
+}
+
+// returns the bike with its reservation, nil if the bike does not exist
+func getBikeAuditSnapshot(ctx context.Context, targetId string) (interface{}, error) {
+	bikeId, parseError := strconv.Atoi(targetId)
+	if parseError != nil {
+		return nil, nil
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	bike, getBikeError := getBikeFromDb(ctx, db, bikeId)
+	if getBikeError != nil {
+		return nil, getBikeError
+	}
+	if bike.BikeId == 0 {
+		return nil, nil
+	}
+
+	snapshot := AuditBikeSnapshotImpl{BikeId: bike.BikeId, Name: bike.Name, Latitude: bike.Latitude, Longitude: bike.Longitude, Status: bike.Status}
+	if bike.StationId.Valid {
+		snapshot.StationId = &bike.StationId.Int64
+	}
+	if bike.BatteryPercent.Valid {
+		snapshot.BatteryPercent = &bike.BatteryPercent.Int64
+	}
+	if bike.ReservationId.Valid {
+		reservation, getReservationError := getReservationFromDb(ctx, db, DB_TABLE_RESERVATION_COLUMN_RESERVATIONID, bike.ReservationId.String)
+		if getReservationError != nil {
+			return nil, getReservationError
+		}
+		snapshot.Reservation = &AuditReservationSnapshotImpl{ReservationId: reservation.ReservationId, Username: reservation.Username,
+			StartedAt: reservation.CreatedAt}
+		if reservation.PromoCode.Valid {
+			snapshot.Reservation.PromoCode = &reservation.PromoCode.String
+		}
+		if reservation.BillingOrganizationId.Valid {
+			snapshot.Reservation.BillingOrganizationId = &reservation.BillingOrganizationId.String
+		}
+	}
+	return snapshot, nil
+}
+
+// returns the first element of the slice which the query returns, nil if the slice is empty
+func getFirstAuditSnapshot(query func(db dbQueryer) (interface{}, int, error)) (interface{}, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	results, count, queryError := query(db)
+	if queryError != nil || count == 0 {
+		return nil, queryError
+	}
+
+	resultsJson, marshalError := json.Marshal(results)
+	if marshalError != nil {
+		return nil, marshalError
+	}
+	var elements []json.RawMessage
+	if unmarshalError := json.Unmarshal(resultsJson, &elements); unmarshalError != nil {
+		return nil, unmarshalError
+	}
+	return elements[0], nil
+}
+
+// verifies the hash of an entry and its link to the hash of the previous entry
+func verifyAuditEntry(entry AuditEntryImpl, previousHash string) error {
+	if entry.PreviousHash != previousHash {
+		return fmt.Errorf("entry %d is not linked to the previous entry, it expects the hash %v instead of %v", entry.EntryId, entry.PreviousHash, previousHash)
+	}
+	if expectedHash := auditEntryHash(entry); entry.Hash != expectedHash {
+		return fmt.Errorf("entry %d was changed, its hash is %v instead of %v", entry.EntryId, entry.Hash, expectedHash)
+	}
+	return nil
+}
+
+/*
+returns the hex encoded SHA-256 of the fields of an entry and the hash of the previous entry.
+every field is written with its length, so the boundaries between the fields can not be moved
+*/
+func auditEntryHash(entry AuditEntryImpl) string {
+	digest := sha256.New()
+	for _, field := range []string{entry.PreviousHash, entry.RequestId, entry.Actor, entry.SourceIp, entry.ForwardedFor, entry.Action,
+		entry.TargetType, entry.TargetId, strconv.Itoa(entry.StatusCode), string(entry.Before), string(entry.After),
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano)} {
+		writeAuditHashField(digest, field)
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// shortens a field to the number of characters of its column, so a long header can not prevent the entry
+func truncateAuditField(value string, maxLength int) string {
+	if characters := []rune(value); len(characters) > maxLength {
+		return string(characters[:maxLength])
+	}
+	return value
+}
+
+// writes a field with its length to the hash
+func writeAuditHashField(digest hash.Hash, field string) {
+	fmt.Fprintf(digest, "%d:%s\n", len(field), field)
+}
+
+// compacts a JSON snapshot, so it is stored and hashed the same way. An empty snapshot is null
+func compactAuditSnapshot(snapshot json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(snapshot)) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	var compacted bytes.Buffer
+	if compactError := json.Compact(&compacted, snapshot); compactError != nil {
+		return nil, fmt.Errorf("audit snapshot is not valid JSON. %w", compactError)
+	}
+	return compacted.Bytes(), nil
+}
+
+// returns the audit entries which match the condition
+func getAuditEntriesWhere(ctx context.Context, db dbQueryer, condition string, arguments ...interface{}) ([]AuditEntryImpl, error) {
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+auditEntryColumns+` FROM `+DB_TABLE_AUDITLOG+` WHERE `+condition+`;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_AUDITLOG, dbQueryError)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntryImpl{}
+	for rows.Next() {
+		entry, scanError := scanAuditEntry(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+// scans a row of the auditlog table which was selected with auditEntryColumns
+func scanAuditEntry(rows *sql.Rows) (*AuditEntryImpl, error) {
+	var entry AuditEntryImpl
+	var before, after string
+
+	scanError := rows.Scan(&entry.EntryId, &entry.RequestId, &entry.Actor, &entry.SourceIp, &entry.ForwardedFor, &entry.Action, &entry.TargetType,
+		&entry.TargetId, &entry.StatusCode, &before, &after, &entry.CreatedAt, &entry.PreviousHash, &entry.Hash)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v. %w", DB_TABLE_AUDITLOG, scanError)
+	}
+
+	entry.Before = json.RawMessage(before)
+	entry.After = json.RawMessage(after)
+	return &entry, nil
+}
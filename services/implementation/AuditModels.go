@@ -0,0 +1,104 @@
+package implementation
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// ---------- types of the targets of audit entries which have a snapshot of their state ---------
+	AUDIT_TARGET_BIKE              = "bike"
+	AUDIT_TARGET_THEFT_ALERT       = "theftalert"
+	AUDIT_TARGET_WORK_ORDER        = "workorder"
+	AUDIT_TARGET_WEBHOOK           = "webhooksubscription"
+	AUDIT_TARGET_ZONE              = "zone"
+	AUDIT_TARGET_DAMAGE_REPORT     = "damagereport"
+	AUDIT_TARGET_USER_SUBSCRIPTION = "subscription"
+	AUDIT_TARGET_ORGANIZATION      = "organization"
+	AUDIT_TARGET_RIDE_PENALTIES    = "ridepenalties"
+	AUDIT_TARGET_PENALTY_RULE      = "penaltyrule"
+	AUDIT_TARGET_MAINTENANCE_PLAN  = "maintenanceplan"
+	// a reservation is only recorded with the response, its bike has the snapshot
+	AUDIT_TARGET_RESERVATION = "reservation"
+
+	// the actor of a request without username, e.g. a rider who reserves a bike with the username in the body
+	AUDIT_ACTOR_ANONYMOUS = "anonymous"
+)
+
+/*
+represents the database structure for the table "auditlog" in the DATABASE.
+every entry records a state-changing request: who did what to which target, with the state of the target before and after.
+the hash of an entry covers its fields and the hash of the previous entry, so a changed or deleted entry breaks the chain
+*/
+type AuditEntryImpl struct {
+	EntryId      int64           `json:"entryId"`
+	RequestId    string          `json:"requestId"`
+	Actor        string          `json:"actor"`
+	SourceIp     string          `json:"sourceIp"`
+	ForwardedFor string          `json:"forwardedFor"` // the X-Forwarded-For header as sent, it is not verified
+	Action       string          `json:"action"`       // the method and the route, e.g. DELETE /reservation/bike/{bikeId}
+	TargetType   string          `json:"targetType"`
+	TargetId     string          `json:"targetId"`
+	StatusCode   int             `json:"statusCode"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	PreviousHash string          `json:"previousHash"`
+	Hash         string          `json:"hash"`
+}
+
+/*
+represents the filter of an admin for the audit log
+*/
+type AuditFilterImpl struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetId   string
+	RequestId  string
+	From       *time.Time
+	To         *time.Time
+	// only the entries before this entry, to read the log page by page from the latest entry
+	BeforeId int64
+	Limit    int
+}
+
+/*
+represents the result of the verification of the hash chain of the audit log
+*/
+type AuditVerificationImpl struct {
+	Entries int64 `json:"entries"`
+	Valid   bool  `json:"valid"`
+	// the first entry whose hash or link to the previous entry does not match
+	FirstInvalidEntryId *int64 `json:"firstInvalidEntryId"`
+	Error               string `json:"error"`
+	LastEntryId         int64  `json:"lastEntryId"`
+	LastHash            string `json:"lastHash"`
+	// the entry which has the expected hash, nil if no valid entry has it
+	ExpectedHashEntryId *int64 `json:"expectedHashEntryId"`
+}
+
+/*
+represents the state of a bike in the audit log, with its reservation
+*/
+type AuditBikeSnapshotImpl struct {
+	BikeId         int                           `json:"bikeId"`
+	Name           string                        `json:"name"`
+	Latitude       string                        `json:"latitude"`
+	Longitude      string                        `json:"longitude"`
+	Status         string                        `json:"status"`
+	StationId      *int64                        `json:"stationId"`
+	BatteryPercent *int64                        `json:"batteryPercent"`
+	Reservation    *AuditReservationSnapshotImpl `json:"reservation"`
+}
+
+/*
+represents the reservation of a bike in the audit log
+*/
+type AuditReservationSnapshotImpl struct {
+	ReservationId         string    `json:"reservationId"`
+	Username              string    `json:"username"`
+	StartedAt             time.Time `json:"startedAt"`
+	PromoCode             *string   `json:"promoCode"`
+	BillingOrganizationId *string   `json:"billingOrganizationId"`
+}
@@ -0,0 +1,68 @@
+package implementation
+
+import (
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// answers the audit log with a valid chain of entries
+func useAuditLog(t *testing.T, count int) []AuditEntryImpl {
+	t.Helper()
+	entries := []AuditEntryImpl{}
+	previousHash := AUDIT_GENESIS_HASH
+	for entryId := 1; entryId <= count; entryId++ {
+		entry := AuditEntryImpl{EntryId: int64(entryId), RequestId: fmt.Sprintf("request-%d", entryId), Actor: "adminOne", SourceIp: "127.0.0.1",
+			Action: "DELETE /reservation/bike/{bikeId}", TargetType: AUDIT_TARGET_BIKE, TargetId: "7", StatusCode: 200,
+			Before: []byte(`null`), After: []byte(`null`), CreatedAt: time.Date(2026, 10, 19, 12, entryId, 0, 0, time.UTC), PreviousHash: previousHash}
+		entry.Hash = auditEntryHash(entry)
+		previousHash = entry.Hash
+		entries = append(entries, entry)
+	}
+
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		result := &fakedb.Result{Columns: strings.Split(auditEntryColumns, ", ")}
+		for _, entry := range entries {
+			if entry.EntryId > statement.Args[0].(int64) && int64(len(result.Rows)) < statement.Args[1].(int64) {
+				result.Rows = append(result.Rows, []driver.Value{entry.EntryId, entry.RequestId, entry.Actor, entry.SourceIp, entry.ForwardedFor,
+					entry.Action, entry.TargetType, entry.TargetId, int64(entry.StatusCode), string(entry.Before), string(entry.After),
+					entry.CreatedAt, entry.PreviousHash, entry.Hash})
+			}
+		}
+		return result, nil
+	})
+	return entries
+}
+
+func TestAuditVerificationAcceptsEntriesAddedAfterExpectedHash(t *testing.T) {
+	entries := useAuditLog(t, 3)
+
+	verification, err := VerifyAuditLog(context.Background(), entries[1].Hash, entries[1].EntryId)
+	if err != nil || !verification.Valid {
+		t.Fatalf("expected a valid audit log, got %+v and %v", verification, err)
+	}
+	if verification.ExpectedHashEntryId == nil || *verification.ExpectedHashEntryId != 2 {
+		t.Fatalf("expected entry 2 to have the expected hash, got %v", verification.ExpectedHashEntryId)
+	}
+	if verification.LastEntryId != 3 || verification.LastHash != entries[2].Hash {
+		t.Fatalf("expected entry 3 as the latest entry, got %v", verification.LastEntryId)
+	}
+}
+
+func TestAuditVerificationNoticesRemovedEntries(t *testing.T) {
+	entries := useAuditLog(t, 3)
+	removedHash := entries[2].Hash
+	useAuditLog(t, 2)
+
+	verification, err := VerifyAuditLog(context.Background(), removedHash, 3)
+	if err != nil || !verification.Valid {
+		t.Fatalf("expected the remaining chain to be valid, got %+v and %v", verification, err)
+	}
+	if verification.ExpectedHashEntryId != nil {
+		t.Fatalf("expected no entry with the hash of the removed entry, got entry %v", *verification.ExpectedHashEntryId)
+	}
+}
@@ -1,59 +1,67 @@
 package implementation
 
 import (
+	"context"
 	"fmt"
+	"time"
 )
 
 /*
-Implementation method to retrieve all bikes from the Database
+Implementation method to retrieve all bikes from the Database.
+if minBattery is greater than 0, only bikes with at least this battery percentage are returned.
+bikes which can not be rented, e.g. in maintenance, are only returned if includeUnavailable is true
 */
-func GetAllBikes() (*[]BikeImpl, error) {
+func GetAllBikes(ctx context.Context, minBattery int, includeUnavailable bool) (*[]BikeImpl, error) {
+
+	if minBattery < 0 || minBattery > 100 {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "minBattery must be between 0 and 100")
+	}
+
 	// connect to database
 	db, dbConnectError := SetupDB()
 	if dbConnectError != nil {
 		return nil, dbConnectError
 	}
-	defer db.Close() // close connection to DB after finishing method
 
 	// Get all bikes from the database
-	rows, getAllRowsFromTableErr := getAllRowsFromTable(db, DB_TABLE_BIKE)
-	if getAllRowsFromTableErr != nil {
-		return nil, getAllRowsFromTableErr
+	rows, getAllBikesError := getAllBikesFromDb(ctx, db, minBattery, includeUnavailable)
+	if getAllBikesError != nil {
+		return nil, getAllBikesError
 	}
+	defer rows.Close()
 
 	var arrayOfBikes []BikeImpl
 	// For each record...
 	for rows.Next() {
-		// create a new Bike Object
-		tempBike := BikeImpl{}
-		// fill the object
-		// reservationID is a nullstring type and will be converted to "rented" (boolean) in the transform method
-		scanError := rows.Scan(&tempBike.BikeId, &tempBike.Name, &tempBike.Latitude, &tempBike.Longitude, &tempBike.ReservationId)
-
+		// create a new Bike Object and fill it
+		tempBike, scanError := scanBike(rows)
 		if scanError != nil {
-			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into bikeobject", DB_TABLE_BIKE)
+			return nil, scanError
 		}
 
-		arrayOfBikes = append(arrayOfBikes, tempBike)
+		arrayOfBikes = append(arrayOfBikes, *tempBike)
 	}
 
 	return &arrayOfBikes, nil
 }
 
-/* Implementation method to Get the bike reservation from a specific user */
-func GetBikeReservation(username string) (*BikeImpl, error) {
+/*
+Implementation method to Get the bike reservations from a specific user.
+Depending on the plan of the user, a user can rent more than one bike at the same time.
+*/
+func GetBikeReservation(ctx context.Context, username string) (*[]BikeImpl, error) {
 	// connect to database
 	db, dbConnectError := SetupDB()
 	if dbConnectError != nil {
 		return nil, dbConnectError
 	}
-	defer db.Close() // close connection to DB after finishing method
 
-	// Get all bikes from the database
-	reservationRecords, getAllRowsFromTableErr := getBikeReservationsForUserFromDb(db, username)
+	// Get all reservations of the user from the database
+	reservationRecords, getAllRowsFromTableErr := getBikeReservationsForUserFromDb(ctx, db, username)
 	if getAllRowsFromTableErr != nil {
 		return nil, getAllRowsFromTableErr
 	}
+	defer reservationRecords.Close()
 
 	var arrayOfBikeReservations []BikeReservationImpl
 	// For each record...
@@ -70,26 +78,17 @@ func GetBikeReservation(username string) (*BikeImpl, error) {
 		arrayOfBikeReservations = append(arrayOfBikeReservations, tempReservation)
 	}
 
-	// if no reservations are found in the reservationTable, return empty array
-	if len(arrayOfBikeReservations) == 0 {
-		return &BikeImpl{}, nil
-	}
-
-	// if there is more than one reservation, return error because this means inconsistency, since a user is only allowed to have one bike.
-	if len(arrayOfBikeReservations) > 1 {
-		return nil, fmt.Errorf("found more than one reservation for user %v. Data Inconsistency", username)
-	}
-
-	// there is just one record... Retrieve the record from the bike table to retrieve all information of the bike
-	targetReservation := arrayOfBikeReservations[0]
-
-	// we got the targetReservation record from the reservation table. Now retrieve all bike information from the bike table via the bikeId
-	targetBike, getBikeFromDbError := getBikeFromDb(db, targetReservation.BikeId)
-	if getBikeFromDbError != nil {
-		return nil, getBikeFromDbError
+	// for every reservation retrieve all bike information from the bike table via the bikeId
+	arrayOfBikes := []BikeImpl{}
+	for _, reservation := range arrayOfBikeReservations {
+		targetBike, getBikeFromDbError := getBikeFromDb(ctx, db, *reservation.BikeId)
+		if getBikeFromDbError != nil {
+			return nil, getBikeFromDbError
+		}
+		arrayOfBikes = append(arrayOfBikes, *targetBike)
 	}
 
-	return targetBike, nil
+	return &arrayOfBikes, nil
 }
 
 /*
@@ -98,106 +97,251 @@ func GetBikeReservation(username string) (*BikeImpl, error) {
 		After successful record creation, it updates the column for the reserved bike in the bike table.
 		If updating the bike record fails, the record in the reservation table will be deleted. otherwise we have an inconsistency.
 */
-func ReserveBike(bikeReservationRequest BikeReservationImpl) (*string, error) {
+func ReserveBike(ctx context.Context, bikeReservationRequest BikeReservationImpl) (*string, error) {
 
-	username := bikeReservationRequest.Username
-	bikeId := bikeReservationRequest.BikeId
-
-	// if the username is missing, throw error
-	if username == "" {
-		usernameMissingError := fmt.Errorf("no username provided. Bike reservation process failed")
-		return nil, usernameMissingError
+	// if the username or the bikeId is missing, throw error
+	validator := fieldValidator{}
+	validator.requiredText("username", bikeReservationRequest.Username, MAX_USERNAME_LENGTH)
+	if validator.required("bikeId", bikeReservationRequest.BikeId != nil) {
+		validator.atLeast("bikeId", *bikeReservationRequest.BikeId, 0)
+	}
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
 	}
 
-	// if bikeId == "" { // TODO: How to check that it is not provided
-	// 	bikeIdMissingError := fmt.Errorf("no bikeId provided. Bike reservation process failed")
-	// 	return nil, bikeIdMissingError
-	// }
+	username := bikeReservationRequest.Username
+	bikeId := *bikeReservationRequest.BikeId
 
 	// connect to database
 	db, dbConnectError := SetupDB()
 	if dbConnectError != nil {
 		return nil, dbConnectError
 	}
-	defer db.Close() // close connection to DB after finishing method
 
-	// verify if user exists in the database
-	userRecordExists, userExistsInDbError := userExistsInDb(db, username)
-	if userExistsInDbError != nil {
-		return nil, userExistsInDbError
+	// the reservation and its event are stored in one transaction
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	// verify if user exists in the database. The user stays locked until the reservation is stored,
+	// so concurrent reservations of the user wait for each other and can not exceed the limit together
+	lockUserError := lockUserForUpdate(ctx, tx, username)
+	if lockUserError != nil {
+		return nil, lockUserError
 	}
 
-	if !userRecordExists {
-		return nil, fmt.Errorf("provided username does not exist in database")
+	// verify that the user has not reached the number of bikes the plan allows to rent at the same time
+	maxConcurrentBikes, maxConcurrentBikesError := maxConcurrentBikesForUser(ctx, tx, username)
+	if maxConcurrentBikesError != nil {
+		return nil, maxConcurrentBikesError
+	}
+
+	rentedBikes, countReservationsError := countReservationsOfUser(ctx, tx, username)
+	if countReservationsError != nil {
+		return nil, countReservationsError
+	}
+
+	if rentedBikes >= maxConcurrentBikes {
+		if maxConcurrentBikes == 1 {
+			return nil, ConflictError(ERROR_CODE_RENTAL_LIMIT_REACHED, "could not rent bike. User already has a rented bike")
+		}
+		return nil, ConflictError(ERROR_CODE_RENTAL_LIMIT_REACHED, "could not rent bike. User already has %d rented bikes, the plan allows %d", rentedBikes, maxConcurrentBikes)
+	}
+
+	// verify that the ride can be billed as requested
+	billingOrganizationId, resolveBillingError := resolveBillingOrganization(ctx, tx, username, bikeReservationRequest.Billing, bikeReservationRequest.OrganizationId)
+	if resolveBillingError != nil {
+		return nil, resolveBillingError
 	}
 
 	// verify if provided bikeId exists in the database
-	bikeIdExistsInBikeTable, bikeIdExistsInDbError := bikeIdExistsInTable(db, DB_TABLE_BIKE, bikeId)
+	bikeIdExistsInBikeTable, bikeIdExistsInDbError := bikeIdExistsInTable(ctx, tx, DB_TABLE_BIKE, bikeId)
 	if bikeIdExistsInDbError != nil {
 		return nil, bikeIdExistsInDbError
 	}
 
 	if !bikeIdExistsInBikeTable {
-		return nil, fmt.Errorf("provided bikeId does not exist in database")
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
 	}
 
 	// verify if provided bikeId is available for rent
-	bikeIsAvailable, bikeIdExistsInDbError := bikeIsAvailableForRent(db, bikeId)
+	bikeIsAvailable, bikeIdExistsInDbError := bikeIsAvailableForRent(ctx, tx, bikeId)
 	if bikeIdExistsInDbError != nil {
 		return nil, bikeIdExistsInDbError
 	}
 
 	if !bikeIsAvailable {
-		return nil, fmt.Errorf("provided bikeId is not available for rent")
+		return nil, ConflictError(ERROR_CODE_BIKE_NOT_AVAILABLE, "provided bikeId is not available for rent")
+	}
+
+	// get the bike to store its position as start position of the ride
+	bike, getBikeFromDbError := getBikeFromDb(ctx, tx, bikeId)
+	if getBikeFromDbError != nil {
+		return nil, getBikeFromDbError
+	}
+
+	// a bike with a nearly empty battery would stop during the ride
+	criticalPercent := BatteryCriticalPercent()
+	if bike.BatteryPercent.Valid && bike.BatteryPercent.Int64 < int64(criticalPercent) {
+		return nil, ConflictError(ERROR_CODE_BIKE_BATTERY_LOW, "the battery of bike %v is at %d%%, bikes below %d%% can not be reserved. Please choose another bike",
+			bike.Name, bike.BatteryPercent.Int64, criticalPercent)
 	}
 
 	//create reservation by inserting it into reservation table
-	createdReservationId, createReservationRecordErr := createRecordInReservationTable(db, bikeId, username)
+	createdReservationId, createReservationRecordErr := createRecordInReservationTable(ctx, tx, bike, username, billingOrganizationId)
 	if createReservationRecordErr != nil {
-		return nil, fmt.Errorf("could not insert record into reservation Table. %v", createReservationRecordErr)
+		return nil, fmt.Errorf("could not insert record into reservation Table. %w", createReservationRecordErr)
+	}
+
+	reservationCreated := ReservationCreatedEventImpl{ReservationId: *createdReservationId, BikeId: bikeId, Username: username, StartedAt: time.Now(),
+		Latitude: bike.Latitude, Longitude: bike.Longitude}
+	if billingOrganizationId.Valid {
+		reservationCreated.BillingOrganizationId = &billingOrganizationId.String
+	}
+	writeEventError := writeBikeDomainEvent(ctx, tx, DOMAIN_EVENT_RESERVATION_CREATED, bikeId, reservationCreated)
+	if writeEventError != nil {
+		return nil, writeEventError
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not reserve bike %v. %w", bikeId, commitError)
+	}
+
+	// the device of the bike unlocks it for the rider
+	if queueReservationCommand(ctx, db, bikeId, BIKE_COMMAND_UNLOCK, *createdReservationId, username) != nil {
+		commandNotifier.notify(bikeId)
 	}
 
+	publishBikeEvent(ctx, db, BIKE_EVENT_RESERVED, bikeId)
+
 	return createdReservationId, nil
 }
 
-// deletes a Bike reservation in the reservation table for given bikeId
+// deletes a Bike reservation in the reservation table for given bikeId and finishes the ride.
+// only the rider of the reservation or an operator can return the bike.
+// the fare of the ride is computed and the ride is stored in the ride table.
 // there is no need to update the bike table, since database is set to "ON DELETE SET NULL"
-func DeleteBikeReservation(bikeId int) error {
+func DeleteBikeReservation(ctx context.Context, bikeId int, requestedBy string) (*RideImpl, error) {
+
+	if requestedBy == "" {
+		return nil, UnauthorizedError(ERROR_CODE_UNAUTHORIZED, "no username provided. Bike return process failed")
+	}
 
 	// connect to DB
 	db, dbConnectError := SetupDB()
 	if dbConnectError != nil {
-		return dbConnectError
+		return nil, dbConnectError
 	}
-	defer db.Close() // close connection to DB after finishing method.
 
 	// verify if provided bikeId exists in the bike table
-	bikeIdExistsInBikeTable, bikeIdExistsInDbError := bikeIdExistsInTable(db, DB_TABLE_BIKE, bikeId)
+	bikeIdExistsInBikeTable, bikeIdExistsInDbError := bikeIdExistsInTable(ctx, db, DB_TABLE_BIKE, bikeId)
 	if bikeIdExistsInDbError != nil {
-		return bikeIdExistsInDbError
+		return nil, bikeIdExistsInDbError
 	}
 
 	if !bikeIdExistsInBikeTable {
-		return fmt.Errorf("provided bikeId does not exist in database")
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
 	}
 
-	// verify if provided bikeId is available for rent
-	bikeIsAvailable, bikeIsAvailableForRentError := bikeIsAvailableForRent(db, bikeId)
-	if bikeIdExistsInDbError != nil {
-		return bikeIsAvailableForRentError
+	// get the bike, which is needed to finish the ride
+	bike, getBikeFromDbError := getBikeFromDb(ctx, db, bikeId)
+	if getBikeFromDbError != nil {
+		return nil, getBikeFromDbError
+	}
+
+	// if bike has no reservation, there is no reservation to delete
+	if !bike.ReservationId.Valid {
+		return nil, ConflictError(ERROR_CODE_BIKE_NOT_RENTED, "provided bikeId is not rented so there is no reservation to delete")
+	}
+
+	reservation, getReservationError := getReservationFromDb(ctx, db, DB_TABLE_RESERVATION_COLUMN_BIKEID, bikeId)
+	if getReservationError != nil {
+		return nil, getReservationError
+	}
+
+	if reservation.Username != requestedBy {
+		role, getUserRoleError := getUserRoleFromDb(ctx, db, requestedBy)
+		if getUserRoleError != nil {
+			return nil, getUserRoleError
+		}
+		if role != ROLE_OPERATOR && role != ROLE_ADMIN {
+			return nil, ForbiddenError(ERROR_CODE_RESERVATION_NOT_OWNED, "bike %v is not rented by %v", bikeId, requestedBy)
+		}
+	}
+
+	// compute the fare, store the ride and delete the reservation
+	ride, finishRideError := finishRide(ctx, db, reservation, bike)
+	if finishRideError != nil {
+		return nil, finishRideError
+	}
+
+	return ride, nil
+}
+
+/*
+Implementation method to store the position which a bike reports.
+The position is evaluated against the zones, e.g. to tell the bike the speed limit of a slow speed zone.
+*/
+func ReportBikePosition(ctx context.Context, bikeId int, position BikePositionImpl) (*PositionEvaluationImpl, error) {
+
+	validator := fieldValidator{}
+	validator.required("latitude", position.Latitude != nil)
+	validator.required("longitude", position.Longitude != nil)
+	if position.Latitude != nil && position.Longitude != nil {
+		validator.coordinates("latitude", *position.Latitude, "longitude", *position.Longitude)
+	}
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+	latitude, longitude := *position.Latitude, *position.Longitude
+
+	// connect to DB
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	// the position and its event are stored in one transaction
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
 	}
+	defer tx.Rollback() // has no effect after a successful commit
 
-	// if bike is available, there is no reservation to delete
-	if bikeIsAvailable {
-		return fmt.Errorf("provided bikeId is not renteds so there is no reservation to delete")
+	updateStatement := `UPDATE ` + DB_TABLE_BIKE + ` SET ` + DB_TABLE_BIKE_COLUMN_LATITUDE + `=$1, ` + DB_TABLE_BIKE_COLUMN_LONGITUDE + `=$2 WHERE ` + DB_TABLE_BIKE_COLUMN_BIKEID + `=$3;`
+	result, dbUpdateError := tx.ExecContext(ctx, updateStatement, latitude, longitude, bikeId)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not update position of bike %v. %w", bikeId, dbUpdateError)
+	}
+
+	rowsAffected, rowsAffectedError := result.RowsAffected()
+	if rowsAffectedError != nil {
+		return nil, fmt.Errorf("could not update position of bike %v. %w", bikeId, rowsAffectedError)
+	}
+	if rowsAffected == 0 {
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
 	}
 
-	// build the delete statement and delete record from database
-	deleteStatement := getDeleteRowStatement(DB_TABLE_RESERVATION, DB_TABLE_RESERVATION_COLUMN_BIKEID)
-	_, dbDeleteError := db.Exec(deleteStatement, bikeId)
-	if dbDeleteError != nil {
-		return fmt.Errorf("could not delete record into reservation Table. %v", dbDeleteError)
+	releaseError := releaseBikeFromStationIfMoved(ctx, tx, bikeId, latitude, longitude)
+	if releaseError != nil {
+		return nil, releaseError
 	}
 
-	return nil
+	bikeMoved := BikeMovedEventImpl{BikeId: bikeId, Latitude: latitude, Longitude: longitude, Source: BIKE_MOVED_BY_POSITION, MovedAt: time.Now()}
+	writeEventError := writeBikeDomainEvent(ctx, tx, DOMAIN_EVENT_BIKE_MOVED, bikeId, bikeMoved)
+	if writeEventError != nil {
+		return nil, writeEventError
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not update position of bike %v. %w", bikeId, commitError)
+	}
+
+	publishBikeEvent(ctx, db, BIKE_EVENT_UPDATED, bikeId)
+
+	return evaluatePosition(ctx, db, latitude, longitude)
 }
@@ -0,0 +1,469 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DB_TABLE_BIKECOMMAND = "bikecommand"
+
+	// a long poll of a device waits at most this long for a command
+	BIKE_COMMAND_DEFAULT_WAIT = 25 * time.Second
+	BIKE_COMMAND_MAX_WAIT     = 30 * time.Second
+	// a waiting poll also checks the database in this interval, e.g. for commands which another instance of the API queued
+	BIKE_COMMAND_RECHECK_INTERVAL = 2 * time.Second
+	// maximal number of commands which are returned in the history of a bike
+	BIKE_COMMAND_HISTORY_LIMIT = 100
+)
+
+// the columns of the bikecommand table in the order they are scanned by scanBikeCommand
+const bikeCommandColumns = `commandid, bikeid, deviceid, command, status, reservationid, requestedby, createdat, deliveredat, completedat, expiresat, message, latitude, longitude`
+
+// the commands which an operator can send to a bike
+var knownBikeCommands = map[string]bool{
+	BIKE_COMMAND_UNLOCK: true,
+	BIKE_COMMAND_LOCK:   true,
+	BIKE_COMMAND_RING:   true,
+	BIKE_COMMAND_LOCATE: true,
+}
+
+/*
+wakes up the long polls which wait for the commands of a bike.
+every bike has one channel, which is closed when a command is queued and replaced by the next waiting poll
+*/
+type bikeCommandNotifier struct {
+	mutex   sync.Mutex
+	waiting map[int]chan struct{}
+	// closed when the API shuts down, it ends all polls
+	ended    chan struct{}
+	hasEnded bool
+}
+
+var commandNotifier = &bikeCommandNotifier{waiting: map[int]chan struct{}{}, ended: make(chan struct{})}
+
+// returns the channel which is closed when the next command of the bike is queued
+func (notifier *bikeCommandNotifier) wait(bikeId int) <-chan struct{} {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	channel, exists := notifier.waiting[bikeId]
+	if !exists {
+		channel = make(chan struct{})
+		notifier.waiting[bikeId] = channel
+	}
+	return channel
+}
+
+// wakes up the polls which wait for the commands of the bike
+func (notifier *bikeCommandNotifier) notify(bikeId int) {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	if channel, exists := notifier.waiting[bikeId]; exists {
+		close(channel)
+		delete(notifier.waiting, bikeId)
+	}
+}
+
+/*
+ends the waiting long polls of the devices with an empty list, e.g. when the API shuts down, so they do not delay
+the shutdown. A device polls again and reaches another instance of the API
+*/
+func EndBikeCommandPolls() {
+	commandNotifier.mutex.Lock()
+	defer commandNotifier.mutex.Unlock()
+
+	if !commandNotifier.hasEnded {
+		commandNotifier.hasEnded = true
+		close(commandNotifier.ended)
+	}
+}
+
+/*
+Implementation method for operators to send a command to a bike.
+the bike must have an active device, which receives the command with its next poll
+*/
+func SendBikeCommand(ctx context.Context, bikeId int, request BikeCommandRequestImpl, requestedBy string) (*BikeCommandImpl, error) {
+
+	if !knownBikeCommands[request.Command] {
+		validator := fieldValidator{}
+		validator.add("command", "unknown command %v", request.Command)
+		return nil, validator.err()
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	bikeIdExistsInBikeTable, bikeIdExistsInDbError := bikeIdExistsInTable(ctx, db, DB_TABLE_BIKE, bikeId)
+	if bikeIdExistsInDbError != nil {
+		return nil, bikeIdExistsInDbError
+	}
+	if !bikeIdExistsInBikeTable {
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
+	}
+
+	command, queueCommandError := queueBikeCommand(ctx, db, bikeId, request.Command, nil, requestedBy)
+	if queueCommandError != nil {
+		return nil, queueCommandError
+	}
+	if command == nil {
+		return nil, ConflictError(ERROR_CODE_BIKE_WITHOUT_DEVICE, "bike %v has no active device", bikeId)
+	}
+
+	commandNotifier.notify(bikeId)
+	return command, nil
+}
+
+/*
+Implementation method to retrieve the latest commands of a bike, the latest command first
+*/
+func GetBikeCommands(ctx context.Context, bikeId int) (*[]BikeCommandImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	expireError := expireBikeCommands(ctx, db)
+	if expireError != nil {
+		return nil, expireError
+	}
+
+	commands, getCommandsError := getBikeCommandsWhere(ctx, db, `bikeid=$1 ORDER BY commandid DESC LIMIT $2`, bikeId, BIKE_COMMAND_HISTORY_LIMIT)
+	if getCommandsError != nil {
+		return nil, getCommandsError
+	}
+
+	return &commands, nil
+}
+
+/*
+Implementation method to retrieve the commands of a reservation, e.g. to show the rider whether the bike was unlocked.
+only the rider of the reservation and operators can retrieve them
+*/
+func GetReservationCommands(ctx context.Context, reservationId string, username string) (*[]BikeCommandImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	role, getUserRoleError := getUserRoleFromDb(ctx, db, username)
+	if getUserRoleError != nil {
+		return nil, getUserRoleError
+	}
+
+	expireError := expireBikeCommands(ctx, db)
+	if expireError != nil {
+		return nil, expireError
+	}
+
+	condition := `reservationid::text=$1 AND requestedby=$2`
+	arguments := []interface{}{reservationId, username}
+	if role == ROLE_OPERATOR || role == ROLE_ADMIN {
+		condition = `reservationid::text=$1`
+		arguments = arguments[:1]
+	}
+
+	commands, getCommandsError := getBikeCommandsWhere(ctx, db, condition+` ORDER BY commandid`, arguments...)
+	if getCommandsError != nil {
+		return nil, getCommandsError
+	}
+	if len(commands) == 0 {
+		return nil, NotFoundError(ERROR_CODE_RESERVATION_NOT_FOUND, "no commands found for reservation %v", reservationId)
+	}
+
+	return &commands, nil
+}
+
+/*
+Implementation method for the long poll of a device.
+the queued commands of the bike of the device are delivered immediately. Otherwise the poll waits until a command is queued,
+the wait time is over, the context is done, e.g. because the device closed the connection, or the API shuts down. An empty list is returned if no command was queued
+*/
+func PollBikeCommands(ctx context.Context, device *DeviceImpl, poll BikeCommandPollImpl) ([]BikeCommandImpl, error) {
+
+	checkTimeError := checkDeviceRequestTime(poll.SentAt)
+	if checkTimeError != nil {
+		return nil, checkTimeError
+	}
+
+	wait := time.Duration(poll.WaitSeconds) * time.Second
+	if poll.WaitSeconds <= 0 {
+		wait = BIKE_COMMAND_DEFAULT_WAIT
+	}
+	if wait > BIKE_COMMAND_MAX_WAIT {
+		wait = BIKE_COMMAND_MAX_WAIT
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	_, dbUpdateDeviceError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_DEVICE+` SET lastseenat=$1 WHERE deviceid=$2;`, time.Now(), device.DeviceId)
+	if dbUpdateDeviceError != nil {
+		return nil, fmt.Errorf("could not update device %v. %w", device.DeviceId, dbUpdateDeviceError)
+	}
+
+	for {
+		// wait for the notification before the database is checked, so a command which is queued in between is not missed
+		notified := commandNotifier.wait(device.BikeId)
+
+		commands, deliverError := deliverBikeCommands(ctx, db, device)
+		if deliverError != nil {
+			return nil, deliverError
+		}
+		if len(commands) > 0 {
+			return commands, nil
+		}
+
+		select {
+		case <-notified:
+		case <-time.After(BIKE_COMMAND_RECHECK_INTERVAL):
+		case <-deadline.C:
+			return []BikeCommandImpl{}, nil
+		case <-ctx.Done():
+			return []BikeCommandImpl{}, nil
+		case <-commandNotifier.ended:
+			return []BikeCommandImpl{}, nil
+		}
+	}
+}
+
+/*
+Implementation method for a device to acknowledge a delivered command.
+a command which timed out can not be acknowledged anymore
+*/
+func AcknowledgeBikeCommand(ctx context.Context, device *DeviceImpl, commandId int64, ack BikeCommandAckImpl) (*BikeCommandImpl, error) {
+
+	checkTimeError := checkDeviceRequestTime(ack.SentAt)
+	if checkTimeError != nil {
+		return nil, checkTimeError
+	}
+	validator := fieldValidator{}
+	if (ack.Latitude == nil) != (ack.Longitude == nil) {
+		validator.add("latitude", "latitude and longitude must be provided together")
+	} else if ack.Latitude != nil {
+		validator.coordinates("latitude", *ack.Latitude, "longitude", *ack.Longitude)
+	}
+	validator.maxLength("message", ack.Message, MAX_NOTE_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	expireError := expireBikeCommands(ctx, db)
+	if expireError != nil {
+		return nil, expireError
+	}
+
+	commands, getCommandsError := getBikeCommandsWhere(ctx, db, `commandid=$1 AND deviceid=$2`, commandId, device.DeviceId)
+	if getCommandsError != nil {
+		return nil, getCommandsError
+	}
+	if len(commands) == 0 {
+		return nil, NotFoundError(ERROR_CODE_COMMAND_NOT_FOUND, "command %v does not exist", commandId)
+	}
+	command := commands[0]
+	if command.Status != BIKE_COMMAND_DELIVERED {
+		return nil, ConflictError(ERROR_CODE_COMMAND_NOT_PENDING, "command %v is %v and can not be acknowledged", commandId, command.Status)
+	}
+
+	command.Status = BIKE_COMMAND_ACKNOWLEDGED
+	if !ack.Success {
+		command.Status = BIKE_COMMAND_FAILED
+	}
+	completedAt := time.Now()
+	command.CompletedAt = &completedAt
+	command.Message = ack.Message
+	command.Latitude = ack.Latitude
+	command.Longitude = ack.Longitude
+
+	// the status is checked again, the command may have timed out in the meantime
+	result, dbUpdateError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_BIKECOMMAND+` SET status=$1, completedat=$2, message=$3, latitude=$4, longitude=$5
+		WHERE commandid=$6 AND status=$7 AND expiresat>=$2;`, command.Status, command.CompletedAt, command.Message, command.Latitude, command.Longitude,
+		commandId, BIKE_COMMAND_DELIVERED)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not update record in %v Table. %w", DB_TABLE_BIKECOMMAND, dbUpdateError)
+	}
+	updatedRows, rowsAffectedError := result.RowsAffected()
+	if rowsAffectedError != nil {
+		return nil, rowsAffectedError
+	}
+	if updatedRows == 0 {
+		return nil, ConflictError(ERROR_CODE_COMMAND_NOT_PENDING, "command %v timed out and can not be acknowledged", commandId)
+	}
+
+	return &command, nil
+}
+
+/*
+queues a command for the active device of a bike. nil is returned if the bike has no active device.
+an unlock or lock supersedes the unlock and lock commands of the bike which were not delivered yet,
+so a device which was offline does not unlock a bike which was locked again in the meantime
+*/
+func queueBikeCommand(ctx context.Context, db dbQueryer, bikeId int, commandName string, reservationId *string, requestedBy string) (*BikeCommandImpl, error) {
+
+	var deviceId string
+	dbQueryError := db.QueryRowContext(ctx, `SELECT deviceid FROM `+DB_TABLE_DEVICE+` WHERE bikeid=$1 AND active;`, bikeId).Scan(&deviceId)
+	if dbQueryError == sql.ErrNoRows {
+		return nil, nil
+	}
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve device of bike %v. %w", bikeId, dbQueryError)
+	}
+
+	if commandName == BIKE_COMMAND_UNLOCK || commandName == BIKE_COMMAND_LOCK {
+		_, dbUpdateError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_BIKECOMMAND+` SET status=$1, completedat=$2 WHERE bikeid=$3 AND status=$4 AND command IN ($5, $6);`,
+			BIKE_COMMAND_SUPERSEDED, time.Now(), bikeId, BIKE_COMMAND_QUEUED, BIKE_COMMAND_UNLOCK, BIKE_COMMAND_LOCK)
+		if dbUpdateError != nil {
+			return nil, fmt.Errorf("could not supersede commands of bike %v. %w", bikeId, dbUpdateError)
+		}
+	}
+
+	command := BikeCommandImpl{
+		BikeId:        bikeId,
+		DeviceId:      deviceId,
+		Command:       commandName,
+		Status:        BIKE_COMMAND_QUEUED,
+		ReservationId: reservationId,
+		RequestedBy:   requestedBy,
+		CreatedAt:     time.Now(),
+	}
+	command.ExpiresAt = command.CreatedAt.Add(BikeCommandTimeout())
+
+	insertStatement := getInsertStmt(DB_TABLE_BIKECOMMAND, "bikeid", "deviceid", "command", "status", "reservationid", "requestedby", "createdat", "expiresat")
+	dbInsertError := db.QueryRowContext(ctx, insertStatement+` RETURNING commandid`, command.BikeId, command.DeviceId, command.Command, command.Status,
+		command.ReservationId, command.RequestedBy, command.CreatedAt, command.ExpiresAt).Scan(&command.CommandId)
+	if dbInsertError != nil {
+		return nil, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_BIKECOMMAND, dbInsertError)
+	}
+
+	return &command, nil
+}
+
+/*
+queues the unlock or lock of a reservation. A failure does not stop the reservation, the rider can still unlock the bike
+with its key and an operator can send the command again
+*/
+func queueReservationCommand(ctx context.Context, db dbQueryer, bikeId int, commandName string, reservationId string, username string) *BikeCommandImpl {
+	command, queueCommandError := queueBikeCommand(ctx, db, bikeId, commandName, &reservationId, username)
+	if queueCommandError != nil {
+		fmt.Printf("WARNING! could not queue %v of bike %v for reservation %v. %v\n", commandName, bikeId, reservationId, queueCommandError)
+		return nil
+	}
+	return command
+}
+
+// marks the commands which were not acknowledged in time as timed out
+func expireBikeCommands(ctx context.Context, db dbQueryer) error {
+	_, dbUpdateError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_BIKECOMMAND+` SET status=$1, completedat=expiresat WHERE status IN ($2, $3) AND expiresat<$4;`,
+		BIKE_COMMAND_TIMED_OUT, BIKE_COMMAND_QUEUED, BIKE_COMMAND_DELIVERED, time.Now())
+	if dbUpdateError != nil {
+		return fmt.Errorf("could not expire commands. %w", dbUpdateError)
+	}
+	return nil
+}
+
+// marks the queued commands of the bike of a device as delivered and returns them in the order they were queued
+func deliverBikeCommands(ctx context.Context, db dbQueryer, device *DeviceImpl) ([]BikeCommandImpl, error) {
+
+	expireError := expireBikeCommands(ctx, db)
+	if expireError != nil {
+		return nil, expireError
+	}
+
+	rows, dbUpdateError := db.QueryContext(ctx, `UPDATE `+DB_TABLE_BIKECOMMAND+` SET status=$1, deliveredat=$2 WHERE bikeid=$3 AND deviceid=$4 AND status=$5
+		RETURNING `+bikeCommandColumns+`;`, BIKE_COMMAND_DELIVERED, time.Now(), device.BikeId, device.DeviceId, BIKE_COMMAND_QUEUED)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not deliver commands of bike %v. %w", device.BikeId, dbUpdateError)
+	}
+	defer rows.Close()
+
+	commands := []BikeCommandImpl{}
+	for rows.Next() {
+		command, scanError := scanBikeCommand(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		commands = append(commands, *command)
+	}
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].CommandId < commands[j].CommandId })
+	return commands, nil
+}
+
+// rejects requests of devices which were sent too long ago or in the future, so recorded requests can not be replayed
+func checkDeviceRequestTime(sentAt time.Time) error {
+	validator := fieldValidator{}
+	if !validator.required("sentAt", !sentAt.IsZero()) {
+		return validator.err()
+	}
+	if age := time.Since(sentAt); age > TELEMETRY_MAX_CLOCK_SKEW || age < -TELEMETRY_MAX_CLOCK_SKEW {
+		validator.add("sentAt", "must be within %v of the time of the server", TELEMETRY_MAX_CLOCK_SKEW)
+	}
+	return validator.err()
+}
+
+// returns the commands which match the condition, e.g. `bikeid=$1 ORDER BY commandid`
+func getBikeCommandsWhere(ctx context.Context, db dbQueryer, condition string, arguments ...interface{}) ([]BikeCommandImpl, error) {
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+bikeCommandColumns+` FROM `+DB_TABLE_BIKECOMMAND+` WHERE `+condition+`;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_BIKECOMMAND, dbQueryError)
+	}
+	defer rows.Close()
+
+	commands := []BikeCommandImpl{}
+	for rows.Next() {
+		command, scanError := scanBikeCommand(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		commands = append(commands, *command)
+	}
+	return commands, nil
+}
+
+// scans a row of the bikecommand table, the columns are selected with bikeCommandColumns
+func scanBikeCommand(rows *sql.Rows) (*BikeCommandImpl, error) {
+	command := BikeCommandImpl{}
+	var reservationId sql.NullString
+	var deliveredAt, completedAt sql.NullTime
+	var latitude, longitude sql.NullFloat64
+	scanError := rows.Scan(&command.CommandId, &command.BikeId, &command.DeviceId, &command.Command, &command.Status, &reservationId,
+		&command.RequestedBy, &command.CreatedAt, &deliveredAt, &completedAt, &command.ExpiresAt, &command.Message, &latitude, &longitude)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into BikeCommand Object. %w", DB_TABLE_BIKECOMMAND, scanError)
+	}
+	if reservationId.Valid {
+		command.ReservationId = &reservationId.String
+	}
+	if deliveredAt.Valid {
+		command.DeliveredAt = &deliveredAt.Time
+	}
+	if completedAt.Valid {
+		command.CompletedAt = &completedAt.Time
+	}
+	if latitude.Valid && longitude.Valid {
+		command.Latitude = &latitude.Float64
+		command.Longitude = &longitude.Float64
+	}
+	return &command, nil
+}
@@ -0,0 +1,73 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- commands which are sent to the device of a bike ---------
+	BIKE_COMMAND_UNLOCK = "unlock"
+	BIKE_COMMAND_LOCK   = "lock"
+	BIKE_COMMAND_RING   = "ring"
+	BIKE_COMMAND_LOCATE = "locate"
+
+	// ---------- status of commands ---------
+	// queued until the device polls, delivered until the device acknowledges it
+	BIKE_COMMAND_QUEUED       = "queued"
+	BIKE_COMMAND_DELIVERED    = "delivered"
+	BIKE_COMMAND_ACKNOWLEDGED = "acknowledged"
+	BIKE_COMMAND_FAILED       = "failed"
+	BIKE_COMMAND_TIMED_OUT    = "timed_out"
+	// a queued unlock or lock is superseded by a later unlock or lock of the same bike
+	BIKE_COMMAND_SUPERSEDED = "superseded"
+)
+
+/*
+represents the database structure for the table "bikecommand" in the DATABASE.
+a command is queued for the device of a bike, delivered when the device polls and completed when the device acknowledges it.
+a command which is not acknowledged before expiresAt times out.
+commands of a reservation are queued by the API, e.g. unlock when a bike is reserved
+*/
+type BikeCommandImpl struct {
+	CommandId     int64      `json:"commandId"`
+	BikeId        int        `json:"bikeId"`
+	DeviceId      string     `json:"deviceId"`
+	Command       string     `json:"command"`
+	Status        string     `json:"status"`
+	ReservationId *string    `json:"reservationId"`
+	RequestedBy   string     `json:"requestedBy"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DeliveredAt   *time.Time `json:"deliveredAt"`
+	CompletedAt   *time.Time `json:"completedAt"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	Message       string     `json:"message"`
+	// the position which the device reported with the acknowledgement, e.g. of a locate command
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+/*
+represents the request of an operator to send a command to a bike
+*/
+type BikeCommandRequestImpl struct {
+	Command string `json:"command"`
+}
+
+/*
+represents the long-poll request of a device for its commands.
+sentAt is signed with the request, so a recorded poll can not be replayed later
+*/
+type BikeCommandPollImpl struct {
+	SentAt      time.Time `json:"sentAt"`
+	WaitSeconds int       `json:"waitSeconds"`
+}
+
+/*
+represents the acknowledgement of a command by the device.
+a device which could not execute the command acknowledges it with success false and the reason in the message
+*/
+type BikeCommandAckImpl struct {
+	SentAt    time.Time `json:"sentAt"`
+	Success   bool      `json:"success"`
+	Message   string    `json:"message"`
+	Latitude  *float64  `json:"latitude"`
+	Longitude *float64  `json:"longitude"`
+}
@@ -1,6 +1,9 @@
 package implementation
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 /*
 represents the database structure for the table "bike" in the DATABASE.
@@ -14,6 +17,18 @@ type BikeImpl struct {
 	Latitude      string         `json:"latitude"`
 	Longitude     string         `json:"longitude"`
 	ReservationId sql.NullString `json:"reservationId"`
+	Status        string         `json:"status"`    // the operational status, see BIKE_STATUS_AVAILABLE
+	StationId     sql.NullInt64  `json:"stationId"` // the docking station the bike is parked at, invalid for free-floating bikes
+	// the state which the bike reported with its last telemetry, invalid before the first report
+	BatteryPercent   sql.NullInt64   `json:"batteryPercent"`
+	EstimatedRangeKm sql.NullFloat64 `json:"estimatedRangeKm"`
+	FullRangeKm      float64         `json:"fullRangeKm"` // range of the bike with a full battery, used to estimate the range
+	Locked           sql.NullBool    `json:"locked"`
+	OdometerMeters   int64           `json:"odometerMeters"`
+	LastTelemetryAt  sql.NullTime    `json:"lastTelemetryAt"`
+	// the bike type selects the maintenance plans of the bike
+	BikeType           string `json:"bikeType"`
+	MaintenanceDueSoon bool   `json:"maintenanceDueSoon"` // set by the maintenance scheduler
 }
 
 /*
@@ -21,9 +36,43 @@ represents the database structure for the reservation table.
 the reservationId is an uuid which can be null
 Since the "Scan" method of the postgresql does not allow parsing null string values,
 we use the sql.Nullstring datatype.
+Billing is "personal" (default) or "company". For company billing the organizationId can be provided,
+if the user is a member of more than one organization.
 */
 type BikeReservationImpl struct {
-	ReservationId sql.NullString `json:"reservationId"`
-	BikeId        int            `json:"bikeid"`
-	Username      string         `json:"username"`
+	ReservationId  sql.NullString `json:"reservationId"`
+	BikeId         *int           `json:"bikeid"` // a pointer, since 0 is a bike and a missing bikeId is not
+	Username       string         `json:"username"`
+	Billing        string         `json:"billing"`
+	OrganizationId string         `json:"organizationId"`
+}
+
+/*
+represents a position which is reported by a bike.
+the coordinates are pointers, since 0 is a valid coordinate and a missing coordinate is not
+*/
+type BikePositionImpl struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+/*
+represents the database structure for the table "bikestatuschange" in the DATABASE, the history of the status of a bike
+*/
+type BikeStatusChangeImpl struct {
+	ChangeId   int64     `json:"changeId"`
+	BikeId     int       `json:"bikeId"`
+	FromStatus string    `json:"fromStatus"`
+	ToStatus   string    `json:"toStatus"`
+	Reason     string    `json:"reason"`
+	ChangedBy  string    `json:"changedBy"`
+	ChangedAt  time.Time `json:"changedAt"`
+}
+
+/*
+represents a request of an operator to change the status of a bike
+*/
+type BikeStatusRequestImpl struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
 }
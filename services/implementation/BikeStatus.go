@@ -0,0 +1,228 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// ---------- operational status of a bike ---------
+	BIKE_STATUS_AVAILABLE      = "available"
+	BIKE_STATUS_RESERVED       = "reserved"
+	BIKE_STATUS_IN_USE         = "in_use"
+	BIKE_STATUS_LOW_BATTERY    = "low_battery"
+	BIKE_STATUS_MAINTENANCE    = "maintenance"
+	BIKE_STATUS_OUT_OF_SERVICE = "out_of_service"
+	BIKE_STATUS_MISSING        = "missing"
+	BIKE_STATUS_RETIRED        = "retired"
+
+	// status changes which are not made by a user, e.g. because of telemetry
+	BIKE_STATUS_CHANGED_BY_SYSTEM = "system"
+
+	DB_TABLE_BIKE_COLUMN_STATUS = "status"
+	DB_TABLE_BIKESTATUSCHANGE   = "bikestatuschange"
+)
+
+/*
+the status which a bike can change to from a status.
+reserved and in_use are only set by reservations, a bike can not be reserved by an operator
+*/
+var allowedBikeStatusTransitions = map[string][]string{
+	BIKE_STATUS_AVAILABLE:      {BIKE_STATUS_RESERVED, BIKE_STATUS_LOW_BATTERY, BIKE_STATUS_MAINTENANCE, BIKE_STATUS_OUT_OF_SERVICE, BIKE_STATUS_MISSING, BIKE_STATUS_RETIRED},
+	BIKE_STATUS_RESERVED:       {BIKE_STATUS_IN_USE, BIKE_STATUS_AVAILABLE, BIKE_STATUS_LOW_BATTERY, BIKE_STATUS_MISSING},
+	BIKE_STATUS_IN_USE:         {BIKE_STATUS_AVAILABLE, BIKE_STATUS_LOW_BATTERY, BIKE_STATUS_MISSING},
+	BIKE_STATUS_LOW_BATTERY:    {BIKE_STATUS_AVAILABLE, BIKE_STATUS_MAINTENANCE, BIKE_STATUS_OUT_OF_SERVICE, BIKE_STATUS_MISSING, BIKE_STATUS_RETIRED},
+	BIKE_STATUS_MAINTENANCE:    {BIKE_STATUS_AVAILABLE, BIKE_STATUS_OUT_OF_SERVICE, BIKE_STATUS_MISSING, BIKE_STATUS_RETIRED},
+	BIKE_STATUS_OUT_OF_SERVICE: {BIKE_STATUS_AVAILABLE, BIKE_STATUS_MAINTENANCE, BIKE_STATUS_MISSING, BIKE_STATUS_RETIRED},
+	BIKE_STATUS_MISSING:        {BIKE_STATUS_AVAILABLE, BIKE_STATUS_MAINTENANCE, BIKE_STATUS_OUT_OF_SERVICE, BIKE_STATUS_RETIRED},
+	BIKE_STATUS_RETIRED:        {},
+}
+
+// the columns of the bikestatuschange table in the order they are scanned by scanBikeStatusChange
+const bikeStatusChangeColumns = `changeid, bikeid, fromstatus, tostatus, reason, changedby, changedat`
+
+/*
+Implementation method for operators to change the operational status of a bike.
+the reason is required and stored in the status history of the bike
+*/
+func ChangeBikeStatus(ctx context.Context, bikeId int, status string, reason string, changedBy string) (*BikeStatusChangeImpl, error) {
+
+	validator := fieldValidator{}
+	if _, isKnownStatus := allowedBikeStatusTransitions[status]; !isKnownStatus {
+		validator.add("status", "unknown status %v", status)
+	} else if status == BIKE_STATUS_RESERVED || status == BIKE_STATUS_IN_USE {
+		validator.add("status", "the status %v is set by reservations and can not be set by an operator", status)
+	}
+	// a reason is required to change the status of a bike
+	validator.requiredText("reason", reason, MAX_NOTE_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	var currentStatus string
+	dbQueryError := tx.QueryRowContext(ctx, `SELECT `+DB_TABLE_BIKE_COLUMN_STATUS+` FROM `+DB_TABLE_BIKE+` WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$1 FOR UPDATE;`, bikeId).Scan(&currentStatus)
+	if dbQueryError == sql.ErrNoRows {
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
+	}
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve status of bike %v. %w", bikeId, dbQueryError)
+	}
+
+	change, transitionError := transitionBikeStatus(ctx, tx, bikeId, currentStatus, status, reason, changedBy)
+	if transitionError != nil {
+		return nil, transitionError
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not change status of bike %v. %w", bikeId, commitError)
+	}
+
+	publishBikeEvent(ctx, db, BIKE_EVENT_UPDATED, bikeId)
+
+	return change, nil
+}
+
+/*
+Implementation method to retrieve the status history of a bike, the latest change first
+*/
+func GetBikeStatusHistory(ctx context.Context, bikeId int) (*[]BikeStatusChangeImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	bikeIdExistsInBikeTable, bikeIdExistsInDbError := bikeIdExistsInTable(ctx, db, DB_TABLE_BIKE, bikeId)
+	if bikeIdExistsInDbError != nil {
+		return nil, bikeIdExistsInDbError
+	}
+	if !bikeIdExistsInBikeTable {
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
+	}
+
+	queryString := `SELECT ` + bikeStatusChangeColumns + ` FROM ` + DB_TABLE_BIKESTATUSCHANGE + ` WHERE bikeid=$1 ORDER BY changedat DESC, changeid DESC;`
+	rows, dbQueryError := db.QueryContext(ctx, queryString, bikeId)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve status history of bike %v. %w", bikeId, dbQueryError)
+	}
+	defer rows.Close()
+
+	history := []BikeStatusChangeImpl{}
+	for rows.Next() {
+		change, scanError := scanBikeStatusChange(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		history = append(history, *change)
+	}
+
+	return &history, nil
+}
+
+/*
+changes the status of a bike and records the change in the status history.
+the bike is only changed if it still has the expected status, so a concurrent change is not overwritten
+*/
+func transitionBikeStatus(ctx context.Context, db dbQueryer, bikeId int, fromStatus string, toStatus string, reason string, changedBy string) (*BikeStatusChangeImpl, error) {
+
+	if !bikeStatusTransitionAllowed(fromStatus, toStatus) {
+		return nil, ConflictError(ERROR_CODE_STATUS_TRANSITION_NOT_ALLOWED, "the status of bike %v can not change from %v to %v", bikeId, fromStatus, toStatus)
+	}
+
+	updateStatement := `UPDATE ` + DB_TABLE_BIKE + ` SET ` + DB_TABLE_BIKE_COLUMN_STATUS + `=$1 WHERE ` + DB_TABLE_BIKE_COLUMN_BIKEID + `=$2 AND ` +
+		DB_TABLE_BIKE_COLUMN_STATUS + `=$3;`
+	result, dbUpdateError := db.ExecContext(ctx, updateStatement, toStatus, bikeId, fromStatus)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not change status of bike %v. %w", bikeId, dbUpdateError)
+	}
+
+	rowsAffected, rowsAffectedError := result.RowsAffected()
+	if rowsAffectedError != nil {
+		return nil, fmt.Errorf("could not change status of bike %v. %w", bikeId, rowsAffectedError)
+	}
+	if rowsAffected == 0 {
+		return nil, ConflictError(ERROR_CODE_CONCURRENT_CHANGE, "the status of bike %v is no longer %v. Please try again", bikeId, fromStatus)
+	}
+
+	// a bike which is parked again, e.g. after a ride or when it was found, is watched from its current position
+	if bikeIsParked(toStatus) && !bikeIsParked(fromStatus) {
+		_, dbUpdateError = db.ExecContext(ctx, `UPDATE `+DB_TABLE_BIKE+` SET parkedlatitude=latitude, parkedlongitude=longitude WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$1;`, bikeId)
+		if dbUpdateError != nil {
+			return nil, fmt.Errorf("could not store parked position of bike %v. %w", bikeId, dbUpdateError)
+		}
+	}
+
+	change := BikeStatusChangeImpl{
+		BikeId:     bikeId,
+		FromStatus: fromStatus,
+		ToStatus:   toStatus,
+		Reason:     reason,
+		ChangedBy:  changedBy,
+		ChangedAt:  time.Now(),
+	}
+
+	insertStatement := getInsertStmt(DB_TABLE_BIKESTATUSCHANGE, "bikeid", "fromstatus", "tostatus", "reason", "changedby", "changedat")
+	dbInsertError := db.QueryRowContext(ctx, insertStatement+` RETURNING changeid`, change.BikeId, change.FromStatus, change.ToStatus,
+		change.Reason, change.ChangedBy, change.ChangedAt).Scan(&change.ChangeId)
+	if dbInsertError != nil {
+		return nil, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_BIKESTATUSCHANGE, dbInsertError)
+	}
+
+	writeEventError := writeBikeDomainEvent(ctx, db, DOMAIN_EVENT_BIKE_STATUS_CHANGED, bikeId, change)
+	if writeEventError != nil {
+		return nil, writeEventError
+	}
+
+	return &change, nil
+}
+
+// returns true, if a bike can change from one status to the other
+func bikeStatusTransitionAllowed(fromStatus string, toStatus string) bool {
+	for _, allowedStatus := range allowedBikeStatusTransitions[fromStatus] {
+		if allowedStatus == toStatus {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+returns the status which follows from the battery of a bike which reported telemetry.
+an available bike below the critical battery level gets low_battery and returns to available after it was charged.
+the status of bikes which are rented or taken out of service by an operator is not changed
+*/
+func bikeStatusForBattery(currentStatus string, batteryPercent int) string {
+	criticalPercent := BatteryCriticalPercent()
+	if currentStatus == BIKE_STATUS_AVAILABLE && batteryPercent < criticalPercent {
+		return BIKE_STATUS_LOW_BATTERY
+	}
+	if currentStatus == BIKE_STATUS_LOW_BATTERY && batteryPercent >= criticalPercent {
+		return BIKE_STATUS_AVAILABLE
+	}
+	return currentStatus
+}
+
+// scans a row of the bikestatuschange table, the columns are selected with bikeStatusChangeColumns
+func scanBikeStatusChange(rows *sql.Rows) (*BikeStatusChangeImpl, error) {
+	change := BikeStatusChangeImpl{}
+	scanError := rows.Scan(&change.ChangeId, &change.BikeId, &change.FromStatus, &change.ToStatus, &change.Reason, &change.ChangedBy, &change.ChangedAt)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into BikeStatusChange Object. %w", DB_TABLE_BIKESTATUSCHANGE, scanError)
+	}
+	return &change, nil
+}
@@ -0,0 +1,319 @@
+package implementation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the number of locks which serialise the events of the bikes, the events of bikes with the same lock wait for each other
+const BIKE_STREAM_LOCK_STRIPES = 64
+
+/*
+the bike stream pushes the changes of bikes to the connected clients, so they do not need to poll GET /bikes/.
+the stream lives in the memory of the API: the latest events are kept in a history, so a client which lost the
+connection can resume with the id of the last event it received. A client which can not resume gets a snapshot first.
+every client has a bounded buffer, a client which is too slow to read its events is disconnected and has to resume
+*/
+type bikeStreamHub struct {
+	mutex sync.Mutex
+	// the start of the API is part of the event ids, so the ids of an earlier run are not resumed
+	epoch       int64
+	sequence    int64
+	history     []BikeEventImpl // the latest events, the oldest first
+	subscribers map[*BikeStreamSubscriptionImpl]bool
+	// set when the API shuts down, a new subscription is closed immediately
+	closed bool
+	// serialise reading and publishing the state of a bike, the lock of a bike is bikeLocks[bikeId % BIKE_STREAM_LOCK_STRIPES]
+	bikeLocks [BIKE_STREAM_LOCK_STRIPES]sync.Mutex
+}
+
+var bikeStream = &bikeStreamHub{epoch: time.Now().Unix(), subscribers: map[*BikeStreamSubscriptionImpl]bool{}}
+
+/*
+represents a client of the bike stream. The events are received from Events() until the subscription is closed
+*/
+type BikeStreamSubscriptionImpl struct {
+	events chan BikeEventImpl
+	bounds *BoundingBoxImpl
+	// the bikes which are in the bounding box for the client. Bikes which are not in the map are visible if
+	// defaultVisible is true, which is the case after a resume, since the client knows bikes from before
+	visible        map[int]bool
+	defaultVisible bool
+}
+
+/*
+Implementation method to subscribe to the bike stream.
+bounds limits the stream to the bikes in the bounding box, nil subscribes to all bikes.
+if the events after lastEventId are still in the history, they are queued for the subscription and no snapshot
+is returned. Otherwise the snapshot of the bikes is returned, which the client has to receive before the events.
+the events which happen while the snapshot is built are received as well, since the state of a bike in an event
+replaces the state of the snapshot this is no problem. The caller has to close the subscription
+*/
+func SubscribeBikeStream(ctx context.Context, bounds *BoundingBoxImpl, lastEventId string) (*BikeStreamSubscriptionImpl, *BikeEventImpl, error) {
+
+	if bounds != nil {
+		boundsError := validateBoundingBox(*bounds)
+		if boundsError != nil {
+			return nil, nil, boundsError
+		}
+	}
+
+	subscription := &BikeStreamSubscriptionImpl{
+		events:         make(chan BikeEventImpl, BikeStreamBufferSize()),
+		bounds:         bounds,
+		visible:        map[int]bool{},
+		defaultVisible: true,
+	}
+
+	bikeStream.mutex.Lock()
+	if bikeStream.closed {
+		close(subscription.events)
+		bikeStream.mutex.Unlock()
+		return subscription, nil, nil
+	}
+	resumed := bikeStream.resume(subscription, lastEventId)
+	snapshotEventId := bikeStream.eventId(bikeStream.sequence)
+	bikeStream.subscribers[subscription] = true
+	bikeStream.mutex.Unlock()
+
+	if resumed {
+		return subscription, nil, nil
+	}
+
+	snapshot, snapshotError := getBikeStreamSnapshot(ctx, bounds, snapshotEventId)
+	if snapshotError != nil {
+		subscription.Close()
+		return nil, nil, snapshotError
+	}
+
+	// from now on only the bikes of the snapshot and the bikes which moved into the bounding box are visible
+	bikeStream.mutex.Lock()
+	for _, bike := range *snapshot.Bikes {
+		subscription.visible[bike.BikeId] = true
+	}
+	subscription.defaultVisible = false
+	bikeStream.mutex.Unlock()
+
+	return subscription, snapshot, nil
+}
+
+// returns the channel of the events. It is closed when the subscription is closed or the client did not read its events in time
+func (subscription *BikeStreamSubscriptionImpl) Events() <-chan BikeEventImpl {
+	return subscription.events
+}
+
+// closes the subscription, it can be called more than once
+func (subscription *BikeStreamSubscriptionImpl) Close() {
+	bikeStream.mutex.Lock()
+	defer bikeStream.mutex.Unlock()
+
+	bikeStream.unsubscribe(subscription)
+}
+
+/*
+closes the subscriptions of all clients of the bike stream, e.g. when the API shuts down, so the streams end
+instead of delaying the shutdown. The clients resume with the id of the last event when they reconnect
+*/
+func CloseBikeStreams() {
+	bikeStream.mutex.Lock()
+	defer bikeStream.mutex.Unlock()
+
+	bikeStream.closed = true
+	for subscription := range bikeStream.subscribers {
+		bikeStream.unsubscribe(subscription)
+	}
+}
+
+/*
+publishes the current state of a bike to the bike stream. It is called after the change of the bike was committed,
+so a failure is only reported and does not fail the change.
+the state is read and published while the bike is locked, so the events of concurrent changes of a bike are published
+in the order their states were read and the latest event always contains the latest committed state
+*/
+func publishBikeEvent(ctx context.Context, db dbQueryer, eventType string, bikeId int) {
+	bikeLock := &bikeStream.bikeLocks[uint(bikeId)%BIKE_STREAM_LOCK_STRIPES]
+	bikeLock.Lock()
+	defer bikeLock.Unlock()
+
+	bike, getBikeFromDbError := getBikeFromDb(ctx, db, bikeId)
+	if getBikeFromDbError == nil && bike.BikeId == 0 {
+		getBikeFromDbError = fmt.Errorf("bike does not exist")
+	}
+	if getBikeFromDbError != nil {
+		fmt.Printf("WARNING! could not publish %v of bike %v. %v\n", eventType, bikeId, getBikeFromDbError)
+		return
+	}
+
+	streamBike, transformError := transformBikeToStreamBike(bike)
+	if transformError != nil {
+		fmt.Printf("WARNING! could not publish %v of bike %v. %v\n", eventType, bikeId, transformError)
+		return
+	}
+
+	bikeStream.publish(eventType, streamBike)
+}
+
+// adds an event to the history and sends it to the subscribers, a subscriber with a full buffer is disconnected
+func (hub *bikeStreamHub) publish(eventType string, bike BikeStreamBikeImpl) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.sequence++
+	event := BikeEventImpl{EventId: hub.eventId(hub.sequence), Type: eventType, OccurredAt: time.Now(), Bike: &bike}
+
+	hub.history = append(hub.history, event)
+	if historySize := BikeStreamHistorySize(); len(hub.history) > historySize {
+		hub.history = hub.history[len(hub.history)-historySize:]
+	}
+
+	for subscription := range hub.subscribers {
+		if !subscription.accepts(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			// the client is too slow, it resumes with the last event it received when it reconnects
+			hub.unsubscribe(subscription)
+		}
+	}
+}
+
+/*
+queues the events after lastEventId for the subscription. Returns false if the client can not resume,
+e.g. because the events are not in the history anymore or they would not fit into the buffer.
+the mutex of the hub must be locked
+*/
+func (hub *bikeStreamHub) resume(subscription *BikeStreamSubscriptionImpl, lastEventId string) bool {
+	epochPart, sequencePart, isEventId := strings.Cut(lastEventId, "-")
+	if !isEventId {
+		return false
+	}
+	epoch, parseEpochError := strconv.ParseInt(epochPart, 10, 64)
+	sequence, parseSequenceError := strconv.ParseInt(sequencePart, 10, 64)
+	if parseEpochError != nil || parseSequenceError != nil || epoch != hub.epoch || sequence < 0 || sequence > hub.sequence {
+		return false
+	}
+
+	// the history contains the events up to the current sequence
+	missed := int(hub.sequence - sequence)
+	if missed > len(hub.history) || missed > cap(subscription.events) {
+		return false
+	}
+	for _, event := range hub.history[len(hub.history)-missed:] {
+		if subscription.accepts(event) {
+			subscription.events <- event
+		}
+	}
+	return true
+}
+
+// removes a subscription from the hub and closes its channel. The mutex of the hub must be locked
+func (hub *bikeStreamHub) unsubscribe(subscription *BikeStreamSubscriptionImpl) {
+	if !hub.subscribers[subscription] {
+		return
+	}
+	delete(hub.subscribers, subscription)
+	close(subscription.events)
+}
+
+// returns the id of the event with the sequence
+func (hub *bikeStreamHub) eventId(sequence int64) string {
+	return fmt.Sprintf("%d-%d", hub.epoch, sequence)
+}
+
+/*
+returns true if the event has to be sent to the subscriber: the bike is in the bounding box or it just left it.
+the mutex of the hub must be locked
+*/
+func (subscription *BikeStreamSubscriptionImpl) accepts(event BikeEventImpl) bool {
+	if subscription.bounds == nil || event.Bike == nil {
+		return true
+	}
+
+	isInside := subscription.bounds.contains(event.Bike.Latitude, event.Bike.Longitude)
+	wasVisible, isKnown := subscription.visible[event.Bike.BikeId]
+	if !isKnown {
+		wasVisible = subscription.defaultVisible
+	}
+	subscription.visible[event.Bike.BikeId] = isInside
+
+	return isInside || wasVisible
+}
+
+/*
+returns the snapshot of the bikes in the bounding box with the id of the last event before it.
+like GET /bikes/ it contains the bikes which can be rented and the rented bikes
+*/
+func getBikeStreamSnapshot(ctx context.Context, bounds *BoundingBoxImpl, eventId string) (*BikeEventImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	rows, getAllBikesError := getAllBikesFromDb(ctx, db, 0, false)
+	if getAllBikesError != nil {
+		return nil, getAllBikesError
+	}
+	defer rows.Close()
+
+	bikes := []BikeStreamBikeImpl{}
+	for rows.Next() {
+		bike, scanError := scanBike(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		streamBike, transformError := transformBikeToStreamBike(bike)
+		if transformError != nil {
+			return nil, transformError
+		}
+		if bounds == nil || bounds.contains(streamBike.Latitude, streamBike.Longitude) {
+			bikes = append(bikes, streamBike)
+		}
+	}
+
+	return &BikeEventImpl{EventId: eventId, Type: BIKE_EVENT_SNAPSHOT, OccurredAt: time.Now(), Bikes: &bikes}, nil
+}
+
+// transforms a bike of the database into the state of the bike in the stream
+func transformBikeToStreamBike(bike *BikeImpl) (BikeStreamBikeImpl, error) {
+	latitude, longitude, parseError := parseCoordinates(bike.Latitude, bike.Longitude)
+	if parseError != nil {
+		return BikeStreamBikeImpl{}, parseError
+	}
+
+	streamBike := BikeStreamBikeImpl{
+		BikeId:    bike.BikeId,
+		Name:      bike.Name,
+		Latitude:  latitude,
+		Longitude: longitude,
+		Rented:    bike.ReservationId.Valid,
+		Status:    bike.Status,
+	}
+	if bike.StationId.Valid {
+		streamBike.StationId = &bike.StationId.Int64
+	}
+	if bike.BatteryPercent.Valid {
+		streamBike.BatteryPercent = &bike.BatteryPercent.Int64
+	}
+	if bike.EstimatedRangeKm.Valid {
+		streamBike.EstimatedRangeKm = &bike.EstimatedRangeKm.Float64
+	}
+	return streamBike, nil
+}
+
+// verifies that the corners of a bounding box are valid coordinates and the minimum is not above the maximum
+func validateBoundingBox(bounds BoundingBoxImpl) error {
+	validator := fieldValidator{}
+	validator.coordinates("bbox.minLatitude", bounds.MinLatitude, "bbox.minLongitude", bounds.MinLongitude)
+	validator.coordinates("bbox.maxLatitude", bounds.MaxLatitude, "bbox.maxLongitude", bounds.MaxLongitude)
+	if bounds.MinLatitude > bounds.MaxLatitude || bounds.MinLongitude > bounds.MaxLongitude {
+		validator.add("bbox", "the minimum of the bounding box must not be above its maximum")
+	}
+	return validator.err()
+}
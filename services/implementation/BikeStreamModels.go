@@ -0,0 +1,46 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- types of the events of the bike stream ---------
+	// the state of all bikes in the bounding box, sent when a client connects and can not resume
+	BIKE_EVENT_SNAPSHOT = "snapshot"
+	// a bike was added to the fleet. There is no endpoint to add bikes yet, so it is reserved for it
+	BIKE_EVENT_CREATED = "bike.created"
+	// the position, the status or the battery of a bike changed
+	BIKE_EVENT_UPDATED = "bike.updated"
+	// a bike was rented
+	BIKE_EVENT_RESERVED = "bike.reserved"
+	// the ride with a bike was finished
+	BIKE_EVENT_RELEASED = "bike.released"
+)
+
+/*
+represents the state of a bike in the bike stream, it has the same values as the response of GET /bikes/
+*/
+type BikeStreamBikeImpl struct {
+	BikeId    int     `json:"bikeId"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Rented    bool    `json:"rented"`
+	Status    string  `json:"status"`
+	StationId *int64  `json:"stationId,omitempty"`
+	// the battery is unknown until the bike reported telemetry
+	BatteryPercent   *int64   `json:"batteryPercent"`
+	EstimatedRangeKm *float64 `json:"estimatedRangeKm"`
+}
+
+/*
+represents an event of the bike stream. An event of a bike contains the full state of the bike, so a client can
+replace the bike it knows. The snapshot contains all bikes in the bounding box of the client.
+a bike which left the bounding box is sent once more with its new position, so the client can remove it
+*/
+type BikeEventImpl struct {
+	EventId    string                `json:"eventId"`
+	Type       string                `json:"type"`
+	OccurredAt time.Time             `json:"occurredAt"`
+	Bike       *BikeStreamBikeImpl   `json:"bike,omitempty"`
+	Bikes      *[]BikeStreamBikeImpl `json:"bikes,omitempty"` // only for the snapshot
+}
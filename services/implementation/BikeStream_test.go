@@ -0,0 +1,77 @@
+package implementation
+
+import (
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// replaces the bike stream with an empty stream for one test
+func useEmptyBikeStream(t *testing.T) *bikeStreamHub {
+	t.Helper()
+	previous := bikeStream
+	bikeStream = &bikeStreamHub{epoch: time.Now().Unix(), subscribers: map[*BikeStreamSubscriptionImpl]bool{}}
+	t.Cleanup(func() { bikeStream = previous })
+	return bikeStream
+}
+
+func bikeRow(bikeId int, status string, reservationId interface{}) []driver.Value {
+	return []driver.Value{int64(bikeId), "bike", "52.52", "13.40", reservationId, status, nil, nil, nil, 50.0, nil, int64(0), nil, "city", false}
+}
+
+func TestConcurrentChangesOfBikeArePublishedInOrder(t *testing.T) {
+	hub := useEmptyBikeStream(t)
+
+	// the first change reads the bike before the second change was committed, but its read is slow
+	firstRead := make(chan struct{})
+	releaseFirstRead := make(chan struct{})
+	var mutex sync.Mutex
+	reads := 0
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		if !strings.HasPrefix(statement.Query, "SELECT "+bikeColumns) {
+			return &fakedb.Result{}, nil
+		}
+		mutex.Lock()
+		reads++
+		read := reads
+		mutex.Unlock()
+
+		columns := strings.Split(strings.Join(strings.Fields(bikeColumns), ""), ",")
+		if read == 1 {
+			close(firstRead)
+			<-releaseFirstRead
+			return &fakedb.Result{Columns: columns, Rows: [][]driver.Value{bikeRow(7, BIKE_STATUS_RESERVED, "reservation-1")}}, nil
+		}
+		return &fakedb.Result{Columns: columns, Rows: [][]driver.Value{bikeRow(7, BIKE_STATUS_AVAILABLE, nil)}}, nil
+	})
+	db, _ := SetupDB()
+
+	var published sync.WaitGroup
+	published.Add(2)
+	go func() {
+		defer published.Done()
+		publishBikeEvent(context.Background(), db, BIKE_EVENT_RESERVED, 7)
+	}()
+	<-firstRead
+	go func() {
+		defer published.Done()
+		publishBikeEvent(context.Background(), db, BIKE_EVENT_RELEASED, 7)
+	}()
+	// the second change must wait for the first one instead of publishing its state before it
+	time.Sleep(50 * time.Millisecond)
+	close(releaseFirstRead)
+	published.Wait()
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	if len(hub.history) != 2 {
+		t.Fatalf("expected 2 events, got %v", len(hub.history))
+	}
+	if hub.history[0].Bike.Status != BIKE_STATUS_RESERVED || hub.history[1].Bike.Status != BIKE_STATUS_AVAILABLE {
+		t.Fatalf("expected the reserved bike before the available bike, got %v and %v", hub.history[0].Bike.Status, hub.history[1].Bike.Status)
+	}
+}
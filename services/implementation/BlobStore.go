@@ -0,0 +1,128 @@
+package implementation
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+/*
+stores binary objects like photos under a key. The database only stores the key and the metadata of an object,
+so the API can store its objects in the local filesystem or in an object storage by plugging in another BlobStore
+*/
+type BlobStore interface {
+	// stores the data under the key, an existing object is overwritten
+	Put(key string, data io.Reader) error
+	// returns a reader for the object, the caller has to close it
+	Get(key string) (io.ReadCloser, error)
+	// deletes the object, deleting a missing object is not an error
+	Delete(key string) error
+}
+
+/*
+BlobStore which stores every object as a file below a base directory.
+the key is the relative path of the file, e.g. damagereports/<reportId>/<photoId>
+*/
+type LocalBlobStore struct {
+	BaseDir string
+}
+
+var (
+	blobStore      BlobStore
+	blobStoreMutex sync.Mutex
+)
+
+/*
+replaces the BlobStore of the API, e.g. with an object storage.
+without a call the LocalBlobStore in the directory of EBIKE_BLOB_DIR is used
+*/
+func SetBlobStore(store BlobStore) {
+	blobStoreMutex.Lock()
+	defer blobStoreMutex.Unlock()
+	blobStore = store
+}
+
+// returns the BlobStore of the API
+func getBlobStore() BlobStore {
+	blobStoreMutex.Lock()
+	defer blobStoreMutex.Unlock()
+	if blobStore == nil {
+		blobStore = &LocalBlobStore{BaseDir: getEnvString(ENV_BLOB_DIR, DEFAULT_BLOB_DIR)}
+	}
+	return blobStore
+}
+
+func (store *LocalBlobStore) Put(key string, data io.Reader) error {
+	path, pathError := store.path(key)
+	if pathError != nil {
+		return pathError
+	}
+
+	mkdirError := os.MkdirAll(filepath.Dir(path), 0o750)
+	if mkdirError != nil {
+		return fmt.Errorf("could not create directory for blob %v. %w", key, mkdirError)
+	}
+
+	// the data is written to a temporary file first, so a reader never sees a partially written object
+	tempFile, createError := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if createError != nil {
+		return fmt.Errorf("could not create blob %v. %w", key, createError)
+	}
+	defer os.Remove(tempFile.Name()) // has no effect after a successful rename
+
+	_, copyError := io.Copy(tempFile, data)
+	closeError := tempFile.Close()
+	if copyError != nil {
+		return fmt.Errorf("could not write blob %v. %w", key, copyError)
+	}
+	if closeError != nil {
+		return fmt.Errorf("could not write blob %v. %w", key, closeError)
+	}
+
+	renameError := os.Rename(tempFile.Name(), path)
+	if renameError != nil {
+		return fmt.Errorf("could not store blob %v. %w", key, renameError)
+	}
+	return nil
+}
+
+func (store *LocalBlobStore) Get(key string) (io.ReadCloser, error) {
+	path, pathError := store.path(key)
+	if pathError != nil {
+		return nil, pathError
+	}
+
+	file, openError := os.Open(path)
+	if os.IsNotExist(openError) {
+		return nil, NotFoundError(ERROR_CODE_NOT_FOUND, "blob %v does not exist", key)
+	}
+	if openError != nil {
+		return nil, fmt.Errorf("could not read blob %v. %w", key, openError)
+	}
+	return file, nil
+}
+
+func (store *LocalBlobStore) Delete(key string) error {
+	path, pathError := store.path(key)
+	if pathError != nil {
+		return pathError
+	}
+
+	removeError := os.Remove(path)
+	if removeError != nil && !os.IsNotExist(removeError) {
+		return fmt.Errorf("could not delete blob %v. %w", key, removeError)
+	}
+	return nil
+}
+
+// returns the file of a key. Keys which would leave the base directory are refused
+func (store *LocalBlobStore) path(key string) (string, error) {
+	cleanKey := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(cleanKey) || cleanKey == ".." || strings.HasPrefix(cleanKey, ".."+string(filepath.Separator)) {
+		return "", ValidationError(ERROR_CODE_VALIDATION, "invalid blob key %v", key)
+	}
+	return filepath.Join(store.BaseDir, cleanKey), nil
+}
@@ -0,0 +1,234 @@
+package implementation
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// ---------- environment variables which configure the API ---------
+	// bikes with a battery below this percentage can not be reserved
+	ENV_BATTERY_CRITICAL_PERCENT = "EBIKE_BATTERY_CRITICAL_PERCENT"
+	// directory of the local blob store, e.g. for the photos of damage reports
+	ENV_BLOB_DIR = "EBIKE_BLOB_DIR"
+	// maximal number of photos of a damage report and maximal size of a photo
+	ENV_DAMAGE_MAX_PHOTOS      = "EBIKE_DAMAGE_MAX_PHOTOS"
+	ENV_DAMAGE_PHOTO_MAX_BYTES = "EBIKE_DAMAGE_PHOTO_MAX_BYTES"
+	// number of open damage reports after which a bike is moved into maintenance, 0 disables it
+	ENV_DAMAGE_REPORTS_FOR_MAINTENANCE = "EBIKE_DAMAGE_REPORTS_FOR_MAINTENANCE"
+	// minutes between two runs of the maintenance scheduler, 0 disables it
+	ENV_MAINTENANCE_SCHEDULER_MINUTES = "EBIKE_MAINTENANCE_SCHEDULER_MINUTES"
+	// seconds after which a command which the device of a bike did not acknowledge times out
+	ENV_COMMAND_TIMEOUT_SECONDS = "EBIKE_COMMAND_TIMEOUT_SECONDS"
+	// distance in meters which a parked bike may move before a theft alert is raised
+	ENV_THEFT_MOVE_METERS = "EBIKE_THEFT_MOVE_METERS"
+	// minutes without contact after which a parked bike raises a theft alert, 0 disables it
+	ENV_THEFT_SILENCE_MINUTES = "EBIKE_THEFT_SILENCE_MINUTES"
+	// minutes between two checks of the theft monitor for silent bikes, 0 disables it
+	ENV_THEFT_CHECK_MINUTES = "EBIKE_THEFT_CHECK_MINUTES"
+	// number of events which a client of the bike stream may fall behind before it is disconnected
+	ENV_STREAM_BUFFER_SIZE = "EBIKE_STREAM_BUFFER_SIZE"
+	// number of the latest events of the bike stream which are kept, so a client can resume after it reconnects
+	ENV_STREAM_HISTORY_SIZE = "EBIKE_STREAM_HISTORY_SIZE"
+	// seconds between two runs of the webhook dispatcher, 0 disables it
+	ENV_WEBHOOK_DISPATCH_SECONDS = "EBIKE_WEBHOOK_DISPATCH_SECONDS"
+	// number of attempts after which a webhook delivery is dead
+	ENV_WEBHOOK_MAX_ATTEMPTS = "EBIKE_WEBHOOK_MAX_ATTEMPTS"
+	// seconds a webhook receiver has to respond
+	ENV_WEBHOOK_TIMEOUT_SECONDS = "EBIKE_WEBHOOK_TIMEOUT_SECONDS"
+	// milliseconds between two runs of the outbox dispatcher, 0 disables it
+	ENV_OUTBOX_DISPATCH_MILLISECONDS = "EBIKE_OUTBOX_DISPATCH_MILLISECONDS"
+	// 1 writes every domain event as JSON line to the standard output
+	ENV_EVENT_LOG = "EBIKE_EVENT_LOG"
+	// hours after which an Idempotency-Key and its stored response expire
+	ENV_IDEMPOTENCY_KEY_HOURS = "EBIKE_IDEMPOTENCY_KEY_HOURS"
+	// seconds after which the queries of a request are cancelled, 0 disables it
+	ENV_REQUEST_TIMEOUT_SECONDS = "EBIKE_REQUEST_TIMEOUT_SECONDS"
+	// seconds after which the queries of a report or an export are cancelled, e.g. an invoice or the audit log, 0 disables it
+	ENV_REPORT_TIMEOUT_SECONDS = "EBIKE_REPORT_TIMEOUT_SECONDS"
+	// seconds a client has to send a request with its body
+	ENV_SERVER_READ_TIMEOUT_SECONDS = "EBIKE_SERVER_READ_TIMEOUT_SECONDS"
+	// seconds the API has to write a response, longer than the report timeout and the long poll of the devices
+	ENV_SERVER_WRITE_TIMEOUT_SECONDS = "EBIKE_SERVER_WRITE_TIMEOUT_SECONDS"
+	// seconds an idle keep-alive connection stays open
+	ENV_SERVER_IDLE_TIMEOUT_SECONDS = "EBIKE_SERVER_IDLE_TIMEOUT_SECONDS"
+	// seconds the running requests have to finish when the API shuts down
+	ENV_SHUTDOWN_TIMEOUT_SECONDS = "EBIKE_SHUTDOWN_TIMEOUT_SECONDS"
+	// certificate and private key in PEM format, the API serves HTTPS if both are set. Renewed files are loaded without a restart
+	ENV_TLS_CERT_FILE = "EBIKE_TLS_CERT_FILE"
+	ENV_TLS_KEY_FILE  = "EBIKE_TLS_KEY_FILE"
+
+	DEFAULT_BATTERY_CRITICAL_PERCENT       = 15
+	DEFAULT_BLOB_DIR                       = "./data/blobs"
+	DEFAULT_DAMAGE_MAX_PHOTOS              = 5
+	DEFAULT_DAMAGE_PHOTO_MAX_BYTES         = 5 << 20
+	DEFAULT_DAMAGE_REPORTS_FOR_MAINTENANCE = 3
+	DEFAULT_MAINTENANCE_SCHEDULER_MINUTES  = 60
+	DEFAULT_COMMAND_TIMEOUT_SECONDS        = 60
+	DEFAULT_THEFT_MOVE_METERS              = 100
+	DEFAULT_THEFT_SILENCE_MINUTES          = 120
+	DEFAULT_THEFT_CHECK_MINUTES            = 5
+	DEFAULT_STREAM_BUFFER_SIZE             = 256
+	DEFAULT_STREAM_HISTORY_SIZE            = 1024
+	DEFAULT_WEBHOOK_DISPATCH_SECONDS       = 5
+	DEFAULT_WEBHOOK_MAX_ATTEMPTS           = 8
+	DEFAULT_WEBHOOK_TIMEOUT_SECONDS        = 10
+	DEFAULT_OUTBOX_DISPATCH_MILLISECONDS   = 500
+	DEFAULT_EVENT_LOG                      = 0
+	DEFAULT_IDEMPOTENCY_KEY_HOURS          = 24
+	DEFAULT_REQUEST_TIMEOUT_SECONDS        = 10
+	DEFAULT_REPORT_TIMEOUT_SECONDS         = 60
+	DEFAULT_SERVER_READ_TIMEOUT_SECONDS    = 60
+	DEFAULT_SERVER_WRITE_TIMEOUT_SECONDS   = 90
+	DEFAULT_SERVER_IDLE_TIMEOUT_SECONDS    = 120
+	DEFAULT_SHUTDOWN_TIMEOUT_SECONDS       = 25
+)
+
+// returns the value of an environment variable, or the default value if the variable is not set
+func getEnvString(name string, defaultValue string) string {
+	value, isSet := os.LookupEnv(name)
+	if !isSet || value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+/*
+returns the integer value of an environment variable, or the default value if the variable is not set.
+an invalid value is reported and the default value is used, so a typo does not stop the API
+*/
+func getEnvInt(name string, defaultValue int) int {
+	value, isSet := os.LookupEnv(name)
+	if !isSet || value == "" {
+		return defaultValue
+	}
+
+	parsedValue, parseError := strconv.Atoi(value)
+	if parseError != nil {
+		fmt.Printf("invalid value %v of %v, using %v\n", value, name, defaultValue)
+		return defaultValue
+	}
+	return parsedValue
+}
+
+// returns the battery percentage below which bikes can not be reserved
+func BatteryCriticalPercent() int {
+	return getEnvInt(ENV_BATTERY_CRITICAL_PERCENT, DEFAULT_BATTERY_CRITICAL_PERCENT)
+}
+
+// returns the maximal number of photos of a damage report
+func DamageMaxPhotos() int {
+	return getEnvInt(ENV_DAMAGE_MAX_PHOTOS, DEFAULT_DAMAGE_MAX_PHOTOS)
+}
+
+// returns the maximal size of a photo of a damage report in bytes
+func DamagePhotoMaxBytes() int {
+	return getEnvInt(ENV_DAMAGE_PHOTO_MAX_BYTES, DEFAULT_DAMAGE_PHOTO_MAX_BYTES)
+}
+
+// returns the number of open damage reports after which a bike is moved into maintenance, 0 if it is disabled
+func DamageReportsForMaintenance() int {
+	return getEnvInt(ENV_DAMAGE_REPORTS_FOR_MAINTENANCE, DEFAULT_DAMAGE_REPORTS_FOR_MAINTENANCE)
+}
+
+// returns the interval of the maintenance scheduler, 0 if it is disabled
+func MaintenanceSchedulerInterval() time.Duration {
+	return time.Duration(getEnvInt(ENV_MAINTENANCE_SCHEDULER_MINUTES, DEFAULT_MAINTENANCE_SCHEDULER_MINUTES)) * time.Minute
+}
+
+// returns the time after which a command which was not acknowledged times out
+func BikeCommandTimeout() time.Duration {
+	return time.Duration(getEnvInt(ENV_COMMAND_TIMEOUT_SECONDS, DEFAULT_COMMAND_TIMEOUT_SECONDS)) * time.Second
+}
+
+// returns the distance in meters which a parked bike may move before a theft alert is raised
+func TheftMoveMeters() int {
+	return getEnvInt(ENV_THEFT_MOVE_METERS, DEFAULT_THEFT_MOVE_METERS)
+}
+
+// returns the time without contact after which a parked bike raises a theft alert, 0 if it is disabled
+func TheftSilenceThreshold() time.Duration {
+	return time.Duration(getEnvInt(ENV_THEFT_SILENCE_MINUTES, DEFAULT_THEFT_SILENCE_MINUTES)) * time.Minute
+}
+
+// returns the interval of the theft monitor, 0 if it is disabled
+func TheftMonitorInterval() time.Duration {
+	return time.Duration(getEnvInt(ENV_THEFT_CHECK_MINUTES, DEFAULT_THEFT_CHECK_MINUTES)) * time.Minute
+}
+
+// returns the size of the buffer of a client of the bike stream, at least 1
+func BikeStreamBufferSize() int {
+	return maxInt(getEnvInt(ENV_STREAM_BUFFER_SIZE, DEFAULT_STREAM_BUFFER_SIZE), 1)
+}
+
+// returns the number of events of the bike stream which are kept to resume, 0 if resuming is disabled
+func BikeStreamHistorySize() int {
+	return maxInt(getEnvInt(ENV_STREAM_HISTORY_SIZE, DEFAULT_STREAM_HISTORY_SIZE), 0)
+}
+
+// returns the interval of the webhook dispatcher, 0 if it is disabled
+func WebhookDispatchInterval() time.Duration {
+	return time.Duration(getEnvInt(ENV_WEBHOOK_DISPATCH_SECONDS, DEFAULT_WEBHOOK_DISPATCH_SECONDS)) * time.Second
+}
+
+// returns the number of attempts after which a webhook delivery is dead, at least 1
+func WebhookMaxAttempts() int {
+	return maxInt(getEnvInt(ENV_WEBHOOK_MAX_ATTEMPTS, DEFAULT_WEBHOOK_MAX_ATTEMPTS), 1)
+}
+
+// returns the time a webhook receiver has to respond
+func WebhookTimeout() time.Duration {
+	return time.Duration(getEnvInt(ENV_WEBHOOK_TIMEOUT_SECONDS, DEFAULT_WEBHOOK_TIMEOUT_SECONDS)) * time.Second
+}
+
+// returns the interval of the outbox dispatcher, 0 if it is disabled
+func OutboxDispatchInterval() time.Duration {
+	return time.Duration(getEnvInt(ENV_OUTBOX_DISPATCH_MILLISECONDS, DEFAULT_OUTBOX_DISPATCH_MILLISECONDS)) * time.Millisecond
+}
+
+// returns true, if the domain events are written to the standard output
+func EventLogEnabled() bool {
+	return getEnvInt(ENV_EVENT_LOG, DEFAULT_EVENT_LOG) == 1
+}
+
+// returns the time after which an Idempotency-Key expires, at least 1 hour
+func IdempotencyKeyExpiry() time.Duration {
+	return time.Duration(maxInt(getEnvInt(ENV_IDEMPOTENCY_KEY_HOURS, DEFAULT_IDEMPOTENCY_KEY_HOURS), 1)) * time.Hour
+}
+
+// returns the time after which the queries of a request are cancelled, 0 if they have no timeout
+func RequestTimeout() time.Duration {
+	return time.Duration(getEnvInt(ENV_REQUEST_TIMEOUT_SECONDS, DEFAULT_REQUEST_TIMEOUT_SECONDS)) * time.Second
+}
+
+// returns the time after which the queries of a report or an export are cancelled, 0 if they have no timeout
+func ReportTimeout() time.Duration {
+	return time.Duration(getEnvInt(ENV_REPORT_TIMEOUT_SECONDS, DEFAULT_REPORT_TIMEOUT_SECONDS)) * time.Second
+}
+
+// returns the time a client has to send a request with its body, 0 if there is no limit
+func ServerReadTimeout() time.Duration {
+	return time.Duration(getEnvInt(ENV_SERVER_READ_TIMEOUT_SECONDS, DEFAULT_SERVER_READ_TIMEOUT_SECONDS)) * time.Second
+}
+
+// returns the time the API has to write a response, 0 if there is no limit
+func ServerWriteTimeout() time.Duration {
+	return time.Duration(getEnvInt(ENV_SERVER_WRITE_TIMEOUT_SECONDS, DEFAULT_SERVER_WRITE_TIMEOUT_SECONDS)) * time.Second
+}
+
+// returns the time an idle keep-alive connection stays open
+func ServerIdleTimeout() time.Duration {
+	return time.Duration(getEnvInt(ENV_SERVER_IDLE_TIMEOUT_SECONDS, DEFAULT_SERVER_IDLE_TIMEOUT_SECONDS)) * time.Second
+}
+
+// returns the time the running requests have to finish when the API shuts down
+func ShutdownTimeout() time.Duration {
+	return time.Duration(getEnvInt(ENV_SHUTDOWN_TIMEOUT_SECONDS, DEFAULT_SHUTDOWN_TIMEOUT_SECONDS)) * time.Second
+}
+
+// returns the files of the certificate and the private key of the API, empty if the API serves HTTP
+func TLSFiles() (string, string) {
+	return getEnvString(ENV_TLS_CERT_FILE, ""), getEnvString(ENV_TLS_KEY_FILE, "")
+}
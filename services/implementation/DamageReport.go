@@ -0,0 +1,487 @@
+package implementation
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DB_TABLE_DAMAGEREPORT = "damagereport"
+	DB_TABLE_DAMAGEPHOTO  = "damagephoto"
+
+	DAMAGE_DESCRIPTION_MAX_LENGTH = 2000
+)
+
+// the columns of the damagereport table in the order they are scanned by scanDamageReport
+const damageReportColumns = `damagereport.reportid, damagereport.bikeid, damagereport.username, damagereport.category, damagereport.description,
+	damagereport.status, damagereport.triagenote, damagereport.triagedby, damagereport.triagedat, damagereport.createdat`
+
+// the columns of the damagephoto table in the order they are scanned by scanDamagePhoto
+const damagePhotoColumns = `damagephoto.photoid, damagephoto.reportid, damagephoto.filename, damagephoto.contenttype, damagephoto.sizebytes,
+	damagephoto.blobkey, damagephoto.createdat`
+
+var damageCategories = []string{DAMAGE_CATEGORY_BRAKES, DAMAGE_CATEGORY_TIRES, DAMAGE_CATEGORY_CHAIN, DAMAGE_CATEGORY_LIGHTS,
+	DAMAGE_CATEGORY_BATTERY, DAMAGE_CATEGORY_LOCK, DAMAGE_CATEGORY_FRAME, DAMAGE_CATEGORY_OTHER}
+
+// the image types which are accepted as photos, detected from the content of the photo
+var damagePhotoContentTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+/*
+Implementation method for a rider to report a damage of a bike.
+the photos are stored in the BlobStore before the report is stored, if storing the report fails the photos are deleted again.
+if the bike has reached the configured number of open reports, it is moved into maintenance
+*/
+func CreateDamageReport(ctx context.Context, report DamageReportImpl, photos []DamagePhotoUploadImpl) (*DamageReportImpl, error) {
+
+	validateError := validateDamageReport(report, photos)
+	if validateError != nil {
+		return nil, validateError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	userRecordExists, userExistsInDbError := userExistsInDb(ctx, db, report.Username)
+	if userExistsInDbError != nil {
+		return nil, userExistsInDbError
+	}
+	if !userRecordExists {
+		return nil, NotFoundError(ERROR_CODE_USER_NOT_FOUND, "provided username does not exist in database")
+	}
+
+	report.ReportId = uuid.New().String()
+	report.Status = DAMAGE_REPORT_OPEN
+	report.CreatedAt = time.Now()
+	report.Photos = []DamagePhotoImpl{}
+
+	// store the photos first, the report only references photos which exist
+	store := getBlobStore()
+	for _, photo := range photos {
+		photoId := uuid.New().String()
+		damagePhoto := DamagePhotoImpl{
+			PhotoId:     photoId,
+			ReportId:    report.ReportId,
+			FileName:    photo.FileName,
+			ContentType: http.DetectContentType(photo.Data),
+			SizeBytes:   int64(len(photo.Data)),
+			BlobKey:     DB_TABLE_DAMAGEREPORT + "/" + report.ReportId + "/" + photoId,
+			CreatedAt:   report.CreatedAt,
+		}
+
+		putError := store.Put(damagePhoto.BlobKey, bytes.NewReader(photo.Data))
+		if putError != nil {
+			deleteDamagePhotoBlobs(report.Photos)
+			return nil, fmt.Errorf("could not store photo %v. %w", photo.FileName, putError)
+		}
+		report.Photos = append(report.Photos, damagePhoto)
+	}
+
+	movedToMaintenance, storeReportError := storeDamageReport(ctx, db, &report)
+	if storeReportError != nil {
+		deleteDamagePhotoBlobs(report.Photos)
+		return nil, storeReportError
+	}
+	report.MovedToMaintenance = movedToMaintenance
+
+	return &report, nil
+}
+
+/*
+Implementation method for operators to triage damage reports, the latest report first.
+the reports can be filtered by status and bike, an empty status and a bikeId of nil return all reports
+*/
+func GetDamageReports(ctx context.Context, status string, bikeId *int) (*[]DamageReportImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	conditions := []string{"true"}
+	arguments := []interface{}{}
+	if status != "" {
+		arguments = append(arguments, status)
+		conditions = append(conditions, fmt.Sprintf("damagereport.status=$%d", len(arguments)))
+	}
+	if bikeId != nil {
+		arguments = append(arguments, *bikeId)
+		conditions = append(conditions, fmt.Sprintf("damagereport.bikeid=$%d", len(arguments)))
+	}
+
+	reports, getReportsError := getDamageReportsWhere(ctx, db, strings.Join(conditions, " AND "), arguments...)
+	if getReportsError != nil {
+		return nil, getReportsError
+	}
+
+	return &reports, nil
+}
+
+/*
+Implementation method to retrieve a damage report by its reportId
+*/
+func GetDamageReport(ctx context.Context, reportId string) (*DamageReportImpl, error) {
+	if _, parseError := uuid.Parse(reportId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_DAMAGE_REPORT_NOT_FOUND, "damage report %v does not exist", reportId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	reports, getReportsError := getDamageReportsWhere(ctx, db, "damagereport.reportid=$1", reportId)
+	if getReportsError != nil {
+		return nil, getReportsError
+	}
+	if len(reports) == 0 {
+		return nil, NotFoundError(ERROR_CODE_DAMAGE_REPORT_NOT_FOUND, "damage report %v does not exist", reportId)
+	}
+
+	return &reports[0], nil
+}
+
+/*
+Implementation method for operators to triage a damage report.
+an open report can be confirmed, rejected or resolved, a confirmed report can be resolved.
+a confirmed report moves the bike into maintenance if requested
+*/
+func TriageDamageReport(ctx context.Context, reportId string, triage DamageReportTriageImpl, triagedBy string) (*DamageReportImpl, error) {
+
+	validator := fieldValidator{}
+	validator.oneOf("status", triage.Status, DAMAGE_REPORT_CONFIRMED, DAMAGE_REPORT_REJECTED, DAMAGE_REPORT_RESOLVED)
+	validator.maxLength("note", triage.Note, MAX_TEXT_LENGTH)
+	if triage.MoveToMaintenance && triage.Status != DAMAGE_REPORT_CONFIRMED {
+		validator.add("moveToMaintenance", "only a confirmed report can move the bike into maintenance")
+	}
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+	if _, parseError := uuid.Parse(reportId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_DAMAGE_REPORT_NOT_FOUND, "damage report %v does not exist", reportId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	// lock the report, so that it is only triaged once
+	_, dbLockError := tx.ExecContext(ctx, `SELECT reportid FROM `+DB_TABLE_DAMAGEREPORT+` WHERE reportid=$1 FOR UPDATE;`, reportId)
+	if dbLockError != nil {
+		return nil, fmt.Errorf("could not lock damage report %v. %w", reportId, dbLockError)
+	}
+
+	reports, getReportsError := getDamageReportsWhere(ctx, tx, "damagereport.reportid=$1", reportId)
+	if getReportsError != nil {
+		return nil, getReportsError
+	}
+	if len(reports) == 0 {
+		return nil, NotFoundError(ERROR_CODE_DAMAGE_REPORT_NOT_FOUND, "damage report %v does not exist", reportId)
+	}
+	report := reports[0]
+
+	if report.Status != DAMAGE_REPORT_OPEN && !(report.Status == DAMAGE_REPORT_CONFIRMED && triage.Status == DAMAGE_REPORT_RESOLVED) {
+		return nil, ConflictError(ERROR_CODE_STATUS_TRANSITION_NOT_ALLOWED, "damage report %v is %v and can not be %v", reportId, report.Status, triage.Status)
+	}
+
+	triagedAt := time.Now()
+	updateStatement := `UPDATE ` + DB_TABLE_DAMAGEREPORT + ` SET status=$1, triagenote=$2, triagedby=$3, triagedat=$4 WHERE reportid=$5;`
+	_, dbUpdateError := tx.ExecContext(ctx, updateStatement, triage.Status, triage.Note, triagedBy, triagedAt, reportId)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not update damage report %v. %w", reportId, dbUpdateError)
+	}
+
+	report.Status = triage.Status
+	report.TriageNote = triage.Note
+	report.TriagedBy = &triagedBy
+	report.TriagedAt = &triagedAt
+
+	if triage.MoveToMaintenance {
+		movedToMaintenance, maintenanceError := moveBikeToMaintenance(ctx, tx, report.BikeId, "damage report "+reportId+" confirmed", triagedBy)
+		if maintenanceError != nil {
+			return nil, maintenanceError
+		}
+		if !movedToMaintenance {
+			return nil, ConflictError(ERROR_CODE_BIKE_NOT_AVAILABLE, "bike %v can not be moved into maintenance now, e.g. because it is rented", report.BikeId)
+		}
+		report.MovedToMaintenance = true
+
+		createOrderError := createDamageWorkOrder(ctx, tx, report, triagedBy)
+		if createOrderError != nil {
+			return nil, createOrderError
+		}
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not triage damage report. %w", commitError)
+	}
+
+	if report.MovedToMaintenance {
+		publishBikeEvent(ctx, db, BIKE_EVENT_UPDATED, report.BikeId)
+	}
+
+	return &report, nil
+}
+
+/*
+Implementation method to retrieve a photo of a damage report.
+returns the metadata of the photo and a reader of its content, the caller has to close the reader
+*/
+func GetDamagePhoto(ctx context.Context, reportId string, photoId string) (*DamagePhotoImpl, io.ReadCloser, error) {
+	_, parseReportIdError := uuid.Parse(reportId)
+	_, parsePhotoIdError := uuid.Parse(photoId)
+	if parseReportIdError != nil || parsePhotoIdError != nil {
+		return nil, nil, NotFoundError(ERROR_CODE_PHOTO_NOT_FOUND, "photo %v of damage report %v does not exist", photoId, reportId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, nil, dbConnectError
+	}
+
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+damagePhotoColumns+` FROM `+DB_TABLE_DAMAGEPHOTO+` WHERE photoid=$1 AND reportid=$2;`, photoId, reportId)
+	if dbQueryError != nil {
+		return nil, nil, fmt.Errorf("could not retrieve photo %v. %w", photoId, dbQueryError)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil, NotFoundError(ERROR_CODE_PHOTO_NOT_FOUND, "photo %v of damage report %v does not exist", photoId, reportId)
+	}
+	photo, scanError := scanDamagePhoto(rows)
+	if scanError != nil {
+		return nil, nil, scanError
+	}
+
+	content, getBlobError := getBlobStore().Get(photo.BlobKey)
+	if getBlobError != nil {
+		return nil, nil, getBlobError
+	}
+
+	return photo, content, nil
+}
+
+/*
+stores a damage report with its photos in one transaction and moves the bike into maintenance,
+if the configured number of open reports is reached. Returns true if the bike was moved into maintenance
+*/
+func storeDamageReport(ctx context.Context, db *sql.DB, report *DamageReportImpl) (bool, error) {
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return false, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	// lock the bike, so that concurrent reports are counted one after the other
+	var bikeId int
+	dbQueryError := tx.QueryRowContext(ctx, `SELECT `+DB_TABLE_BIKE_COLUMN_BIKEID+` FROM `+DB_TABLE_BIKE+` WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$1 FOR UPDATE;`, report.BikeId).Scan(&bikeId)
+	if dbQueryError == sql.ErrNoRows {
+		return false, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
+	}
+	if dbQueryError != nil {
+		return false, fmt.Errorf("could not retrieve bike %v. %w", report.BikeId, dbQueryError)
+	}
+
+	insertReportStatement := getInsertStmt(DB_TABLE_DAMAGEREPORT, "reportid", "bikeid", "username", "category", "description", "status", "createdat")
+	_, dbInsertError := tx.ExecContext(ctx, insertReportStatement, report.ReportId, report.BikeId, report.Username, report.Category, report.Description,
+		report.Status, report.CreatedAt)
+	if dbInsertError != nil {
+		return false, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_DAMAGEREPORT, dbInsertError)
+	}
+
+	insertPhotoStatement := getInsertStmt(DB_TABLE_DAMAGEPHOTO, "photoid", "reportid", "filename", "contenttype", "sizebytes", "blobkey", "createdat")
+	for _, photo := range report.Photos {
+		_, dbInsertPhotoError := tx.ExecContext(ctx, insertPhotoStatement, photo.PhotoId, photo.ReportId, photo.FileName, photo.ContentType, photo.SizeBytes,
+			photo.BlobKey, photo.CreatedAt)
+		if dbInsertPhotoError != nil {
+			return false, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_DAMAGEPHOTO, dbInsertPhotoError)
+		}
+	}
+
+	movedToMaintenance := false
+	reportsForMaintenance := DamageReportsForMaintenance()
+	if reportsForMaintenance > 0 {
+		var openReports int
+		dbCountError := tx.QueryRowContext(ctx, `SELECT count(*) FROM `+DB_TABLE_DAMAGEREPORT+` WHERE bikeid=$1 AND status IN ($2, $3);`,
+			report.BikeId, DAMAGE_REPORT_OPEN, DAMAGE_REPORT_CONFIRMED).Scan(&openReports)
+		if dbCountError != nil {
+			return false, fmt.Errorf("could not count damage reports of bike %v. %w", report.BikeId, dbCountError)
+		}
+
+		if openReports >= reportsForMaintenance {
+			moved, maintenanceError := moveBikeToMaintenance(ctx, tx, report.BikeId, fmt.Sprintf("%d open damage reports", openReports),
+				BIKE_STATUS_CHANGED_BY_SYSTEM)
+			if maintenanceError != nil {
+				return false, maintenanceError
+			}
+			movedToMaintenance = moved
+		}
+
+		// the bike is repaired with a work order for the report which moved it into maintenance
+		if movedToMaintenance {
+			createOrderError := createDamageWorkOrder(ctx, tx, *report, BIKE_STATUS_CHANGED_BY_SYSTEM)
+			if createOrderError != nil {
+				return false, createOrderError
+			}
+		}
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return false, fmt.Errorf("could not store damage report. %w", commitError)
+	}
+
+	if movedToMaintenance {
+		publishBikeEvent(ctx, db, BIKE_EVENT_UPDATED, report.BikeId)
+	}
+
+	return movedToMaintenance, nil
+}
+
+// checks the values of a damage report and its photos
+func validateDamageReport(report DamageReportImpl, photos []DamagePhotoUploadImpl) error {
+
+	validator := fieldValidator{}
+	validator.requiredText("username", report.Username, MAX_USERNAME_LENGTH)
+	validator.oneOf("category", report.Category, damageCategories...)
+	validator.maxLength("description", report.Description, DAMAGE_DESCRIPTION_MAX_LENGTH)
+
+	maxPhotos := DamageMaxPhotos()
+	if len(photos) > maxPhotos {
+		validator.add("photos", "a damage report must not have more than %d photos", maxPhotos)
+	}
+
+	maxBytes := DamagePhotoMaxBytes()
+	for _, photo := range photos {
+		if len(photo.Data) == 0 {
+			validator.add("photos", "photo %v is empty", photo.FileName)
+			continue
+		}
+		if len(photo.Data) > maxBytes {
+			validator.add("photos", "photo %v is larger than %d bytes", photo.FileName, maxBytes)
+		}
+
+		contentType := http.DetectContentType(photo.Data)
+		validContentType := false
+		for _, allowedContentType := range damagePhotoContentTypes {
+			if contentType == allowedContentType {
+				validContentType = true
+			}
+		}
+		if !validContentType {
+			validator.add("photos", "photo %v is %v, only %v are accepted", photo.FileName, contentType, strings.Join(damagePhotoContentTypes, ", "))
+		}
+	}
+
+	return validator.err()
+}
+
+// deletes the photos of a report which could not be stored. Errors are only reported, since the report fails anyway
+func deleteDamagePhotoBlobs(photos []DamagePhotoImpl) {
+	store := getBlobStore()
+	for _, photo := range photos {
+		deleteError := store.Delete(photo.BlobKey)
+		if deleteError != nil {
+			fmt.Printf("could not delete photo %v. %v\n", photo.BlobKey, deleteError)
+		}
+	}
+}
+
+/*
+returns the damage reports which match the condition with their photos, the latest report first.
+the condition refers to the columns of the damagereport table, e.g. "damagereport.bikeid=$1"
+*/
+func getDamageReportsWhere(ctx context.Context, db dbQueryer, condition string, arguments ...interface{}) ([]DamageReportImpl, error) {
+
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+damageReportColumns+` FROM `+DB_TABLE_DAMAGEREPORT+` WHERE `+condition+` ORDER BY damagereport.createdat DESC;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve damage reports. %w", dbQueryError)
+	}
+
+	reports := []DamageReportImpl{}
+	reportIndex := map[string]int{}
+	for rows.Next() {
+		report, scanError := scanDamageReport(rows)
+		if scanError != nil {
+			rows.Close()
+			return nil, scanError
+		}
+		reportIndex[report.ReportId] = len(reports)
+		reports = append(reports, *report)
+	}
+	rows.Close()
+
+	if len(reports) == 0 {
+		return reports, nil
+	}
+
+	// the photos of all selected reports are retrieved with one query
+	photoRows, dbPhotoQueryError := db.QueryContext(ctx, `SELECT `+damagePhotoColumns+` FROM `+DB_TABLE_DAMAGEPHOTO+` JOIN `+DB_TABLE_DAMAGEREPORT+
+		` ON damagereport.reportid=damagephoto.reportid WHERE `+condition+` ORDER BY damagephoto.createdat, damagephoto.photoid;`, arguments...)
+	if dbPhotoQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve photos of damage reports. %w", dbPhotoQueryError)
+	}
+	defer photoRows.Close()
+
+	for photoRows.Next() {
+		photo, scanError := scanDamagePhoto(photoRows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		index := reportIndex[photo.ReportId]
+		reports[index].Photos = append(reports[index].Photos, *photo)
+	}
+
+	return reports, nil
+}
+
+// scans a row of the damagereport table, the columns are selected with damageReportColumns
+func scanDamageReport(rows *sql.Rows) (*DamageReportImpl, error) {
+	report := DamageReportImpl{Photos: []DamagePhotoImpl{}}
+	var triagedBy sql.NullString
+	var triagedAt sql.NullTime
+	scanError := rows.Scan(&report.ReportId, &report.BikeId, &report.Username, &report.Category, &report.Description,
+		&report.Status, &report.TriageNote, &triagedBy, &triagedAt, &report.CreatedAt)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into DamageReport Object. %w", DB_TABLE_DAMAGEREPORT, scanError)
+	}
+	if triagedBy.Valid {
+		report.TriagedBy = &triagedBy.String
+	}
+	if triagedAt.Valid {
+		report.TriagedAt = &triagedAt.Time
+	}
+	return &report, nil
+}
+
+// scans a row of the damagephoto table, the columns are selected with damagePhotoColumns
+func scanDamagePhoto(rows *sql.Rows) (*DamagePhotoImpl, error) {
+	photo := DamagePhotoImpl{}
+	scanError := rows.Scan(&photo.PhotoId, &photo.ReportId, &photo.FileName, &photo.ContentType, &photo.SizeBytes, &photo.BlobKey, &photo.CreatedAt)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into DamagePhoto Object. %w", DB_TABLE_DAMAGEPHOTO, scanError)
+	}
+	return &photo, nil
+}
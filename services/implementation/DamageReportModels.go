@@ -0,0 +1,74 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- categories of damage reports ---------
+	DAMAGE_CATEGORY_BRAKES  = "brakes"
+	DAMAGE_CATEGORY_TIRES   = "tires"
+	DAMAGE_CATEGORY_CHAIN   = "chain"
+	DAMAGE_CATEGORY_LIGHTS  = "lights"
+	DAMAGE_CATEGORY_BATTERY = "battery"
+	DAMAGE_CATEGORY_LOCK    = "lock"
+	DAMAGE_CATEGORY_FRAME   = "frame"
+	DAMAGE_CATEGORY_OTHER   = "other"
+
+	// ---------- status of damage reports ---------
+	// a report is open until an operator triaged it. Open and confirmed reports count towards the automatic maintenance
+	DAMAGE_REPORT_OPEN      = "open"
+	DAMAGE_REPORT_CONFIRMED = "confirmed"
+	DAMAGE_REPORT_REJECTED  = "rejected"
+	DAMAGE_REPORT_RESOLVED  = "resolved"
+)
+
+/*
+represents the database structure for the table "damagereport" in the DATABASE.
+a rider reports a damage of a bike with a category, a description and photos
+*/
+type DamageReportImpl struct {
+	ReportId    string            `json:"reportId"`
+	BikeId      int               `json:"bikeId"`
+	Username    string            `json:"username"`
+	Category    string            `json:"category"`
+	Description string            `json:"description"`
+	Status      string            `json:"status"`
+	TriageNote  string            `json:"triageNote"`
+	TriagedBy   *string           `json:"triagedBy"`
+	TriagedAt   *time.Time        `json:"triagedAt"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	Photos      []DamagePhotoImpl `json:"photos"`
+	// only set when the report is created: true if the report moved the bike into maintenance
+	MovedToMaintenance bool `json:"movedToMaintenance,omitempty"`
+}
+
+/*
+represents the database structure for the table "damagephoto" in the DATABASE.
+the photo itself is stored in the BlobStore under the blobkey
+*/
+type DamagePhotoImpl struct {
+	PhotoId     string    `json:"photoId"`
+	ReportId    string    `json:"reportId"`
+	FileName    string    `json:"fileName"`
+	ContentType string    `json:"contentType"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	BlobKey     string    `json:"-"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+/*
+represents a photo which is uploaded with a damage report
+*/
+type DamagePhotoUploadImpl struct {
+	FileName string
+	Data     []byte
+}
+
+/*
+represents the decision of an operator about a damage report.
+a confirmed report can move the bike into maintenance, even if the number of reports for the automatic maintenance is not reached
+*/
+type DamageReportTriageImpl struct {
+	Status            string `json:"status"`
+	Note              string `json:"note"`
+	MoveToMaintenance bool   `json:"moveToMaintenance"`
+}
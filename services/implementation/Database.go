@@ -1,9 +1,12 @@
 package implementation
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 )
@@ -17,27 +20,65 @@ const (
 	DB_USER     = "postgres"
 	DB_PASSWORD = "password"
 	// ---------- BIKE TABLE CONSTANTS ---------
-	DB_TABLE_BIKE                      = "bike"
-	DB_TABLE_BIKE_COLUMN_BIKEID        = "bikeid"
-	DB_TABLE_BIKE_COLUMN_NAME          = "name"
-	DB_TABLE_BIKE_COLUMN_LATITUDE      = "latitude"
-	DB_TABLE_BIKE_COLUMN_LONGITUDE     = "longitude"
-	DB_TABLE_BIKE_COLUMN_RESERVATIONID = "reservationid"
+	DB_TABLE_BIKE                       = "bike"
+	DB_TABLE_BIKE_COLUMN_BIKEID         = "bikeid"
+	DB_TABLE_BIKE_COLUMN_NAME           = "name"
+	DB_TABLE_BIKE_COLUMN_LATITUDE       = "latitude"
+	DB_TABLE_BIKE_COLUMN_LONGITUDE      = "longitude"
+	DB_TABLE_BIKE_COLUMN_RESERVATIONID  = "reservationid"
+	DB_TABLE_BIKE_COLUMN_STATIONID      = "stationid"
+	DB_TABLE_BIKE_COLUMN_BATTERYPERCENT = "batterypercent"
 	// ---------- RESERVATION TABLE CONSTANTS ---------
-	DB_TABLE_RESERVATION                      = "reservation"
-	DB_TABLE_RESERVATION_COLUMN_RESERVATIONID = "reservationid"
-	DB_TABLE_RESERVATION_COLUMN_BIKEID        = "bikeid"
-	DB_TABLE_RESERVATION_COLUMN_USERNAME      = "username"
+	DB_TABLE_RESERVATION                              = "reservation"
+	DB_TABLE_RESERVATION_COLUMN_RESERVATIONID         = "reservationid"
+	DB_TABLE_RESERVATION_COLUMN_BIKEID                = "bikeid"
+	DB_TABLE_RESERVATION_COLUMN_USERNAME              = "username"
+	DB_TABLE_RESERVATION_COLUMN_STARTLATITUDE         = "startlatitude"
+	DB_TABLE_RESERVATION_COLUMN_STARTLONGITUDE        = "startlongitude"
+	DB_TABLE_RESERVATION_COLUMN_PROMOCODE             = "promocode"
+	DB_TABLE_RESERVATION_COLUMN_BILLINGORGANIZATIONID = "billingorganizationid"
 	// ---------- USER TABLE CONSTANTS ---------
 	DB_TABLE_USER                 = "users"
 	DB_TABLE_USER_COLUMN_USERNAME = "username"
+	DB_TABLE_USER_COLUMN_ROLE     = "role"
+	// ---------- RIDE TABLE CONSTANTS ---------
+	DB_TABLE_RIDE = "ride"
+	// ---------- PROMOTION TABLE CONSTANTS ---------
+	DB_TABLE_PROMOTION           = "promotion"
+	DB_TABLE_PROMOTIONREDEMPTION = "promotionredemption"
 )
 
+/*
+common interface of *sql.DB and *sql.Tx.
+functions which take a dbQueryer can be used inside and outside of a transaction
+*/
+type dbQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// the pool of connections to the database, which all methods share. It is opened by the first call of SetupDB
+var dbPool struct {
+	mutex  sync.Mutex
+	db     *sql.DB
+	closed bool
+}
+
 /*
 function to connect to Database.
-returns a pointer to the connected Database
+returns a pointer to the pool of connections to the Database, which all methods share until CloseDB is called
 */
 func SetupDB() (*sql.DB, error) {
+	dbPool.mutex.Lock()
+	defer dbPool.mutex.Unlock()
+
+	if dbPool.closed {
+		return nil, fmt.Errorf("the database is closed, the API is shutting down")
+	}
+	if dbPool.db != nil {
+		return dbPool.db, nil
+	}
 
 	// prepare connect parameters to a string
 	dbinfo := fmt.Sprintf(
@@ -49,104 +90,168 @@ func SetupDB() (*sql.DB, error) {
 	if dbConnectError != nil {
 		return nil, fmt.Errorf("error while connecting to database")
 	}
+	dbPool.db = db
 	// return a pointer to the connected database
 	return db, nil
 }
 
 /*
-returns all records from a given table without conditions
+replaces the pool of connections to the database which all methods share, e.g. with the database of a test.
+CloseDB closes it like a pool which SetupDB opened
+*/
+func UseDB(db *sql.DB) {
+	dbPool.mutex.Lock()
+	defer dbPool.mutex.Unlock()
+
+	dbPool.db = db
+	dbPool.closed = false
+}
+
+/*
+closes the pool of connections to the database, e.g. when the API shuts down.
+new queries fail, the queries which already started are finished first
 */
-func getAllRowsFromTable(db *sql.DB, tableName string) (*sql.Rows, error) {
+func CloseDB() error {
+	dbPool.mutex.Lock()
+	defer dbPool.mutex.Unlock()
 
-	sqlStatement := "SELECT * FROM " + tableName
+	dbPool.closed = true
+	if dbPool.db == nil {
+		return nil
+	}
+	return dbPool.db.Close()
+}
+
+// the columns of the bike table in the order they are scanned by scanBike
+const bikeColumns = `bikeid, name, latitude, longitude, reservationid, status, stationid, batterypercent, estimatedrangekm, fullrangekm, locked, odometermeters, lasttelemetryat,
+	biketype, maintenanceduesoon`
+
+/*
+returns all bikes ordered by their bikeId.
+if minBattery is greater than 0, only bikes which reported at least this battery percentage are returned.
+bikes which are not in operation (e.g. in maintenance) are only returned if includeUnavailable is true,
+rented bikes are always returned
+*/
+func getAllBikesFromDb(ctx context.Context, db *sql.DB, minBattery int, includeUnavailable bool) (*sql.Rows, error) {
+
+	sqlStatement := `SELECT ` + bikeColumns + ` FROM ` + DB_TABLE_BIKE + ` WHERE ($1<=0 OR ` + DB_TABLE_BIKE_COLUMN_BATTERYPERCENT + `>=$1) AND ($2 OR ` +
+		DB_TABLE_BIKE_COLUMN_STATUS + ` IN ('` + BIKE_STATUS_AVAILABLE + `', '` + BIKE_STATUS_RESERVED + `', '` + BIKE_STATUS_IN_USE + `')) ORDER BY ` + DB_TABLE_BIKE_COLUMN_BIKEID
 
 	// perform query.
-	rows, dbQueryError := db.Query(sqlStatement)
+	rows, dbQueryError := db.QueryContext(ctx, sqlStatement, minBattery, includeUnavailable)
 	if dbQueryError != nil {
-		return nil, fmt.Errorf("error retrieving all records from table " + tableName)
+		return nil, fmt.Errorf("error retrieving all records from table %v. %w", DB_TABLE_BIKE, dbQueryError)
 	}
 	return rows, nil
 }
 
+// scans a row which was selected with bikeColumns into a bike object
+func scanBike(rows *sql.Rows) (*BikeImpl, error) {
+	bike := BikeImpl{}
+	// reservationID is a nullstring type and will be converted to "rented" (boolean) in the transform method
+	scanError := rows.Scan(&bike.BikeId, &bike.Name, &bike.Latitude, &bike.Longitude, &bike.ReservationId, &bike.Status, &bike.StationId,
+		&bike.BatteryPercent, &bike.EstimatedRangeKm, &bike.FullRangeKm, &bike.Locked, &bike.OdometerMeters, &bike.LastTelemetryAt,
+		&bike.BikeType, &bike.MaintenanceDueSoon)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into bikeobject", DB_TABLE_BIKE)
+	}
+	return &bike, nil
+}
+
 /* returns all reserved bikes for a user from the reservation table */
-func getBikeReservationsForUserFromDb(db *sql.DB, username string) (*sql.Rows, error) {
+func getBikeReservationsForUserFromDb(ctx context.Context, db *sql.DB, username string) (*sql.Rows, error) {
 
-	// prepare Statement
-	sqlStatement := getAllRecordsWithSingleConditionStatement(DB_TABLE_RESERVATION, DB_TABLE_RESERVATION_COLUMN_USERNAME)
+	// prepare Statement. The columns are listed explicitly, since the reservation table has more columns than the reservation object
+	sqlStatement := `SELECT ` + DB_TABLE_RESERVATION_COLUMN_RESERVATIONID + `, ` + DB_TABLE_RESERVATION_COLUMN_BIKEID + `, ` + DB_TABLE_RESERVATION_COLUMN_USERNAME +
+		` FROM ` + DB_TABLE_RESERVATION + ` WHERE ` + DB_TABLE_RESERVATION_COLUMN_USERNAME + `=$1;`
 
 	// Perform Query
-	rows, dbQueryError := db.Query(sqlStatement, username)
+	rows, dbQueryError := db.QueryContext(ctx, sqlStatement, username)
 	if dbQueryError != nil {
 		return nil, fmt.Errorf("error retrieving bike reservations for user %v", username)
 	}
 	return rows, nil
 }
 
-/*
-function which queries all records for a given username in the user table to verify if the user exists
-returns true if the user exists in the database
-*/
-func userExistsInDb(db *sql.DB, username string) (bool, error) {
+/* returns how many bikes a user has currently reserved */
+func countReservationsOfUser(ctx context.Context, db dbQueryer, username string) (int, error) {
 
-	queryString := getAllRecordsWithSingleConditionStatement(DB_TABLE_USER, DB_TABLE_USER_COLUMN_USERNAME)
-	rows, dbQueryError := db.Query(queryString, username)
+	sqlStatement := `SELECT count(*) FROM ` + DB_TABLE_RESERVATION + ` WHERE ` + DB_TABLE_RESERVATION_COLUMN_USERNAME + `=$1;`
 
+	var reservationCount int
+	dbQueryError := db.QueryRowContext(ctx, sqlStatement, username).Scan(&reservationCount)
 	if dbQueryError != nil {
-		return false, nil
+		return 0, fmt.Errorf("error counting bike reservations for user %v. %w", username, dbQueryError)
 	}
+	return reservationCount, nil
+}
 
-	if rows.Next() {
-		// record exists
-		return true, nil
+/*
+function which checks in the user table if the user exists.
+returns true if the user exists in the database, a failed query, e.g. because it was cancelled, is returned as error
+*/
+func userExistsInDb(ctx context.Context, db dbQueryer, username string) (bool, error) {
+	var exists bool
+	dbQueryError := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM `+DB_TABLE_USER+` WHERE `+DB_TABLE_USER_COLUMN_USERNAME+`=$1);`, username).Scan(&exists)
+	if dbQueryError != nil {
+		return false, fmt.Errorf("error checking if user %v exists. %w", username, dbQueryError)
 	}
-
-	return false, nil
+	return exists, nil
 }
 
 /*
-function which queries all records for a given bikeId in the given table to verify if the bikeId exists
-returns true if the bikeId exists in the table
+function which locks the record of the user in the user table until the transaction ends.
+returns a NotFoundError if the user does not exist, a failed query is returned as error
 */
-func bikeIdExistsInTable(db *sql.DB, tablename string, bikeId int) (bool, error) {
-	queryString := getAllRecordsWithSingleConditionStatement(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_BIKEID)
-	rows, dbQueryError := db.Query(queryString, bikeId)
-
+func lockUserForUpdate(ctx context.Context, tx dbQueryer, username string) error {
+	var lockedUsername string
+	dbQueryError := tx.QueryRowContext(ctx, `SELECT `+DB_TABLE_USER_COLUMN_USERNAME+` FROM `+DB_TABLE_USER+` WHERE `+DB_TABLE_USER_COLUMN_USERNAME+`=$1 FOR UPDATE;`,
+		username).Scan(&lockedUsername)
+	if errors.Is(dbQueryError, sql.ErrNoRows) {
+		return NotFoundError(ERROR_CODE_USER_NOT_FOUND, "provided username does not exist in database")
+	}
 	if dbQueryError != nil {
-		return false, nil
+		return fmt.Errorf("could not lock user %v. %w", username, dbQueryError)
 	}
+	return nil
+}
 
-	if rows.Next() {
-		// record exists
-		return true, nil
+/*
+function which checks in the given table if a record with the bikeId exists.
+returns true if the bikeId exists in the table, a failed query, e.g. because it was cancelled, is returned as error
+*/
+func bikeIdExistsInTable(ctx context.Context, db dbQueryer, tablename string, bikeId int) (bool, error) {
+	var exists bool
+	dbQueryError := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM `+tablename+` WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$1);`, bikeId).Scan(&exists)
+	if dbQueryError != nil {
+		return false, fmt.Errorf("error checking if bike %v exists in table %v. %w", bikeId, tablename, dbQueryError)
 	}
-
-	return false, nil
+	return exists, nil
 }
 
 /*
 function, which looks up, if the bike with given bikeId is available for rent
-It is available for rent, if the reservationId in the record is null
+It is available for rent, if the reservationId in the record is null and its status is available
 returns true, if bike is available.
 */
-func bikeIsAvailableForRent(db *sql.DB, bikeId int) (bool, error) {
-	queryString := getAllRecordsWithSingleConditionStatement(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_BIKEID)
-	rows, dbQueryError := db.Query(queryString, bikeId)
+func bikeIsAvailableForRent(ctx context.Context, db dbQueryer, bikeId int) (bool, error) {
+	queryString := `SELECT ` + bikeColumns + ` FROM ` + DB_TABLE_BIKE + ` WHERE ` + DB_TABLE_BIKE_COLUMN_BIKEID + `=$1;`
+	rows, dbQueryError := db.QueryContext(ctx, queryString, bikeId)
 
 	if dbQueryError != nil {
-		return false, nil
+		return false, fmt.Errorf("error checking if bike %v is available. %w", bikeId, dbQueryError)
 	}
+	defer rows.Close()
 
 	if rows.Next() {
 		// record exists
 		// if a record exists, look for a uuid of a reservation
-		tempBike := BikeImpl{}
-		// fill the object
-		scanError := rows.Scan(&tempBike.BikeId, &tempBike.Name, &tempBike.Latitude, &tempBike.Longitude, &tempBike.ReservationId)
+		tempBike, scanError := scanBike(rows)
 		if scanError != nil {
-			return false, fmt.Errorf("error scanning fields. could not scan rows of %v into bikeobject", DB_TABLE_BIKE)
+			return false, scanError
 		}
 		// if it has a reservationId, then the bike is not available for rent
-		if tempBike.ReservationId.Valid {
+		if tempBike.ReservationId.Valid || tempBike.Status != BIKE_STATUS_AVAILABLE {
 			return false, nil
 		}
 
@@ -155,78 +260,70 @@ func bikeIsAvailableForRent(db *sql.DB, bikeId int) (bool, error) {
 	}
 
 	// provided bikeId does not exist
-	return false, fmt.Errorf("provided bike Id does not exist")
+	return false, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bike Id does not exist")
 }
 
 /*
 function, which takes a bikeId, and returns the corresponding Bike object
 */
-func getBikeFromDb(db *sql.DB, bikeId int) (*BikeImpl, error) {
+func getBikeFromDb(ctx context.Context, db dbQueryer, bikeId int) (*BikeImpl, error) {
 
-	queryString := getAllRecordsWithSingleConditionStatement(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_BIKEID)
-	rows, dbQueryError := db.Query(queryString, bikeId)
+	queryString := `SELECT ` + bikeColumns + ` FROM ` + DB_TABLE_BIKE + ` WHERE ` + DB_TABLE_BIKE_COLUMN_BIKEID + `=$1;`
+	rows, dbQueryError := db.QueryContext(ctx, queryString, bikeId)
 
 	if dbQueryError != nil {
-		return nil, fmt.Errorf("could not retrieve bike with %v %v from table", DB_TABLE_BIKE_COLUMN_BIKEID, bikeId, DB_TABLE_BIKE)
+		return nil, fmt.Errorf("could not retrieve bike with %v %v from table %v", DB_TABLE_BIKE_COLUMN_BIKEID, bikeId, DB_TABLE_BIKE)
 	}
+	defer rows.Close()
 
-	targetBike := BikeImpl{}
 	if rows.Next() {
 		// record exists
 		// fill the object
-		scanError := rows.Scan(&targetBike.BikeId, &targetBike.Name, &targetBike.Latitude, &targetBike.Longitude, &targetBike.ReservationId)
-		if scanError != nil {
-			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into bikeobject", DB_TABLE_BIKE)
-		}
-
+		return scanBike(rows)
 	}
 
-	return &targetBike, nil
+	return &BikeImpl{}, nil
 }
 
 /*
-function which creates a new record in the reservation table.
+function which creates a new record in the reservation table and marks the bike as reserved.
+it is called in a transaction, so the reservation is not stored if the bike can not be reserved.
 
-	1st param: bikeId
+	1st param: the bike which gets reserved. Its position is stored as start position of the ride
 	2nd param: username
+	3rd param: the organization which pays for the ride, invalid for personal billing
 
 returns the primary key which is the newly generated uuid
 */
-func createRecordInReservationTable(database *sql.DB, bikeId int, username string) (*string, error) {
+func createRecordInReservationTable(ctx context.Context, tx dbQueryer, bike *BikeImpl, username string, billingOrganizationId sql.NullString) (*string, error) {
 
-	insertStatement := getInsertStmtThreeColumns(DB_TABLE_RESERVATION, DB_TABLE_RESERVATION_COLUMN_RESERVATIONID, DB_TABLE_RESERVATION_COLUMN_BIKEID, DB_TABLE_RESERVATION_COLUMN_USERNAME)
+	bikeId := bike.BikeId
+	insertStatement := getInsertStmt(DB_TABLE_RESERVATION, DB_TABLE_RESERVATION_COLUMN_RESERVATIONID, DB_TABLE_RESERVATION_COLUMN_BIKEID, DB_TABLE_RESERVATION_COLUMN_USERNAME,
+		DB_TABLE_RESERVATION_COLUMN_STARTLATITUDE, DB_TABLE_RESERVATION_COLUMN_STARTLONGITUDE, DB_TABLE_RESERVATION_COLUMN_BILLINGORGANIZATIONID)
 
 	newReservationId := uuid.New().String() // create new uuid for reservationId
-	_, dbInsertError := database.Exec(insertStatement, newReservationId, bikeId, username)
+	_, dbInsertError := tx.ExecContext(ctx, insertStatement, newReservationId, bikeId, username, bike.Latitude, bike.Longitude, billingOrganizationId)
 	if dbInsertError != nil {
-		duplicateErrorMessage := "duplicate key value"
-		if strings.Contains(dbInsertError.Error(), duplicateErrorMessage) {
-			return nil, fmt.Errorf("could not rent bike. User already has a rented bike")
-		}
-
-		return nil, fmt.Errorf("could not insert record into reservation Table. %v", dbInsertError)
+		return nil, fmt.Errorf("could not insert record into reservation Table. %w", dbInsertError)
 	}
 
 	// update bike table
 	// get the update statement
 	updateStmt := getUpdateStmtOneColumn(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_RESERVATIONID, DB_TABLE_BIKE_COLUMN_BIKEID)
-	_, dbUpdateError := database.Exec(updateStmt, newReservationId, bikeId)
+	_, dbUpdateError := tx.ExecContext(ctx, updateStmt, newReservationId, bikeId)
+	if dbUpdateError == nil {
+		// a rented bike leaves its dock, so the dock is free for other bikes during the ride
+		releaseStmt := getUpdateStmtOneColumn(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_STATIONID, DB_TABLE_BIKE_COLUMN_BIKEID)
+		_, dbUpdateError = tx.ExecContext(ctx, releaseStmt, nil, bikeId)
+	}
+	if dbUpdateError == nil {
+		// the bike must still be available, otherwise an operator changed its status in the meantime
+		_, dbUpdateError = transitionBikeStatus(ctx, tx, bikeId, BIKE_STATUS_AVAILABLE, BIKE_STATUS_RESERVED, "reserved", username)
+	}
 
 	if dbUpdateError != nil {
-		/*
-			If the record in the reservation table has been created, the update Statement needs to succeed also in the bikeTable.
-			If the update does not succeed, we have an inconsistency in the database. Thus, we need to delete the newly created record which we created in the reservation table.
-		*/
-
-		// build the delete statement and delete record from database
-		deleteStatement := getDeleteRowStatement(DB_TABLE_RESERVATION, DB_TABLE_RESERVATION_COLUMN_BIKEID)
-		_, dbDeleteError := database.Exec(deleteStatement, bikeId)
-		if dbDeleteError != nil {
-			return nil, fmt.Errorf("WARNING! Inconsistency! Tried to delete newly created reservation for bike with BikeId %v but failed. Delete manually if possible. Error: %v", bikeId, dbDeleteError)
-		}
-
-		return nil, fmt.Errorf("could not insert record into reservation Table. %v", dbInsertError) // TODO: if update fails, delete entry in reservation table
-
+		// the transaction is rolled back, so the reservation is not stored
+		return nil, fmt.Errorf("could not insert record into reservation Table. %w", dbUpdateError)
 	}
 
 	return &newReservationId, nil
@@ -244,26 +341,21 @@ func getDeleteRowStatement(dbTableName string, columnName string) string {
 }
 
 /*
-function which returns a query Statement with one condition
-example: SELECT * FROM TABLENAME WHERE CONDITION=$1
-*/
-func getAllRecordsWithSingleConditionStatement(dbTableName string, columnName string) string {
-	queryString := `SELECT * FROM ` + dbTableName + ` WHERE ` + columnName + `=$1;`
-	return queryString
-}
-
-/*
-returns an insert statement string for a table with 3 columns
+returns an insert statement string for a table with the given columns
 example: INSERT INTO TABLENAME (COLUMNNAME1, COLUMNNAME2, COLUMNNAME3) VALUES ($1, $2, $3)
 */
-
-func getInsertStmtThreeColumns(tableName string, column1 string, column2 string, column3 string) string {
+func getInsertStmt(tableName string, columns ...string) string {
 	insertInto := `insert into `
 	table := `"` + tableName + `"`
-	columns := `("` + column1 + `", "` + column2 + `", "` + column3 + `") `
-	values := `values($1, $2, $3)`
 
-	insertStatement := insertInto + table + columns + values
+	quotedColumns := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for index, column := range columns {
+		quotedColumns[index] = `"` + column + `"`
+		placeholders[index] = fmt.Sprintf("$%d", index+1)
+	}
+
+	insertStatement := insertInto + table + `(` + strings.Join(quotedColumns, ", ") + `) ` + `values(` + strings.Join(placeholders, ", ") + `)`
 
 	return insertStatement
 }
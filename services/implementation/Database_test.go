@@ -0,0 +1,129 @@
+package implementation
+
+import (
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the time a test waits for a query which should abort, a query which ignores its context blocks forever
+const testAbortWait = 2 * time.Second
+
+// replaces the database of the methods with a fake database for one test
+func useFakeDB(t *testing.T, handler fakedb.Handler) {
+	t.Helper()
+	db := fakedb.Open(handler)
+	UseDB(db)
+	t.Cleanup(func() {
+		db.Close()
+		UseDB(nil)
+	})
+}
+
+// runs a method in the background and returns its error, or fails the test if the method does not return in time
+func waitForError(t *testing.T, method func() error) error {
+	t.Helper()
+	result := make(chan error, 1)
+	go func() {
+		result <- method()
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(testAbortWait):
+		t.Fatalf("the query did not abort within %v", testAbortWait)
+		return nil
+	}
+}
+
+func TestQueryAbortsWhenClientGoesAway(t *testing.T) {
+	useFakeDB(t, fakedb.Block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := waitForError(t, func() error {
+		_, getBikesError := GetAllBikes(ctx, 0, false)
+		return getBikesError
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestQueryAbortsAfterDeadline(t *testing.T) {
+	useFakeDB(t, fakedb.Block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	bikeId := 1
+	err := waitForError(t, func() error {
+		_, reserveError := ReserveBike(ctx, BikeReservationImpl{Username: "userOne", BikeId: &bikeId})
+		return reserveError
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Fatalf("a cancelled existence check must not be reported as not found, got %v", err)
+	}
+}
+
+func TestExistenceCheckReturnsFailedQuery(t *testing.T) {
+	queryError := errors.New("connection reset")
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		return nil, queryError
+	})
+
+	db, _ := SetupDB()
+	exists, err := bikeIdExistsInTable(context.Background(), db, DB_TABLE_BIKE, 1)
+	if exists || !errors.Is(err, queryError) {
+		t.Fatalf("expected the error of the query, got %v and %v", exists, err)
+	}
+}
+
+func TestReservationCountsRentedBikesAfterLockingUser(t *testing.T) {
+	var statements []fakedb.Statement
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		statements = append(statements, statement)
+		switch {
+		case strings.HasSuffix(statement.Query, "FOR UPDATE;"):
+			return &fakedb.Result{Columns: []string{DB_TABLE_USER_COLUMN_USERNAME}, Rows: [][]driver.Value{{"userOne"}}}, nil
+		case strings.HasPrefix(statement.Query, "SELECT count(*) FROM "+DB_TABLE_RESERVATION):
+			return &fakedb.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(DEFAULT_MAX_CONCURRENT_BIKES)}}}, nil
+		case strings.HasPrefix(statement.Query, "SELECT "+subscriptionColumns):
+			return &fakedb.Result{Columns: strings.Split(subscriptionColumns, ", ")}, nil
+		}
+		return &fakedb.Result{}, nil
+	})
+
+	bikeId := 1
+	_, err := ReserveBike(context.Background(), BikeReservationImpl{Username: "userOne", BikeId: &bikeId})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected the rental limit to be reached, got %v", err)
+	}
+
+	lockIndex, countIndex := -1, -1
+	for index, statement := range statements {
+		if strings.HasSuffix(statement.Query, "FOR UPDATE;") && lockIndex < 0 {
+			lockIndex = index
+		}
+		if strings.HasPrefix(statement.Query, "SELECT count(*) FROM "+DB_TABLE_RESERVATION) {
+			countIndex = index
+			if !statement.InTransaction {
+				t.Fatalf("expected the reservations to be counted in the transaction of the reservation")
+			}
+		}
+	}
+	if lockIndex != 0 || countIndex < lockIndex {
+		t.Fatalf("expected the user to be locked before the reservations are counted, got the lock at %v and the count at %v", lockIndex, countIndex)
+	}
+	if !statements[lockIndex].InTransaction || statements[lockIndex].Args[0] != "userOne" {
+		t.Fatalf("expected userOne to be locked in the transaction, got %+v", statements[lockIndex])
+	}
+}
@@ -0,0 +1,107 @@
+package implementation
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// ---------- types of the domain events which are written to the outbox ---------
+	DOMAIN_EVENT_RESERVATION_CREATED = "reservation.created"
+	DOMAIN_EVENT_RESERVATION_ENDED   = "reservation.ended"
+	DOMAIN_EVENT_RIDE_FINISHED       = "ride.finished"
+	DOMAIN_EVENT_BIKE_MOVED          = "bike.moved"
+	DOMAIN_EVENT_BIKE_STATUS_CHANGED = "bike.status_changed"
+	DOMAIN_EVENT_THEFT_ALERT_RAISED  = "theft_alert.raised"
+
+	// ---------- aggregates of the domain events, the events of one aggregate are published in the order of their changes ---------
+	DOMAIN_AGGREGATE_BIKE = "bike"
+
+	// ---------- sources of the position of a bike.moved event ---------
+	BIKE_MOVED_BY_POSITION  = "position"
+	BIKE_MOVED_BY_TELEMETRY = "telemetry"
+)
+
+/*
+represents the database structure for the table "outboxevent" in the DATABASE.
+a domain event is written in the transaction of the change, the dispatcher publishes it to the sinks after the commit.
+the sequence orders the events, PublishedAt is null until every sink received the event
+*/
+type DomainEventImpl struct {
+	Sequence      int64           `json:"sequence"`
+	EventId       string          `json:"eventId"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregateType"`
+	AggregateId   string          `json:"aggregateId"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	Payload       json.RawMessage `json:"payload"`
+	PublishedAt   *time.Time      `json:"publishedAt"`
+	AttemptCount  int             `json:"attemptCount"`
+	LastError     string          `json:"lastError"`
+}
+
+/*
+represents the filter of an operator for the domain events in the outbox
+*/
+type DomainEventFilterImpl struct {
+	Type          string
+	AggregateType string
+	AggregateId   string
+	// only the events after this sequence, e.g. to read the events page by page
+	AfterSequence int64
+	// only the events which were not published yet
+	Pending bool
+	Limit   int
+}
+
+/*
+represents the payload of the event reservation.created
+*/
+type ReservationCreatedEventImpl struct {
+	ReservationId         string    `json:"reservationId"`
+	BikeId                int       `json:"bikeId"`
+	Username              string    `json:"username"`
+	StartedAt             time.Time `json:"startedAt"`
+	Latitude              string    `json:"latitude"`
+	Longitude             string    `json:"longitude"`
+	BillingOrganizationId *string   `json:"billingOrganizationId"`
+}
+
+/*
+represents the payload of the event reservation.ended, it is followed by the event ride.finished of the same ride
+*/
+type ReservationEndedEventImpl struct {
+	ReservationId string    `json:"reservationId"`
+	BikeId        int       `json:"bikeId"`
+	Username      string    `json:"username"`
+	StartedAt     time.Time `json:"startedAt"`
+	EndedAt       time.Time `json:"endedAt"`
+}
+
+/*
+represents the payload of the event ride.finished
+*/
+type RideFinishedEventImpl struct {
+	RideId          string    `json:"rideId"`
+	BikeId          int       `json:"bikeId"`
+	Username        string    `json:"username"`
+	StartedAt       time.Time `json:"startedAt"`
+	EndedAt         time.Time `json:"endedAt"`
+	DurationMinutes int       `json:"durationMinutes"`
+	EndLatitude     string    `json:"endLatitude"`
+	EndLongitude    string    `json:"endLongitude"`
+	EndStationId    *int64    `json:"endStationId"`
+	TotalCents      int       `json:"totalCents"`
+	Currency        string    `json:"currency"`
+}
+
+/*
+represents the payload of the event bike.moved, the bike reported its position or its telemetry
+*/
+type BikeMovedEventImpl struct {
+	BikeId    int       `json:"bikeId"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Source    string    `json:"source"`
+	MovedAt   time.Time `json:"movedAt"`
+}
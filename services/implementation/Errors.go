@@ -0,0 +1,167 @@
+package implementation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// the kinds of the domain errors, the handlers map them to the HTTP status codes
+var (
+	// the request can not be read, e.g. an id which is not a number
+	ErrBadRequest = errors.New("bad request")
+	// the request was read, but a value is not allowed
+	ErrValidation = errors.New("validation failed")
+	ErrNotFound   = errors.New("not found")
+	// the request does not fit the current state, e.g. a bike which is already rented
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	// the caller is known, but not allowed to do this
+	ErrForbidden = errors.New("forbidden")
+)
+
+const (
+	// ---------- stable codes of the errors, the message of an error may change, its code does not ---------
+	ERROR_CODE_BAD_REQUEST  = "bad_request"
+	ERROR_CODE_VALIDATION   = "validation_failed"
+	ERROR_CODE_NOT_FOUND    = "not_found"
+	ERROR_CODE_CONFLICT     = "conflict"
+	ERROR_CODE_UNAUTHORIZED = "unauthorized"
+	ERROR_CODE_FORBIDDEN    = "forbidden"
+	ERROR_CODE_INTERNAL     = "internal_error"
+	ERROR_CODE_TIMEOUT      = "timeout"
+
+	ERROR_CODE_USER_NOT_FOUND                = "user_not_found"
+	ERROR_CODE_BIKE_NOT_FOUND                = "bike_not_found"
+	ERROR_CODE_BIKE_NOT_AVAILABLE            = "bike_not_available"
+	ERROR_CODE_BIKE_BATTERY_LOW              = "bike_battery_low"
+	ERROR_CODE_BIKE_NOT_RENTED               = "bike_not_rented"
+	ERROR_CODE_BIKE_WITHOUT_DEVICE           = "bike_without_device"
+	ERROR_CODE_RENTAL_LIMIT_REACHED          = "rental_limit_reached"
+	ERROR_CODE_RESERVATION_NOT_FOUND         = "reservation_not_found"
+	ERROR_CODE_RESERVATION_NOT_OWNED         = "reservation_not_owned"
+	ERROR_CODE_STATUS_TRANSITION_NOT_ALLOWED = "status_transition_not_allowed"
+	// the state changed between reading and writing it, the request can be retried
+	ERROR_CODE_CONCURRENT_CHANGE           = "concurrent_change"
+	ERROR_CODE_STATION_NOT_FOUND           = "station_not_found"
+	ERROR_CODE_STATION_FULL                = "station_full"
+	ERROR_CODE_ZONE_NOT_FOUND              = "zone_not_found"
+	ERROR_CODE_RECEIPT_NOT_FOUND           = "receipt_not_found"
+	ERROR_CODE_PENALTY_NOT_FOUND           = "penalty_not_found"
+	ERROR_CODE_PENALTY_ALREADY_WAIVED      = "penalty_already_waived"
+	ERROR_CODE_PROMOTION_NOT_FOUND         = "promotion_not_found"
+	ERROR_CODE_PROMOTION_EXISTS            = "promotion_exists"
+	ERROR_CODE_PROMOTION_NOT_APPLICABLE    = "promotion_not_applicable"
+	ERROR_CODE_PLAN_NOT_FOUND              = "plan_not_found"
+	ERROR_CODE_PLAN_EXISTS                 = "plan_exists"
+	ERROR_CODE_PLAN_NOT_AVAILABLE          = "plan_not_available"
+	ERROR_CODE_SUBSCRIPTION_NOT_FOUND      = "subscription_not_found"
+	ERROR_CODE_SUBSCRIPTION_EXISTS         = "subscription_exists"
+	ERROR_CODE_SUBSCRIPTION_CANCELLED      = "subscription_cancelled"
+	ERROR_CODE_ORGANIZATION_NOT_FOUND      = "organization_not_found"
+	ERROR_CODE_ORGANIZATION_EXISTS         = "organization_exists"
+	ERROR_CODE_NOT_ORGANIZATION_MEMBER     = "not_organization_member"
+	ERROR_CODE_SPENDING_LIMIT_REACHED      = "spending_limit_reached"
+	ERROR_CODE_WEBHOOK_NOT_FOUND           = "webhook_not_found"
+	ERROR_CODE_WEBHOOK_INACTIVE            = "webhook_inactive"
+	ERROR_CODE_ALERT_NOT_FOUND             = "alert_not_found"
+	ERROR_CODE_WORK_ORDER_NOT_FOUND        = "work_order_not_found"
+	ERROR_CODE_WORK_ORDER_DONE             = "work_order_done"
+	ERROR_CODE_DAMAGE_REPORT_NOT_FOUND     = "damage_report_not_found"
+	ERROR_CODE_PHOTO_NOT_FOUND             = "photo_not_found"
+	ERROR_CODE_COMMAND_NOT_FOUND           = "command_not_found"
+	ERROR_CODE_COMMAND_NOT_PENDING         = "command_not_pending"
+	ERROR_CODE_DEVICE_UNAUTHORIZED         = "device_unauthorized"
+	ERROR_CODE_IDEMPOTENCY_KEY_REUSED      = "idempotency_key_reused"
+	ERROR_CODE_IDEMPOTENCY_KEY_IN_PROGRESS = "idempotency_key_in_progress"
+
+	// the code of postgres for a violated unique constraint
+	PQ_UNIQUE_VIOLATION = "23505"
+)
+
+/*
+an error of the domain, e.g. a bike which does not exist. Its kind decides the HTTP status code
+and its code identifies the error for the clients. Other errors are internal errors of the API
+*/
+type DomainError struct {
+	Kind    error
+	Code    string
+	Message string
+	// the values of the request which are not allowed, e.g. for a validation error
+	Fields []FieldErrorImpl
+}
+
+/*
+represents a value of a request which is not allowed
+*/
+type FieldErrorImpl struct {
+	Field   string `json:"field"` // e.g. latitude or bbox.minLatitude
+	Message string `json:"message"`
+}
+
+func (domainError *DomainError) Error() string {
+	return domainError.Message
+}
+
+// lets errors.Is find the kind of a domain error, e.g. errors.Is(err, ErrNotFound)
+func (domainError *DomainError) Is(target error) bool {
+	return target == domainError.Kind
+}
+
+// returns an error for a request which can not be read
+func BadRequestError(code string, format string, arguments ...interface{}) error {
+	return newDomainError(ErrBadRequest, code, format, arguments...)
+}
+
+// returns an error for a value which is not allowed
+func ValidationError(code string, format string, arguments ...interface{}) error {
+	return newDomainError(ErrValidation, code, format, arguments...)
+}
+
+// returns an error for something which does not exist
+func NotFoundError(code string, format string, arguments ...interface{}) error {
+	return newDomainError(ErrNotFound, code, format, arguments...)
+}
+
+// returns an error for a request which does not fit the current state
+func ConflictError(code string, format string, arguments ...interface{}) error {
+	return newDomainError(ErrConflict, code, format, arguments...)
+}
+
+// returns an error for a caller who is not known
+func UnauthorizedError(code string, format string, arguments ...interface{}) error {
+	return newDomainError(ErrUnauthorized, code, format, arguments...)
+}
+
+// returns an error for a caller who is not allowed to do this
+func ForbiddenError(code string, format string, arguments ...interface{}) error {
+	return newDomainError(ErrForbidden, code, format, arguments...)
+}
+
+/*
+returns a validation error for all values of a request which are not allowed, so a client can show them at once.
+returns nil if there are no field errors
+*/
+func FieldValidationError(fieldErrors []FieldErrorImpl) error {
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(fieldErrors))
+	for index, fieldError := range fieldErrors {
+		messages[index] = fieldError.Field + ": " + fieldError.Message
+	}
+	return &DomainError{Kind: ErrValidation, Code: ERROR_CODE_VALIDATION, Message: strings.Join(messages, "; "), Fields: fieldErrors}
+}
+
+func newDomainError(kind error, code string, format string, arguments ...interface{}) error {
+	return &DomainError{Kind: kind, Code: code, Message: fmt.Sprintf(format, arguments...)}
+}
+
+// returns true, if the database rejected a statement because of a unique constraint, e.g. a code which already exists
+func isUniqueViolation(err error) bool {
+	var pqError *pq.Error
+	return errors.As(err, &pqError) && pqError.Code == PQ_UNIQUE_VIOLATION
+}
@@ -0,0 +1,116 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+/*
+a sink receives every domain event which the outbox dispatcher publishes, e.g. the webhooks or the event log.
+the delivery is at-least-once: a sink receives an event again if the event could not be marked as published,
+e.g. because a later sink failed or the API stopped. A sink which writes to the database uses the transaction,
+then its writes are only committed together with the mark and it receives every event exactly once.
+an error stops the dispatcher at this event until the sink accepts it, so a sink must not fail on an event it can not handle
+*/
+type DomainEventSink interface {
+	Name() string
+	Publish(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error
+}
+
+/*
+the bus of the domain events in the process, the sinks receive the events in the order they were registered.
+the bike stream is not a sink: its clients are connected to every instance of the API, but only one instance dispatches the outbox
+*/
+type eventBus struct {
+	mutex sync.RWMutex
+	sinks []DomainEventSink
+}
+
+var domainEventBus = &eventBus{}
+
+// a subscriber in the process which receives the events of some types, all events if no type is given
+type domainEventSubscriber struct {
+	name       string
+	eventTypes map[string]bool
+	handle     func(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error
+}
+
+// writes every event as JSON line, e.g. to ship them to a log aggregator
+type logEventSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+/*
+registers a sink on the bus, it receives all events which are published from now on.
+sinks are registered before the outbox dispatcher is started
+*/
+func RegisterDomainEventSink(sink DomainEventSink) {
+	domainEventBus.mutex.Lock()
+	defer domainEventBus.mutex.Unlock()
+
+	domainEventBus.sinks = append(domainEventBus.sinks, sink)
+}
+
+/*
+subscribes a function in the process to the events of the types, all events if no type is given.
+the function is called after the change was committed, an error is retried like the error of any sink.
+it runs in the transaction of the dispatcher, so its writes are committed together with the mark of the event
+*/
+func SubscribeDomainEvents(name string, handle func(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error, eventTypes ...string) {
+	subscriber := &domainEventSubscriber{name: name, eventTypes: map[string]bool{}, handle: handle}
+	for _, eventType := range eventTypes {
+		subscriber.eventTypes[eventType] = true
+	}
+	RegisterDomainEventSink(subscriber)
+}
+
+// returns a sink which writes every event as JSON line to the writer
+func NewLogEventSink(writer io.Writer) DomainEventSink {
+	return &logEventSink{writer: writer}
+}
+
+// publishes an event to all sinks, the first error stops the event
+func (bus *eventBus) publish(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error {
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	for _, sink := range bus.sinks {
+		publishError := sink.Publish(ctx, tx, event)
+		if publishError != nil {
+			return fmt.Errorf("sink %v failed. %w", sink.Name(), publishError)
+		}
+	}
+	return nil
+}
+
+func (subscriber *domainEventSubscriber) Name() string {
+	return subscriber.name
+}
+
+func (subscriber *domainEventSubscriber) Publish(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error {
+	if len(subscriber.eventTypes) > 0 && !subscriber.eventTypes[event.Type] {
+		return nil
+	}
+	return subscriber.handle(ctx, tx, event)
+}
+
+func (sink *logEventSink) Name() string {
+	return "event log"
+}
+
+func (sink *logEventSink) Publish(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error {
+	eventJson, marshalError := json.Marshal(event)
+	if marshalError != nil {
+		return marshalError
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	_, writeError := fmt.Fprintf(sink.writer, "%s\n", eventJson)
+	return writeError
+}
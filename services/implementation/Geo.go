@@ -0,0 +1,124 @@
+package implementation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+/*
+a point of a polygon in GeoJSON order: longitude first, latitude second
+*/
+type GeoPointImpl [2]float64
+
+/*
+a polygon as in GeoJSON: the first ring is the outer boundary, all other rings are holes.
+every ring is closed, the first point equals the last point
+*/
+type GeoPolygonImpl [][]GeoPointImpl
+
+// returns an error with both coordinates if they are not a valid position on earth
+func validateCoordinates(latitude float64, longitude float64) error {
+	validator := fieldValidator{}
+	validator.coordinates("latitude", latitude, "longitude", longitude)
+	return validator.err()
+}
+
+// parses the coordinates of a bike, which are scanned as strings, into numbers
+func parseCoordinates(latitude string, longitude string) (float64, float64, error) {
+	parsedLatitude, parseLatitudeError := strconv.ParseFloat(latitude, 64)
+	if parseLatitudeError != nil {
+		return 0, 0, fmt.Errorf("latitude %v is not a number", latitude)
+	}
+	parsedLongitude, parseLongitudeError := strconv.ParseFloat(longitude, 64)
+	if parseLongitudeError != nil {
+		return 0, 0, fmt.Errorf("longitude %v is not a number", longitude)
+	}
+	return parsedLatitude, parsedLongitude, nil
+}
+
+/*
+returns true if the point is inside the polygon.
+the point is inside, if it is inside the outer ring and not inside one of the holes
+*/
+func (polygon GeoPolygonImpl) contains(latitude float64, longitude float64) bool {
+	if len(polygon) == 0 || !ringContains(polygon[0], latitude, longitude) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, latitude, longitude) {
+			return false
+		}
+	}
+	return true
+}
+
+// returns true if the position is inside of the bounding box
+func (bounds BoundingBoxImpl) contains(latitude float64, longitude float64) bool {
+	return latitude >= bounds.MinLatitude && latitude <= bounds.MaxLatitude &&
+		longitude >= bounds.MinLongitude && longitude <= bounds.MaxLongitude
+}
+
+/*
+ray casting: a ray from the point to the east crosses the border of the ring an odd number of times, if the point is inside.
+for the size of a city the earth can be treated as flat
+*/
+func ringContains(ring []GeoPointImpl, latitude float64, longitude float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		lonI, latI := ring[i][0], ring[i][1]
+		lonJ, latJ := ring[j][0], ring[j][1]
+		if (latI > latitude) != (latJ > latitude) &&
+			longitude < (lonJ-lonI)*(latitude-latI)/(latJ-latI)+lonI {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+/*
+checks that every ring of the polygon has at least three distinct points and valid coordinates.
+rings which are not closed are closed by appending the first point
+*/
+func normalizePolygon(polygon GeoPolygonImpl) (GeoPolygonImpl, error) {
+	if len(polygon) == 0 {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "polygon has no rings")
+	}
+
+	normalized := GeoPolygonImpl{}
+	for _, ring := range polygon {
+		if len(ring) > 0 && ring[0] != ring[len(ring)-1] {
+			ring = append(ring, ring[0])
+		}
+		// a closing point or a repeated point does not span an area
+		distinctPoints := map[GeoPointImpl]bool{}
+		for _, point := range ring {
+			distinctPoints[point] = true
+		}
+		if len(distinctPoints) < 3 {
+			return nil, ValidationError(ERROR_CODE_VALIDATION, "a ring of a polygon needs at least three distinct points")
+		}
+		for _, point := range ring {
+			coordinateError := validateCoordinates(point[1], point[0])
+			if coordinateError != nil {
+				return nil, coordinateError
+			}
+		}
+		normalized = append(normalized, ring)
+	}
+
+	return normalized, nil
+}
+
+// mean radius of the earth, used to compute distances
+const EARTH_RADIUS_METERS = 6371000
+
+// returns the great circle distance between two positions in meters (haversine formula)
+func distanceMeters(latitude1 float64, longitude1 float64, latitude2 float64, longitude2 float64) float64 {
+	lat1, lat2 := latitude1*math.Pi/180, latitude2*math.Pi/180
+	deltaLat := lat2 - lat1
+	deltaLon := (longitude2 - longitude1) * math.Pi / 180
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+	return 2 * EARTH_RADIUS_METERS * math.Asin(math.Sqrt(a))
+}
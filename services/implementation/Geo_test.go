@@ -0,0 +1,24 @@
+package implementation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolygonRingNeedsThreeDistinctPoints(t *testing.T) {
+	for name, ring := range map[string][]GeoPointImpl{
+		"repeated point":  {{8.63, 50.11}, {8.63, 50.11}, {8.64, 50.11}, {8.63, 50.11}},
+		"one point":       {{8.63, 50.11}, {8.63, 50.11}, {8.63, 50.11}, {8.63, 50.11}},
+		"two open points": {{8.63, 50.11}, {8.64, 50.11}, {8.64, 50.11}},
+	} {
+		_, normalizeError := normalizePolygon(GeoPolygonImpl{ring})
+		if !errors.Is(normalizeError, ErrValidation) {
+			t.Errorf("expected a ring with a %v to be invalid, got %v", name, normalizeError)
+		}
+	}
+
+	polygon, normalizeError := normalizePolygon(GeoPolygonImpl{{{8.63, 50.11}, {8.64, 50.11}, {8.64, 50.12}}})
+	if normalizeError != nil || len(polygon[0]) != 4 || polygon[0][3] != polygon[0][0] {
+		t.Fatalf("expected the triangle to be closed, got %v and %v", polygon, normalizeError)
+	}
+}
@@ -0,0 +1,159 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	DB_TABLE_IDEMPOTENCYKEY = "idempotencykey"
+
+	// a first request which did not finish in this time is abandoned, e.g. because the API stopped, and a retry runs again
+	IDEMPOTENCY_IN_PROGRESS_TIMEOUT = 5 * time.Minute
+	// interval of the job which deletes the expired keys
+	IDEMPOTENCY_PURGE_INTERVAL = time.Hour
+)
+
+// the columns of the idempotencykey table in the order they are scanned by getIdempotencyRecord
+const idempotencyRecordColumns = `username, idempotencykey, fingerprint, statuscode, headers, responsebody, createdat, expiresat`
+
+/*
+Implementation method which starts a request with an Idempotency-Key. Returns true if it is the first request with the key of the user,
+then the request runs and its response is stored with CompleteIdempotentRequest. Otherwise the stored request is returned:
+its response is replayed, or it is still in progress if its status code is nil. An expired key is used like a new key
+*/
+func BeginIdempotentRequest(ctx context.Context, username string, idempotencyKey string, fingerprint string) (*IdempotencyRecordImpl, bool, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, false, dbConnectError
+	}
+
+	now := time.Now()
+	_, dbDeleteError := db.ExecContext(ctx, `DELETE FROM `+DB_TABLE_IDEMPOTENCYKEY+` WHERE username=$1 AND idempotencykey=$2
+		AND (expiresat<$3 OR (statuscode IS NULL AND createdat<$4));`, username, idempotencyKey, now, now.Add(-IDEMPOTENCY_IN_PROGRESS_TIMEOUT))
+	if dbDeleteError != nil {
+		return nil, false, fmt.Errorf("could not delete expired record in %v Table. %w", DB_TABLE_IDEMPOTENCYKEY, dbDeleteError)
+	}
+
+	// the primary key lets only one of two concurrent requests with the same key insert
+	result, dbInsertError := db.ExecContext(ctx, `INSERT INTO `+DB_TABLE_IDEMPOTENCYKEY+` (username, idempotencykey, fingerprint, createdat, expiresat)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (username, idempotencykey) DO NOTHING;`,
+		username, idempotencyKey, fingerprint, now, now.Add(IdempotencyKeyExpiry()))
+	if dbInsertError != nil {
+		return nil, false, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_IDEMPOTENCYKEY, dbInsertError)
+	}
+	if insertedRows, _ := result.RowsAffected(); insertedRows == 1 {
+		return nil, true, nil
+	}
+
+	record, getRecordError := getIdempotencyRecord(ctx, db, username, idempotencyKey)
+	if getRecordError != nil {
+		return nil, false, getRecordError
+	}
+	return record, false, nil
+}
+
+/*
+Implementation method which stores the response of the first request with an Idempotency-Key, so it is replayed for the retries
+*/
+func CompleteIdempotentRequest(ctx context.Context, username string, idempotencyKey string, statusCode int, headers map[string]string, responseBody []byte) error {
+	headersJson, marshalError := json.Marshal(headers)
+	if marshalError != nil {
+		return fmt.Errorf("could not marshal response headers. %w", marshalError)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return dbConnectError
+	}
+
+	_, dbUpdateError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_IDEMPOTENCYKEY+` SET statuscode=$1, headers=$2, responsebody=$3
+		WHERE username=$4 AND idempotencykey=$5;`, statusCode, string(headersJson), responseBody, username, idempotencyKey)
+	if dbUpdateError != nil {
+		return fmt.Errorf("could not update record in %v Table. %w", DB_TABLE_IDEMPOTENCYKEY, dbUpdateError)
+	}
+	return nil
+}
+
+/*
+Implementation method which releases an Idempotency-Key whose first request failed with a server error, so a retry runs again
+*/
+func ReleaseIdempotencyKey(ctx context.Context, username string, idempotencyKey string) error {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return dbConnectError
+	}
+
+	_, dbDeleteError := db.ExecContext(ctx, `DELETE FROM `+DB_TABLE_IDEMPOTENCYKEY+` WHERE username=$1 AND idempotencykey=$2 AND statuscode IS NULL;`,
+		username, idempotencyKey)
+	if dbDeleteError != nil {
+		return fmt.Errorf("could not delete record in %v Table. %w", DB_TABLE_IDEMPOTENCYKEY, dbDeleteError)
+	}
+	return nil
+}
+
+/*
+Implementation method which deletes the expired Idempotency-Keys with their responses. Returns the number of deleted keys
+*/
+func PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return 0, dbConnectError
+	}
+
+	result, dbDeleteError := db.ExecContext(ctx, `DELETE FROM `+DB_TABLE_IDEMPOTENCYKEY+` WHERE expiresat<$1;`, time.Now())
+	if dbDeleteError != nil {
+		return 0, fmt.Errorf("could not delete records in %v Table. %w", DB_TABLE_IDEMPOTENCYKEY, dbDeleteError)
+	}
+	return result.RowsAffected()
+}
+
+/*
+starts the job which deletes the expired Idempotency-Keys once per interval. The returned function stops the job
+*/
+func StartIdempotencyKeyPurge(interval time.Duration) func() {
+	return startPeriodicJob("idempotency key purge", interval, func(ctx context.Context) {
+		purged, purgeError := PurgeExpiredIdempotencyKeys(ctx)
+		if purgeError != nil {
+			fmt.Printf("WARNING! idempotency key purge failed. %v\n", purgeError)
+			return
+		}
+		if purged > 0 {
+			fmt.Printf("idempotency key purge deleted %d keys\n", purged)
+		}
+	})
+}
+
+// returns the stored request of an Idempotency-Key of a user
+func getIdempotencyRecord(ctx context.Context, db dbQueryer, username string, idempotencyKey string) (*IdempotencyRecordImpl, error) {
+	var record IdempotencyRecordImpl
+	var statusCode sql.NullInt64
+	var headers string
+
+	dbQueryError := db.QueryRowContext(ctx, `SELECT `+idempotencyRecordColumns+` FROM `+DB_TABLE_IDEMPOTENCYKEY+` WHERE username=$1 AND idempotencykey=$2;`,
+		username, idempotencyKey).Scan(&record.Username, &record.IdempotencyKey, &record.Fingerprint, &statusCode, &headers,
+		&record.ResponseBody, &record.CreatedAt, &record.ExpiresAt)
+	if dbQueryError == sql.ErrNoRows {
+		// the key expired between the insert and the query
+		return nil, ConflictError(ERROR_CODE_CONCURRENT_CHANGE, "idempotency key %v expired, please retry", idempotencyKey)
+	}
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving record from table %v. %w", DB_TABLE_IDEMPOTENCYKEY, dbQueryError)
+	}
+
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		record.StatusCode = &code
+	}
+	if unmarshalError := json.Unmarshal([]byte(headers), &record.Headers); unmarshalError != nil {
+		return nil, fmt.Errorf("could not unmarshal the response headers of idempotency key %v. %w", idempotencyKey, unmarshalError)
+	}
+	return &record, nil
+}
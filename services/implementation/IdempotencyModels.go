@@ -0,0 +1,20 @@
+package implementation
+
+import "time"
+
+/*
+represents the database structure for the table "idempotencykey" in the DATABASE.
+the first response of a request with an Idempotency-Key header, it is replayed for the retries of the request.
+the status code is nil while the first request is in progress
+*/
+type IdempotencyRecordImpl struct {
+	Username       string
+	IdempotencyKey string
+	// hash of the method, the path and the body of the first request, a retry must have the same
+	Fingerprint  string
+	StatusCode   *int
+	Headers      map[string]string // the headers of the response which are replayed, e.g. Content-Type and Location
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
@@ -0,0 +1,40 @@
+package implementation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+/*
+runs a job in the background once per interval, e.g. the maintenance scheduler.
+an interval of 0 disables the job. The returned function stops the job and returns when it stopped, the context of a running job
+is cancelled, so its queries abort instead of delaying the stop
+*/
+func startPeriodicJob(name string, interval time.Duration, run func(ctx context.Context)) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	if interval <= 0 {
+		fmt.Printf("%v is disabled\n", name)
+		return cancel
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run(ctx)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-stopped
+	}
+}
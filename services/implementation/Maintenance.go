@@ -0,0 +1,388 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DB_TABLE_MAINTENANCEPLAN    = "maintenanceplan"
+	DB_TABLE_MAINTENANCESERVICE = "maintenanceservice"
+
+	// the upcoming maintenance report covers at most this many weeks
+	MAINTENANCE_REPORT_DEFAULT_WEEKS = 8
+	MAINTENANCE_REPORT_MAX_WEEKS     = 52
+
+	HOURS_PER_DAY = 24
+)
+
+// the columns of the maintenanceplan table in the order they are scanned by GetMaintenancePlans
+const maintenancePlanColumns = `planid, biketype, name, intervalkm, intervaldays, duesoonkm, duesoondays, active, createdat`
+
+/*
+selects every bike with every active plan of its bike type, its last service of the plan, its unfinished preventive order
+and the number of rides since the last service. Retired bikes are not serviced anymore
+*/
+const maintenanceUsageQuery = `SELECT bike.bikeid, bike.name, bike.biketype, bike.odometermeters,
+	maintenanceplan.planid, maintenanceplan.name, maintenanceplan.intervalkm, maintenanceplan.intervaldays,
+	maintenanceplan.duesoonkm, maintenanceplan.duesoondays, maintenanceplan.createdat,
+	maintenanceservice.lastservicedat, coalesce(maintenanceservice.lastserviceodometermeters, 0),
+	(SELECT min(workorder.orderid) FROM workorder WHERE workorder.bikeid=bike.bikeid AND workorder.planid=maintenanceplan.planid AND workorder.status<>'` + WORK_ORDER_DONE + `'),
+	(SELECT count(*) FROM ride WHERE ride.bikeid=bike.bikeid AND ride.endedat>=coalesce(maintenanceservice.lastservicedat, maintenanceplan.createdat))
+	FROM ` + DB_TABLE_BIKE + ` JOIN ` + DB_TABLE_MAINTENANCEPLAN + ` ON maintenanceplan.biketype=bike.biketype AND maintenanceplan.active
+	LEFT JOIN ` + DB_TABLE_MAINTENANCESERVICE + ` ON maintenanceservice.bikeid=bike.bikeid AND maintenanceservice.planid=maintenanceplan.planid
+	WHERE bike.status<>'` + BIKE_STATUS_RETIRED + `'`
+
+/*
+Implementation method to retrieve all maintenance plans from the Database
+*/
+func GetMaintenancePlans(ctx context.Context) (*[]MaintenancePlanImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+maintenancePlanColumns+` FROM `+DB_TABLE_MAINTENANCEPLAN+` ORDER BY biketype, planid;`)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving all records from table %v. %w", DB_TABLE_MAINTENANCEPLAN, dbQueryError)
+	}
+	defer rows.Close()
+
+	plans := []MaintenancePlanImpl{}
+	for rows.Next() {
+		plan := MaintenancePlanImpl{}
+		scanError := rows.Scan(&plan.PlanId, &plan.BikeType, &plan.Name, &plan.IntervalKm, &plan.IntervalDays, &plan.DueSoonKm, &plan.DueSoonDays,
+			&plan.Active, &plan.CreatedAt)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into MaintenancePlan Object. %w", DB_TABLE_MAINTENANCEPLAN, scanError)
+		}
+		plans = append(plans, plan)
+	}
+
+	return &plans, nil
+}
+
+/*
+Implementation method to create a maintenance plan or to update an existing plan with the same planId.
+a changed interval applies to the next run of the maintenance scheduler
+*/
+func SaveMaintenancePlan(ctx context.Context, plan MaintenancePlanImpl) (*MaintenancePlanImpl, error) {
+
+	plan.PlanId = strings.TrimSpace(plan.PlanId)
+	validationError := validateMaintenancePlan(plan)
+	if validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	upsertStatement := `INSERT INTO ` + DB_TABLE_MAINTENANCEPLAN + ` (planid, biketype, name, intervalkm, intervaldays, duesoonkm, duesoondays, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (planid) DO UPDATE SET biketype=EXCLUDED.biketype, name=EXCLUDED.name, intervalkm=EXCLUDED.intervalkm,
+		intervaldays=EXCLUDED.intervaldays, duesoonkm=EXCLUDED.duesoonkm, duesoondays=EXCLUDED.duesoondays, active=EXCLUDED.active
+		RETURNING createdat;`
+	dbUpsertError := db.QueryRowContext(ctx, upsertStatement, plan.PlanId, plan.BikeType, plan.Name, plan.IntervalKm, plan.IntervalDays, plan.DueSoonKm,
+		plan.DueSoonDays, plan.Active).Scan(&plan.CreatedAt)
+	if dbUpsertError != nil {
+		return nil, fmt.Errorf("could not save record into %v Table. %w", DB_TABLE_MAINTENANCEPLAN, dbUpsertError)
+	}
+
+	return &plan, nil
+}
+
+/*
+Implementation method to retrieve the usage of a bike since its last service of every maintenance plan of its bike type
+*/
+func GetBikeMaintenanceUsage(ctx context.Context, bikeId int) (*[]MaintenanceUsageImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	bikeIdExistsInBikeTable, bikeIdExistsInDbError := bikeIdExistsInTable(ctx, db, DB_TABLE_BIKE, bikeId)
+	if bikeIdExistsInDbError != nil {
+		return nil, bikeIdExistsInDbError
+	}
+	if !bikeIdExistsInBikeTable {
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
+	}
+
+	usages, getUsagesError := getMaintenanceUsages(ctx, db, time.Now(), " AND bike.bikeid=$1", bikeId)
+	if getUsagesError != nil {
+		return nil, getUsagesError
+	}
+
+	return &usages, nil
+}
+
+/*
+Implementation method to report the preventive maintenance which becomes due in the next weeks, grouped by week.
+maintenance which is already due is reported in the current week. The date of a distance interval is estimated
+from the average distance per day since the last service
+*/
+func GetUpcomingMaintenance(ctx context.Context, weeks int) (*[]MaintenanceWeekImpl, error) {
+
+	if weeks <= 0 {
+		weeks = MAINTENANCE_REPORT_DEFAULT_WEEKS
+	}
+	if weeks > MAINTENANCE_REPORT_MAX_WEEKS {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "weeks must not be greater than %d", MAINTENANCE_REPORT_MAX_WEEKS)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	now := time.Now()
+	usages, getUsagesError := getMaintenanceUsages(ctx, db, now, "")
+	if getUsagesError != nil {
+		return nil, getUsagesError
+	}
+
+	report := []MaintenanceWeekImpl{}
+	firstWeekStart := startOfWeek(now)
+	for week := 0; week < weeks; week++ {
+		report = append(report, MaintenanceWeekImpl{
+			WeekStart: firstWeekStart.AddDate(0, 0, 7*week).Format("2006-01-02"),
+			Items:     []MaintenanceUsageImpl{},
+		})
+	}
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].EstimatedDueAt.Before(usages[j].EstimatedDueAt) })
+	for _, usage := range usages {
+		week := int(startOfWeek(usage.EstimatedDueAt).Sub(firstWeekStart).Hours()) / (7 * HOURS_PER_DAY)
+		if week < 0 {
+			week = 0
+		}
+		if week < weeks {
+			report[week].Items = append(report[week].Items, usage)
+		}
+	}
+
+	return &report, nil
+}
+
+/*
+Implementation method which runs the maintenance scheduler once.
+every bike which is due for a plan gets a preventive work order, unless it already has an unfinished order of the plan.
+bikes which are due or due soon for any plan are flagged as due soon
+*/
+func RunMaintenanceScheduler(ctx context.Context) (*MaintenanceRunImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	run := MaintenanceRunImpl{StartedAt: time.Now(), CreatedOrders: []int64{}}
+
+	// concurrent runs, e.g. of several instances of the API, would create duplicate orders
+	_, dbLockError := tx.ExecContext(ctx, `LOCK TABLE `+DB_TABLE_MAINTENANCESERVICE+` IN EXCLUSIVE MODE;`)
+	if dbLockError != nil {
+		return nil, fmt.Errorf("could not lock %v Table. %w", DB_TABLE_MAINTENANCESERVICE, dbLockError)
+	}
+
+	usages, getUsagesError := getMaintenanceUsages(ctx, tx, run.StartedAt, "")
+	if getUsagesError != nil {
+		return nil, getUsagesError
+	}
+
+	dueSoonBikes := map[int]bool{}
+	for _, usage := range usages {
+		if usage.Due || usage.DueSoon {
+			dueSoonBikes[usage.BikeId] = true
+		}
+		if !usage.Due || usage.OpenOrderId != nil {
+			continue
+		}
+
+		planId := usage.PlanId
+		order := WorkOrderImpl{
+			BikeId:      usage.BikeId,
+			Source:      WORK_ORDER_SOURCE_PREVENTIVE,
+			PlanId:      &planId,
+			Title:       "Preventive maintenance: " + usage.PlanName,
+			Description: fmt.Sprintf("%.0f km and %d days since the last service, due by %v", usage.KmSinceService, usage.DaysSinceService, usage.DueBy),
+			CreatedBy:   BIKE_STATUS_CHANGED_BY_SYSTEM,
+		}
+		createOrderError := createWorkOrder(ctx, tx, &order)
+		if createOrderError != nil {
+			return nil, createOrderError
+		}
+		run.CreatedOrders = append(run.CreatedOrders, order.OrderId)
+	}
+
+	// the flag of every bike is set again, so bikes which were serviced lose it
+	checkedBikes := map[int]bool{}
+	updateStatement := `UPDATE ` + DB_TABLE_BIKE + ` SET maintenanceduesoon=$1 WHERE ` + DB_TABLE_BIKE_COLUMN_BIKEID + `=$2 AND maintenanceduesoon<>$1;`
+	for _, usage := range usages {
+		if checkedBikes[usage.BikeId] {
+			continue
+		}
+		checkedBikes[usage.BikeId] = true
+		_, dbUpdateError := tx.ExecContext(ctx, updateStatement, dueSoonBikes[usage.BikeId], usage.BikeId)
+		if dbUpdateError != nil {
+			return nil, fmt.Errorf("could not flag bike %v. %w", usage.BikeId, dbUpdateError)
+		}
+	}
+	run.CheckedBikes = len(checkedBikes)
+	run.DueSoonBikes = len(dueSoonBikes)
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not store the result of the maintenance scheduler. %w", commitError)
+	}
+
+	return &run, nil
+}
+
+/*
+starts the maintenance scheduler in the background, it runs once per interval.
+an interval of 0 disables the scheduler. The returned function stops the scheduler
+*/
+func StartMaintenanceScheduler(interval time.Duration) func() {
+	return startPeriodicJob("maintenance scheduler", interval, func(ctx context.Context) {
+		run, runError := RunMaintenanceScheduler(ctx)
+		if runError != nil {
+			fmt.Printf("maintenance scheduler failed. %v\n", runError)
+			return
+		}
+		fmt.Printf("maintenance scheduler checked %d bikes, created %d work orders\n", run.CheckedBikes, len(run.CreatedOrders))
+	})
+}
+
+/*
+records the service of a bike when its preventive work order is done.
+the usage of the plan starts again at the current odometer of the bike
+*/
+func recordMaintenanceService(ctx context.Context, tx dbQueryer, bikeId int, planId string, servicedAt time.Time) error {
+	upsertStatement := `INSERT INTO ` + DB_TABLE_MAINTENANCESERVICE + ` (bikeid, planid, lastservicedat, lastserviceodometermeters)
+		SELECT bikeid, $2, $3, odometermeters FROM ` + DB_TABLE_BIKE + ` WHERE bikeid=$1
+		ON CONFLICT (bikeid, planid) DO UPDATE SET lastservicedat=EXCLUDED.lastservicedat, lastserviceodometermeters=EXCLUDED.lastserviceodometermeters;`
+	_, dbUpsertError := tx.ExecContext(ctx, upsertStatement, bikeId, planId, servicedAt)
+	if dbUpsertError != nil {
+		return fmt.Errorf("could not record service of bike %v. %w", bikeId, dbUpsertError)
+	}
+	return nil
+}
+
+// returns the usage of the bikes for their plans. The condition is appended to the where clause of maintenanceUsageQuery
+func getMaintenanceUsages(ctx context.Context, db dbQueryer, now time.Time, condition string, arguments ...interface{}) ([]MaintenanceUsageImpl, error) {
+
+	rows, dbQueryError := db.QueryContext(ctx, maintenanceUsageQuery+condition+` ORDER BY bike.bikeid, maintenanceplan.planid;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve maintenance usage. %w", dbQueryError)
+	}
+	defer rows.Close()
+
+	usages := []MaintenanceUsageImpl{}
+	for rows.Next() {
+		var plan MaintenancePlanImpl
+		var usage MaintenanceUsageImpl
+		var odometerMeters, lastServiceOdometerMeters int64
+		var lastServicedAt sql.NullTime
+		var openOrderId sql.NullInt64
+		scanError := rows.Scan(&usage.BikeId, &usage.BikeName, &usage.BikeType, &odometerMeters,
+			&plan.PlanId, &plan.Name, &plan.IntervalKm, &plan.IntervalDays, &plan.DueSoonKm, &plan.DueSoonDays, &plan.CreatedAt,
+			&lastServicedAt, &lastServiceOdometerMeters, &openOrderId, &usage.RidesSinceService)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan maintenance usage. %w", scanError)
+		}
+
+		usage.PlanId = plan.PlanId
+		usage.PlanName = plan.Name
+		if openOrderId.Valid {
+			usage.OpenOrderId = &openOrderId.Int64
+		}
+
+		// a bike which was not serviced yet is counted from the creation of the plan
+		servicedAt := plan.CreatedAt
+		if lastServicedAt.Valid {
+			servicedAt = lastServicedAt.Time
+			usage.LastServicedAt = &lastServicedAt.Time
+		}
+		kmSinceService := math.Max(float64(odometerMeters-lastServiceOdometerMeters), 0) / 1000
+		evaluateMaintenanceUsage(&usage, plan, servicedAt, kmSinceService, now)
+
+		usages = append(usages, usage)
+	}
+
+	return usages, nil
+}
+
+/*
+computes whether a plan is due or due soon and when it becomes due.
+the date of the distance interval is estimated from the average distance per day since the last service,
+a bike which did not move since its last service only becomes due by time
+*/
+func evaluateMaintenanceUsage(usage *MaintenanceUsageImpl, plan MaintenancePlanImpl, servicedAt time.Time, kmSinceService float64, now time.Time) {
+
+	daysSinceService := now.Sub(servicedAt).Hours() / HOURS_PER_DAY
+	usage.KmSinceService = math.Round(kmSinceService*10) / 10
+	usage.DaysSinceService = int(math.Max(daysSinceService, 0))
+	usage.KmRemaining = math.Round((float64(plan.IntervalKm)-kmSinceService)*10) / 10
+	usage.DaysRemaining = plan.IntervalDays - usage.DaysSinceService
+
+	usage.Due = usage.KmRemaining <= 0 || usage.DaysRemaining <= 0
+	usage.DueSoon = !usage.Due && (usage.KmRemaining <= float64(plan.DueSoonKm) || usage.DaysRemaining <= plan.DueSoonDays)
+
+	usage.EstimatedDueAt = servicedAt.AddDate(0, 0, plan.IntervalDays)
+	usage.DueBy = MAINTENANCE_DUE_BY_TIME
+	if usage.KmRemaining <= 0 {
+		usage.EstimatedDueAt = now
+		usage.DueBy = MAINTENANCE_DUE_BY_DISTANCE
+	} else if kmSinceService > 0 && daysSinceService > 0 {
+		kmPerDay := kmSinceService / math.Max(daysSinceService, 1)
+		dueByDistanceAt := now.Add(time.Duration(usage.KmRemaining / kmPerDay * HOURS_PER_DAY * float64(time.Hour)))
+		if dueByDistanceAt.Before(usage.EstimatedDueAt) {
+			usage.EstimatedDueAt = dueByDistanceAt
+			usage.DueBy = MAINTENANCE_DUE_BY_DISTANCE
+		}
+	}
+	if usage.EstimatedDueAt.Before(now) {
+		usage.EstimatedDueAt = now
+	}
+}
+
+// returns monday 00:00 of the week of the given time, in UTC
+func startOfWeek(t time.Time) time.Time {
+	day := t.UTC().Truncate(HOURS_PER_DAY * time.Hour)
+	daysSinceMonday := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -daysSinceMonday)
+}
+
+// checks the values of a maintenance plan
+func validateMaintenancePlan(plan MaintenancePlanImpl) error {
+	validator := fieldValidator{}
+	validator.requiredText("planId", plan.PlanId, MAX_ID_LENGTH)
+	validator.requiredText("bikeType", plan.BikeType, MAX_ID_LENGTH)
+	validator.requiredText("name", plan.Name, MAX_NAME_LENGTH)
+	validator.atLeast("intervalKm", plan.IntervalKm, 1)
+	validator.atLeast("intervalDays", plan.IntervalDays, 1)
+	if plan.DueSoonKm < 0 || plan.DueSoonKm >= plan.IntervalKm {
+		validator.add("dueSoonKm", "must be at least 0 and less than intervalKm")
+	}
+	if plan.DueSoonDays < 0 || plan.DueSoonDays >= plan.IntervalDays {
+		validator.add("dueSoonDays", "must be at least 0 and less than intervalDays")
+	}
+	return validator.err()
+}
@@ -0,0 +1,67 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- reasons why preventive maintenance is due ---------
+	MAINTENANCE_DUE_BY_DISTANCE = "distance"
+	MAINTENANCE_DUE_BY_TIME     = "time"
+)
+
+/*
+represents the database structure for the table "maintenanceplan" in the DATABASE.
+a bike of the bike type of the plan is serviced every intervalKm kilometers or intervalDays days, whichever comes first.
+the bike is flagged as due soon dueSoonKm kilometers or dueSoonDays days before
+*/
+type MaintenancePlanImpl struct {
+	PlanId       string    `json:"planId"`
+	BikeType     string    `json:"bikeType"`
+	Name         string    `json:"name"`
+	IntervalKm   int       `json:"intervalKm"`
+	IntervalDays int       `json:"intervalDays"`
+	DueSoonKm    int       `json:"dueSoonKm"`
+	DueSoonDays  int       `json:"dueSoonDays"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+/*
+represents the usage of a bike since its last service of a maintenance plan.
+the distance is the difference of the odometer, which is reported by telemetry or accumulated from the rides of the bike
+*/
+type MaintenanceUsageImpl struct {
+	BikeId            int        `json:"bikeId"`
+	BikeName          string     `json:"bikeName"`
+	BikeType          string     `json:"bikeType"`
+	PlanId            string     `json:"planId"`
+	PlanName          string     `json:"planName"`
+	LastServicedAt    *time.Time `json:"lastServicedAt"` // nil if the bike was not serviced since the plan was created
+	KmSinceService    float64    `json:"kmSinceService"`
+	DaysSinceService  int        `json:"daysSinceService"`
+	RidesSinceService int        `json:"ridesSinceService"`
+	KmRemaining       float64    `json:"kmRemaining"`
+	DaysRemaining     int        `json:"daysRemaining"`
+	Due               bool       `json:"due"`
+	DueSoon           bool       `json:"dueSoon"`
+	EstimatedDueAt    time.Time  `json:"estimatedDueAt"`
+	DueBy             string     `json:"dueBy"`
+	OpenOrderId       *int64     `json:"openOrderId"` // the unfinished preventive work order of the plan
+}
+
+/*
+represents the result of a run of the maintenance scheduler
+*/
+type MaintenanceRunImpl struct {
+	StartedAt     time.Time `json:"startedAt"`
+	CheckedBikes  int       `json:"checkedBikes"`
+	DueSoonBikes  int       `json:"dueSoonBikes"`
+	CreatedOrders []int64   `json:"createdOrders"`
+}
+
+/*
+represents the preventive maintenance which becomes due in a week, starting on monday
+*/
+type MaintenanceWeekImpl struct {
+	WeekStart string                 `json:"weekStart"`
+	Items     []MaintenanceUsageImpl `json:"items"`
+}
@@ -0,0 +1,429 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ---------- ORGANIZATION TABLE CONSTANTS ---------
+	DB_TABLE_ORGANIZATION       = "organization"
+	DB_TABLE_ORGANIZATIONMEMBER = "organizationmember"
+)
+
+// the columns of the organization table in the order they are scanned by getOrganizationFromDb
+const organizationColumns = `organizationid, name, billingemail, billingreference, createdat`
+
+/*
+Implementation method to create an organization which owns a billing account
+*/
+func CreateOrganization(ctx context.Context, organization OrganizationImpl) (*OrganizationImpl, error) {
+
+	organization.Name = strings.TrimSpace(organization.Name)
+	validator := fieldValidator{}
+	validator.requiredText("name", organization.Name, MAX_NAME_LENGTH)
+	if validator.required("billingEmail", organization.BillingEmail != "") {
+		validator.maxLength("billingEmail", organization.BillingEmail, MAX_DESCRIPTION_LENGTH)
+		if _, parseError := mail.ParseAddress(organization.BillingEmail); parseError != nil {
+			validator.add("billingEmail", "%v is not an email address", organization.BillingEmail)
+		}
+	}
+	validator.maxLength("billingReference", organization.BillingReference, MAX_NAME_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	organization.OrganizationId = uuid.New().String()
+	organization.CreatedAt = time.Now()
+
+	insertStatement := getInsertStmt(DB_TABLE_ORGANIZATION, "organizationid", "name", "billingemail", "billingreference", "createdat")
+	_, dbInsertError := db.ExecContext(ctx, insertStatement, organization.OrganizationId, organization.Name, organization.BillingEmail, organization.BillingReference, organization.CreatedAt)
+	if dbInsertError != nil {
+		if isUniqueViolation(dbInsertError) {
+			return nil, ConflictError(ERROR_CODE_ORGANIZATION_EXISTS, "organization %v already exists", organization.Name)
+		}
+		return nil, fmt.Errorf("could not insert record into organization Table. %w", dbInsertError)
+	}
+
+	return &organization, nil
+}
+
+/*
+Implementation method to retrieve an organization
+*/
+func GetOrganization(ctx context.Context, organizationId string) (*OrganizationImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	return getOrganizationFromDb(ctx, db, organizationId)
+}
+
+/*
+Implementation method to add a user to an organization.
+If the user is already a member, the role and the spending limit are updated.
+*/
+func AddOrganizationMember(ctx context.Context, member OrganizationMemberImpl) (*OrganizationMemberImpl, error) {
+
+	if member.MemberRole == "" {
+		member.MemberRole = MEMBER_ROLE_MEMBER
+	}
+	validator := fieldValidator{}
+	validator.requiredText("username", member.Username, MAX_USERNAME_LENGTH)
+	validator.oneOf("memberRole", member.MemberRole, MEMBER_ROLE_MEMBER, MEMBER_ROLE_MANAGER)
+	if member.MonthlySpendingLimitCents != nil {
+		validator.atLeast("monthlySpendingLimitCents", *member.MonthlySpendingLimitCents, 0)
+	}
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	if _, getOrganizationError := getOrganizationFromDb(ctx, db, member.OrganizationId); getOrganizationError != nil {
+		return nil, getOrganizationError
+	}
+
+	userRecordExists, userExistsInDbError := userExistsInDb(ctx, db, member.Username)
+	if userExistsInDbError != nil {
+		return nil, userExistsInDbError
+	}
+	if !userRecordExists {
+		return nil, NotFoundError(ERROR_CODE_USER_NOT_FOUND, "provided username does not exist in database")
+	}
+
+	upsertStatement := `INSERT INTO ` + DB_TABLE_ORGANIZATIONMEMBER + ` (organizationid, username, memberrole, monthlyspendinglimitcents) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organizationid, username) DO UPDATE SET memberrole=EXCLUDED.memberrole, monthlyspendinglimitcents=EXCLUDED.monthlyspendinglimitcents
+		RETURNING joinedat;`
+	dbUpsertError := db.QueryRowContext(ctx, upsertStatement, member.OrganizationId, member.Username, member.MemberRole, member.MonthlySpendingLimitCents).Scan(&member.JoinedAt)
+	if dbUpsertError != nil {
+		return nil, fmt.Errorf("could not insert record into organization member Table. %w", dbUpsertError)
+	}
+
+	spentThisMonth, spentError := memberSpentThisMonth(ctx, db, member.OrganizationId, member.Username, time.Now())
+	if spentError != nil {
+		return nil, spentError
+	}
+	member.SpentThisMonthCents = spentThisMonth
+
+	return &member, nil
+}
+
+/*
+Implementation method to retrieve all members of an organization with the amount they spent this month
+*/
+func GetOrganizationMembers(ctx context.Context, organizationId string) (*[]OrganizationMemberImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	if _, getOrganizationError := getOrganizationFromDb(ctx, db, organizationId); getOrganizationError != nil {
+		return nil, getOrganizationError
+	}
+
+	queryString := `SELECT m.organizationid, m.username, m.memberrole, m.monthlyspendinglimitcents, m.joinedat, COALESCE(SUM(r.totalcents), 0)
+		FROM ` + DB_TABLE_ORGANIZATIONMEMBER + ` m
+		LEFT JOIN ` + DB_TABLE_RECEIPT + ` r ON r.organizationid=m.organizationid AND r.username=m.username AND r.issuedat>=$2
+		WHERE m.organizationid=$1
+		GROUP BY m.organizationid, m.username, m.memberrole, m.monthlyspendinglimitcents, m.joinedat
+		ORDER BY m.username;`
+	rows, dbQueryError := db.QueryContext(ctx, queryString, organizationId, startOfMonth(time.Now()))
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving members of organization %v. %w", organizationId, dbQueryError)
+	}
+	defer rows.Close()
+
+	arrayOfMembers := []OrganizationMemberImpl{}
+	for rows.Next() {
+		member := OrganizationMemberImpl{}
+		var spendingLimit sql.NullInt64
+		scanError := rows.Scan(&member.OrganizationId, &member.Username, &member.MemberRole, &spendingLimit, &member.JoinedAt, &member.SpentThisMonthCents)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into member object", DB_TABLE_ORGANIZATIONMEMBER)
+		}
+		if spendingLimit.Valid {
+			limit := int(spendingLimit.Int64)
+			member.MonthlySpendingLimitCents = &limit
+		}
+		arrayOfMembers = append(arrayOfMembers, member)
+	}
+
+	return &arrayOfMembers, nil
+}
+
+/*
+Implementation method to remove a user from an organization.
+Rides which were already billed to the organization stay on its invoice.
+*/
+func RemoveOrganizationMember(ctx context.Context, organizationId string, username string) error {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return dbConnectError
+	}
+
+	if _, parseError := uuid.Parse(organizationId); parseError != nil {
+		return NotFoundError(ERROR_CODE_ORGANIZATION_NOT_FOUND, "organization %v does not exist", organizationId)
+	}
+
+	result, dbDeleteError := db.ExecContext(ctx, `DELETE FROM `+DB_TABLE_ORGANIZATIONMEMBER+` WHERE organizationid=$1 AND username=$2;`, organizationId, username)
+	if dbDeleteError != nil {
+		return fmt.Errorf("could not delete record from organization member Table. %w", dbDeleteError)
+	}
+
+	if deletedRows, _ := result.RowsAffected(); deletedRows == 0 {
+		return NotFoundError(ERROR_CODE_NOT_ORGANIZATION_MEMBER, "user %v is not a member of organization %v", username, organizationId)
+	}
+
+	return nil
+}
+
+/*
+Implementation method to check if a user is a manager of an organization
+*/
+func IsOrganizationManager(ctx context.Context, organizationId string, username string) (bool, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return false, dbConnectError
+	}
+
+	member, getMemberError := getOrganizationMember(ctx, db, organizationId, username)
+	if getMemberError != nil {
+		return false, getMemberError
+	}
+
+	return member != nil && member.MemberRole == MEMBER_ROLE_MANAGER, nil
+}
+
+/*
+Implementation method to create the invoice report of an organization.
+It lists all rides of the members which were billed to the organization between periodStart (inclusive) and periodEnd (exclusive)
+and the credits which were issued for rides of the organization in this period.
+*/
+func GetInvoiceReport(ctx context.Context, organizationId string, periodStart time.Time, periodEnd time.Time) (*InvoiceReportImpl, error) {
+
+	if !periodEnd.After(periodStart) {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "the end of the period must be after its start")
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	organization, getOrganizationError := getOrganizationFromDb(ctx, db, organizationId)
+	if getOrganizationError != nil {
+		return nil, getOrganizationError
+	}
+
+	queryString := `SELECT kind, rideid, receiptid, username, bikename, startedat, endedat, durationminutes, totalcents, vatcents, issuedat FROM ` + DB_TABLE_RECEIPT + `
+		WHERE organizationid=$1 AND issuedat>=$2 AND issuedat<$3 ORDER BY issuedat;`
+	rows, dbQueryError := db.QueryContext(ctx, queryString, organizationId, periodStart, periodEnd)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving rides of organization %v. %w", organizationId, dbQueryError)
+	}
+	defer rows.Close()
+
+	report := InvoiceReportImpl{
+		Organization: *organization,
+		PeriodStart:  periodStart,
+		PeriodEnd:    periodEnd,
+		Rides:        []InvoiceRideImpl{},
+		Credits:      []InvoiceCreditImpl{},
+		MemberTotals: []InvoiceMemberTotalImpl{},
+		Currency:     TARIFF_CURRENCY,
+	}
+
+	memberTotals := map[string]*InvoiceMemberTotalImpl{}
+	for rows.Next() {
+		var kind string
+		var issuedAt time.Time
+		ride := InvoiceRideImpl{}
+		scanError := rows.Scan(&kind, &ride.RideId, &ride.ReceiptId, &ride.Username, &ride.BikeName, &ride.StartedAt, &ride.EndedAt, &ride.DurationMinutes,
+			&ride.TotalCents, &ride.VatCents, &issuedAt)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into invoice ride object", DB_TABLE_RECEIPT)
+		}
+
+		if _, exists := memberTotals[ride.Username]; !exists {
+			memberTotals[ride.Username] = &InvoiceMemberTotalImpl{Username: ride.Username}
+		}
+		memberTotals[ride.Username].TotalCents += ride.TotalCents
+		report.TotalCents += ride.TotalCents
+		report.VatCents += ride.VatCents
+
+		// a credit corrects a ride which was already counted, so it is listed on its own
+		if kind == RECEIPT_KIND_CREDIT {
+			report.Credits = append(report.Credits, InvoiceCreditImpl{ReceiptId: ride.ReceiptId, RideId: ride.RideId, Username: ride.Username,
+				IssuedAt: issuedAt, TotalCents: ride.TotalCents, VatCents: ride.VatCents})
+			report.CreditCents += ride.TotalCents
+			continue
+		}
+
+		report.Rides = append(report.Rides, ride)
+		report.RideCount++
+		memberTotals[ride.Username].RideCount++
+	}
+
+	for _, memberTotal := range memberTotals {
+		report.MemberTotals = append(report.MemberTotals, *memberTotal)
+	}
+	sort.Slice(report.MemberTotals, func(i, j int) bool {
+		return report.MemberTotals[i].Username < report.MemberTotals[j].Username
+	})
+
+	return &report, nil
+}
+
+/*
+returns the organization which pays for a reservation, or an invalid NullString for personal billing.
+For company billing the user must be a member of the organization and must not have exceeded the monthly spending limit.
+If no organizationId is given, the only organization of the user is used.
+*/
+func resolveBillingOrganization(ctx context.Context, database dbQueryer, username string, billing string, organizationId string) (sql.NullString, error) {
+
+	if billing == "" || billing == BILLING_PERSONAL {
+		if organizationId != "" {
+			validator := fieldValidator{}
+			validator.add("organizationId", "can only be provided for %v billing", BILLING_COMPANY)
+			return sql.NullString{}, validator.err()
+		}
+		return sql.NullString{}, nil
+	}
+
+	if billing != BILLING_COMPANY {
+		validator := fieldValidator{}
+		validator.oneOf("billing", billing, BILLING_PERSONAL, BILLING_COMPANY)
+		return sql.NullString{}, validator.err()
+	}
+
+	if organizationId == "" {
+		rows, dbQueryError := database.QueryContext(ctx, `SELECT organizationid FROM `+DB_TABLE_ORGANIZATIONMEMBER+` WHERE username=$1;`, username)
+		if dbQueryError != nil {
+			return sql.NullString{}, fmt.Errorf("could not retrieve organizations of user %v. %w", username, dbQueryError)
+		}
+		defer rows.Close()
+
+		var organizationIds []string
+		for rows.Next() {
+			var memberOrganizationId string
+			if scanError := rows.Scan(&memberOrganizationId); scanError != nil {
+				return sql.NullString{}, fmt.Errorf("error scanning fields. could not scan rows of %v", DB_TABLE_ORGANIZATIONMEMBER)
+			}
+			organizationIds = append(organizationIds, memberOrganizationId)
+		}
+
+		if len(organizationIds) == 0 {
+			return sql.NullString{}, ForbiddenError(ERROR_CODE_NOT_ORGANIZATION_MEMBER, "user %v is not a member of any organization", username)
+		}
+		if len(organizationIds) > 1 {
+			return sql.NullString{}, ValidationError(ERROR_CODE_VALIDATION, "user %v is a member of more than one organization. Please provide an organizationId", username)
+		}
+		organizationId = organizationIds[0]
+	}
+
+	member, getMemberError := getOrganizationMember(ctx, database, organizationId, username)
+	if getMemberError != nil {
+		return sql.NullString{}, getMemberError
+	}
+	if member == nil {
+		return sql.NullString{}, ForbiddenError(ERROR_CODE_NOT_ORGANIZATION_MEMBER, "user %v is not a member of organization %v", username, organizationId)
+	}
+
+	if member.MonthlySpendingLimitCents != nil {
+		spentThisMonth, spentError := memberSpentThisMonth(ctx, database, organizationId, username, time.Now())
+		if spentError != nil {
+			return sql.NullString{}, spentError
+		}
+		if spentThisMonth >= *member.MonthlySpendingLimitCents {
+			return sql.NullString{}, ConflictError(ERROR_CODE_SPENDING_LIMIT_REACHED, "user %v has reached the monthly spending limit of %v for organization %v", username, FormatCents(*member.MonthlySpendingLimitCents), organizationId)
+		}
+	}
+
+	return sql.NullString{String: organizationId, Valid: true}, nil
+}
+
+// returns the amount a member spent on the company's tab in the month of the given time
+func memberSpentThisMonth(ctx context.Context, database dbQueryer, organizationId string, username string, now time.Time) (int, error) {
+	var spentCents int
+	queryString := `SELECT COALESCE(SUM(totalcents), 0) FROM ` + DB_TABLE_RECEIPT + ` WHERE organizationid=$1 AND username=$2 AND issuedat>=$3;`
+	dbQueryError := database.QueryRowContext(ctx, queryString, organizationId, username, startOfMonth(now)).Scan(&spentCents)
+	if dbQueryError != nil {
+		return 0, fmt.Errorf("could not calculate spending of user %v. %w", username, dbQueryError)
+	}
+	return spentCents, nil
+}
+
+// returns the membership of a user in an organization, or nil if the user is not a member
+func getOrganizationMember(ctx context.Context, database dbQueryer, organizationId string, username string) (*OrganizationMemberImpl, error) {
+
+	if _, parseError := uuid.Parse(organizationId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_ORGANIZATION_NOT_FOUND, "organization %v does not exist", organizationId)
+	}
+
+	member := OrganizationMemberImpl{}
+	var spendingLimit sql.NullInt64
+	queryString := `SELECT organizationid, username, memberrole, monthlyspendinglimitcents, joinedat FROM ` + DB_TABLE_ORGANIZATIONMEMBER + ` WHERE organizationid=$1 AND username=$2;`
+	scanError := database.QueryRowContext(ctx, queryString, organizationId, username).Scan(&member.OrganizationId, &member.Username, &member.MemberRole, &spendingLimit, &member.JoinedAt)
+	if scanError == sql.ErrNoRows {
+		return nil, nil
+	}
+	if scanError != nil {
+		return nil, fmt.Errorf("could not retrieve membership of user %v. %w", username, scanError)
+	}
+	if spendingLimit.Valid {
+		limit := int(spendingLimit.Int64)
+		member.MonthlySpendingLimitCents = &limit
+	}
+
+	return &member, nil
+}
+
+// returns the organization with the given organizationId
+func getOrganizationFromDb(ctx context.Context, database dbQueryer, organizationId string) (*OrganizationImpl, error) {
+
+	if _, parseError := uuid.Parse(organizationId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_ORGANIZATION_NOT_FOUND, "organization %v does not exist", organizationId)
+	}
+
+	organization := OrganizationImpl{}
+	queryString := `SELECT ` + organizationColumns + ` FROM ` + DB_TABLE_ORGANIZATION + ` WHERE organizationid=$1;`
+	scanError := database.QueryRowContext(ctx, queryString, organizationId).Scan(&organization.OrganizationId, &organization.Name, &organization.BillingEmail,
+		&organization.BillingReference, &organization.CreatedAt)
+	if scanError == sql.ErrNoRows {
+		return nil, NotFoundError(ERROR_CODE_ORGANIZATION_NOT_FOUND, "organization %v does not exist", organizationId)
+	}
+	if scanError != nil {
+		return nil, fmt.Errorf("could not retrieve organization %v. %w", organizationId, scanError)
+	}
+
+	return &organization, nil
+}
+
+// returns the first moment of the month of the given time
+func startOfMonth(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
@@ -0,0 +1,94 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- billing of a reservation ---------
+	BILLING_PERSONAL = "personal"
+	BILLING_COMPANY  = "company"
+
+	// ---------- roles of a member in an organization ---------
+	MEMBER_ROLE_MEMBER  = "member"
+	MEMBER_ROLE_MANAGER = "manager"
+)
+
+/*
+represents the database structure for the table "organization" in the DATABASE.
+an organization owns the billing account for the rides of its members
+*/
+type OrganizationImpl struct {
+	OrganizationId   string    `json:"organizationId"`
+	Name             string    `json:"name"`
+	BillingEmail     string    `json:"billingEmail"`
+	BillingReference string    `json:"billingReference"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+/*
+represents the database structure for the table "organizationmember" in the DATABASE.
+MonthlySpendingLimitCents is nil if the member can ride without limit on the company's tab.
+SpentThisMonthCents is not stored, it is calculated from the rides of the member.
+*/
+type OrganizationMemberImpl struct {
+	OrganizationId            string    `json:"organizationId"`
+	Username                  string    `json:"username"`
+	MemberRole                string    `json:"memberRole"`
+	MonthlySpendingLimitCents *int      `json:"monthlySpendingLimitCents"`
+	SpentThisMonthCents       int       `json:"spentThisMonthCents"`
+	JoinedAt                  time.Time `json:"joinedAt"`
+}
+
+/*
+represents a single ride on the invoice report of an organization
+*/
+type InvoiceRideImpl struct {
+	RideId          string    `json:"rideId"`
+	ReceiptId       string    `json:"receiptId"`
+	Username        string    `json:"username"`
+	BikeName        string    `json:"bikeName"`
+	StartedAt       time.Time `json:"startedAt"`
+	EndedAt         time.Time `json:"endedAt"`
+	DurationMinutes int       `json:"durationMinutes"`
+	TotalCents      int       `json:"totalCents"`
+	VatCents        int       `json:"vatCents"`
+}
+
+/*
+represents a credit note for a ride on the invoice report of an organization, e.g. for a waived penalty.
+its total is negative
+*/
+type InvoiceCreditImpl struct {
+	ReceiptId  string    `json:"receiptId"`
+	RideId     string    `json:"rideId"`
+	Username   string    `json:"username"`
+	IssuedAt   time.Time `json:"issuedAt"`
+	TotalCents int       `json:"totalCents"`
+	VatCents   int       `json:"vatCents"`
+}
+
+/*
+represents the sum of the rides and credits of one employee on the invoice report
+*/
+type InvoiceMemberTotalImpl struct {
+	Username   string `json:"username"`
+	RideCount  int    `json:"rideCount"`
+	TotalCents int    `json:"totalCents"`
+}
+
+/*
+represents the invoice report of an organization which lists all rides of its members
+which were billed to the organization in a period
+*/
+type InvoiceReportImpl struct {
+	Organization OrganizationImpl         `json:"organization"`
+	PeriodStart  time.Time                `json:"periodStart"`
+	PeriodEnd    time.Time                `json:"periodEnd"`
+	Rides        []InvoiceRideImpl        `json:"rides"`
+	Credits      []InvoiceCreditImpl      `json:"credits"`
+	MemberTotals []InvoiceMemberTotalImpl `json:"memberTotals"`
+	RideCount    int                      `json:"rideCount"`
+	CreditCents  int                      `json:"creditCents"` // the sum of the credits, it is negative and part of the total
+	TotalCents   int                      `json:"totalCents"`
+	VatCents     int                      `json:"vatCents"`
+	Currency     string                   `json:"currency"`
+}
@@ -0,0 +1,48 @@
+package implementation
+
+import (
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvoiceReportListsCreditsApartFromRides(t *testing.T) {
+	organizationId := "3f1c2a4e-5b6d-4e7f-8a9b-0c1d2e3f4a5b"
+	startedAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	endedAt := startedAt.Add(30 * time.Minute)
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		switch {
+		case strings.HasPrefix(statement.Query, "SELECT "+organizationColumns):
+			return &fakedb.Result{Columns: strings.Split(organizationColumns, ", "),
+				Rows: [][]driver.Value{{organizationId, "ACME Corp", "billing@acme.example", "PO-4711", startedAt}}}, nil
+		case strings.Contains(statement.Query, "FROM "+DB_TABLE_RECEIPT):
+			columns := []string{"kind", "rideid", "receiptid", "username", "bikename", "startedat", "endedat", "durationminutes", "totalcents", "vatcents", "issuedat"}
+			return &fakedb.Result{Columns: columns, Rows: [][]driver.Value{
+				{RECEIPT_KIND_RIDE, "ride-1", "receipt-1", "userOne", "bike", startedAt, endedAt, int64(30), int64(2500), int64(399), endedAt},
+				{RECEIPT_KIND_RIDE, "ride-2", "receipt-2", "userTwo", "bike", startedAt, endedAt, int64(30), int64(500), int64(80), endedAt},
+				{RECEIPT_KIND_CREDIT, "ride-1", "receipt-3", "userOne", "bike", startedAt, endedAt, int64(30), int64(-2000), int64(-319), endedAt.Add(time.Hour)},
+			}}, nil
+		}
+		return &fakedb.Result{}, nil
+	})
+
+	report, err := GetInvoiceReport(context.Background(), organizationId, startedAt.AddDate(0, 0, -1), startedAt.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("expected the invoice report, got %v", err)
+	}
+	if report.RideCount != 2 || len(report.Rides) != 2 {
+		t.Fatalf("expected 2 rides, got %v and %v listed", report.RideCount, len(report.Rides))
+	}
+	if len(report.Credits) != 1 || report.Credits[0].RideId != "ride-1" || report.CreditCents != -2000 {
+		t.Fatalf("expected the credit of ride-1, got %+v with %v cents", report.Credits, report.CreditCents)
+	}
+	if report.TotalCents != 1000 || report.VatCents != 160 {
+		t.Fatalf("expected a total of 1000 with 160 VAT, got %v with %v VAT", report.TotalCents, report.VatCents)
+	}
+	if report.MemberTotals[0].Username != "userOne" || report.MemberTotals[0].RideCount != 1 || report.MemberTotals[0].TotalCents != 500 {
+		t.Fatalf("expected 1 ride for 500 cents of userOne, got %+v", report.MemberTotals[0])
+	}
+}
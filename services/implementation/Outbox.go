@@ -0,0 +1,253 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DB_TABLE_OUTBOXEVENT = "outboxevent"
+
+	// key of the advisory lock of the dispatcher, so only one instance of the API publishes the events at a time
+	OUTBOX_DISPATCH_LOCK_KEY = 4304
+	// first key of the advisory locks of the aggregates, the second key is the hash of the aggregate
+	OUTBOX_AGGREGATE_LOCK_KEY = 4305
+	// maximal number of events which are published by one run of the dispatcher
+	OUTBOX_DISPATCH_BATCH_SIZE = 100
+	// the error of a sink which failed to receive an event is stored up to this length
+	OUTBOX_MAX_ERROR_LENGTH = 500
+	// default and maximal number of events which are returned to an operator
+	OUTBOX_EVENT_DEFAULT_LIMIT = 100
+	OUTBOX_EVENT_MAX_LIMIT     = 1000
+)
+
+// the columns of the outboxevent table in the order they are scanned by scanDomainEvent
+const domainEventColumns = `sequence, eventid, eventtype, aggregatetype, aggregateid, occurredat, payload, publishedat, attemptcount, lasterror`
+
+/*
+Implementation method for operators to read the domain events of the outbox, the oldest event first.
+the events after a sequence can be read page by page, the pending events show a sink which does not receive them
+*/
+func GetDomainEvents(ctx context.Context, filter DomainEventFilterImpl) (*[]DomainEventImpl, error) {
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = OUTBOX_EVENT_DEFAULT_LIMIT
+	}
+	if limit < 0 || limit > OUTBOX_EVENT_MAX_LIMIT {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "limit must be between 1 and %d", OUTBOX_EVENT_MAX_LIMIT)
+	}
+
+	conditions := []string{`sequence>$1`}
+	arguments := []interface{}{filter.AfterSequence}
+	if filter.Type != "" {
+		arguments = append(arguments, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("eventtype=$%d", len(arguments)))
+	}
+	if filter.AggregateType != "" {
+		arguments = append(arguments, filter.AggregateType)
+		conditions = append(conditions, fmt.Sprintf("aggregatetype=$%d", len(arguments)))
+	}
+	if filter.AggregateId != "" {
+		arguments = append(arguments, filter.AggregateId)
+		conditions = append(conditions, fmt.Sprintf("aggregateid=$%d", len(arguments)))
+	}
+	if filter.Pending {
+		conditions = append(conditions, `publishedat IS NULL`)
+	}
+	arguments = append(arguments, limit)
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	events, getEventsError := getDomainEventsWhere(ctx, db, strings.Join(conditions, " AND ")+fmt.Sprintf(" ORDER BY sequence LIMIT $%d", len(arguments)), arguments...)
+	if getEventsError != nil {
+		return nil, getEventsError
+	}
+
+	return &events, nil
+}
+
+/*
+Implementation method which publishes the pending domain events once, in the order of their sequence. Returns the number of published events.
+every event is published in its own transaction, which also marks it as published. A sink which writes to the database uses this
+transaction, so its writes and the mark are committed together. A sink which fails stops the run, the event and all later events
+are published again by the next run, so the order is kept and no event is lost.
+the order only holds for the events of one aggregate: the sequence is assigned when an event is written, not when its transaction
+commits, so the event of another aggregate with a lower sequence may become visible after a higher one was published.
+writeDomainEvent makes sure that the events of one aggregate are committed in the order of their sequence
+*/
+func RunOutboxDispatcher(ctx context.Context) (int, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return 0, dbConnectError
+	}
+
+	published := 0
+	for published < OUTBOX_DISPATCH_BATCH_SIZE {
+		isPublished, publishError := publishNextDomainEvent(ctx, db)
+		if publishError != nil {
+			return published, publishError
+		}
+		if !isPublished {
+			break
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+/*
+starts the outbox dispatcher in the background, it publishes the pending domain events once per interval.
+an interval of 0 disables the dispatcher, then the events stay in the outbox. The returned function stops the dispatcher
+*/
+func StartOutboxDispatcher(interval time.Duration) func() {
+	return startPeriodicJob("outbox dispatcher", interval, func(ctx context.Context) {
+		_, runError := RunOutboxDispatcher(ctx)
+		if runError != nil {
+			fmt.Printf("WARNING! outbox dispatcher failed. %v\n", runError)
+		}
+	})
+}
+
+/*
+writes a domain event to the outbox. It is called in the transaction of the change,
+so the event is only published if the change is committed and it is not lost if the API stops.
+the aggregate is locked until the transaction ends, so a second transaction which writes an event of the aggregate waits
+for the first one and the events of an aggregate are committed in the order of their sequence
+*/
+func writeDomainEvent(ctx context.Context, tx dbQueryer, eventType string, aggregateType string, aggregateId string, payload interface{}) error {
+	payloadJson, marshalError := json.Marshal(payload)
+	if marshalError != nil {
+		return fmt.Errorf("could not marshal domain event %v. %w", eventType, marshalError)
+	}
+
+	_, dbLockError := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2));`, OUTBOX_AGGREGATE_LOCK_KEY, aggregateType+":"+aggregateId)
+	if dbLockError != nil {
+		return fmt.Errorf("could not lock %v %v for domain event %v. %w", aggregateType, aggregateId, eventType, dbLockError)
+	}
+
+	insertStatement := getInsertStmt(DB_TABLE_OUTBOXEVENT, "eventid", "eventtype", "aggregatetype", "aggregateid", "occurredat", "payload")
+	_, dbInsertError := tx.ExecContext(ctx, insertStatement, uuid.New().String(), eventType, aggregateType, aggregateId, time.Now(), string(payloadJson))
+	if dbInsertError != nil {
+		return fmt.Errorf("could not write domain event %v. %w", eventType, dbInsertError)
+	}
+	return nil
+}
+
+// writes a domain event of a bike to the outbox
+func writeBikeDomainEvent(ctx context.Context, tx dbQueryer, eventType string, bikeId int, payload interface{}) error {
+	return writeDomainEvent(ctx, tx, eventType, DOMAIN_AGGREGATE_BIKE, strconv.Itoa(bikeId), payload)
+}
+
+/*
+publishes the oldest pending event to the sinks and marks it as published. Returns false if there is no pending event
+or another instance of the API holds the lock of the dispatcher
+*/
+func publishNextDomainEvent(ctx context.Context, db *sql.DB) (bool, error) {
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return false, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	var isLocked bool
+	dbLockError := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1);`, OUTBOX_DISPATCH_LOCK_KEY).Scan(&isLocked)
+	if dbLockError != nil {
+		return false, fmt.Errorf("could not lock the outbox. %w", dbLockError)
+	}
+	if !isLocked {
+		return false, nil
+	}
+
+	events, getEventsError := getDomainEventsWhere(ctx, tx, `publishedat IS NULL ORDER BY sequence LIMIT 1`)
+	if getEventsError != nil {
+		return false, getEventsError
+	}
+	if len(events) == 0 {
+		return false, nil
+	}
+	event := events[0]
+
+	publishError := domainEventBus.publish(ctx, tx, event)
+	if publishError != nil {
+		// the writes of the sinks are rolled back, the failure is recorded outside of the transaction
+		tx.Rollback()
+		_, dbUpdateError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_OUTBOXEVENT+` SET attemptcount=attemptcount+1, lasterror=$1 WHERE sequence=$2;`,
+			truncateOutboxError(publishError.Error()), event.Sequence)
+		if dbUpdateError != nil {
+			fmt.Printf("WARNING! could not record the failure of domain event %v. %v\n", event.Sequence, dbUpdateError)
+		}
+		return false, fmt.Errorf("could not publish domain event %v (%v), the later events wait for it. %w", event.Sequence, event.Type, publishError)
+	}
+
+	_, dbUpdateError := tx.ExecContext(ctx, `UPDATE `+DB_TABLE_OUTBOXEVENT+` SET publishedat=$1, attemptcount=attemptcount+1, lasterror='' WHERE sequence=$2;`,
+		time.Now(), event.Sequence)
+	if dbUpdateError != nil {
+		return false, fmt.Errorf("could not update record in %v Table. %w", DB_TABLE_OUTBOXEVENT, dbUpdateError)
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return false, fmt.Errorf("could not publish domain event %v. %w", event.Sequence, commitError)
+	}
+	return true, nil
+}
+
+// shortens the error of a sink to the length of the column
+func truncateOutboxError(errorText string) string {
+	if len(errorText) > OUTBOX_MAX_ERROR_LENGTH {
+		return errorText[:OUTBOX_MAX_ERROR_LENGTH]
+	}
+	return errorText
+}
+
+// returns the domain events which match the condition
+func getDomainEventsWhere(ctx context.Context, db dbQueryer, condition string, arguments ...interface{}) ([]DomainEventImpl, error) {
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+domainEventColumns+` FROM `+DB_TABLE_OUTBOXEVENT+` WHERE `+condition+`;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_OUTBOXEVENT, dbQueryError)
+	}
+	defer rows.Close()
+
+	events := []DomainEventImpl{}
+	for rows.Next() {
+		event, scanError := scanDomainEvent(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		events = append(events, *event)
+	}
+	return events, nil
+}
+
+// scans a row of the outboxevent table which was selected with domainEventColumns
+func scanDomainEvent(rows *sql.Rows) (*DomainEventImpl, error) {
+	var event DomainEventImpl
+	var payload string
+	var publishedAt sql.NullTime
+
+	scanError := rows.Scan(&event.Sequence, &event.EventId, &event.Type, &event.AggregateType, &event.AggregateId, &event.OccurredAt,
+		&payload, &publishedAt, &event.AttemptCount, &event.LastError)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v. %w", DB_TABLE_OUTBOXEVENT, scanError)
+	}
+
+	event.Payload = json.RawMessage(payload)
+	if publishedAt.Valid {
+		event.PublishedAt = &publishedAt.Time
+	}
+	return &event, nil
+}
@@ -0,0 +1,336 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+the outboxevent table in memory. The updates of a transaction are applied on its commit,
+so a rolled back or failed transaction leaves the table unchanged like in PostgreSQL
+*/
+type fakeOutbox struct {
+	mutex       sync.Mutex
+	events      []DomainEventImpl
+	pending     []func()
+	failCommits int // the number of the next commits which fail
+	statements  []fakedb.Statement
+	other       fakedb.Handler // answers the statements of the subscribers on other tables
+}
+
+func (outbox *fakeOutbox) handle(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+	outbox.statements = append(outbox.statements, statement)
+
+	switch {
+	case statement.Query == fakedb.COMMIT:
+		pending := outbox.pending
+		outbox.pending = nil
+		if outbox.failCommits > 0 {
+			outbox.failCommits--
+			return nil, errors.New("connection reset by peer")
+		}
+		for _, update := range pending {
+			update()
+		}
+		return &fakedb.Result{}, nil
+	case statement.Query == fakedb.ROLLBACK:
+		outbox.pending = nil
+		return &fakedb.Result{}, nil
+	case strings.Contains(statement.Query, "pg_try_advisory_xact_lock"):
+		return &fakedb.Result{Columns: []string{"pg_try_advisory_xact_lock"}, Rows: [][]driver.Value{{true}}}, nil
+	case strings.Contains(statement.Query, "pg_advisory_xact_lock"):
+		return &fakedb.Result{}, nil
+	case strings.HasPrefix(statement.Query, "SELECT "+domainEventColumns):
+		return outbox.nextPendingEvent(), nil
+	case strings.HasPrefix(statement.Query, "UPDATE "+DB_TABLE_OUTBOXEVENT):
+		update := outbox.update(statement)
+		if statement.InTransaction {
+			outbox.pending = append(outbox.pending, update)
+		} else {
+			update()
+		}
+		return &fakedb.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(statement.Query, `insert into "`+DB_TABLE_OUTBOXEVENT+`"`):
+		return &fakedb.Result{RowsAffected: 1}, nil
+	}
+	if outbox.other != nil {
+		return outbox.other(ctx, statement)
+	}
+	return nil, errors.New("unexpected statement: " + statement.Query)
+}
+
+// returns the pending event with the lowest sequence, the mutex must be locked
+func (outbox *fakeOutbox) nextPendingEvent() *fakedb.Result {
+	result := &fakedb.Result{Columns: strings.Split(domainEventColumns, ", ")}
+	var next *DomainEventImpl
+	for i := range outbox.events {
+		event := &outbox.events[i]
+		if event.PublishedAt == nil && (next == nil || event.Sequence < next.Sequence) {
+			next = event
+		}
+	}
+	if next != nil {
+		result.Rows = [][]driver.Value{{next.Sequence, next.EventId, next.Type, next.AggregateType, next.AggregateId, next.OccurredAt,
+			string(next.Payload), nil, int64(next.AttemptCount), next.LastError}}
+	}
+	return result
+}
+
+// returns the update of the event of the statement: a failed attempt or a published event
+func (outbox *fakeOutbox) update(statement fakedb.Statement) func() {
+	sequence := statement.Args[1].(int64)
+	return func() {
+		for i := range outbox.events {
+			event := &outbox.events[i]
+			if event.Sequence != sequence {
+				continue
+			}
+			event.AttemptCount++
+			if strings.Contains(statement.Query, "publishedat=$1") {
+				publishedAt := statement.Args[0].(time.Time)
+				event.PublishedAt = &publishedAt
+				event.LastError = ""
+			} else {
+				event.LastError = statement.Args[0].(string)
+			}
+		}
+	}
+}
+
+func (outbox *fakeOutbox) event(sequence int64) DomainEventImpl {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+	for _, event := range outbox.events {
+		if event.Sequence == sequence {
+			return event
+		}
+	}
+	return DomainEventImpl{}
+}
+
+// a sink which records the events it receives and fails while fail returns an error
+type recordingSink struct {
+	mutex    sync.Mutex
+	received []DomainEventImpl
+	fail     func(event DomainEventImpl) error
+}
+
+func (sink *recordingSink) Name() string {
+	return "recording"
+}
+
+func (sink *recordingSink) Publish(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.received = append(sink.received, event)
+	if sink.fail != nil {
+		return sink.fail(event)
+	}
+	return nil
+}
+
+func (sink *recordingSink) sequences() []int64 {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sequences := []int64{}
+	for _, event := range sink.received {
+		sequences = append(sequences, event.Sequence)
+	}
+	return sequences
+}
+
+// replaces the outbox and the sinks of the bus for one test
+func useFakeOutbox(t *testing.T, events ...DomainEventImpl) (*fakeOutbox, *recordingSink) {
+	t.Helper()
+	outbox := &fakeOutbox{events: events}
+	useFakeDB(t, outbox.handle)
+
+	sink := &recordingSink{}
+	domainEventBus.mutex.Lock()
+	sinks := domainEventBus.sinks
+	domainEventBus.sinks = []DomainEventSink{sink}
+	domainEventBus.mutex.Unlock()
+	t.Cleanup(func() {
+		domainEventBus.mutex.Lock()
+		domainEventBus.sinks = sinks
+		domainEventBus.mutex.Unlock()
+	})
+	return outbox, sink
+}
+
+func testDomainEvent(sequence int64, eventId string, aggregateId string) DomainEventImpl {
+	return DomainEventImpl{
+		Sequence:      sequence,
+		EventId:       eventId,
+		Type:          "BikeReserved",
+		AggregateType: DOMAIN_AGGREGATE_BIKE,
+		AggregateId:   aggregateId,
+		OccurredAt:    time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		Payload:       []byte(`{}`),
+	}
+}
+
+func equalSequences(a []int64, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFailingSinkLeavesEventPendingAndRetries(t *testing.T) {
+	outbox, sink := useFakeOutbox(t, testDomainEvent(1, "event-1", "7"), testDomainEvent(2, "event-2", "7"))
+	failures := 1
+	sink.fail = func(event DomainEventImpl) error {
+		if failures > 0 {
+			failures--
+			return errors.New("sink unavailable")
+		}
+		return nil
+	}
+
+	published, err := RunOutboxDispatcher(context.Background())
+	if err == nil || published != 0 {
+		t.Fatalf("expected the failing sink to stop the dispatcher, got %v published and error %v", published, err)
+	}
+	failed := outbox.event(1)
+	if failed.PublishedAt != nil || failed.AttemptCount != 1 || !strings.Contains(failed.LastError, "sink unavailable") {
+		t.Fatalf("expected event 1 to stay pending with the failed attempt recorded, got %+v", failed)
+	}
+	if !equalSequences(sink.sequences(), []int64{1}) {
+		t.Fatalf("expected event 2 to wait for event 1, the sink received %v", sink.sequences())
+	}
+
+	published, err = RunOutboxDispatcher(context.Background())
+	if err != nil || published != 2 {
+		t.Fatalf("expected the retry to publish both events, got %v published and error %v", published, err)
+	}
+	retried := outbox.event(1)
+	if retried.PublishedAt == nil || retried.AttemptCount != 2 || retried.LastError != "" {
+		t.Fatalf("expected event 1 to be published on the second attempt, got %+v", retried)
+	}
+	if !equalSequences(sink.sequences(), []int64{1, 1, 2}) {
+		t.Fatalf("expected the sink to receive 1, 1, 2, got %v", sink.sequences())
+	}
+}
+
+func TestEventsArePublishedInSequenceOrder(t *testing.T) {
+	// the rows are stored in another order than their sequence, e.g. because the transactions committed in another order
+	_, sink := useFakeOutbox(t,
+		testDomainEvent(3, "event-3", "7"),
+		testDomainEvent(1, "event-1", "7"),
+		testDomainEvent(4, "event-4", "8"),
+		testDomainEvent(2, "event-2", "8"))
+
+	published, err := RunOutboxDispatcher(context.Background())
+	if err != nil || published != 4 {
+		t.Fatalf("expected 4 published events, got %v and error %v", published, err)
+	}
+	if !equalSequences(sink.sequences(), []int64{1, 2, 3, 4}) {
+		t.Fatalf("expected the events in the order of their sequence, got %v", sink.sequences())
+	}
+
+	perAggregate := map[string][]int64{}
+	for _, event := range sink.received {
+		perAggregate[event.AggregateId] = append(perAggregate[event.AggregateId], event.Sequence)
+	}
+	if !equalSequences(perAggregate["7"], []int64{1, 3}) || !equalSequences(perAggregate["8"], []int64{2, 4}) {
+		t.Fatalf("expected the events of every bike in the order of their sequence, got %v", perAggregate)
+	}
+}
+
+func TestRedeliveredEventKeepsEventId(t *testing.T) {
+	outbox, sink := useFakeOutbox(t, testDomainEvent(1, "event-1", "7"))
+	// the sink received the event, but the API could not mark it as published
+	outbox.failCommits = 1
+
+	_, err := RunOutboxDispatcher(context.Background())
+	if err == nil {
+		t.Fatalf("expected the failed commit to be reported")
+	}
+	if outbox.event(1).PublishedAt != nil {
+		t.Fatalf("expected the event to stay pending after the failed commit")
+	}
+
+	published, err := RunOutboxDispatcher(context.Background())
+	if err != nil || published != 1 {
+		t.Fatalf("expected the event to be delivered again, got %v published and error %v", published, err)
+	}
+	if len(sink.received) != 2 {
+		t.Fatalf("expected 2 deliveries, got %v", len(sink.received))
+	}
+	if sink.received[0].EventId != "event-1" || sink.received[1].EventId != sink.received[0].EventId {
+		t.Fatalf("expected both deliveries to carry event id event-1, got %v and %v", sink.received[0].EventId, sink.received[1].EventId)
+	}
+}
+
+func TestWriteDomainEventLocksAggregateBeforeInsert(t *testing.T) {
+	outbox, _ := useFakeOutbox(t)
+	db, _ := SetupDB()
+
+	err := writeBikeDomainEvent(context.Background(), db, "BikeReserved", 7, map[string]string{})
+	if err != nil {
+		t.Fatalf("expected the event to be written, got %v", err)
+	}
+	if len(outbox.statements) != 2 {
+		t.Fatalf("expected the lock and the insert, got %v statements", len(outbox.statements))
+	}
+	lock, insert := outbox.statements[0], outbox.statements[1]
+	if !strings.Contains(lock.Query, "pg_advisory_xact_lock") || lock.Args[0] != int64(OUTBOX_AGGREGATE_LOCK_KEY) || lock.Args[1] != "bike:7" {
+		t.Fatalf("expected the lock of bike 7 first, got %v %v", lock.Query, lock.Args)
+	}
+	if !strings.HasPrefix(insert.Query, `insert into "`+DB_TABLE_OUTBOXEVENT+`"`) {
+		t.Fatalf("expected the insert after the lock, got %v", insert.Query)
+	}
+}
+
+func TestSubscriberCreatesLowBatteryWorkOrderInDispatcherTransaction(t *testing.T) {
+	lowBattery := testDomainEvent(1, "event-1", "7")
+	lowBattery.Type = DOMAIN_EVENT_BIKE_STATUS_CHANGED
+	lowBattery.Payload = []byte(`{"bikeId":7,"fromStatus":"available","toStatus":"low_battery"}`)
+	charged := testDomainEvent(2, "event-2", "7")
+	charged.Type = DOMAIN_EVENT_BIKE_STATUS_CHANGED
+	charged.Payload = []byte(`{"bikeId":7,"fromStatus":"low_battery","toStatus":"available"}`)
+	outbox, _ := useFakeOutbox(t, lowBattery, charged)
+	SubscribeDomainEvents("low battery work orders", CreateLowBatteryWorkOrders, DOMAIN_EVENT_BIKE_STATUS_CHANGED)
+
+	bikeStatus := BIKE_STATUS_LOW_BATTERY
+	var orders []fakedb.Statement
+	outbox.other = func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		switch {
+		case strings.HasPrefix(statement.Query, "SELECT "+bikeColumns):
+			return &fakedb.Result{Columns: strings.Split(strings.Join(strings.Fields(bikeColumns), ""), ","), Rows: [][]driver.Value{{int64(7), "bike 7", "52.52", "13.40", nil,
+				bikeStatus, nil, int64(12), nil, 50.0, nil, int64(0), nil, "city", false}}}, nil
+		case strings.HasPrefix(statement.Query, "SELECT count(*) FROM "+DB_TABLE_WORKORDER):
+			return &fakedb.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(len(orders))}}}, nil
+		case strings.HasPrefix(statement.Query, `insert into "`+DB_TABLE_WORKORDER+`"`):
+			orders = append(orders, statement)
+			return &fakedb.Result{Columns: []string{"orderid"}, Rows: [][]driver.Value{{int64(len(orders))}}}, nil
+		}
+		return nil, errors.New("unexpected statement: " + statement.Query)
+	}
+
+	published, err := RunOutboxDispatcher(context.Background())
+	if err != nil || published != 2 {
+		t.Fatalf("expected 2 published events, got %v and error %v", published, err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("expected one low battery work order, got %v", len(orders))
+	}
+	if !orders[0].InTransaction || orders[0].Args[0] != int64(7) || orders[0].Args[1] != WORK_ORDER_SOURCE_LOW_BATTERY || orders[0].Args[5] != "battery at 12%" {
+		t.Fatalf("expected the order for bike 7 in the transaction of the dispatcher, got %v", orders[0].Args)
+	}
+}
@@ -0,0 +1,337 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ---------- PENALTY TABLE CONSTANTS ---------
+	DB_TABLE_PENALTYRULE = "penaltyrule"
+	DB_TABLE_RIDEPENALTY = "ridepenalty"
+)
+
+// the columns of the penaltyrule table in the order they are scanned by scanPenaltyRule
+const penaltyRuleColumns = `ruleid, kind, description, feecents, thresholdminutes, additionalcentsperhour, active`
+
+// the columns of the ridepenalty table in the order they are scanned by scanRidePenalty
+const ridePenaltyColumns = `penaltyid, rideid, ruleid, kind, description, amountcents, createdat, waivedat, waivedby, waivedreason`
+
+/*
+Implementation method to retrieve all penalty rules from the Database
+*/
+func GetPenaltyRules(ctx context.Context) (*[]PenaltyRuleImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	rules, getRulesError := getPenaltyRulesFromDb(ctx, db, false)
+	if getRulesError != nil {
+		return nil, getRulesError
+	}
+
+	return &rules, nil
+}
+
+/*
+Implementation method to create a penalty rule or to update an existing rule with the same ruleId.
+Changed rules only apply to rides which end afterwards.
+*/
+func SavePenaltyRule(ctx context.Context, rule PenaltyRuleImpl) (*PenaltyRuleImpl, error) {
+
+	rule.RuleId = strings.TrimSpace(rule.RuleId)
+	validationError := validatePenaltyRule(rule)
+	if validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	upsertStatement := `INSERT INTO ` + DB_TABLE_PENALTYRULE + ` (` + penaltyRuleColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (ruleid) DO UPDATE SET kind=EXCLUDED.kind, description=EXCLUDED.description, feecents=EXCLUDED.feecents,
+		thresholdminutes=EXCLUDED.thresholdminutes, additionalcentsperhour=EXCLUDED.additionalcentsperhour, active=EXCLUDED.active;`
+	_, dbUpsertError := db.ExecContext(ctx, upsertStatement, rule.RuleId, rule.Kind, rule.Description, rule.FeeCents, rule.ThresholdMinutes,
+		rule.AdditionalCentsPerHour, rule.Active)
+	if dbUpsertError != nil {
+		return nil, fmt.Errorf("could not save record into penaltyrule Table. %w", dbUpsertError)
+	}
+
+	return &rule, nil
+}
+
+/*
+Implementation method to retrieve the itemized penalties of a ride
+*/
+func GetRidePenalties(ctx context.Context, rideId string) (*[]RidePenaltyImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+ridePenaltyColumns+` FROM `+DB_TABLE_RIDEPENALTY+` WHERE rideid=$1 ORDER BY createdat, kind;`, rideId)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving penalties of ride %v", rideId)
+	}
+	defer rows.Close()
+
+	arrayOfPenalties := []RidePenaltyImpl{}
+	for rows.Next() {
+		penalty, scanError := scanRidePenalty(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		arrayOfPenalties = append(arrayOfPenalties, *penalty)
+	}
+
+	return &arrayOfPenalties, nil
+}
+
+/*
+Implementation method for an operator to waive a penalty of a ride.
+The receipt of the ride is immutable, so the waived amount is refunded with a credit note.
+Who waived the penalty, when and why is stored with the penalty.
+*/
+func WaivePenalty(ctx context.Context, waiveRequest WaivePenaltyImpl) (*RidePenaltyImpl, error) {
+
+	waiveRequest.Reason = strings.TrimSpace(waiveRequest.Reason)
+	// a reason is required to waive a penalty
+	validator := fieldValidator{}
+	validator.requiredText("reason", waiveRequest.Reason, MAX_NOTE_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+	if waiveRequest.Operator == "" {
+		return nil, UnauthorizedError(ERROR_CODE_UNAUTHORIZED, "no operator provided")
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	// lock the penalty, so that it can not be waived twice
+	rows, dbQueryError := tx.QueryContext(ctx, `SELECT `+ridePenaltyColumns+` FROM `+DB_TABLE_RIDEPENALTY+` WHERE penaltyid=$1 AND rideid=$2 FOR UPDATE;`,
+		waiveRequest.PenaltyId, waiveRequest.RideId)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve penalty %v. %w", waiveRequest.PenaltyId, dbQueryError)
+	}
+	if !rows.Next() {
+		rows.Close()
+		return nil, NotFoundError(ERROR_CODE_PENALTY_NOT_FOUND, "penalty %v does not exist for ride %v", waiveRequest.PenaltyId, waiveRequest.RideId)
+	}
+	penalty, scanError := scanRidePenalty(rows)
+	rows.Close()
+	if scanError != nil {
+		return nil, scanError
+	}
+
+	if penalty.WaivedAt != nil {
+		return nil, ConflictError(ERROR_CODE_PENALTY_ALREADY_WAIVED, "penalty %v was already waived", penalty.PenaltyId)
+	}
+
+	waivedAt := time.Now()
+	updateStatement := `UPDATE ` + DB_TABLE_RIDEPENALTY + ` SET waivedat=$1, waivedby=$2, waivedreason=$3 WHERE penaltyid=$4;`
+	_, dbUpdateError := tx.ExecContext(ctx, updateStatement, waivedAt, waiveRequest.Operator, waiveRequest.Reason, penalty.PenaltyId)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not waive penalty %v. %w", penalty.PenaltyId, dbUpdateError)
+	}
+	penalty.WaivedAt = &waivedAt
+	penalty.WaivedBy = &waiveRequest.Operator
+	penalty.WaivedReason = &waiveRequest.Reason
+
+	rideReceipt, getReceiptError := getReceiptFromDb(ctx, tx, "rideid=$1 AND kind='"+RECEIPT_KIND_RIDE+"'", penalty.RideId)
+	if getReceiptError != nil {
+		return nil, getReceiptError
+	}
+
+	_, createCreditError := createCreditReceipt(ctx, tx, rideReceipt, "Waived penalty: "+penalty.Description, penalty.AmountCents)
+	if createCreditError != nil {
+		return nil, createCreditError
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not waive penalty. %w", commitError)
+	}
+
+	return penalty, nil
+}
+
+/*
+returns the conditions at the end of a ride which are checked by the penalty rules.
+the end position of the ride is evaluated against the zones, a damage is reported if the rider reported a damage
+of the bike during the ride which was not rejected
+*/
+func evaluateRideEndConditions(ctx context.Context, tx dbQueryer, ride *RideImpl) (RideEndConditionsImpl, error) {
+	conditions := RideEndConditionsImpl{
+		DurationMinutes: int(ride.EndedAt.Sub(ride.StartedAt).Minutes()),
+	}
+
+	latitude, longitude, parseError := parseCoordinates(ride.EndLatitude, ride.EndLongitude)
+	if parseError != nil {
+		return conditions, parseError
+	}
+
+	evaluation, evaluatePositionError := evaluatePosition(ctx, tx, latitude, longitude)
+	if evaluatePositionError != nil {
+		return conditions, evaluatePositionError
+	}
+	conditions.OutsideOperatingZone = !evaluation.InsideOperatingArea
+	conditions.InNoParkingZone = evaluation.InNoParkingZone
+
+	dbQueryError := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM `+DB_TABLE_DAMAGEREPORT+` WHERE bikeid=$1 AND username=$2
+		AND createdat BETWEEN $3 AND $4 AND status<>$5);`, ride.BikeId, ride.Username, ride.StartedAt, ride.EndedAt, DAMAGE_REPORT_REJECTED).Scan(&conditions.DamageReported)
+	if dbQueryError != nil {
+		return conditions, fmt.Errorf("could not check damage reports of ride %v. %w", ride.RideId, dbQueryError)
+	}
+
+	return conditions, nil
+}
+
+/*
+evaluates the active penalty rules against the conditions at the end of a ride.
+every penalty is added as a charge to the fare after discounts were applied, so included minutes and promotions never reduce a penalty.
+returns the penalties, which have to be stored with insertRidePenalties after the ride was inserted
+*/
+func applyPenalties(ctx context.Context, tx dbQueryer, fare *FareImpl, ride *RideImpl, conditions RideEndConditionsImpl) ([]RidePenaltyImpl, error) {
+
+	rules, getRulesError := getPenaltyRulesFromDb(ctx, tx, true)
+	if getRulesError != nil {
+		return nil, getRulesError
+	}
+
+	penalties := []RidePenaltyImpl{}
+	for _, rule := range rules {
+		amountCents := 0
+		switch rule.Kind {
+		case PENALTY_OVER_DURATION:
+			if conditions.DurationMinutes > rule.ThresholdMinutes {
+				// every started hour above the threshold is charged additionally
+				startedHours := (conditions.DurationMinutes - rule.ThresholdMinutes + 59) / 60
+				amountCents = rule.FeeCents + startedHours*rule.AdditionalCentsPerHour
+			}
+		case PENALTY_OUTSIDE_OPERATING_ZONE:
+			if conditions.OutsideOperatingZone {
+				amountCents = rule.FeeCents
+			}
+		case PENALTY_NO_PARKING_ZONE:
+			if conditions.InNoParkingZone {
+				amountCents = rule.FeeCents
+			}
+		case PENALTY_REPORTED_DAMAGE:
+			if conditions.DamageReported {
+				amountCents = rule.FeeCents
+			}
+		}
+
+		if amountCents <= 0 {
+			continue
+		}
+
+		fare.addCharge("Penalty: "+rule.Description, amountCents)
+		penalties = append(penalties, RidePenaltyImpl{
+			PenaltyId:   uuid.New().String(),
+			RideId:      ride.RideId,
+			RuleId:      rule.RuleId,
+			Kind:        rule.Kind,
+			Description: rule.Description,
+			AmountCents: amountCents,
+			CreatedAt:   ride.EndedAt,
+		})
+	}
+
+	return penalties, nil
+}
+
+// stores the penalties of a ride. It is called in the transaction which finishes the ride
+func insertRidePenalties(ctx context.Context, tx dbQueryer, penalties []RidePenaltyImpl) error {
+	insertStatement := getInsertStmt(DB_TABLE_RIDEPENALTY, "penaltyid", "rideid", "ruleid", "kind", "description", "amountcents", "createdat")
+	for _, penalty := range penalties {
+		_, dbInsertError := tx.ExecContext(ctx, insertStatement, penalty.PenaltyId, penalty.RideId, penalty.RuleId, penalty.Kind, penalty.Description,
+			penalty.AmountCents, penalty.CreatedAt)
+		if dbInsertError != nil {
+			return fmt.Errorf("could not insert record into ridepenalty Table. %w", dbInsertError)
+		}
+	}
+
+	return nil
+}
+
+// checks that a penalty rule which is saved has a consistent configuration
+func validatePenaltyRule(rule PenaltyRuleImpl) error {
+	validator := fieldValidator{}
+	validator.requiredText("ruleId", rule.RuleId, MAX_ID_LENGTH)
+	validator.requiredText("description", rule.Description, MAX_DESCRIPTION_LENGTH)
+	validator.oneOf("kind", rule.Kind, PENALTY_OVER_DURATION, PENALTY_OUTSIDE_OPERATING_ZONE, PENALTY_NO_PARKING_ZONE, PENALTY_REPORTED_DAMAGE)
+	validator.atLeast("feeCents", rule.FeeCents, 0)
+	validator.atLeast("thresholdMinutes", rule.ThresholdMinutes, 0)
+	validator.atLeast("additionalCentsPerHour", rule.AdditionalCentsPerHour, 0)
+	return validator.err()
+}
+
+// returns the penalty rules ordered by their id. if onlyActive is set, inactive rules are skipped
+func getPenaltyRulesFromDb(ctx context.Context, database dbQueryer, onlyActive bool) ([]PenaltyRuleImpl, error) {
+	queryString := `SELECT ` + penaltyRuleColumns + ` FROM ` + DB_TABLE_PENALTYRULE
+	if onlyActive {
+		queryString += ` WHERE active`
+	}
+
+	rows, dbQueryError := database.QueryContext(ctx, queryString+` ORDER BY ruleid;`)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving all records from table %v", DB_TABLE_PENALTYRULE)
+	}
+	defer rows.Close()
+
+	rules := []PenaltyRuleImpl{}
+	for rows.Next() {
+		rule := PenaltyRuleImpl{}
+		scanError := rows.Scan(&rule.RuleId, &rule.Kind, &rule.Description, &rule.FeeCents, &rule.ThresholdMinutes,
+			&rule.AdditionalCentsPerHour, &rule.Active)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into penalty rule object", DB_TABLE_PENALTYRULE)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// scans a row which was selected with ridePenaltyColumns into a penalty object
+func scanRidePenalty(rows *sql.Rows) (*RidePenaltyImpl, error) {
+	penalty := RidePenaltyImpl{}
+	var waivedAt sql.NullTime
+	var waivedBy, waivedReason sql.NullString
+
+	scanError := rows.Scan(&penalty.PenaltyId, &penalty.RideId, &penalty.RuleId, &penalty.Kind, &penalty.Description, &penalty.AmountCents,
+		&penalty.CreatedAt, &waivedAt, &waivedBy, &waivedReason)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into penalty object", DB_TABLE_RIDEPENALTY)
+	}
+
+	if waivedAt.Valid {
+		penalty.WaivedAt = &waivedAt.Time
+		penalty.WaivedBy = &waivedBy.String
+		penalty.WaivedReason = &waivedReason.String
+	}
+
+	return &penalty, nil
+}
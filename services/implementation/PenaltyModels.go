@@ -0,0 +1,63 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- kinds of penalty rules ---------
+	PENALTY_OVER_DURATION          = "over_duration"
+	PENALTY_OUTSIDE_OPERATING_ZONE = "outside_operating_zone"
+	PENALTY_NO_PARKING_ZONE        = "no_parking_zone"
+	PENALTY_REPORTED_DAMAGE        = "reported_damage"
+)
+
+/*
+represents the database structure for the table "penaltyrule" in the DATABASE.
+ThresholdMinutes and AdditionalCentsPerHour are only used by the kind over_duration:
+a ride longer than ThresholdMinutes is charged the FeeCents plus AdditionalCentsPerHour for every started hour above the threshold.
+*/
+type PenaltyRuleImpl struct {
+	RuleId                 string `json:"ruleId"`
+	Kind                   string `json:"kind"`
+	Description            string `json:"description"`
+	FeeCents               int    `json:"feeCents"`
+	ThresholdMinutes       int    `json:"thresholdMinutes"`
+	AdditionalCentsPerHour int    `json:"additionalCentsPerHour"`
+	Active                 bool   `json:"active"`
+}
+
+/*
+represents the database structure for the table "ridepenalty" in the DATABASE.
+The waiver fields are nil as long as the penalty is not waived.
+*/
+type RidePenaltyImpl struct {
+	PenaltyId    string     `json:"penaltyId"`
+	RideId       string     `json:"rideId"`
+	RuleId       string     `json:"ruleId"`
+	Kind         string     `json:"kind"`
+	Description  string     `json:"description"`
+	AmountCents  int        `json:"amountCents"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	WaivedAt     *time.Time `json:"waivedAt"`
+	WaivedBy     *string    `json:"waivedBy"`
+	WaivedReason *string    `json:"waivedReason"`
+}
+
+/*
+represents the conditions at the end of a ride which are checked by the penalty rules
+*/
+type RideEndConditionsImpl struct {
+	DurationMinutes      int
+	OutsideOperatingZone bool
+	InNoParkingZone      bool
+	DamageReported       bool
+}
+
+/*
+represents the request of an operator to waive a penalty
+*/
+type WaivePenaltyImpl struct {
+	RideId    string `json:"rideId"`
+	PenaltyId string `json:"penaltyId"`
+	Operator  string `json:"operator"`
+	Reason    string `json:"reason"`
+}
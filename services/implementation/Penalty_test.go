@@ -0,0 +1,86 @@
+package implementation
+
+import (
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"strings"
+	"testing"
+	"time"
+)
+
+// one active rule of every kind
+var testPenaltyRules = [][]driver.Value{
+	{"late", PENALTY_OVER_DURATION, "Ride longer than 2 hours", int64(500), int64(120), int64(300), true},
+	{"outside", PENALTY_OUTSIDE_OPERATING_ZONE, "Returned outside of the operating zone", int64(2000), int64(0), int64(0), true},
+	{"noparking", PENALTY_NO_PARKING_ZONE, "Returned in a no-parking zone", int64(1000), int64(0), int64(0), true},
+	{"damage", PENALTY_REPORTED_DAMAGE, "Damage reported", int64(1500), int64(0), int64(0), true},
+}
+
+// answers the penalty rules, the zones and the damage reports of a ride
+func usePenaltyDB(t *testing.T, damageReported bool) *[]fakedb.Statement {
+	t.Helper()
+	statements := []fakedb.Statement{}
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		statements = append(statements, statement)
+		switch {
+		case strings.HasPrefix(statement.Query, "SELECT "+penaltyRuleColumns):
+			return &fakedb.Result{Columns: strings.Split(penaltyRuleColumns, ", "), Rows: testPenaltyRules}, nil
+		case strings.Contains(statement.Query, "FROM "+DB_TABLE_DAMAGEREPORT):
+			return &fakedb.Result{Columns: []string{"exists"}, Rows: [][]driver.Value{{damageReported}}}, nil
+		}
+		// no zones are defined
+		return &fakedb.Result{}, nil
+	})
+	return &statements
+}
+
+func TestRideEndConditionsReportDamageOfRider(t *testing.T) {
+	for _, damageReported := range []bool{true, false} {
+		statements := usePenaltyDB(t, damageReported)
+		db, _ := SetupDB()
+
+		startedAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+		ride := &RideImpl{RideId: "ride-1", BikeId: 7, Username: "userOne", StartedAt: startedAt, EndedAt: startedAt.Add(30 * time.Minute),
+			EndLatitude: "52.52", EndLongitude: "13.40"}
+		conditions, err := evaluateRideEndConditions(context.Background(), db, ride)
+		if err != nil {
+			t.Fatalf("expected the conditions of the ride, got %v", err)
+		}
+		if conditions.DamageReported != damageReported {
+			t.Fatalf("expected damageReported %v, got %v", damageReported, conditions.DamageReported)
+		}
+
+		for _, statement := range *statements {
+			if strings.Contains(statement.Query, "FROM "+DB_TABLE_DAMAGEREPORT) &&
+				(statement.Args[0] != int64(7) || statement.Args[1] != "userOne" || !statement.Args[2].(time.Time).Equal(ride.StartedAt)) {
+				t.Fatalf("expected the reports of userOne for bike 7 during the ride, got %v", statement.Args)
+			}
+		}
+	}
+}
+
+func TestEveryPenaltyRuleKindCharges(t *testing.T) {
+	usePenaltyDB(t, false)
+	db, _ := SetupDB()
+
+	for kind, conditions := range map[string]RideEndConditionsImpl{
+		PENALTY_OVER_DURATION:          {DurationMinutes: 121},
+		PENALTY_OUTSIDE_OPERATING_ZONE: {OutsideOperatingZone: true},
+		PENALTY_NO_PARKING_ZONE:        {InNoParkingZone: true},
+		PENALTY_REPORTED_DAMAGE:        {DamageReported: true},
+	} {
+		penalties, err := applyPenalties(context.Background(), db, newBaseFare(conditions.DurationMinutes), &RideImpl{RideId: "ride-1"}, conditions)
+		if err != nil {
+			t.Fatalf("expected the penalties of %v, got %v", kind, err)
+		}
+		if len(penalties) != 1 || penalties[0].Kind != kind {
+			t.Fatalf("expected only a penalty of %v, got %+v", kind, penalties)
+		}
+	}
+
+	penalties, err := applyPenalties(context.Background(), db, newBaseFare(30), &RideImpl{RideId: "ride-1"}, RideEndConditionsImpl{DurationMinutes: 30})
+	if err != nil || len(penalties) != 0 {
+		t.Fatalf("expected no penalty for a short ride in the operating zone, got %+v and %v", penalties, err)
+	}
+}
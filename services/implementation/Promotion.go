@@ -0,0 +1,315 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the columns of the promotion table in the order they are scanned by scanPromotion
+const promotionColumns = `code, description, discounttype, discountvalue, freeminutes, validfrom, validuntil, weekendsonly, peruserlimit, globallimit, firstrideonly, active`
+
+/*
+Implementation method to retrieve all promotions from the Database
+*/
+func GetAllPromotions(ctx context.Context) (*[]PromotionImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+promotionColumns+` FROM `+DB_TABLE_PROMOTION+` ORDER BY code;`)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving all records from table %v", DB_TABLE_PROMOTION)
+	}
+	defer rows.Close()
+
+	arrayOfPromotions := []PromotionImpl{}
+	for rows.Next() {
+		promotion, scanError := scanPromotion(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		arrayOfPromotions = append(arrayOfPromotions, *promotion)
+	}
+
+	return &arrayOfPromotions, nil
+}
+
+/*
+Implementation method to create a new promotion code.
+The code is stored in upper case, so codes are case insensitive for riders.
+*/
+func CreatePromotion(ctx context.Context, promotion PromotionImpl) (*PromotionImpl, error) {
+
+	promotion.Code = strings.ToUpper(strings.TrimSpace(promotion.Code))
+	if promotion.DiscountType == "" {
+		promotion.DiscountType = PROMOTION_DISCOUNT_NONE
+	}
+
+	validationError := validatePromotion(promotion)
+	if validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	insertStatement := `INSERT INTO ` + DB_TABLE_PROMOTION + ` (` + promotionColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);`
+	_, dbInsertError := db.ExecContext(ctx, insertStatement, promotion.Code, promotion.Description, promotion.DiscountType, promotion.DiscountValue, promotion.FreeMinutes,
+		promotion.ValidFrom, promotion.ValidUntil, promotion.WeekendsOnly, promotion.PerUserLimit, promotion.GlobalLimit, promotion.FirstRideOnly, promotion.Active)
+	if dbInsertError != nil {
+		if isUniqueViolation(dbInsertError) {
+			return nil, ConflictError(ERROR_CODE_PROMOTION_EXISTS, "promotion code %v already exists", promotion.Code)
+		}
+		return nil, fmt.Errorf("could not insert record into promotion Table. %w", dbInsertError)
+	}
+
+	return &promotion, nil
+}
+
+/*
+Implementation method to attach a promotion code to a running reservation.
+The code is checked against the rules of the promotion now, and checked again when the ride ends,
+since limits might have been reached in the meantime.
+*/
+func AttachPromotionToReservation(ctx context.Context, attachRequest AttachPromotionImpl) error {
+
+	code := strings.ToUpper(strings.TrimSpace(attachRequest.Code))
+	validator := fieldValidator{}
+	validator.requiredText("code", code, MAX_ID_LENGTH)
+	validator.requiredText("username", attachRequest.Username, MAX_USERNAME_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return dbConnectError
+	}
+
+	reservation, getReservationError := getReservationFromDb(ctx, db, DB_TABLE_RESERVATION_COLUMN_RESERVATIONID, attachRequest.ReservationId)
+	if getReservationError != nil {
+		return getReservationError
+	}
+
+	if reservation.Username != attachRequest.Username {
+		return ForbiddenError(ERROR_CODE_RESERVATION_NOT_OWNED, "reservation %v does not belong to user %v", attachRequest.ReservationId, attachRequest.Username)
+	}
+
+	promotion, getPromotionError := getPromotionFromDb(ctx, db, code, false)
+	if getPromotionError != nil {
+		return getPromotionError
+	}
+
+	eligibilityError := checkPromotionEligibility(ctx, db, promotion, reservation.Username, reservation.CreatedAt)
+	if eligibilityError != nil {
+		return eligibilityError
+	}
+
+	updateStmt := getUpdateStmtOneColumn(DB_TABLE_RESERVATION, DB_TABLE_RESERVATION_COLUMN_PROMOCODE, DB_TABLE_RESERVATION_COLUMN_RESERVATIONID)
+	_, dbUpdateError := db.ExecContext(ctx, updateStmt, promotion.Code, reservation.ReservationId)
+	if dbUpdateError != nil {
+		return fmt.Errorf("could not attach promotion code to reservation. %w", dbUpdateError)
+	}
+
+	return nil
+}
+
+// checks that a promotion which is created has a consistent configuration
+func validatePromotion(promotion PromotionImpl) error {
+	validator := fieldValidator{}
+	validator.requiredText("code", promotion.Code, MAX_ID_LENGTH)
+	validator.maxLength("description", promotion.Description, MAX_DESCRIPTION_LENGTH)
+
+	switch promotion.DiscountType {
+	case PROMOTION_DISCOUNT_NONE:
+		if promotion.FreeMinutes <= 0 {
+			validator.add("freeMinutes", "a promotion without discount needs free minutes")
+		}
+	case PROMOTION_DISCOUNT_PERCENT:
+		validator.between("discountValue", promotion.DiscountValue, 1, 100)
+	case PROMOTION_DISCOUNT_FIXED:
+		validator.atLeast("discountValue", promotion.DiscountValue, 1)
+	default:
+		validator.oneOf("discountType", promotion.DiscountType, PROMOTION_DISCOUNT_NONE, PROMOTION_DISCOUNT_PERCENT, PROMOTION_DISCOUNT_FIXED)
+	}
+
+	validator.atLeast("freeMinutes", promotion.FreeMinutes, 0)
+	validator.atLeast("perUserLimit", promotion.PerUserLimit, 0)
+	validator.atLeast("globalLimit", promotion.GlobalLimit, 0)
+
+	if promotion.ValidFrom != nil && promotion.ValidUntil != nil && promotion.ValidUntil.Before(*promotion.ValidFrom) {
+		validator.add("validUntil", "must not be before validFrom")
+	}
+
+	return validator.err()
+}
+
+/*
+returns the promotion for the given code.
+if forUpdate is set, the promotion row is locked until the transaction ends,
+so that concurrent redemptions can not exceed the limits
+*/
+func getPromotionFromDb(ctx context.Context, database dbQueryer, code string, forUpdate bool) (*PromotionImpl, error) {
+	queryString := `SELECT ` + promotionColumns + ` FROM ` + DB_TABLE_PROMOTION + ` WHERE code=$1`
+	if forUpdate {
+		queryString += ` FOR UPDATE`
+	}
+
+	rows, dbQueryError := database.QueryContext(ctx, queryString, code)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve promotion %v. %w", code, dbQueryError)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, NotFoundError(ERROR_CODE_PROMOTION_NOT_FOUND, "promotion code %v does not exist", code)
+	}
+
+	return scanPromotion(rows)
+}
+
+// scans a row which was selected with promotionColumns into a promotion object
+func scanPromotion(rows *sql.Rows) (*PromotionImpl, error) {
+	promotion := PromotionImpl{}
+	var validFrom, validUntil sql.NullTime
+
+	scanError := rows.Scan(&promotion.Code, &promotion.Description, &promotion.DiscountType, &promotion.DiscountValue, &promotion.FreeMinutes,
+		&validFrom, &validUntil, &promotion.WeekendsOnly, &promotion.PerUserLimit, &promotion.GlobalLimit, &promotion.FirstRideOnly, &promotion.Active)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into promotion object", DB_TABLE_PROMOTION)
+	}
+
+	if validFrom.Valid {
+		promotion.ValidFrom = &validFrom.Time
+	}
+	if validUntil.Valid {
+		promotion.ValidUntil = &validUntil.Time
+	}
+
+	return &promotion, nil
+}
+
+/*
+checks if a user may redeem a promotion for a ride which started at the given time.
+returns an error which describes why the promotion can not be used
+*/
+func checkPromotionEligibility(ctx context.Context, database dbQueryer, promotion *PromotionImpl, username string, rideStart time.Time) error {
+
+	if !promotion.Active {
+		return ValidationError(ERROR_CODE_PROMOTION_NOT_APPLICABLE, "promotion code %v is not active", promotion.Code)
+	}
+
+	if promotion.ValidFrom != nil && rideStart.Before(*promotion.ValidFrom) {
+		return ValidationError(ERROR_CODE_PROMOTION_NOT_APPLICABLE, "promotion code %v is not valid yet", promotion.Code)
+	}
+
+	if promotion.ValidUntil != nil && rideStart.After(*promotion.ValidUntil) {
+		return ValidationError(ERROR_CODE_PROMOTION_NOT_APPLICABLE, "promotion code %v has expired", promotion.Code)
+	}
+
+	if promotion.WeekendsOnly {
+		weekday := rideStart.Local().Weekday()
+		if weekday != time.Saturday && weekday != time.Sunday {
+			return ValidationError(ERROR_CODE_PROMOTION_NOT_APPLICABLE, "promotion code %v is only valid for rides started on weekends", promotion.Code)
+		}
+	}
+
+	if promotion.PerUserLimit > 0 {
+		var redemptionsOfUser int
+		countError := database.QueryRowContext(ctx, `SELECT count(*) FROM `+DB_TABLE_PROMOTIONREDEMPTION+` WHERE code=$1 AND username=$2;`, promotion.Code, username).Scan(&redemptionsOfUser)
+		if countError != nil {
+			return fmt.Errorf("could not count redemptions of promotion %v. %w", promotion.Code, countError)
+		}
+		if redemptionsOfUser >= promotion.PerUserLimit {
+			return ValidationError(ERROR_CODE_PROMOTION_NOT_APPLICABLE, "promotion code %v has already been redeemed by user %v", promotion.Code, username)
+		}
+	}
+
+	if promotion.GlobalLimit > 0 {
+		var redemptions int
+		countError := database.QueryRowContext(ctx, `SELECT count(*) FROM `+DB_TABLE_PROMOTIONREDEMPTION+` WHERE code=$1;`, promotion.Code).Scan(&redemptions)
+		if countError != nil {
+			return fmt.Errorf("could not count redemptions of promotion %v. %w", promotion.Code, countError)
+		}
+		if redemptions >= promotion.GlobalLimit {
+			return ValidationError(ERROR_CODE_PROMOTION_NOT_APPLICABLE, "promotion code %v has been fully redeemed", promotion.Code)
+		}
+	}
+
+	if promotion.FirstRideOnly {
+		var ridesOfUser int
+		countError := database.QueryRowContext(ctx, `SELECT count(*) FROM `+DB_TABLE_RIDE+` WHERE username=$1;`, username).Scan(&ridesOfUser)
+		if countError != nil {
+			return fmt.Errorf("could not count rides of user %v. %w", username, countError)
+		}
+		if ridesOfUser > 0 {
+			return ValidationError(ERROR_CODE_PROMOTION_NOT_APPLICABLE, "promotion code %v is only valid for the first ride", promotion.Code)
+		}
+	}
+
+	return nil
+}
+
+/*
+applies the promotion attached to a reservation to the fare of the ride and records the redemption.
+The eligibility is checked again, since limits might have been reached while the ride was running.
+If the promotion can not be applied anymore, the ride is charged without discount and a note is added to the fare.
+returns the discount in cents which was applied
+*/
+func applyPromotion(ctx context.Context, tx dbQueryer, fare *FareImpl, ride *RideImpl) (int, error) {
+
+	if !ride.PromoCode.Valid {
+		return 0, nil
+	}
+
+	promotion, getPromotionError := getPromotionFromDb(ctx, tx, ride.PromoCode.String, true)
+	if getPromotionError != nil {
+		return 0, getPromotionError
+	}
+
+	eligibilityError := checkPromotionEligibility(ctx, tx, promotion, ride.Username, ride.StartedAt)
+	if eligibilityError != nil {
+		fare.Lines = append(fare.Lines, FareLineImpl{Description: fmt.Sprintf("Promotion %v not applied: %v", promotion.Code, eligibilityError), AmountCents: 0})
+		return 0, nil
+	}
+
+	discountCents := 0
+
+	// free minutes are taken from the minutes which are not yet covered by another discount
+	if promotion.FreeMinutes > 0 {
+		freeMinutes := promotion.FreeMinutes
+		if freeMinutes > fare.chargeableMinutes() {
+			freeMinutes = fare.chargeableMinutes()
+		}
+		fare.FreeMinutes += freeMinutes
+		discountCents += fare.addDiscount(fmt.Sprintf("Promotion %v: %d free min", promotion.Code, freeMinutes), freeMinutes*TARIFF_PRICE_PER_MINUTE_CENTS)
+	}
+
+	switch promotion.DiscountType {
+	case PROMOTION_DISCOUNT_PERCENT:
+		percentDiscount := (fare.TotalCents()*promotion.DiscountValue + 50) / 100
+		discountCents += fare.addDiscount(fmt.Sprintf("Promotion %v: %d%% off", promotion.Code, promotion.DiscountValue), percentDiscount)
+	case PROMOTION_DISCOUNT_FIXED:
+		discountCents += fare.addDiscount(fmt.Sprintf("Promotion %v: %v off", promotion.Code, FormatCents(promotion.DiscountValue)), promotion.DiscountValue)
+	}
+
+	// record the redemption, so that the limits of the promotion are counted
+	insertStatement := getInsertStmt(DB_TABLE_PROMOTIONREDEMPTION, "redemptionid", "code", "username", "rideid", "discountcents")
+	_, dbInsertError := tx.ExecContext(ctx, insertStatement, uuid.New().String(), promotion.Code, ride.Username, ride.RideId, discountCents)
+	if dbInsertError != nil {
+		return 0, fmt.Errorf("could not insert record into promotion redemption Table. %w", dbInsertError)
+	}
+
+	return discountCents, nil
+}
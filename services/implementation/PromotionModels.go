@@ -0,0 +1,41 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- discount types of a promotion ---------
+	PROMOTION_DISCOUNT_NONE    = "none"
+	PROMOTION_DISCOUNT_PERCENT = "percent"
+	PROMOTION_DISCOUNT_FIXED   = "fixed"
+)
+
+/*
+represents the database structure for the table "promotion" in the DATABASE.
+DiscountValue is a percentage for the discount type "percent" and an amount of cents for "fixed".
+FreeMinutes can be combined with every discount type.
+A limit of 0 means that the promotion can be redeemed unlimited times.
+ValidFrom and ValidUntil are optional, nil means that the promotion is not limited in time.
+*/
+type PromotionImpl struct {
+	Code          string     `json:"code"`
+	Description   string     `json:"description"`
+	DiscountType  string     `json:"discountType"`
+	DiscountValue int        `json:"discountValue"`
+	FreeMinutes   int        `json:"freeMinutes"`
+	ValidFrom     *time.Time `json:"validFrom"`
+	ValidUntil    *time.Time `json:"validUntil"`
+	WeekendsOnly  bool       `json:"weekendsOnly"`
+	PerUserLimit  int        `json:"perUserLimit"`
+	GlobalLimit   int        `json:"globalLimit"`
+	FirstRideOnly bool       `json:"firstRideOnly"`
+	Active        bool       `json:"active"`
+}
+
+/*
+represents a request to attach a promotion code to a running reservation
+*/
+type AttachPromotionImpl struct {
+	ReservationId string `json:"reservationId"`
+	Username      string `json:"username"`
+	Code          string `json:"code"`
+}
@@ -0,0 +1,244 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ---------- RECEIPT TABLE CONSTANTS ---------
+	DB_TABLE_RECEIPT = "receipt"
+
+	// VAT rate which is included in all prices
+	VAT_RATE_PERCENT = 19
+
+	// layout of the month of a statement, e.g. 2023-04
+	STATEMENT_MONTH_LAYOUT = "2006-01"
+
+	// ---------- kinds of receipts ---------
+	RECEIPT_KIND_RIDE   = "ride"
+	RECEIPT_KIND_CREDIT = "credit"
+)
+
+// the columns of the receipt table in the order they are scanned by scanReceipt
+const receiptColumns = `receiptid, receiptnumber, kind, rideid, username, bikeid, bikename, startedat, endedat, startlatitude, startlongitude, endlatitude, endlongitude,
+	durationminutes, lines, subtotalcents, discountcents, totalcents, vatratepercent, vatcents, currency, issuedat, organizationid`
+
+/*
+Implementation method to retrieve a receipt by its receiptId
+*/
+func GetReceipt(ctx context.Context, receiptId string) (*ReceiptImpl, error) {
+	if _, parseError := uuid.Parse(receiptId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_RECEIPT_NOT_FOUND, "no receipt found for %v", receiptId)
+	}
+	return getReceiptWhere(ctx, "receiptid=$1", receiptId)
+}
+
+/*
+Implementation method to retrieve the receipt of a ride
+*/
+func GetReceiptForRide(ctx context.Context, rideId string) (*ReceiptImpl, error) {
+	if _, parseError := uuid.Parse(rideId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_RECEIPT_NOT_FOUND, "no receipt found for %v", rideId)
+	}
+	return getReceiptWhere(ctx, "rideid=$1 AND kind='"+RECEIPT_KIND_RIDE+"'", rideId)
+}
+
+/*
+Implementation method to get the statement of a user for a month.
+month has the format YYYY-MM. The statement contains all receipts and credit notes which were issued in the month and paid by the user.
+rides which were billed to an organization are on the invoice report of the organization.
+*/
+func GetMonthlyStatement(ctx context.Context, username string, month string) (*StatementImpl, error) {
+
+	periodStart, parseError := time.ParseInLocation(STATEMENT_MONTH_LAYOUT, month, time.Local)
+	if parseError != nil {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "month %v does not have the format YYYY-MM", month)
+	}
+	periodEnd := periodStart.AddDate(0, 1, 0)
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	queryString := `SELECT ` + receiptColumns + ` FROM ` + DB_TABLE_RECEIPT + ` WHERE username=$1 AND organizationid IS NULL AND issuedat>=$2 AND issuedat<$3 ORDER BY receiptnumber;`
+	rows, dbQueryError := db.QueryContext(ctx, queryString, username, periodStart, periodEnd)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving receipts for user %v", username)
+	}
+	defer rows.Close()
+
+	statement := StatementImpl{
+		Username:    username,
+		Month:       month,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Receipts:    []ReceiptImpl{},
+		Currency:    TARIFF_CURRENCY,
+	}
+
+	for rows.Next() {
+		receipt, scanError := scanReceipt(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		statement.Receipts = append(statement.Receipts, *receipt)
+		if receipt.Kind == RECEIPT_KIND_RIDE {
+			statement.RideCount++
+		}
+		statement.SubtotalCents += receipt.SubtotalCents
+		statement.DiscountCents += receipt.DiscountCents
+		statement.TotalCents += receipt.TotalCents
+		statement.VatCents += receipt.VatCents
+	}
+
+	return &statement, nil
+}
+
+/*
+creates the receipt for a finished ride. It is called in the transaction which finishes the ride.
+returns the receiptId
+*/
+func createReceipt(ctx context.Context, tx dbQueryer, ride *RideImpl, bikeName string) (string, error) {
+
+	receipt := ReceiptImpl{
+		ReceiptId:       uuid.New().String(),
+		Kind:            RECEIPT_KIND_RIDE,
+		RideId:          ride.RideId,
+		Username:        ride.Username,
+		BikeId:          ride.BikeId,
+		BikeName:        bikeName,
+		StartedAt:       ride.StartedAt,
+		EndedAt:         ride.EndedAt,
+		StartLatitude:   ride.StartLatitude,
+		StartLongitude:  ride.StartLongitude,
+		EndLatitude:     ride.EndLatitude,
+		EndLongitude:    ride.EndLongitude,
+		DurationMinutes: ride.DurationMinutes,
+		Lines:           ride.FareLines,
+		SubtotalCents:   ride.SubtotalCents,
+		DiscountCents:   ride.DiscountCents,
+		TotalCents:      ride.TotalCents,
+		IssuedAt:        ride.EndedAt,
+		OrganizationId:  ride.OrganizationId,
+	}
+
+	insertReceiptError := insertReceipt(ctx, tx, &receipt)
+	if insertReceiptError != nil {
+		return "", insertReceiptError
+	}
+
+	return receipt.ReceiptId, nil
+}
+
+/*
+creates a credit note for a ride, e.g. when a penalty is waived.
+The credit note copies the ride details from the receipt of the ride and has a negative total.
+*/
+func createCreditReceipt(ctx context.Context, tx dbQueryer, rideReceipt *ReceiptImpl, description string, creditCents int) (*ReceiptImpl, error) {
+
+	credit := *rideReceipt
+	credit.ReceiptId = uuid.New().String()
+	credit.Kind = RECEIPT_KIND_CREDIT
+	credit.Lines = []FareLineImpl{{Description: description, AmountCents: -creditCents}}
+	credit.SubtotalCents = -creditCents
+	credit.DiscountCents = 0
+	credit.TotalCents = -creditCents
+	credit.IssuedAt = time.Now()
+
+	insertReceiptError := insertReceipt(ctx, tx, &credit)
+	if insertReceiptError != nil {
+		return nil, insertReceiptError
+	}
+
+	return &credit, nil
+}
+
+// inserts a receipt into the receipt table. VAT rate, VAT and currency are set here
+func insertReceipt(ctx context.Context, tx dbQueryer, receipt *ReceiptImpl) error {
+
+	receipt.VatRatePercent = VAT_RATE_PERCENT
+	receipt.VatCents = includedVatCents(receipt.TotalCents)
+	receipt.Currency = TARIFF_CURRENCY
+
+	linesJson, marshalError := json.Marshal(receipt.Lines)
+	if marshalError != nil {
+		return fmt.Errorf("could not marshal receipt lines. %w", marshalError)
+	}
+
+	insertStatement := getInsertStmt(DB_TABLE_RECEIPT, "receiptid", "kind", "rideid", "username", "bikeid", "bikename", "startedat", "endedat",
+		"startlatitude", "startlongitude", "endlatitude", "endlongitude", "durationminutes", "lines", "subtotalcents", "discountcents",
+		"totalcents", "vatratepercent", "vatcents", "currency", "issuedat", "organizationid")
+	dbInsertError := tx.QueryRowContext(ctx, insertStatement+` RETURNING receiptnumber`, receipt.ReceiptId, receipt.Kind, receipt.RideId, receipt.Username, receipt.BikeId, receipt.BikeName,
+		receipt.StartedAt, receipt.EndedAt, receipt.StartLatitude, receipt.StartLongitude, receipt.EndLatitude, receipt.EndLongitude, receipt.DurationMinutes,
+		string(linesJson), receipt.SubtotalCents, receipt.DiscountCents, receipt.TotalCents, receipt.VatRatePercent, receipt.VatCents, receipt.Currency,
+		receipt.IssuedAt, receipt.OrganizationId).Scan(&receipt.ReceiptNumber)
+	if dbInsertError != nil {
+		return fmt.Errorf("could not insert record into receipt Table. %w", dbInsertError)
+	}
+
+	return nil
+}
+
+// returns the VAT which is included in a gross amount, rounded to full cents. Works for credits with negative amounts
+func includedVatCents(grossCents int) int {
+	if grossCents < 0 {
+		return -includedVatCents(-grossCents)
+	}
+	return (grossCents*VAT_RATE_PERCENT*2 + (100 + VAT_RATE_PERCENT)) / ((100 + VAT_RATE_PERCENT) * 2)
+}
+
+// returns the receipt which matches the condition, e.g. "receiptid=$1"
+func getReceiptWhere(ctx context.Context, condition string, value string) (*ReceiptImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	return getReceiptFromDb(ctx, db, condition, value)
+}
+
+// returns the receipt which matches the condition, e.g. "receiptid=$1"
+func getReceiptFromDb(ctx context.Context, database dbQueryer, condition string, value string) (*ReceiptImpl, error) {
+
+	rows, dbQueryError := database.QueryContext(ctx, `SELECT `+receiptColumns+` FROM `+DB_TABLE_RECEIPT+` WHERE `+condition+`;`, value)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve receipt %v. %w", value, dbQueryError)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, NotFoundError(ERROR_CODE_RECEIPT_NOT_FOUND, "no receipt found for %v", value)
+	}
+
+	return scanReceipt(rows)
+}
+
+// scans a row which was selected with receiptColumns into a receipt object
+func scanReceipt(rows *sql.Rows) (*ReceiptImpl, error) {
+	receipt := ReceiptImpl{}
+	var linesJson []byte
+
+	scanError := rows.Scan(&receipt.ReceiptId, &receipt.ReceiptNumber, &receipt.Kind, &receipt.RideId, &receipt.Username, &receipt.BikeId, &receipt.BikeName,
+		&receipt.StartedAt, &receipt.EndedAt, &receipt.StartLatitude, &receipt.StartLongitude, &receipt.EndLatitude, &receipt.EndLongitude,
+		&receipt.DurationMinutes, &linesJson, &receipt.SubtotalCents, &receipt.DiscountCents, &receipt.TotalCents, &receipt.VatRatePercent,
+		&receipt.VatCents, &receipt.Currency, &receipt.IssuedAt, &receipt.OrganizationId)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into receipt object", DB_TABLE_RECEIPT)
+	}
+
+	unmarshalError := json.Unmarshal(linesJson, &receipt.Lines)
+	if unmarshalError != nil {
+		return nil, fmt.Errorf("could not unmarshal lines of receipt %v. %w", receipt.ReceiptId, unmarshalError)
+	}
+
+	return &receipt, nil
+}
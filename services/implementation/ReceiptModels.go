@@ -0,0 +1,55 @@
+package implementation
+
+import (
+	"database/sql"
+	"time"
+)
+
+/*
+represents the database structure for the table "receipt" in the DATABASE.
+a receipt is created once for every finished ride and never changed afterwards.
+corrections are issued as receipts of the kind "credit" with a negative total.
+the total includes VAT, VatCents is the VAT part of the total.
+*/
+type ReceiptImpl struct {
+	ReceiptId       string         `json:"receiptId"`
+	ReceiptNumber   int64          `json:"receiptNumber"`
+	Kind            string         `json:"kind"`
+	RideId          string         `json:"rideId"`
+	Username        string         `json:"username"`
+	BikeId          int            `json:"bikeId"`
+	BikeName        string         `json:"bikeName"`
+	StartedAt       time.Time      `json:"startedAt"`
+	EndedAt         time.Time      `json:"endedAt"`
+	StartLatitude   sql.NullString `json:"startLatitude"`
+	StartLongitude  sql.NullString `json:"startLongitude"`
+	EndLatitude     string         `json:"endLatitude"`
+	EndLongitude    string         `json:"endLongitude"`
+	DurationMinutes int            `json:"durationMinutes"`
+	Lines           []FareLineImpl `json:"lines"`
+	SubtotalCents   int            `json:"subtotalCents"`
+	DiscountCents   int            `json:"discountCents"`
+	TotalCents      int            `json:"totalCents"`
+	VatRatePercent  int            `json:"vatRatePercent"`
+	VatCents        int            `json:"vatCents"`
+	Currency        string         `json:"currency"`
+	IssuedAt        time.Time      `json:"issuedAt"`
+	OrganizationId  sql.NullString `json:"organizationId"` // the organization which pays for the ride, invalid for personal billing
+}
+
+/*
+represents the monthly statement of a user. It aggregates all receipts which were issued in the month.
+*/
+type StatementImpl struct {
+	Username      string        `json:"username"`
+	Month         string        `json:"month"`
+	PeriodStart   time.Time     `json:"periodStart"`
+	PeriodEnd     time.Time     `json:"periodEnd"`
+	Receipts      []ReceiptImpl `json:"receipts"`
+	RideCount     int           `json:"rideCount"`
+	SubtotalCents int           `json:"subtotalCents"`
+	DiscountCents int           `json:"discountCents"`
+	TotalCents    int           `json:"totalCents"`
+	VatCents      int           `json:"vatCents"`
+	Currency      string        `json:"currency"`
+}
@@ -0,0 +1,261 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// the columns of the ride table in the order they are scanned by scanRide
+const rideColumns = `rideid, bikeid, username, startedat, endedat, startlatitude, startlongitude, endlatitude, endlongitude, durationminutes, farelines, subtotalcents, discountcents, totalcents, promocode, organizationid, endstationid`
+
+// the columns of the reservation table in the order they are scanned by getReservationFromDb
+const reservationColumns = `reservationid, bikeid, username, createdat, startlatitude, startlongitude, promocode, billingorganizationid`
+
+/*
+Implementation method to retrieve all finished rides of a user, the latest ride first
+*/
+func GetRidesForUser(ctx context.Context, username string) (*[]RideImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+rideColumns+` FROM `+DB_TABLE_RIDE+` WHERE username=$1 ORDER BY endedat DESC;`, username)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving rides for user %v", username)
+	}
+	defer rows.Close()
+
+	arrayOfRides := []RideImpl{}
+	for rows.Next() {
+		ride, scanError := scanRide(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		arrayOfRides = append(arrayOfRides, *ride)
+	}
+
+	return &arrayOfRides, nil
+}
+
+/*
+finishes the ride of a reservation.
+The fare is computed, the ride and its receipt are stored and the reservation is deleted.
+All of this happens in one transaction, so a ride is never stored without the reservation being deleted and vice versa.
+The end position of the ride is the current position of the bike.
+*/
+func finishRide(ctx context.Context, database *sql.DB, reservation *ReservationRecordImpl, bike *BikeImpl) (*RideImpl, error) {
+
+	endedAt := time.Now()
+
+	ride := RideImpl{
+		RideId:         reservation.ReservationId,
+		BikeId:         reservation.BikeId,
+		Username:       reservation.Username,
+		StartedAt:      reservation.CreatedAt,
+		EndedAt:        endedAt,
+		StartLatitude:  reservation.StartLatitude,
+		StartLongitude: reservation.StartLongitude,
+		EndLatitude:    bike.Latitude,
+		EndLongitude:   bike.Longitude,
+		PromoCode:      reservation.PromoCode,
+		OrganizationId: reservation.BillingOrganizationId,
+	}
+	ride.DurationMinutes = billableMinutes(ride.StartedAt, ride.EndedAt)
+
+	tx, beginError := database.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	// a bike which is returned at a station is docked there. A full station can refuse the return
+	endLatitude, endLongitude, parseError := parseCoordinates(bike.Latitude, bike.Longitude)
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	assignment, assignStationError := assignBikeToStation(ctx, tx, bike.BikeId, endLatitude, endLongitude)
+	if assignStationError != nil {
+		return nil, assignStationError
+	}
+	ride.EndStationId = assignment.StationId
+	if assignment.Warning != "" {
+		ride.Warnings = append(ride.Warnings, assignment.Warning)
+	}
+
+	// compute the fare of the ride. The included minutes of a subscription are used before a promotion.
+	// a subscription is personal, so it is not used for rides which are billed to an organization
+	fare := newBaseFare(ride.DurationMinutes)
+
+	if !ride.OrganizationId.Valid {
+		_, applySubscriptionError := applySubscription(ctx, tx, fare, &ride)
+		if applySubscriptionError != nil {
+			return nil, applySubscriptionError
+		}
+	}
+
+	_, applyPromotionError := applyPromotion(ctx, tx, fare, &ride)
+	if applyPromotionError != nil {
+		return nil, applyPromotionError
+	}
+
+	// penalties are charged after all discounts, so they are always paid in full
+	conditions, evaluateConditionsError := evaluateRideEndConditions(ctx, tx, &ride)
+	if evaluateConditionsError != nil {
+		return nil, evaluateConditionsError
+	}
+
+	penalties, applyPenaltiesError := applyPenalties(ctx, tx, fare, &ride, conditions)
+	if applyPenaltiesError != nil {
+		return nil, applyPenaltiesError
+	}
+
+	ride.FareLines = fare.Lines
+	ride.SubtotalCents = fare.SubtotalCents
+	ride.DiscountCents = fare.DiscountCents
+	ride.TotalCents = fare.TotalCents()
+
+	insertRideError := insertRide(ctx, tx, &ride)
+	if insertRideError != nil {
+		return nil, insertRideError
+	}
+
+	insertPenaltiesError := insertRidePenalties(ctx, tx, penalties)
+	if insertPenaltiesError != nil {
+		return nil, insertPenaltiesError
+	}
+
+	// bikes without telemetry do not report their odometer, so the distance of the ride counts for the maintenance plans.
+	// the straight line between start and end underestimates the distance, which is good enough for the intervals
+	if !bike.LastTelemetryAt.Valid && ride.StartLatitude.Valid && ride.StartLongitude.Valid {
+		startLatitude, startLongitude, parseStartError := parseCoordinates(ride.StartLatitude.String, ride.StartLongitude.String)
+		if parseStartError == nil {
+			rideMeters := int64(distanceMeters(startLatitude, startLongitude, endLatitude, endLongitude))
+			_, dbUpdateError := tx.ExecContext(ctx, `UPDATE `+DB_TABLE_BIKE+` SET odometermeters=odometermeters+$1 WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$2;`, rideMeters, bike.BikeId)
+			if dbUpdateError != nil {
+				return nil, fmt.Errorf("could not update odometer of bike %v. %w", bike.BikeId, dbUpdateError)
+			}
+		}
+	}
+
+	// every finished ride produces a receipt
+	receiptId, createReceiptError := createReceipt(ctx, tx, &ride, bike.Name)
+	if createReceiptError != nil {
+		return nil, createReceiptError
+	}
+	ride.ReceiptId = receiptId
+
+	// the returned bike is available again, unless its battery is too low or an operator changed its status during the ride
+	if bike.Status == BIKE_STATUS_RESERVED || bike.Status == BIKE_STATUS_IN_USE {
+		endStatus := BIKE_STATUS_AVAILABLE
+		if bike.BatteryPercent.Valid && bike.BatteryPercent.Int64 < int64(BatteryCriticalPercent()) {
+			endStatus = BIKE_STATUS_LOW_BATTERY
+		}
+		_, transitionError := transitionBikeStatus(ctx, tx, bike.BikeId, bike.Status, endStatus, "ride finished", ride.Username)
+		if transitionError != nil {
+			return nil, transitionError
+		}
+	}
+
+	// build the delete statement and delete the reservation
+	deleteStatement := getDeleteRowStatement(DB_TABLE_RESERVATION, DB_TABLE_RESERVATION_COLUMN_RESERVATIONID)
+	_, dbDeleteError := tx.ExecContext(ctx, deleteStatement, reservation.ReservationId)
+	if dbDeleteError != nil {
+		return nil, fmt.Errorf("could not delete record into reservation Table. %w", dbDeleteError)
+	}
+
+	reservationEnded := ReservationEndedEventImpl{ReservationId: reservation.ReservationId, BikeId: ride.BikeId, Username: ride.Username,
+		StartedAt: ride.StartedAt, EndedAt: ride.EndedAt}
+	writeEventError := writeBikeDomainEvent(ctx, tx, DOMAIN_EVENT_RESERVATION_ENDED, ride.BikeId, reservationEnded)
+	if writeEventError != nil {
+		return nil, writeEventError
+	}
+
+	rideFinished := RideFinishedEventImpl{RideId: ride.RideId, BikeId: ride.BikeId, Username: ride.Username, StartedAt: ride.StartedAt,
+		EndedAt: ride.EndedAt, DurationMinutes: ride.DurationMinutes, EndLatitude: ride.EndLatitude, EndLongitude: ride.EndLongitude,
+		TotalCents: ride.TotalCents, Currency: TARIFF_CURRENCY}
+	if ride.EndStationId.Valid {
+		rideFinished.EndStationId = &ride.EndStationId.Int64
+	}
+	writeEventError = writeBikeDomainEvent(ctx, tx, DOMAIN_EVENT_RIDE_FINISHED, ride.BikeId, rideFinished)
+	if writeEventError != nil {
+		return nil, writeEventError
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not finish ride. %w", commitError)
+	}
+
+	// the device of the bike locks it. The command is queued after the commit, so a failure does not stop the ride from being finished
+	if queueReservationCommand(ctx, database, bike.BikeId, BIKE_COMMAND_LOCK, reservation.ReservationId, ride.Username) != nil {
+		commandNotifier.notify(bike.BikeId)
+	}
+
+	publishBikeEvent(ctx, database, BIKE_EVENT_RELEASED, bike.BikeId)
+
+	return &ride, nil
+}
+
+// inserts a finished ride into the ride table
+func insertRide(ctx context.Context, tx dbQueryer, ride *RideImpl) error {
+
+	fareLinesJson, marshalError := json.Marshal(ride.FareLines)
+	if marshalError != nil {
+		return fmt.Errorf("could not marshal fare lines. %w", marshalError)
+	}
+
+	insertStatement := `INSERT INTO ` + DB_TABLE_RIDE + ` (` + rideColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17);`
+	_, dbInsertError := tx.ExecContext(ctx, insertStatement, ride.RideId, ride.BikeId, ride.Username, ride.StartedAt, ride.EndedAt, ride.StartLatitude, ride.StartLongitude,
+		ride.EndLatitude, ride.EndLongitude, ride.DurationMinutes, string(fareLinesJson), ride.SubtotalCents, ride.DiscountCents, ride.TotalCents, ride.PromoCode, ride.OrganizationId, ride.EndStationId)
+	if dbInsertError != nil {
+		return fmt.Errorf("could not insert record into ride Table. %w", dbInsertError)
+	}
+
+	return nil
+}
+
+// scans a row which was selected with rideColumns into a ride object
+func scanRide(rows *sql.Rows) (*RideImpl, error) {
+	ride := RideImpl{}
+	var fareLinesJson []byte
+
+	scanError := rows.Scan(&ride.RideId, &ride.BikeId, &ride.Username, &ride.StartedAt, &ride.EndedAt, &ride.StartLatitude, &ride.StartLongitude,
+		&ride.EndLatitude, &ride.EndLongitude, &ride.DurationMinutes, &fareLinesJson, &ride.SubtotalCents, &ride.DiscountCents, &ride.TotalCents, &ride.PromoCode, &ride.OrganizationId, &ride.EndStationId)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into ride object", DB_TABLE_RIDE)
+	}
+
+	unmarshalError := json.Unmarshal(fareLinesJson, &ride.FareLines)
+	if unmarshalError != nil {
+		return nil, fmt.Errorf("could not unmarshal fare lines of ride %v. %w", ride.RideId, unmarshalError)
+	}
+
+	return &ride, nil
+}
+
+/*
+returns the reservation where the given column has the given value,
+e.g. the reservation for a reservationId or a bikeId
+*/
+func getReservationFromDb(ctx context.Context, database dbQueryer, columnName string, value interface{}) (*ReservationRecordImpl, error) {
+
+	queryString := `SELECT ` + reservationColumns + ` FROM ` + DB_TABLE_RESERVATION + ` WHERE ` + columnName + `=$1;`
+
+	reservation := ReservationRecordImpl{}
+	scanError := database.QueryRowContext(ctx, queryString, value).Scan(&reservation.ReservationId, &reservation.BikeId, &reservation.Username, &reservation.CreatedAt,
+		&reservation.StartLatitude, &reservation.StartLongitude, &reservation.PromoCode, &reservation.BillingOrganizationId)
+	if scanError == sql.ErrNoRows {
+		return nil, NotFoundError(ERROR_CODE_RESERVATION_NOT_FOUND, "no reservation found for %v %v", columnName, value)
+	}
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into reservation object. %w", DB_TABLE_RESERVATION, scanError)
+	}
+
+	return &reservation, nil
+}
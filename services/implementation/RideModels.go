@@ -0,0 +1,72 @@
+package implementation
+
+import (
+	"database/sql"
+	"time"
+)
+
+/*
+represents a single line of a fare, e.g. the unlock fee or a discount.
+charges have a positive amount, discounts a negative amount.
+all amounts are in cents of the TARIFF_CURRENCY
+*/
+type FareLineImpl struct {
+	Description string `json:"description"`
+	AmountCents int    `json:"amountCents"`
+}
+
+/*
+represents the fare of a ride while it is calculated.
+BillableMinutes are the minutes of the ride which are charged by time,
+FreeMinutes are the billable minutes which are already covered by a discount (e.g. a promotion)
+*/
+type FareImpl struct {
+	Lines           []FareLineImpl
+	BillableMinutes int
+	FreeMinutes     int
+	SubtotalCents   int
+	DiscountCents   int
+}
+
+/*
+represents the database structure for the table "ride" in the DATABASE.
+a ride is created when a reservation ends and keeps the computed fare.
+*/
+type RideImpl struct {
+	RideId          string         `json:"rideId"`
+	BikeId          int            `json:"bikeId"`
+	Username        string         `json:"username"`
+	StartedAt       time.Time      `json:"startedAt"`
+	EndedAt         time.Time      `json:"endedAt"`
+	StartLatitude   sql.NullString `json:"startLatitude"`
+	StartLongitude  sql.NullString `json:"startLongitude"`
+	EndLatitude     string         `json:"endLatitude"`
+	EndLongitude    string         `json:"endLongitude"`
+	DurationMinutes int            `json:"durationMinutes"`
+	FareLines       []FareLineImpl `json:"fareLines"`
+	SubtotalCents   int            `json:"subtotalCents"`
+	DiscountCents   int            `json:"discountCents"`
+	TotalCents      int            `json:"totalCents"`
+	PromoCode       sql.NullString `json:"promoCode"`
+	OrganizationId  sql.NullString `json:"organizationId"` // the organization which pays for the ride, invalid for personal billing
+	ReceiptId       string         `json:"receiptId"`      // only set when the ride is finished, the receipt references the ride
+	EndStationId    sql.NullInt64  `json:"endStationId"`   // the station the bike was returned at, invalid for free-floating returns
+	Warnings        []string       `json:"warnings"`       // only set when the ride is finished, not stored
+}
+
+/*
+represents a full record of the reservation table.
+BikeReservationImpl only holds the columns which are sent by the client,
+this struct also holds the columns which are needed to finish a ride.
+*/
+type ReservationRecordImpl struct {
+	ReservationId  string
+	BikeId         int
+	Username       string
+	CreatedAt      time.Time
+	StartLatitude  sql.NullString
+	StartLongitude sql.NullString
+	PromoCode      sql.NullString
+	// the organization which pays for the ride, invalid for personal billing
+	BillingOrganizationId sql.NullString
+}
@@ -0,0 +1,237 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// ---------- STATION TABLE CONSTANTS ---------
+	DB_TABLE_STATION = "station"
+
+	// radius around a station in which returned bikes are assigned to the station, if the station does not define one
+	STATION_DEFAULT_RETURN_RADIUS_METERS = 30
+	// upper limit of the return radius, so that the stations near a position can be found with a small bounding box
+	STATION_MAX_RETURN_RADIUS_METERS = 200
+	// length of one degree of latitude
+	METERS_PER_DEGREE_LATITUDE = 111320
+)
+
+// the columns of the station table in the order they are scanned by getStationsWithOccupancy
+const stationColumns = `station.stationid, station.name, station.latitude, station.longitude, station.capacity, station.returnradiusmeters, station.fullpolicy, station.active`
+
+// selects the stations with the number of docked and available bikes
+const stationOccupancyQuery = `SELECT ` + stationColumns + `, count(bike.bikeid), count(bike.bikeid) FILTER (WHERE bike.reservationid IS NULL AND bike.status='` + BIKE_STATUS_AVAILABLE + `')
+	FROM ` + DB_TABLE_STATION + ` LEFT JOIN ` + DB_TABLE_BIKE + ` ON bike.stationid=station.stationid`
+
+/*
+Implementation method to retrieve all active stations with their occupancy
+*/
+func GetStations(ctx context.Context) (*[]StationOccupancyImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	stations, getStationsError := getStationsWithOccupancy(ctx, db, "station.active")
+	if getStationsError != nil {
+		return nil, getStationsError
+	}
+
+	return &stations, nil
+}
+
+/*
+Implementation method to retrieve a station with its occupancy
+*/
+func GetStation(ctx context.Context, stationId int) (*StationOccupancyImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	stations, getStationsError := getStationsWithOccupancy(ctx, db, "station.stationid=$1", stationId)
+	if getStationsError != nil {
+		return nil, getStationsError
+	}
+	if len(stations) == 0 {
+		return nil, NotFoundError(ERROR_CODE_STATION_NOT_FOUND, "station %v does not exist", stationId)
+	}
+
+	return &stations[0], nil
+}
+
+/*
+Implementation method to create a docking station. The stationId is generated by the database
+*/
+func CreateStation(ctx context.Context, station StationImpl) (*StationImpl, error) {
+
+	station.Name = strings.TrimSpace(station.Name)
+	if station.ReturnRadiusMeters == 0 {
+		station.ReturnRadiusMeters = STATION_DEFAULT_RETURN_RADIUS_METERS
+	}
+	if station.FullPolicy == "" {
+		station.FullPolicy = STATION_FULL_REFUSE
+	}
+
+	validationError := validateStation(station)
+	if validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	insertStatement := getInsertStmt(DB_TABLE_STATION, "name", "latitude", "longitude", "capacity", "returnradiusmeters", "fullpolicy", "active")
+	dbInsertError := db.QueryRowContext(ctx, insertStatement+` RETURNING stationid`, station.Name, station.Latitude, station.Longitude, station.Capacity,
+		station.ReturnRadiusMeters, station.FullPolicy, station.Active).Scan(&station.StationId)
+	if dbInsertError != nil {
+		return nil, fmt.Errorf("could not insert record into station Table. %w", dbInsertError)
+	}
+
+	return &station, nil
+}
+
+/*
+assigns a returned bike to the nearest active station whose return radius contains the position.
+the station is locked until the transaction ends, so that concurrent returns can not exceed the capacity.
+if the station is full, the return is refused or the bike is returned free-floating with a warning, depending on the policy of the station
+*/
+func assignBikeToStation(ctx context.Context, tx dbQueryer, bikeId int, latitude float64, longitude float64) (*StationAssignmentImpl, error) {
+
+	// only stations within the maximal return radius can contain the position
+	latitudeDelta := float64(STATION_MAX_RETURN_RADIUS_METERS) / METERS_PER_DEGREE_LATITUDE
+	longitudeDelta := latitudeDelta / math.Max(math.Cos(latitude*math.Pi/180), 0.01)
+
+	rows, dbQueryError := tx.QueryContext(ctx, `SELECT `+stationColumns+` FROM `+DB_TABLE_STATION+` WHERE station.active
+		AND station.latitude BETWEEN $1 AND $2 AND station.longitude BETWEEN $3 AND $4;`,
+		latitude-latitudeDelta, latitude+latitudeDelta, longitude-longitudeDelta, longitude+longitudeDelta)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve stations near the bike. %w", dbQueryError)
+	}
+
+	var nearestStation *StationImpl
+	nearestDistance := math.Inf(1)
+	for rows.Next() {
+		station := StationImpl{}
+		scanError := rows.Scan(&station.StationId, &station.Name, &station.Latitude, &station.Longitude, &station.Capacity,
+			&station.ReturnRadiusMeters, &station.FullPolicy, &station.Active)
+		if scanError != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into station object", DB_TABLE_STATION)
+		}
+
+		distance := distanceMeters(latitude, longitude, station.Latitude, station.Longitude)
+		if distance <= float64(station.ReturnRadiusMeters) && distance < nearestDistance {
+			nearestStation = &station
+			nearestDistance = distance
+		}
+	}
+	rows.Close()
+
+	assignment := StationAssignmentImpl{}
+	if nearestStation != nil {
+		// lock the station and count the other bikes which are docked there
+		_, dbLockError := tx.ExecContext(ctx, `SELECT stationid FROM `+DB_TABLE_STATION+` WHERE stationid=$1 FOR UPDATE;`, nearestStation.StationId)
+		if dbLockError != nil {
+			return nil, fmt.Errorf("could not lock station %v. %w", nearestStation.StationId, dbLockError)
+		}
+
+		var dockedBikes int
+		dbCountError := tx.QueryRowContext(ctx, `SELECT count(*) FROM `+DB_TABLE_BIKE+` WHERE `+DB_TABLE_BIKE_COLUMN_STATIONID+`=$1 AND `+DB_TABLE_BIKE_COLUMN_BIKEID+`<>$2;`,
+			nearestStation.StationId, bikeId).Scan(&dockedBikes)
+		if dbCountError != nil {
+			return nil, fmt.Errorf("could not count bikes at station %v. %w", nearestStation.StationId, dbCountError)
+		}
+
+		if dockedBikes < nearestStation.Capacity {
+			assignment.StationId = sql.NullInt64{Int64: int64(nearestStation.StationId), Valid: true}
+		} else if nearestStation.FullPolicy == STATION_FULL_WARN {
+			assignment.Warning = fmt.Sprintf("station %v is full, the bike was returned next to the station", nearestStation.Name)
+		} else {
+			return nil, ConflictError(ERROR_CODE_STATION_FULL, "station %v is full. Please return the bike at another station", nearestStation.Name)
+		}
+	}
+
+	updateStatement := getUpdateStmtOneColumn(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_STATIONID, DB_TABLE_BIKE_COLUMN_BIKEID)
+	_, dbUpdateError := tx.ExecContext(ctx, updateStatement, assignment.StationId, bikeId)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not assign bike %v to station. %w", bikeId, dbUpdateError)
+	}
+
+	return &assignment, nil
+}
+
+/*
+releases a bike from its station, if the bike reports a position outside of the return radius of the station.
+this keeps the occupancy of the station correct when a bike is moved by the service team
+*/
+func releaseBikeFromStationIfMoved(ctx context.Context, database dbQueryer, bikeId int, latitude float64, longitude float64) error {
+
+	var stationLatitude, stationLongitude float64
+	var returnRadiusMeters int
+	dbQueryError := database.QueryRowContext(ctx, `SELECT station.latitude, station.longitude, station.returnradiusmeters FROM `+DB_TABLE_STATION+
+		` JOIN `+DB_TABLE_BIKE+` ON bike.stationid=station.stationid WHERE bike.bikeid=$1;`, bikeId).Scan(&stationLatitude, &stationLongitude, &returnRadiusMeters)
+	if dbQueryError == sql.ErrNoRows {
+		// the bike is not docked
+		return nil
+	}
+	if dbQueryError != nil {
+		return fmt.Errorf("could not retrieve station of bike %v. %w", bikeId, dbQueryError)
+	}
+
+	if distanceMeters(latitude, longitude, stationLatitude, stationLongitude) <= float64(returnRadiusMeters) {
+		return nil
+	}
+
+	updateStatement := getUpdateStmtOneColumn(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_STATIONID, DB_TABLE_BIKE_COLUMN_BIKEID)
+	_, dbUpdateError := database.ExecContext(ctx, updateStatement, nil, bikeId)
+	if dbUpdateError != nil {
+		return fmt.Errorf("could not release bike %v from station. %w", bikeId, dbUpdateError)
+	}
+
+	return nil
+}
+
+// checks that a station which is created has a consistent configuration
+func validateStation(station StationImpl) error {
+	validator := fieldValidator{}
+	validator.requiredText("name", station.Name, MAX_NAME_LENGTH)
+	validator.coordinates("latitude", station.Latitude, "longitude", station.Longitude)
+	validator.atLeast("capacity", station.Capacity, 1)
+	validator.between("returnRadiusMeters", station.ReturnRadiusMeters, 1, STATION_MAX_RETURN_RADIUS_METERS)
+	validator.oneOf("fullPolicy", station.FullPolicy, STATION_FULL_REFUSE, STATION_FULL_WARN)
+	return validator.err()
+}
+
+// returns the stations which match the condition with their occupancy, ordered by stationId
+func getStationsWithOccupancy(ctx context.Context, database dbQueryer, condition string, arguments ...interface{}) ([]StationOccupancyImpl, error) {
+
+	rows, dbQueryError := database.QueryContext(ctx, stationOccupancyQuery+` WHERE `+condition+` GROUP BY station.stationid ORDER BY station.stationid;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_STATION, dbQueryError)
+	}
+	defer rows.Close()
+
+	stations := []StationOccupancyImpl{}
+	for rows.Next() {
+		station := StationOccupancyImpl{}
+		scanError := rows.Scan(&station.StationId, &station.Name, &station.Latitude, &station.Longitude, &station.Capacity,
+			&station.ReturnRadiusMeters, &station.FullPolicy, &station.Active, &station.DockedBikes, &station.AvailableBikes)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into station object", DB_TABLE_STATION)
+		}
+		station.FreeDocks = maxInt(station.Capacity-station.DockedBikes, 0)
+		stations = append(stations, station)
+	}
+
+	return stations, nil
+}
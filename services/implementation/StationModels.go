@@ -0,0 +1,44 @@
+package implementation
+
+import "database/sql"
+
+const (
+	// ---------- what happens when a bike is returned to a full station ---------
+	STATION_FULL_REFUSE = "refuse" // the return is refused, the rider has to park the bike somewhere else
+	STATION_FULL_WARN   = "warn"   // the bike is returned free-floating next to the station and the rider gets a warning
+)
+
+/*
+represents the database structure for the table "station" in the DATABASE.
+a bike which is returned within ReturnRadiusMeters of the station is assigned to the station
+*/
+type StationImpl struct {
+	StationId          int     `json:"stationId"`
+	Name               string  `json:"name"`
+	Latitude           float64 `json:"latitude"`
+	Longitude          float64 `json:"longitude"`
+	Capacity           int     `json:"capacity"`
+	ReturnRadiusMeters int     `json:"returnRadiusMeters"`
+	FullPolicy         string  `json:"fullPolicy"`
+	Active             bool    `json:"active"`
+}
+
+/*
+represents a station with its live occupancy.
+DockedBikes counts all bikes at the station, AvailableBikes only those which are not reserved
+*/
+type StationOccupancyImpl struct {
+	StationImpl
+	DockedBikes    int `json:"dockedBikes"`
+	AvailableBikes int `json:"availableBikes"`
+	FreeDocks      int `json:"freeDocks"`
+}
+
+/*
+represents the result of the assignment of a returned bike to a station.
+the station is invalid if the bike was not returned at a station or if the station was full
+*/
+type StationAssignmentImpl struct {
+	StationId sql.NullInt64
+	Warning   string
+}
@@ -0,0 +1,103 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// the bike table in memory with one station, as far as reserving a bike and returning it at a station uses them
+type fakeBikeTable struct {
+	mutex    sync.Mutex
+	station  StationImpl
+	stations map[int]sql.NullInt64 // the station of every bike
+	statuses map[int]string
+}
+
+func (table *fakeBikeTable) handle(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	query := statement.Query
+	switch {
+	case query == fakedb.COMMIT, query == fakedb.ROLLBACK, strings.Contains(query, "pg_advisory_xact_lock"),
+		strings.HasPrefix(query, `insert into "`+DB_TABLE_RESERVATION+`"`), strings.HasPrefix(query, `insert into "`+DB_TABLE_OUTBOXEVENT+`"`),
+		strings.HasSuffix(query, "FOR UPDATE;"):
+		return &fakedb.Result{}, nil
+	case query == getUpdateStmtOneColumn(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_RESERVATIONID, DB_TABLE_BIKE_COLUMN_BIKEID):
+		return &fakedb.Result{RowsAffected: 1}, nil
+	case query == getUpdateStmtOneColumn(DB_TABLE_BIKE, DB_TABLE_BIKE_COLUMN_STATIONID, DB_TABLE_BIKE_COLUMN_BIKEID):
+		stationId := sql.NullInt64{}
+		if statement.Args[0] != nil {
+			stationId = sql.NullInt64{Int64: statement.Args[0].(int64), Valid: true}
+		}
+		table.stations[int(statement.Args[1].(int64))] = stationId
+		return &fakedb.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, "UPDATE "+DB_TABLE_BIKE+" SET "+DB_TABLE_BIKE_COLUMN_STATUS+"=$1"):
+		bikeId := int(statement.Args[1].(int64))
+		if table.statuses[bikeId] != statement.Args[2] {
+			return &fakedb.Result{}, nil
+		}
+		table.statuses[bikeId] = statement.Args[0].(string)
+		return &fakedb.Result{RowsAffected: 1}, nil
+	case strings.HasPrefix(query, `insert into "`+DB_TABLE_BIKESTATUSCHANGE+`"`):
+		return &fakedb.Result{Columns: []string{"changeid"}, Rows: [][]driver.Value{{int64(1)}}}, nil
+	case strings.HasPrefix(query, "SELECT "+stationColumns):
+		station := table.station
+		return &fakedb.Result{Columns: strings.Split(stationColumns, ", "), Rows: [][]driver.Value{{int64(station.StationId), station.Name,
+			station.Latitude, station.Longitude, int64(station.Capacity), int64(station.ReturnRadiusMeters), station.FullPolicy, station.Active}}}, nil
+	case strings.HasPrefix(query, "SELECT count(*) FROM "+DB_TABLE_BIKE):
+		docked := 0
+		for bikeId, stationId := range table.stations {
+			if stationId.Valid && stationId.Int64 == statement.Args[0].(int64) && int64(bikeId) != statement.Args[1].(int64) {
+				docked++
+			}
+		}
+		return &fakedb.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(docked)}}}, nil
+	}
+	return nil, errors.New("unexpected statement: " + query)
+}
+
+func TestReservedBikeFreesItsDock(t *testing.T) {
+	// the station is full with bike 7 and bike 8
+	station := StationImpl{StationId: 1, Name: "Main Station", Latitude: 52.52, Longitude: 13.40, Capacity: 2, ReturnRadiusMeters: 30,
+		FullPolicy: STATION_FULL_REFUSE, Active: true}
+	docked := sql.NullInt64{Int64: 1, Valid: true}
+	table := &fakeBikeTable{
+		station:  station,
+		stations: map[int]sql.NullInt64{7: docked, 8: docked, 9: {}},
+		statuses: map[int]string{7: BIKE_STATUS_AVAILABLE, 8: BIKE_STATUS_AVAILABLE, 9: BIKE_STATUS_IN_USE},
+	}
+	useFakeDB(t, table.handle)
+	db, _ := SetupDB()
+	ctx := context.Background()
+
+	bike := &BikeImpl{BikeId: 7, Latitude: "52.52", Longitude: "13.40", Status: BIKE_STATUS_AVAILABLE}
+	_, reserveError := createRecordInReservationTable(ctx, db, bike, "userOne", sql.NullString{})
+	if reserveError != nil {
+		t.Fatalf("expected bike 7 to be reserved, got %v", reserveError)
+	}
+	if table.stations[7].Valid {
+		t.Fatalf("expected the reserved bike to leave its dock")
+	}
+
+	// the dock of the reserved bike is free for another bike
+	assignment, assignError := assignBikeToStation(ctx, db, 9, station.Latitude, station.Longitude)
+	if assignError != nil {
+		t.Fatalf("expected bike 9 to be docked at the station, got %v", assignError)
+	}
+	if !assignment.StationId.Valid || assignment.StationId.Int64 != 1 {
+		t.Fatalf("expected bike 9 at station 1, got %+v", assignment.StationId)
+	}
+
+	// now the station is full again, so the reserved bike can not be returned there
+	_, assignError = assignBikeToStation(ctx, db, 7, station.Latitude, station.Longitude)
+	if !errors.Is(assignError, ErrConflict) {
+		t.Fatalf("expected the full station to refuse bike 7, got %v", assignError)
+	}
+}
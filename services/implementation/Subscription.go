@@ -0,0 +1,402 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ---------- PLAN AND SUBSCRIPTION TABLE CONSTANTS ---------
+	DB_TABLE_PLAN              = "plan"
+	DB_TABLE_SUBSCRIPTION      = "subscription"
+	DB_TABLE_SUBSCRIPTIONUSAGE = "subscriptionusage"
+
+	// number of bikes a user can rent at the same time without a subscription
+	DEFAULT_MAX_CONCURRENT_BIKES = 1
+)
+
+// the columns of the plan table in the order they are scanned by scanPlan
+const planColumns = `planid, name, pricecents, includedminutesperday, includedminutespermonth, maxconcurrentbikes, active`
+
+// the columns of the subscription table in the order they are scanned by getActiveSubscription
+const subscriptionColumns = `subscriptionid, username, planid, startedat, renewsat, cancelledat`
+
+/*
+Implementation method to retrieve all plans from the Database
+*/
+func GetAllPlans(ctx context.Context) (*[]PlanImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+planColumns+` FROM `+DB_TABLE_PLAN+` ORDER BY pricecents;`)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving all records from table %v", DB_TABLE_PLAN)
+	}
+	defer rows.Close()
+
+	arrayOfPlans := []PlanImpl{}
+	for rows.Next() {
+		plan, scanError := scanPlan(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		arrayOfPlans = append(arrayOfPlans, *plan)
+	}
+
+	return &arrayOfPlans, nil
+}
+
+/*
+Implementation method to create a new subscription plan
+*/
+func CreatePlan(ctx context.Context, plan PlanImpl) (*PlanImpl, error) {
+
+	plan.PlanId = strings.TrimSpace(plan.PlanId)
+	if plan.MaxConcurrentBikes == 0 {
+		plan.MaxConcurrentBikes = DEFAULT_MAX_CONCURRENT_BIKES
+	}
+
+	validator := fieldValidator{}
+	validator.requiredText("planId", plan.PlanId, MAX_ID_LENGTH)
+	validator.requiredText("name", plan.Name, MAX_NAME_LENGTH)
+	validator.atLeast("priceCents", plan.PriceCents, 0)
+	validator.atLeast("includedMinutesPerDay", plan.IncludedMinutesPerDay, 0)
+	validator.atLeast("includedMinutesPerMonth", plan.IncludedMinutesPerMonth, 0)
+	validator.atLeast("maxConcurrentBikes", plan.MaxConcurrentBikes, 1)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	insertStatement := `INSERT INTO ` + DB_TABLE_PLAN + ` (` + planColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+	_, dbInsertError := db.ExecContext(ctx, insertStatement, plan.PlanId, plan.Name, plan.PriceCents, plan.IncludedMinutesPerDay, plan.IncludedMinutesPerMonth, plan.MaxConcurrentBikes, plan.Active)
+	if dbInsertError != nil {
+		if isUniqueViolation(dbInsertError) {
+			return nil, ConflictError(ERROR_CODE_PLAN_EXISTS, "plan %v already exists", plan.PlanId)
+		}
+		return nil, fmt.Errorf("could not insert record into plan Table. %w", dbInsertError)
+	}
+
+	return &plan, nil
+}
+
+/*
+Implementation method to get the active subscription of a user with its plan and the usage of the included minutes
+*/
+func GetSubscriptionStatus(ctx context.Context, username string) (*SubscriptionStatusImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	return getSubscriptionStatus(ctx, db, username, time.Now())
+}
+
+/*
+Implementation method to subscribe a user to a plan.
+A user can only have one active subscription at a time.
+*/
+func Subscribe(ctx context.Context, subscribeRequest SubscribeImpl) (*SubscriptionStatusImpl, error) {
+
+	validator := fieldValidator{}
+	validator.requiredText("username", subscribeRequest.Username, MAX_USERNAME_LENGTH)
+	validator.requiredText("planId", subscribeRequest.PlanId, MAX_ID_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	// the check for an active subscription and the new subscription are stored in one transaction
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	// verify if user exists in the database. The user stays locked until the subscription is stored,
+	// so concurrent subscriptions of the user wait for each other and only the first one is stored
+	lockUserError := lockUserForUpdate(ctx, tx, subscribeRequest.Username)
+	if lockUserError != nil {
+		return nil, lockUserError
+	}
+
+	plan, getPlanError := getPlanFromDb(ctx, tx, subscribeRequest.PlanId)
+	if getPlanError != nil {
+		return nil, getPlanError
+	}
+	if !plan.Active {
+		return nil, ConflictError(ERROR_CODE_PLAN_NOT_AVAILABLE, "plan %v can not be subscribed anymore", plan.PlanId)
+	}
+
+	now := time.Now()
+	activeSubscription, _, getActiveSubscriptionError := getActiveSubscription(ctx, tx, subscribeRequest.Username, now)
+	if getActiveSubscriptionError != nil {
+		return nil, getActiveSubscriptionError
+	}
+	if activeSubscription != nil {
+		return nil, ConflictError(ERROR_CODE_SUBSCRIPTION_EXISTS, "user %v already has an active subscription for plan %v", subscribeRequest.Username, activeSubscription.PlanId)
+	}
+
+	insertStatement := getInsertStmt(DB_TABLE_SUBSCRIPTION, "subscriptionid", "username", "planid", "startedat", "renewsat")
+	_, dbInsertError := tx.ExecContext(ctx, insertStatement, uuid.New().String(), subscribeRequest.Username, plan.PlanId, now, now.AddDate(0, 1, 0))
+	if dbInsertError != nil {
+		return nil, fmt.Errorf("could not insert record into subscription Table. %w", dbInsertError)
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not subscribe user %v. %w", subscribeRequest.Username, commitError)
+	}
+
+	return getSubscriptionStatus(ctx, db, subscribeRequest.Username, now)
+}
+
+/*
+Implementation method to cancel the active subscription of a user.
+The subscription is not renewed anymore, but stays active until the end of the current period.
+*/
+func CancelSubscription(ctx context.Context, username string) (*SubscriptionStatusImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	now := time.Now()
+	activeSubscription, _, getActiveSubscriptionError := getActiveSubscription(ctx, db, username, now)
+	if getActiveSubscriptionError != nil {
+		return nil, getActiveSubscriptionError
+	}
+	if activeSubscription == nil {
+		return nil, NotFoundError(ERROR_CODE_SUBSCRIPTION_NOT_FOUND, "user %v has no active subscription", username)
+	}
+	if activeSubscription.CancelledAt != nil {
+		return nil, ConflictError(ERROR_CODE_SUBSCRIPTION_CANCELLED, "subscription of user %v is already cancelled", username)
+	}
+
+	updateStmt := getUpdateStmtOneColumn(DB_TABLE_SUBSCRIPTION, "cancelledat", "subscriptionid")
+	_, dbUpdateError := db.ExecContext(ctx, updateStmt, now, activeSubscription.SubscriptionId)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not cancel subscription. %w", dbUpdateError)
+	}
+
+	return getSubscriptionStatus(ctx, db, username, now)
+}
+
+// returns the active subscription of a user with its plan and usage, or an error if there is none
+func getSubscriptionStatus(ctx context.Context, database dbQueryer, username string, now time.Time) (*SubscriptionStatusImpl, error) {
+
+	subscription, plan, getActiveSubscriptionError := getActiveSubscription(ctx, database, username, now)
+	if getActiveSubscriptionError != nil {
+		return nil, getActiveSubscriptionError
+	}
+	if subscription == nil {
+		return nil, NotFoundError(ERROR_CODE_SUBSCRIPTION_NOT_FOUND, "user %v has no active subscription", username)
+	}
+
+	usage, getUsageError := getSubscriptionUsage(ctx, database, subscription, plan, now)
+	if getUsageError != nil {
+		return nil, getUsageError
+	}
+
+	return &SubscriptionStatusImpl{Subscription: *subscription, Plan: *plan, Usage: *usage}, nil
+}
+
+/*
+returns the subscription of a user which is active at the given time together with its plan.
+returns nil without an error if the user has no active subscription.
+Subscriptions are renewed lazily: if the renewal date has passed and the subscription is not cancelled,
+the renewal date is moved to the next month.
+*/
+func getActiveSubscription(ctx context.Context, database dbQueryer, username string, now time.Time) (*SubscriptionImpl, *PlanImpl, error) {
+
+	queryString := `SELECT ` + subscriptionColumns + ` FROM ` + DB_TABLE_SUBSCRIPTION +
+		` WHERE username=$1 AND startedat<=$2 AND (cancelledat IS NULL OR renewsat>$2) ORDER BY startedat DESC LIMIT 1;`
+
+	subscription := SubscriptionImpl{}
+	var cancelledAt sql.NullTime
+	scanError := database.QueryRowContext(ctx, queryString, username, now).Scan(&subscription.SubscriptionId, &subscription.Username, &subscription.PlanId,
+		&subscription.StartedAt, &subscription.RenewsAt, &cancelledAt)
+	if scanError == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if scanError != nil {
+		return nil, nil, fmt.Errorf("could not retrieve subscription of user %v. %w", username, scanError)
+	}
+	if cancelledAt.Valid {
+		subscription.CancelledAt = &cancelledAt.Time
+	}
+
+	// renew the subscription if the renewal date has passed.
+	// the renewal date is always calculated from the start, so that it does not drift at the end of a month
+	if !subscription.RenewsAt.After(now) {
+		renewsAt := subscription.RenewsAt
+		for months := 1; !renewsAt.After(now); months++ {
+			renewsAt = subscription.StartedAt.AddDate(0, months, 0)
+		}
+
+		updateStmt := getUpdateStmtOneColumn(DB_TABLE_SUBSCRIPTION, "renewsat", "subscriptionid")
+		_, dbUpdateError := database.ExecContext(ctx, updateStmt, renewsAt, subscription.SubscriptionId)
+		if dbUpdateError != nil {
+			return nil, nil, fmt.Errorf("could not renew subscription. %w", dbUpdateError)
+		}
+		subscription.RenewsAt = renewsAt
+	}
+
+	plan, getPlanError := getPlanFromDb(ctx, database, subscription.PlanId)
+	if getPlanError != nil {
+		return nil, nil, getPlanError
+	}
+
+	return &subscription, plan, nil
+}
+
+/*
+returns how many included minutes of a subscription were used today and in the current period,
+and how many are remaining
+*/
+func getSubscriptionUsage(ctx context.Context, database dbQueryer, subscription *SubscriptionImpl, plan *PlanImpl, now time.Time) (*SubscriptionUsageImpl, error) {
+
+	usage := SubscriptionUsageImpl{PeriodStart: subscription.RenewsAt.AddDate(0, -1, 0)}
+	if usage.PeriodStart.Before(subscription.StartedAt) {
+		usage.PeriodStart = subscription.StartedAt
+	}
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	queryString := `SELECT COALESCE(SUM(minutes) FILTER (WHERE usedat>=$2), 0), COALESCE(SUM(minutes) FILTER (WHERE usedat>=$3), 0) FROM ` +
+		DB_TABLE_SUBSCRIPTIONUSAGE + ` WHERE subscriptionid=$1;`
+	scanError := database.QueryRowContext(ctx, queryString, subscription.SubscriptionId, startOfDay, usage.PeriodStart).Scan(&usage.MinutesUsedToday, &usage.MinutesUsedThisPeriod)
+	if scanError != nil {
+		return nil, fmt.Errorf("could not retrieve usage of subscription %v. %w", subscription.SubscriptionId, scanError)
+	}
+
+	if plan.IncludedMinutesPerDay > 0 {
+		remainingToday := maxInt(plan.IncludedMinutesPerDay-usage.MinutesUsedToday, 0)
+		usage.RemainingMinutesToday = &remainingToday
+	}
+	if plan.IncludedMinutesPerMonth > 0 {
+		remainingThisPeriod := maxInt(plan.IncludedMinutesPerMonth-usage.MinutesUsedThisPeriod, 0)
+		usage.RemainingMinutesThisPeriod = &remainingThisPeriod
+	}
+
+	return &usage, nil
+}
+
+/*
+returns how many included minutes of a subscription can still be used.
+The smaller allowance of day and period counts.
+*/
+func (usage *SubscriptionUsageImpl) remainingMinutes() int {
+	if usage.RemainingMinutesToday == nil && usage.RemainingMinutesThisPeriod == nil {
+		return 0
+	}
+	if usage.RemainingMinutesToday == nil {
+		return *usage.RemainingMinutesThisPeriod
+	}
+	if usage.RemainingMinutesThisPeriod == nil {
+		return *usage.RemainingMinutesToday
+	}
+	return minInt(*usage.RemainingMinutesToday, *usage.RemainingMinutesThisPeriod)
+}
+
+/*
+applies the included minutes of the active subscription of the rider to the fare and records the used minutes.
+The minutes are accounted at the end of the ride.
+returns the discount in cents which was applied
+*/
+func applySubscription(ctx context.Context, tx dbQueryer, fare *FareImpl, ride *RideImpl) (int, error) {
+
+	subscription, plan, getActiveSubscriptionError := getActiveSubscription(ctx, tx, ride.Username, ride.EndedAt)
+	if getActiveSubscriptionError != nil {
+		return 0, getActiveSubscriptionError
+	}
+	if subscription == nil {
+		return 0, nil
+	}
+
+	// lock the subscription, so that concurrent rides of the same subscription can not use the same minutes
+	_, lockError := tx.ExecContext(ctx, `SELECT 1 FROM `+DB_TABLE_SUBSCRIPTION+` WHERE subscriptionid=$1 FOR UPDATE;`, subscription.SubscriptionId)
+	if lockError != nil {
+		return 0, fmt.Errorf("could not lock subscription %v. %w", subscription.SubscriptionId, lockError)
+	}
+
+	usage, getUsageError := getSubscriptionUsage(ctx, tx, subscription, plan, ride.EndedAt)
+	if getUsageError != nil {
+		return 0, getUsageError
+	}
+
+	includedMinutes := minInt(usage.remainingMinutes(), fare.chargeableMinutes())
+	if includedMinutes <= 0 {
+		return 0, nil
+	}
+
+	fare.FreeMinutes += includedMinutes
+	discountCents := fare.addDiscount(fmt.Sprintf("Plan %v: %d included min", plan.Name, includedMinutes), includedMinutes*TARIFF_PRICE_PER_MINUTE_CENTS)
+
+	insertStatement := getInsertStmt(DB_TABLE_SUBSCRIPTIONUSAGE, "rideid", "subscriptionid", "minutes", "usedat")
+	_, dbInsertError := tx.ExecContext(ctx, insertStatement, ride.RideId, subscription.SubscriptionId, includedMinutes, ride.EndedAt)
+	if dbInsertError != nil {
+		return 0, fmt.Errorf("could not insert record into subscription usage Table. %w", dbInsertError)
+	}
+
+	return discountCents, nil
+}
+
+/*
+returns how many bikes a user can rent at the same time.
+this is defined by the plan of the active subscription.
+*/
+func maxConcurrentBikesForUser(ctx context.Context, database dbQueryer, username string) (int, error) {
+	_, plan, getActiveSubscriptionError := getActiveSubscription(ctx, database, username, time.Now())
+	if getActiveSubscriptionError != nil {
+		return 0, getActiveSubscriptionError
+	}
+	if plan == nil {
+		return DEFAULT_MAX_CONCURRENT_BIKES, nil
+	}
+	return plan.MaxConcurrentBikes, nil
+}
+
+// returns the plan with the given planId
+func getPlanFromDb(ctx context.Context, database dbQueryer, planId string) (*PlanImpl, error) {
+	rows, dbQueryError := database.QueryContext(ctx, `SELECT `+planColumns+` FROM `+DB_TABLE_PLAN+` WHERE planid=$1;`, planId)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve plan %v. %w", planId, dbQueryError)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, NotFoundError(ERROR_CODE_PLAN_NOT_FOUND, "plan %v does not exist", planId)
+	}
+
+	return scanPlan(rows)
+}
+
+// scans a row which was selected with planColumns into a plan object
+func scanPlan(rows *sql.Rows) (*PlanImpl, error) {
+	plan := PlanImpl{}
+	scanError := rows.Scan(&plan.PlanId, &plan.Name, &plan.PriceCents, &plan.IncludedMinutesPerDay, &plan.IncludedMinutesPerMonth, &plan.MaxConcurrentBikes, &plan.Active)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into plan object", DB_TABLE_PLAN)
+	}
+	return &plan, nil
+}
@@ -0,0 +1,61 @@
+package implementation
+
+import "time"
+
+/*
+represents the database structure for the table "plan" in the DATABASE.
+A period with 0 included minutes does not limit the allowance,
+a plan with 0 minutes for both periods includes no free minutes.
+*/
+type PlanImpl struct {
+	PlanId                  string `json:"planId"`
+	Name                    string `json:"name"`
+	PriceCents              int    `json:"priceCents"`
+	IncludedMinutesPerDay   int    `json:"includedMinutesPerDay"`
+	IncludedMinutesPerMonth int    `json:"includedMinutesPerMonth"`
+	MaxConcurrentBikes      int    `json:"maxConcurrentBikes"`
+	Active                  bool   `json:"active"`
+}
+
+/*
+represents the database structure for the table "subscription" in the DATABASE.
+A subscription renews every month at RenewsAt.
+A cancelled subscription stays active until RenewsAt.
+*/
+type SubscriptionImpl struct {
+	SubscriptionId string     `json:"subscriptionId"`
+	Username       string     `json:"username"`
+	PlanId         string     `json:"planId"`
+	StartedAt      time.Time  `json:"startedAt"`
+	RenewsAt       time.Time  `json:"renewsAt"`
+	CancelledAt    *time.Time `json:"cancelledAt"`
+}
+
+/*
+represents the usage of the included minutes of a subscription.
+the remaining minutes are nil if the plan does not limit the allowance for the period
+*/
+type SubscriptionUsageImpl struct {
+	PeriodStart                time.Time `json:"periodStart"`
+	MinutesUsedToday           int       `json:"minutesUsedToday"`
+	MinutesUsedThisPeriod      int       `json:"minutesUsedThisPeriod"`
+	RemainingMinutesToday      *int      `json:"remainingMinutesToday"`
+	RemainingMinutesThisPeriod *int      `json:"remainingMinutesThisPeriod"`
+}
+
+/*
+represents the active subscription of a user together with its plan and the usage of the included minutes
+*/
+type SubscriptionStatusImpl struct {
+	Subscription SubscriptionImpl      `json:"subscription"`
+	Plan         PlanImpl              `json:"plan"`
+	Usage        SubscriptionUsageImpl `json:"usage"`
+}
+
+/*
+represents a request of a user to subscribe to a plan
+*/
+type SubscribeImpl struct {
+	Username string `json:"username"`
+	PlanId   string `json:"planId"`
+}
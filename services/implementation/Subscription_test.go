@@ -0,0 +1,45 @@
+package implementation
+
+import (
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"strings"
+	"testing"
+)
+
+func TestSubscribeLocksUserBeforeCheckingActiveSubscription(t *testing.T) {
+	var statements []fakedb.Statement
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		statements = append(statements, statement)
+		switch {
+		case strings.HasSuffix(statement.Query, "FOR UPDATE;"):
+			return &fakedb.Result{Columns: []string{"username"}, Rows: [][]driver.Value{{"userOne"}}}, nil
+		case strings.HasPrefix(statement.Query, "SELECT "+planColumns):
+			return &fakedb.Result{Columns: strings.Split(planColumns, ", "),
+				Rows: [][]driver.Value{{"basic", "Basic", int64(999), int64(30), int64(0), int64(1), true}}}, nil
+		}
+		return &fakedb.Result{}, nil
+	})
+
+	// the status of the new subscription is not answered by the fake database
+	Subscribe(context.Background(), SubscribeImpl{Username: "userOne", PlanId: "basic"})
+
+	lockIndex, checkIndex, insertIndex, commitIndex := -1, -1, -1, -1
+	for index, statement := range statements {
+		switch {
+		case strings.HasSuffix(statement.Query, "FOR UPDATE;") && statement.InTransaction:
+			lockIndex = index
+		case strings.HasPrefix(statement.Query, "SELECT "+subscriptionColumns) && statement.InTransaction && checkIndex < 0:
+			checkIndex = index
+		case strings.HasPrefix(statement.Query, `insert into "`+DB_TABLE_SUBSCRIPTION+`"`) && statement.InTransaction:
+			insertIndex = index
+		case statement.Query == fakedb.COMMIT:
+			commitIndex = index
+		}
+	}
+	if lockIndex < 0 || checkIndex < lockIndex || insertIndex < checkIndex || commitIndex < insertIndex {
+		t.Fatalf("expected the user to be locked before the active subscription is checked and the subscription is stored in the same transaction, "+
+			"got the lock at %v, the check at %v, the insert at %v and the commit at %v", lockIndex, checkIndex, insertIndex, commitIndex)
+	}
+}
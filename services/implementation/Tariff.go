@@ -0,0 +1,96 @@
+package implementation
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// ---------- Tariff constants. All prices are in cents and include VAT ---------
+	TARIFF_CURRENCY               = "EUR"
+	TARIFF_UNLOCK_FEE_CENTS       = 100
+	TARIFF_PRICE_PER_MINUTE_CENTS = 25
+)
+
+/*
+returns the minutes of a ride which are charged.
+every started minute is charged, but at least one minute.
+*/
+func billableMinutes(startedAt time.Time, endedAt time.Time) int {
+	minutes := int(math.Ceil(endedAt.Sub(startedAt).Minutes()))
+	if minutes < 1 {
+		return 1
+	}
+	return minutes
+}
+
+/*
+creates the base fare of a ride without any discounts.
+The base fare consists of the unlock fee and the price for every billable minute.
+*/
+func newBaseFare(minutes int) *FareImpl {
+	fare := &FareImpl{BillableMinutes: minutes}
+	fare.addCharge("Unlock fee", TARIFF_UNLOCK_FEE_CENTS)
+	fare.addCharge(fmt.Sprintf("Ride time %d min x %v", minutes, FormatCents(TARIFF_PRICE_PER_MINUTE_CENTS)), minutes*TARIFF_PRICE_PER_MINUTE_CENTS)
+	return fare
+}
+
+// adds a charge to the fare
+func (fare *FareImpl) addCharge(description string, amountCents int) {
+	fare.Lines = append(fare.Lines, FareLineImpl{Description: description, AmountCents: amountCents})
+	fare.SubtotalCents += amountCents
+}
+
+/*
+adds a discount to the fare. A discount can never make the total negative,
+so it is capped to the remaining total.
+returns the discount which was actually applied
+*/
+func (fare *FareImpl) addDiscount(description string, amountCents int) int {
+	if amountCents > fare.TotalCents() {
+		amountCents = fare.TotalCents()
+	}
+	if amountCents <= 0 {
+		return 0
+	}
+	fare.Lines = append(fare.Lines, FareLineImpl{Description: description, AmountCents: -amountCents})
+	fare.DiscountCents += amountCents
+	return amountCents
+}
+
+// returns the billable minutes which are not yet covered by a discount
+func (fare *FareImpl) chargeableMinutes() int {
+	return fare.BillableMinutes - fare.FreeMinutes
+}
+
+// returns the amount the rider has to pay
+func (fare *FareImpl) TotalCents() int {
+	return fare.SubtotalCents - fare.DiscountCents
+}
+
+// formats an amount of cents as a human readable price, e.g. 125 -> "1.25 EUR"
+func FormatCents(amountCents int) string {
+	sign := ""
+	if amountCents < 0 {
+		sign = "-"
+		amountCents = -amountCents
+	}
+	return fmt.Sprintf("%v%d.%02d %v", sign, amountCents/100, amountCents%100, TARIFF_CURRENCY)
+}
+
+// returns the smaller of two integers
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// returns the bigger of two integers
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,371 @@
+package implementation
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// ---------- TELEMETRY TABLE CONSTANTS ---------
+	DB_TABLE_DEVICE    = "device"
+	DB_TABLE_TELEMETRY = "telemetry"
+
+	// maximal length of the id of a device
+	DEVICE_MAX_ID_LENGTH = 64
+
+	// maximal number of records in one request
+	TELEMETRY_MAX_BATCH_SIZE = 500
+	// records which are recorded further in the future are rejected, the clock of a device may be a bit ahead
+	TELEMETRY_MAX_CLOCK_SKEW = 2 * time.Minute
+	// a bike which moves faster between two records has reported an implausible jump
+	TELEMETRY_MAX_SPEED_KMH = 60
+	// movements below this distance are GPS noise and never treated as jump
+	TELEMETRY_POSITION_TOLERANCE_METERS = 50
+	// default and maximal number of history entries which are returned
+	TELEMETRY_HISTORY_DEFAULT_LIMIT = 100
+	TELEMETRY_HISTORY_MAX_LIMIT     = 1000
+)
+
+// the columns of the telemetry table in the order they are scanned by GetTelemetryHistory
+const telemetryColumns = `bikeid, deviceid, recordedat, receivedat, latitude, longitude, batterypercent, locked, odometermeters`
+
+/*
+Implementation method to register the device of a bike. A new secret is generated, which the device uses to sign its requests.
+If the bike already has a device, the device is replaced, e.g. when the hardware was exchanged.
+*/
+func RegisterDevice(ctx context.Context, device DeviceImpl) (*DeviceImpl, error) {
+
+	device.DeviceId = strings.TrimSpace(device.DeviceId)
+	validator := fieldValidator{}
+	validator.requiredText("deviceId", device.DeviceId, DEVICE_MAX_ID_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	secret, generateSecretError := generateSecret()
+	if generateSecretError != nil {
+		return nil, generateSecretError
+	}
+	device.Secret = secret
+	device.Active = true
+	device.CreatedAt = time.Now()
+	device.LastSeenAt = nil
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	bikeIdExists, bikeIdExistsError := bikeIdExistsInTable(ctx, db, DB_TABLE_BIKE, device.BikeId)
+	if bikeIdExistsError != nil {
+		return nil, bikeIdExistsError
+	}
+	if !bikeIdExists {
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
+	}
+
+	upsertStatement := `INSERT INTO ` + DB_TABLE_DEVICE + ` (deviceid, bikeid, secret, active, createdat) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (bikeid) DO UPDATE SET deviceid=EXCLUDED.deviceid, secret=EXCLUDED.secret, active=EXCLUDED.active, createdat=EXCLUDED.createdat, lastseenat=NULL;`
+	_, dbUpsertError := db.ExecContext(ctx, upsertStatement, device.DeviceId, device.BikeId, device.Secret, device.Active, device.CreatedAt)
+	if dbUpsertError != nil {
+		return nil, fmt.Errorf("could not save record into device Table. %w", dbUpsertError)
+	}
+
+	return &device, nil
+}
+
+/*
+Implementation method to authenticate a device.
+the signature is the hex encoded HMAC-SHA256 of the request body with the secret of the device
+*/
+func AuthenticateDevice(ctx context.Context, deviceId string, body []byte, signature string) (*DeviceImpl, error) {
+
+	if deviceId == "" || signature == "" {
+		return nil, UnauthorizedError(ERROR_CODE_DEVICE_UNAUTHORIZED, "device id and signature are required")
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	device := DeviceImpl{}
+	dbQueryError := db.QueryRowContext(ctx, `SELECT deviceid, bikeid, secret, active, createdat FROM `+DB_TABLE_DEVICE+` WHERE deviceid=$1;`, deviceId).
+		Scan(&device.DeviceId, &device.BikeId, &device.Secret, &device.Active, &device.CreatedAt)
+	if dbQueryError != nil {
+		// do not tell the caller whether the device exists
+		return nil, UnauthorizedError(ERROR_CODE_DEVICE_UNAUTHORIZED, "invalid device or signature")
+	}
+
+	receivedSignature, decodeError := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if decodeError != nil {
+		return nil, UnauthorizedError(ERROR_CODE_DEVICE_UNAUTHORIZED, "invalid device or signature")
+	}
+
+	mac := hmac.New(sha256.New, []byte(device.Secret))
+	mac.Write(body)
+	if !hmac.Equal(receivedSignature, mac.Sum(nil)) || !device.Active {
+		return nil, UnauthorizedError(ERROR_CODE_DEVICE_UNAUTHORIZED, "invalid device or signature")
+	}
+
+	// the secret is not needed any more and must never be returned
+	device.Secret = ""
+	return &device, nil
+}
+
+/*
+Implementation method to ingest the telemetry records of a device.
+the records are processed in the order they were sent. A record is rejected if it is older than the last accepted record of the bike,
+recorded in the future, has invalid values or shows an implausible jump of the position or the odometer.
+accepted records are appended to the telemetry history and the latest one becomes the current state of the bike.
+*/
+func IngestTelemetry(ctx context.Context, device *DeviceImpl, records []TelemetryRecordImpl) (*TelemetryIngestionImpl, error) {
+
+	if len(records) == 0 {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "no telemetry records provided")
+	}
+	if len(records) > TELEMETRY_MAX_BATCH_SIZE {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "a batch must not contain more than %d records", TELEMETRY_MAX_BATCH_SIZE)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	// lock the bike, so that concurrent requests of the device are processed one after the other
+	rows, dbQueryError := tx.QueryContext(ctx, `SELECT `+bikeColumns+` FROM `+DB_TABLE_BIKE+` WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$1 FOR UPDATE;`, device.BikeId)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve bike %v. %w", device.BikeId, dbQueryError)
+	}
+	if !rows.Next() {
+		rows.Close()
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "bike %v of device %v does not exist", device.BikeId, device.DeviceId)
+	}
+	bike, scanError := scanBike(rows)
+	rows.Close()
+	if scanError != nil {
+		return nil, scanError
+	}
+
+	// the last accepted record, invalid before the first report of the bike
+	var last *TelemetryRecordImpl
+	if bike.LastTelemetryAt.Valid {
+		latitude, longitude, parseError := parseCoordinates(bike.Latitude, bike.Longitude)
+		if parseError != nil {
+			return nil, parseError
+		}
+		last = &TelemetryRecordImpl{RecordedAt: bike.LastTelemetryAt.Time, Latitude: latitude, Longitude: longitude, OdometerMeters: bike.OdometerMeters}
+	}
+
+	ingestion := TelemetryIngestionImpl{BikeId: device.BikeId, Results: []TelemetryResultImpl{}}
+	receivedAt := time.Now()
+	insertStatement := getInsertStmt(DB_TABLE_TELEMETRY, "bikeid", "deviceid", "recordedat", "receivedat", "latitude", "longitude",
+		"batterypercent", "locked", "odometermeters")
+
+	for index := range records {
+		record := records[index]
+		rejectReason := checkTelemetryRecord(record, last, receivedAt)
+		if rejectReason != "" {
+			ingestion.Rejected++
+			ingestion.Results = append(ingestion.Results, TelemetryResultImpl{Index: index, Accepted: false, Reason: rejectReason})
+			continue
+		}
+
+		_, dbInsertError := tx.ExecContext(ctx, insertStatement, device.BikeId, device.DeviceId, record.RecordedAt, receivedAt, record.Latitude, record.Longitude,
+			record.BatteryPercent, record.Locked, record.OdometerMeters)
+		if dbInsertError != nil {
+			return nil, fmt.Errorf("could not insert record into telemetry Table. %w", dbInsertError)
+		}
+
+		last = &record
+		ingestion.Accepted++
+		ingestion.Results = append(ingestion.Results, TelemetryResultImpl{Index: index, Accepted: true})
+	}
+
+	if ingestion.Accepted > 0 {
+		// the range which the device estimates is preferred, otherwise it is estimated from the range of the bike with a full battery
+		estimatedRangeKm := bike.FullRangeKm * float64(last.BatteryPercent) / 100
+		if last.EstimatedRangeKm != nil {
+			estimatedRangeKm = *last.EstimatedRangeKm
+		}
+
+		updateStatement := `UPDATE ` + DB_TABLE_BIKE + ` SET latitude=$1, longitude=$2, batterypercent=$3, estimatedrangekm=$4, locked=$5, odometermeters=$6, lasttelemetryat=$7 WHERE ` +
+			DB_TABLE_BIKE_COLUMN_BIKEID + `=$8;`
+		_, dbUpdateError := tx.ExecContext(ctx, updateStatement, last.Latitude, last.Longitude, last.BatteryPercent, math.Round(estimatedRangeKm*10)/10, last.Locked,
+			last.OdometerMeters, last.RecordedAt, device.BikeId)
+		if dbUpdateError != nil {
+			return nil, fmt.Errorf("could not update state of bike %v. %w", device.BikeId, dbUpdateError)
+		}
+
+		// a reserved bike which is unlocked is in use, the battery decides whether an available bike can be rented
+		newStatus := bikeStatusForBattery(bike.Status, last.BatteryPercent)
+		statusReason := fmt.Sprintf("battery at %d%%", last.BatteryPercent)
+		if bike.Status == BIKE_STATUS_RESERVED && !last.Locked {
+			newStatus = BIKE_STATUS_IN_USE
+			statusReason = "bike unlocked"
+		}
+		if newStatus != bike.Status {
+			_, transitionError := transitionBikeStatus(ctx, tx, device.BikeId, bike.Status, newStatus, statusReason, BIKE_STATUS_CHANGED_BY_SYSTEM)
+			if transitionError != nil {
+				return nil, transitionError
+			}
+		}
+
+		// the telemetry of a parked bike repeats its position, only a new position is an event
+		previousLatitude, previousLongitude, parseError := parseCoordinates(bike.Latitude, bike.Longitude)
+		if parseError != nil || previousLatitude != last.Latitude || previousLongitude != last.Longitude {
+			bikeMoved := BikeMovedEventImpl{BikeId: device.BikeId, Latitude: last.Latitude, Longitude: last.Longitude, Source: BIKE_MOVED_BY_TELEMETRY,
+				MovedAt: last.RecordedAt}
+			writeEventError := writeBikeDomainEvent(ctx, tx, DOMAIN_EVENT_BIKE_MOVED, device.BikeId, bikeMoved)
+			if writeEventError != nil {
+				return nil, writeEventError
+			}
+		}
+
+		releaseError := releaseBikeFromStationIfMoved(ctx, tx, device.BikeId, last.Latitude, last.Longitude)
+		if releaseError != nil {
+			return nil, releaseError
+		}
+
+		evaluation, evaluatePositionError := evaluatePosition(ctx, tx, last.Latitude, last.Longitude)
+		if evaluatePositionError != nil {
+			return nil, evaluatePositionError
+		}
+		ingestion.Position = evaluation
+
+		// a parked bike which moves without reservation is probably stolen
+		_, evaluateTheftError := evaluateTheftRules(ctx, tx, bike, newStatus, last, evaluation)
+		if evaluateTheftError != nil {
+			return nil, evaluateTheftError
+		}
+	}
+
+	_, dbUpdateDeviceError := tx.ExecContext(ctx, `UPDATE `+DB_TABLE_DEVICE+` SET lastseenat=$1 WHERE deviceid=$2;`, receivedAt, device.DeviceId)
+	if dbUpdateDeviceError != nil {
+		return nil, fmt.Errorf("could not update device %v. %w", device.DeviceId, dbUpdateDeviceError)
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not store telemetry. %w", commitError)
+	}
+
+	if ingestion.Accepted > 0 {
+		publishBikeEvent(ctx, db, BIKE_EVENT_UPDATED, device.BikeId)
+	}
+
+	return &ingestion, nil
+}
+
+/*
+Implementation method to retrieve the telemetry history of a bike in a period, the latest record first
+*/
+func GetTelemetryHistory(ctx context.Context, bikeId int, from time.Time, to time.Time, limit int) (*[]TelemetryHistoryEntryImpl, error) {
+
+	if limit <= 0 {
+		limit = TELEMETRY_HISTORY_DEFAULT_LIMIT
+	}
+	limit = minInt(limit, TELEMETRY_HISTORY_MAX_LIMIT)
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	queryString := `SELECT ` + telemetryColumns + ` FROM ` + DB_TABLE_TELEMETRY + ` WHERE bikeid=$1 AND recordedat>=$2 AND recordedat<$3 ORDER BY recordedat DESC LIMIT $4;`
+	rows, dbQueryError := db.QueryContext(ctx, queryString, bikeId, from, to, limit)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving telemetry of bike %v", bikeId)
+	}
+	defer rows.Close()
+
+	history := []TelemetryHistoryEntryImpl{}
+	for rows.Next() {
+		entry := TelemetryHistoryEntryImpl{}
+		scanError := rows.Scan(&entry.BikeId, &entry.DeviceId, &entry.RecordedAt, &entry.ReceivedAt, &entry.Latitude, &entry.Longitude,
+			&entry.BatteryPercent, &entry.Locked, &entry.OdometerMeters)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into telemetry object", DB_TABLE_TELEMETRY)
+		}
+		history = append(history, entry)
+	}
+
+	return &history, nil
+}
+
+/*
+checks a record against the last accepted record of the bike.
+returns the reason why the record is rejected, or an empty string if the record is accepted
+*/
+func checkTelemetryRecord(record TelemetryRecordImpl, last *TelemetryRecordImpl, receivedAt time.Time) string {
+
+	if record.RecordedAt.IsZero() {
+		return "recordedAt is missing"
+	}
+	if record.RecordedAt.After(receivedAt.Add(TELEMETRY_MAX_CLOCK_SKEW)) {
+		return "recordedAt is in the future"
+	}
+	if coordinateError := validateCoordinates(record.Latitude, record.Longitude); coordinateError != nil {
+		return coordinateError.Error()
+	}
+	if record.BatteryPercent < 0 || record.BatteryPercent > 100 {
+		return "batteryPercent must be between 0 and 100"
+	}
+	if record.EstimatedRangeKm != nil && *record.EstimatedRangeKm < 0 {
+		return "estimatedRangeKm must not be negative"
+	}
+	if record.OdometerMeters < 0 {
+		return "odometerMeters must not be negative"
+	}
+
+	if last == nil {
+		return ""
+	}
+
+	if !record.RecordedAt.After(last.RecordedAt) {
+		return fmt.Sprintf("out of order: recordedAt is not after the last accepted record at %v", last.RecordedAt.Format(time.RFC3339))
+	}
+	if record.OdometerMeters < last.OdometerMeters {
+		return "odometer must not decrease"
+	}
+
+	// neither the position nor the odometer may change faster than a bike can move
+	hours := record.RecordedAt.Sub(last.RecordedAt).Hours()
+	maxMeters := TELEMETRY_MAX_SPEED_KMH*1000*hours + TELEMETRY_POSITION_TOLERANCE_METERS
+	if distanceMeters(last.Latitude, last.Longitude, record.Latitude, record.Longitude) > maxMeters {
+		return "implausible jump of the position"
+	}
+	if float64(record.OdometerMeters-last.OdometerMeters) > maxMeters {
+		return "implausible jump of the odometer"
+	}
+
+	return ""
+}
+
+// generates a random secret, e.g. for a device or a webhook subscription
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, readError := rand.Read(secret)
+	if readError != nil {
+		return "", fmt.Errorf("could not generate secret. %w", readError)
+	}
+	return hex.EncodeToString(secret), nil
+}
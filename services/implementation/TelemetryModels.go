@@ -0,0 +1,63 @@
+package implementation
+
+import "time"
+
+/*
+represents the database structure for the table "device" in the DATABASE.
+every bike has one device which reports its telemetry. The device signs its requests with the secret.
+the secret is only returned once, when the device is registered
+*/
+type DeviceImpl struct {
+	DeviceId   string     `json:"deviceId"`
+	BikeId     int        `json:"bikeId"`
+	Secret     string     `json:"secret,omitempty"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt *time.Time `json:"lastSeenAt"`
+}
+
+/*
+represents a single telemetry record which is reported by a device.
+the bike is not part of the record, it is the bike of the device which sent the record
+*/
+type TelemetryRecordImpl struct {
+	RecordedAt     time.Time `json:"recordedAt"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	BatteryPercent int       `json:"batteryPercent"`
+	// optional, if the device does not estimate the range it is estimated from the battery percentage
+	EstimatedRangeKm *float64 `json:"estimatedRangeKm,omitempty"`
+	Locked           bool     `json:"locked"`
+	OdometerMeters   int64    `json:"odometerMeters"`
+}
+
+/*
+represents the database structure for the table "telemetry" in the DATABASE, the history of all accepted records
+*/
+type TelemetryHistoryEntryImpl struct {
+	TelemetryRecordImpl
+	BikeId     int       `json:"bikeId"`
+	DeviceId   string    `json:"deviceId"`
+	ReceivedAt time.Time `json:"receivedAt"`
+}
+
+/*
+represents whether a record of a batch was accepted. Index is the position of the record in the batch
+*/
+type TelemetryResultImpl struct {
+	Index    int    `json:"index"`
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+/*
+represents the result of the ingestion of a batch of telemetry records.
+the zone evaluation of the latest accepted position tells the device e.g. the speed limit
+*/
+type TelemetryIngestionImpl struct {
+	BikeId   int                     `json:"bikeId"`
+	Accepted int                     `json:"accepted"`
+	Rejected int                     `json:"rejected"`
+	Results  []TelemetryResultImpl   `json:"results"`
+	Position *PositionEvaluationImpl `json:"position"`
+}
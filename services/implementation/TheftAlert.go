@@ -0,0 +1,393 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	DB_TABLE_THEFTALERT = "theftalert"
+
+	// default and maximal number of alerts which are returned by the feed
+	THEFT_ALERT_DEFAULT_LIMIT = 100
+	THEFT_ALERT_MAX_LIMIT     = 500
+)
+
+// the columns of the theftalert table in the order they are scanned by scanTheftAlert
+const theftAlertColumns = `alertid, bikeid, kind, status, detail, latitude, longitude, distancemeters, createdat, updatedby, updatedat, note`
+
+// the status which an alert can change to from a status
+var allowedTheftAlertTransitions = map[string][]string{
+	THEFT_ALERT_OPEN:         {THEFT_ALERT_ACKNOWLEDGED, THEFT_ALERT_RESOLVED},
+	THEFT_ALERT_ACKNOWLEDGED: {THEFT_ALERT_RESOLVED},
+	THEFT_ALERT_RESOLVED:     {},
+}
+
+/*
+Implementation method to retrieve the alerts feed, the latest alert first
+*/
+func GetTheftAlerts(ctx context.Context, filter TheftAlertFilterImpl) (*[]TheftAlertImpl, error) {
+
+	if filter.Status != "" {
+		if _, isKnownStatus := allowedTheftAlertTransitions[filter.Status]; !isKnownStatus {
+			return nil, ValidationError(ERROR_CODE_VALIDATION, "unknown status %v", filter.Status)
+		}
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = THEFT_ALERT_DEFAULT_LIMIT
+	}
+	if filter.Limit > THEFT_ALERT_MAX_LIMIT {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "limit must not be greater than %d", THEFT_ALERT_MAX_LIMIT)
+	}
+
+	conditions := []string{"alertid>$1"}
+	arguments := []interface{}{filter.AfterId}
+	if filter.Status != "" {
+		arguments = append(arguments, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status=$%d", len(arguments)))
+	}
+	if filter.Kind != "" {
+		arguments = append(arguments, filter.Kind)
+		conditions = append(conditions, fmt.Sprintf("kind=$%d", len(arguments)))
+	}
+	if filter.BikeId != nil {
+		arguments = append(arguments, *filter.BikeId)
+		conditions = append(conditions, fmt.Sprintf("bikeid=$%d", len(arguments)))
+	}
+	arguments = append(arguments, filter.Limit)
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	alerts, getAlertsError := getTheftAlertsWhere(ctx, db, strings.Join(conditions, " AND ")+fmt.Sprintf(" ORDER BY alertid DESC LIMIT $%d", len(arguments)), arguments...)
+	if getAlertsError != nil {
+		return nil, getAlertsError
+	}
+
+	return &alerts, nil
+}
+
+/*
+Implementation method for operators to acknowledge or resolve an alert.
+resolving an alert does not change the status of the bike, a bike which was found is set to available with its status endpoint
+*/
+func UpdateTheftAlert(ctx context.Context, alertId int64, update TheftAlertUpdateImpl, updatedBy string) (*TheftAlertImpl, error) {
+
+	validator := fieldValidator{}
+	if _, isKnownStatus := allowedTheftAlertTransitions[update.Status]; !isKnownStatus {
+		validator.add("status", "unknown status %v", update.Status)
+	}
+	validator.maxLength("note", update.Note, MAX_NOTE_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	alerts, getAlertsError := getTheftAlertsWhere(ctx, db, `alertid=$1`, alertId)
+	if getAlertsError != nil {
+		return nil, getAlertsError
+	}
+	if len(alerts) == 0 {
+		return nil, NotFoundError(ERROR_CODE_ALERT_NOT_FOUND, "alert %v does not exist", alertId)
+	}
+	alert := alerts[0]
+
+	if !theftAlertTransitionAllowed(alert.Status, update.Status) {
+		return nil, ConflictError(ERROR_CODE_STATUS_TRANSITION_NOT_ALLOWED, "the status of alert %v can not change from %v to %v", alertId, alert.Status, update.Status)
+	}
+
+	updatedAt := time.Now()
+	result, dbUpdateError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_THEFTALERT+` SET status=$1, note=$2, updatedby=$3, updatedat=$4 WHERE alertid=$5 AND status=$6;`,
+		update.Status, update.Note, updatedBy, updatedAt, alertId, alert.Status)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not update record in %v Table. %w", DB_TABLE_THEFTALERT, dbUpdateError)
+	}
+	updatedRows, rowsAffectedError := result.RowsAffected()
+	if rowsAffectedError != nil {
+		return nil, rowsAffectedError
+	}
+	if updatedRows == 0 {
+		return nil, ConflictError(ERROR_CODE_CONCURRENT_CHANGE, "the status of alert %v is no longer %v. Please try again", alertId, alert.Status)
+	}
+
+	alert.Status = update.Status
+	alert.Note = update.Note
+	alert.UpdatedBy = &updatedBy
+	alert.UpdatedAt = &updatedAt
+	return &alert, nil
+}
+
+/*
+Implementation method which checks once for silent bikes.
+a parked bike whose device did not contact the API for longer than the silence threshold raises an alert and is marked as missing
+*/
+func RunTheftMonitor(ctx context.Context) (*[]TheftAlertImpl, error) {
+
+	silence := TheftSilenceThreshold()
+	if silence <= 0 {
+		return &[]TheftAlertImpl{}, nil
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	// a device which was never seen is counted from its registration
+	rows, dbQueryError := tx.QueryContext(ctx, `SELECT bike.bikeid, bike.status, coalesce(device.lastseenat, device.createdat) FROM `+DB_TABLE_BIKE+`
+		JOIN `+DB_TABLE_DEVICE+` ON device.bikeid=bike.bikeid AND device.active
+		WHERE bike.status IN ($1, $2) AND bike.reservationid IS NULL AND coalesce(device.lastseenat, device.createdat)<$3
+		ORDER BY bike.bikeid FOR UPDATE OF bike;`, BIKE_STATUS_AVAILABLE, BIKE_STATUS_LOW_BATTERY, time.Now().Add(-silence))
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve silent bikes. %w", dbQueryError)
+	}
+
+	type silentBike struct {
+		bikeId     int
+		status     string
+		lastSeenAt time.Time
+	}
+	silentBikes := []silentBike{}
+	for rows.Next() {
+		bike := silentBike{}
+		scanError := rows.Scan(&bike.bikeId, &bike.status, &bike.lastSeenAt)
+		if scanError != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning fields. could not scan silent bike. %w", scanError)
+		}
+		silentBikes = append(silentBikes, bike)
+	}
+	rows.Close()
+
+	alerts := []TheftAlertImpl{}
+	for _, bike := range silentBikes {
+		alert := TheftAlertImpl{
+			BikeId: bike.bikeId,
+			Kind:   THEFT_ALERT_SILENT,
+			Detail: fmt.Sprintf("no contact since %v", bike.lastSeenAt.Format(time.RFC3339)),
+		}
+		raised, raiseAlertError := raiseTheftAlerts(ctx, tx, bike.bikeId, bike.status, []TheftAlertImpl{alert})
+		if raiseAlertError != nil {
+			return nil, raiseAlertError
+		}
+		alerts = append(alerts, raised...)
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not store theft alerts. %w", commitError)
+	}
+
+	// the bikes with a new alert are missing now
+	for _, alert := range alerts {
+		publishBikeEvent(ctx, db, BIKE_EVENT_UPDATED, alert.BikeId)
+	}
+
+	return &alerts, nil
+}
+
+/*
+starts the theft monitor in the background, it checks for silent bikes once per interval.
+an interval of 0 disables the monitor. The returned function stops the monitor
+*/
+func StartTheftMonitor(interval time.Duration) func() {
+	return startPeriodicJob("theft monitor", interval, func(ctx context.Context) {
+		alerts, runError := RunTheftMonitor(ctx)
+		if runError != nil {
+			fmt.Printf("theft monitor failed. %v\n", runError)
+			return
+		}
+		if len(*alerts) > 0 {
+			fmt.Printf("theft monitor raised %d alerts\n", len(*alerts))
+		}
+	})
+}
+
+/*
+evaluates the theft rules for the telemetry of a bike. bike is the state before the telemetry, status its current status.
+a bike which is not reserved and parked (available or low_battery) raises an alert when it moved further than the threshold
+from the position where it was parked or when it is outside of the operating area
+*/
+func evaluateTheftRules(ctx context.Context, tx dbQueryer, bike *BikeImpl, status string, last *TelemetryRecordImpl, evaluation *PositionEvaluationImpl) ([]TheftAlertImpl, error) {
+
+	if bike.ReservationId.Valid || !bikeIsParked(status) {
+		return nil, nil
+	}
+
+	var parkedLatitude, parkedLongitude sql.NullFloat64
+	dbQueryError := tx.QueryRowContext(ctx, `SELECT parkedlatitude, parkedlongitude FROM `+DB_TABLE_BIKE+` WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$1;`, bike.BikeId).
+		Scan(&parkedLatitude, &parkedLongitude)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve parked position of bike %v. %w", bike.BikeId, dbQueryError)
+	}
+
+	// a bike which was parked before theft detection existed is parked at its position before the telemetry
+	if !parkedLatitude.Valid || !parkedLongitude.Valid {
+		latitude, longitude, parseError := parseCoordinates(bike.Latitude, bike.Longitude)
+		if parseError != nil {
+			return nil, parseError
+		}
+		_, dbUpdateError := tx.ExecContext(ctx, `UPDATE `+DB_TABLE_BIKE+` SET parkedlatitude=$1, parkedlongitude=$2 WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$3;`,
+			latitude, longitude, bike.BikeId)
+		if dbUpdateError != nil {
+			return nil, fmt.Errorf("could not store parked position of bike %v. %w", bike.BikeId, dbUpdateError)
+		}
+		parkedLatitude = sql.NullFloat64{Float64: latitude, Valid: true}
+		parkedLongitude = sql.NullFloat64{Float64: longitude, Valid: true}
+	}
+
+	latitude, longitude := last.Latitude, last.Longitude
+	candidates := []TheftAlertImpl{}
+
+	distance := int(math.Round(distanceMeters(parkedLatitude.Float64, parkedLongitude.Float64, latitude, longitude)))
+	if distance > TheftMoveMeters() {
+		candidates = append(candidates, TheftAlertImpl{
+			BikeId:         bike.BikeId,
+			Kind:           THEFT_ALERT_MOVED_WHILE_PARKED,
+			Detail:         fmt.Sprintf("moved %d m without reservation, locked: %v", distance, last.Locked),
+			Latitude:       &latitude,
+			Longitude:      &longitude,
+			DistanceMeters: &distance,
+		})
+	}
+	if evaluation != nil && !evaluation.InsideOperatingArea {
+		candidates = append(candidates, TheftAlertImpl{
+			BikeId:    bike.BikeId,
+			Kind:      THEFT_ALERT_LEFT_OPERATING_AREA,
+			Detail:    "outside of the operating area without reservation",
+			Latitude:  &latitude,
+			Longitude: &longitude,
+		})
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	return raiseTheftAlerts(ctx, tx, bike.BikeId, status, candidates)
+}
+
+/*
+stores the alerts of a bike and marks the bike as missing.
+an alert is not raised again while the bike has an unresolved alert of the same kind
+*/
+func raiseTheftAlerts(ctx context.Context, tx dbQueryer, bikeId int, status string, candidates []TheftAlertImpl) ([]TheftAlertImpl, error) {
+
+	raised := []TheftAlertImpl{}
+	insertStatement := getInsertStmt(DB_TABLE_THEFTALERT, "bikeid", "kind", "status", "detail", "latitude", "longitude", "distancemeters", "createdat")
+	for _, alert := range candidates {
+		var unresolvedAlerts int
+		dbCountError := tx.QueryRowContext(ctx, `SELECT count(*) FROM `+DB_TABLE_THEFTALERT+` WHERE bikeid=$1 AND kind=$2 AND status<>$3;`,
+			bikeId, alert.Kind, THEFT_ALERT_RESOLVED).Scan(&unresolvedAlerts)
+		if dbCountError != nil {
+			return nil, fmt.Errorf("could not count alerts of bike %v. %w", bikeId, dbCountError)
+		}
+		if unresolvedAlerts > 0 {
+			continue
+		}
+
+		alert.Status = THEFT_ALERT_OPEN
+		alert.CreatedAt = time.Now()
+		dbInsertError := tx.QueryRowContext(ctx, insertStatement+` RETURNING alertid`, alert.BikeId, alert.Kind, alert.Status, alert.Detail, alert.Latitude,
+			alert.Longitude, alert.DistanceMeters, alert.CreatedAt).Scan(&alert.AlertId)
+		if dbInsertError != nil {
+			return nil, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_THEFTALERT, dbInsertError)
+		}
+
+		writeEventError := writeBikeDomainEvent(ctx, tx, DOMAIN_EVENT_THEFT_ALERT_RAISED, bikeId, alert)
+		if writeEventError != nil {
+			return nil, writeEventError
+		}
+		raised = append(raised, alert)
+	}
+
+	if len(raised) > 0 && status != BIKE_STATUS_MISSING {
+		_, transitionError := transitionBikeStatus(ctx, tx, bikeId, status, BIKE_STATUS_MISSING, "theft alert: "+raised[0].Kind, BIKE_STATUS_CHANGED_BY_SYSTEM)
+		if transitionError != nil {
+			return nil, transitionError
+		}
+	}
+
+	return raised, nil
+}
+
+// returns true, if the bike is parked and can be rented, so it must not move
+func bikeIsParked(status string) bool {
+	return status == BIKE_STATUS_AVAILABLE || status == BIKE_STATUS_LOW_BATTERY
+}
+
+// returns true, if an alert can change from one status to the other
+func theftAlertTransitionAllowed(fromStatus string, toStatus string) bool {
+	for _, allowedStatus := range allowedTheftAlertTransitions[fromStatus] {
+		if allowedStatus == toStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the alerts which match the condition, e.g. `bikeid=$1 ORDER BY alertid`
+func getTheftAlertsWhere(ctx context.Context, db dbQueryer, condition string, arguments ...interface{}) ([]TheftAlertImpl, error) {
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+theftAlertColumns+` FROM `+DB_TABLE_THEFTALERT+` WHERE `+condition+`;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_THEFTALERT, dbQueryError)
+	}
+	defer rows.Close()
+
+	alerts := []TheftAlertImpl{}
+	for rows.Next() {
+		alert, scanError := scanTheftAlert(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		alerts = append(alerts, *alert)
+	}
+	return alerts, nil
+}
+
+// scans a row of the theftalert table, the columns are selected with theftAlertColumns
+func scanTheftAlert(rows *sql.Rows) (*TheftAlertImpl, error) {
+	alert := TheftAlertImpl{}
+	var latitude, longitude sql.NullFloat64
+	var distance sql.NullInt64
+	var updatedBy sql.NullString
+	var updatedAt sql.NullTime
+	scanError := rows.Scan(&alert.AlertId, &alert.BikeId, &alert.Kind, &alert.Status, &alert.Detail, &latitude, &longitude, &distance,
+		&alert.CreatedAt, &updatedBy, &updatedAt, &alert.Note)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into TheftAlert Object. %w", DB_TABLE_THEFTALERT, scanError)
+	}
+	if latitude.Valid && longitude.Valid {
+		alert.Latitude = &latitude.Float64
+		alert.Longitude = &longitude.Float64
+	}
+	if distance.Valid {
+		distanceMeters := int(distance.Int64)
+		alert.DistanceMeters = &distanceMeters
+	}
+	if updatedBy.Valid {
+		alert.UpdatedBy = &updatedBy.String
+	}
+	if updatedAt.Valid {
+		alert.UpdatedAt = &updatedAt.Time
+	}
+	return &alert, nil
+}
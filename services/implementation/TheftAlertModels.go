@@ -0,0 +1,58 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- kinds of theft alerts ---------
+	// a bike without reservation moved away from the position where it was parked
+	THEFT_ALERT_MOVED_WHILE_PARKED = "moved_while_parked"
+	// a bike without reservation is outside of the operating area
+	THEFT_ALERT_LEFT_OPERATING_AREA = "left_operating_area"
+	// the device of a parked bike did not contact the API for too long
+	THEFT_ALERT_SILENT = "silent"
+
+	// ---------- status of theft alerts ---------
+	THEFT_ALERT_OPEN         = "open"
+	THEFT_ALERT_ACKNOWLEDGED = "acknowledged"
+	THEFT_ALERT_RESOLVED     = "resolved"
+)
+
+/*
+represents the database structure for the table "theftalert" in the DATABASE.
+an alert is raised by the rules which evaluate the telemetry of parked bikes, the bike is marked as missing.
+the position is the position of the bike when the alert was raised, it is unknown for silent bikes
+*/
+type TheftAlertImpl struct {
+	AlertId        int64      `json:"alertId"`
+	BikeId         int        `json:"bikeId"`
+	Kind           string     `json:"kind"`
+	Status         string     `json:"status"`
+	Detail         string     `json:"detail"`
+	Latitude       *float64   `json:"latitude"`
+	Longitude      *float64   `json:"longitude"`
+	DistanceMeters *int       `json:"distanceMeters"` // distance from the parked position, only for moved_while_parked
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedBy      *string    `json:"updatedBy"`
+	UpdatedAt      *time.Time `json:"updatedAt"`
+	Note           string     `json:"note"`
+}
+
+/*
+represents the filter of the alerts feed. Empty values do not filter.
+AfterId only returns alerts which were raised after the alert, so a client can poll for new alerts
+*/
+type TheftAlertFilterImpl struct {
+	Status  string
+	Kind    string
+	BikeId  *int
+	AfterId int64
+	Limit   int
+}
+
+/*
+represents the request of an operator to acknowledge or resolve an alert
+*/
+type TheftAlertUpdateImpl struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
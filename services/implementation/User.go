@@ -0,0 +1,45 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	// ---------- roles of a user ---------
+	ROLE_RIDER    = "rider"
+	ROLE_OPERATOR = "operator"
+	ROLE_ADMIN    = "admin"
+)
+
+/*
+Implementation method to look up the role of a user.
+returns an error if the user does not exist
+*/
+func GetUserRole(ctx context.Context, username string) (string, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return "", dbConnectError
+	}
+
+	return getUserRoleFromDb(ctx, db, username)
+}
+
+// returns the role of a user, or an error if the user does not exist
+func getUserRoleFromDb(ctx context.Context, db dbQueryer, username string) (string, error) {
+
+	queryString := `SELECT ` + DB_TABLE_USER_COLUMN_ROLE + ` FROM ` + DB_TABLE_USER + ` WHERE ` + DB_TABLE_USER_COLUMN_USERNAME + `=$1;`
+
+	var role string
+	scanError := db.QueryRowContext(ctx, queryString, username).Scan(&role)
+	if scanError == sql.ErrNoRows {
+		return "", NotFoundError(ERROR_CODE_USER_NOT_FOUND, "provided username does not exist in database")
+	}
+	if scanError != nil {
+		return "", fmt.Errorf("could not retrieve role of user %v. %w", username, scanError)
+	}
+
+	return role, nil
+}
@@ -0,0 +1,95 @@
+package implementation
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// ---------- maximal lengths of the texts of the requests, the columns of the database are not longer ---------
+	MAX_USERNAME_LENGTH = 32
+	// e.g. the id of a plan or of a penalty rule, or a promotion code
+	MAX_ID_LENGTH = 32
+	// e.g. the name of a station or of an organization
+	MAX_NAME_LENGTH        = 100
+	MAX_DESCRIPTION_LENGTH = 255
+	// e.g. the reason of a status change or the note of a theft alert
+	MAX_NOTE_LENGTH = 500
+	// e.g. the description of a work order
+	MAX_TEXT_LENGTH = 2000
+)
+
+/*
+collects the values of a request which are not allowed. A validation does not stop at the first value which is not allowed,
+so a client receives all of them at once
+*/
+type fieldValidator struct {
+	fieldErrors []FieldErrorImpl
+}
+
+// adds a value which is not allowed, the message describes the value, e.g. "must not be negative"
+func (validator *fieldValidator) add(field string, format string, arguments ...interface{}) {
+	validator.fieldErrors = append(validator.fieldErrors, FieldErrorImpl{Field: field, Message: fmt.Sprintf(format, arguments...)})
+}
+
+// adds an error if a mandatory value is not provided. Returns true if it is provided, so its other checks can follow
+func (validator *fieldValidator) required(field string, isProvided bool) bool {
+	if !isProvided {
+		validator.add(field, "is required")
+	}
+	return isProvided
+}
+
+// adds an error if a mandatory text is empty or longer than the maximal number of characters
+func (validator *fieldValidator) requiredText(field string, value string, maxLength int) {
+	if validator.required(field, strings.TrimSpace(value) != "") {
+		validator.maxLength(field, value, maxLength)
+	}
+}
+
+// adds an error if a text is longer than the maximal number of characters
+func (validator *fieldValidator) maxLength(field string, value string, maxLength int) {
+	if utf8.RuneCountInString(value) > maxLength {
+		validator.add(field, "must not be longer than %d characters", maxLength)
+	}
+}
+
+// adds an error if a number is not between min and max
+func (validator *fieldValidator) between(field string, value int, min int, max int) {
+	if value < min || value > max {
+		validator.add(field, "must be between %d and %d", min, max)
+	}
+}
+
+// adds an error if a number is less than min
+func (validator *fieldValidator) atLeast(field string, value int, min int) {
+	if value < min {
+		validator.add(field, "must be at least %d", min)
+	}
+}
+
+// adds an error if a value is not one of the allowed values
+func (validator *fieldValidator) oneOf(field string, value string, allowedValues ...string) {
+	for _, allowedValue := range allowedValues {
+		if value == allowedValue {
+			return
+		}
+	}
+	validator.add(field, "must be one of %v", strings.Join(allowedValues, ", "))
+}
+
+// adds the errors of the coordinates of a position on earth
+func (validator *fieldValidator) coordinates(latitudeField string, latitude float64, longitudeField string, longitude float64) {
+	if latitude < -90 || latitude > 90 {
+		validator.add(latitudeField, "%v is not between -90 and 90", latitude)
+	}
+	if longitude < -180 || longitude > 180 {
+		validator.add(longitudeField, "%v is not between -180 and 180", longitude)
+	}
+}
+
+// returns a validation error with all values which are not allowed, or nil if all values are allowed
+func (validator *fieldValidator) err() error {
+	return FieldValidationError(validator.fieldErrors)
+}
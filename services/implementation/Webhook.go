@@ -0,0 +1,759 @@
+package implementation
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	DB_TABLE_WEBHOOKSUBSCRIPTION = "webhooksubscription"
+	DB_TABLE_WEBHOOKDELIVERY     = "webhookdelivery"
+	DB_TABLE_WEBHOOKATTEMPT      = "webhookattempt"
+
+	// headers of a webhook request. The signature is "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>" with the secret>"
+	WEBHOOK_SIGNATURE_HEADER = "X-Webhook-Signature"
+	WEBHOOK_EVENT_HEADER     = "X-Webhook-Event"
+	WEBHOOK_DELIVERY_HEADER  = "X-Webhook-Delivery"
+
+	// the first retry waits WEBHOOK_RETRY_BASE_DELAY, every further retry twice as long up to WEBHOOK_RETRY_MAX_DELAY
+	WEBHOOK_RETRY_BASE_DELAY = 30 * time.Second
+	WEBHOOK_RETRY_MAX_DELAY  = 6 * time.Hour
+	// a claimed delivery is attempted again after this time, if the API stopped while it was sent
+	WEBHOOK_CLAIM_TIMEOUT = 5 * time.Minute
+	// maximal number of deliveries which are sent by one run of the dispatcher
+	WEBHOOK_DISPATCH_BATCH_SIZE = 20
+	// the error of a failed attempt, e.g. the response of the receiver, is stored up to this length
+	WEBHOOK_MAX_ERROR_LENGTH = 500
+	WEBHOOK_MAX_URL_LENGTH   = 2048
+	// default and maximal number of deliveries which are returned by the delivery log
+	WEBHOOK_DELIVERY_DEFAULT_LIMIT = 50
+	WEBHOOK_DELIVERY_MAX_LIMIT     = 500
+)
+
+// the columns of the webhooksubscription table in the order they are scanned by scanWebhookSubscription. The secret is never selected
+const webhookSubscriptionColumns = `subscriptionid, url, eventtypes, description, active, createdby, createdat, updatedat`
+
+// the columns of the webhookdelivery table in the order they are scanned by scanWebhookDelivery
+const webhookDeliveryColumns = `deliveryid, subscriptionid, eventid, eventtype, status, attemptcount, nextattemptat, laststatuscode, lasterror, createdat, deliveredat`
+
+// the event types which can be subscribed
+var knownWebhookEventTypes = map[string]bool{
+	WEBHOOK_EVENT_RIDE_STARTED:        true,
+	WEBHOOK_EVENT_RIDE_FINISHED:       true,
+	WEBHOOK_EVENT_BIKE_STATUS_CHANGED: true,
+	WEBHOOK_EVENT_THEFT_ALERT_RAISED:  true,
+}
+
+// the webhook event types of the domain events which are sent to webhooks
+var webhookEventTypesOfDomainEvents = map[string]string{
+	DOMAIN_EVENT_RESERVATION_CREATED: WEBHOOK_EVENT_RIDE_STARTED,
+	DOMAIN_EVENT_RIDE_FINISHED:       WEBHOOK_EVENT_RIDE_FINISHED,
+	DOMAIN_EVENT_BIKE_STATUS_CHANGED: WEBHOOK_EVENT_BIKE_STATUS_CHANGED,
+	DOMAIN_EVENT_THEFT_ALERT_RAISED:  WEBHOOK_EVENT_THEFT_ALERT_RAISED,
+}
+
+// queues the domain events which are sent to webhooks, the payload of the domain event is the data of the webhook event
+type webhookEventSink struct{}
+
+// a delivery which was claimed by the dispatcher, with the url and the secret of its subscription
+type webhookDispatch struct {
+	deliveryId   int64
+	eventType    string
+	payload      string
+	attemptCount int
+	url          string
+	secret       string
+}
+
+/*
+Implementation method for operators to create a webhook subscription.
+the secret to verify the signatures is generated and only returned now
+*/
+func CreateWebhookSubscription(ctx context.Context, request WebhookSubscriptionRequestImpl, createdBy string) (*WebhookSubscriptionImpl, error) {
+
+	validateError := validateWebhookSubscriptionRequest(request)
+	if validateError != nil {
+		return nil, validateError
+	}
+
+	secret, generateSecretError := generateSecret()
+	if generateSecretError != nil {
+		return nil, generateSecretError
+	}
+
+	subscription := WebhookSubscriptionImpl{
+		SubscriptionId: uuid.New().String(),
+		Url:            request.Url,
+		EventTypes:     request.EventTypes,
+		Description:    request.Description,
+		Active:         request.Active == nil || *request.Active,
+		Secret:         secret,
+		CreatedBy:      createdBy,
+		CreatedAt:      time.Now(),
+	}
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	insertStatement := getInsertStmt(DB_TABLE_WEBHOOKSUBSCRIPTION, "subscriptionid", "url", "eventtypes", "description", "active", "secret", "createdby", "createdat")
+	_, dbInsertError := db.ExecContext(ctx, insertStatement, subscription.SubscriptionId, subscription.Url, pq.Array(subscription.EventTypes), subscription.Description,
+		subscription.Active, subscription.Secret, subscription.CreatedBy, subscription.CreatedAt)
+	if dbInsertError != nil {
+		return nil, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_WEBHOOKSUBSCRIPTION, dbInsertError)
+	}
+
+	return &subscription, nil
+}
+
+/*
+Implementation method to retrieve all webhook subscriptions, the oldest first
+*/
+func GetWebhookSubscriptions(ctx context.Context) (*[]WebhookSubscriptionImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	subscriptions, getSubscriptionsError := getWebhookSubscriptionsWhere(ctx, db, `true ORDER BY createdat`)
+	if getSubscriptionsError != nil {
+		return nil, getSubscriptionsError
+	}
+
+	return &subscriptions, nil
+}
+
+/*
+Implementation method for operators to change the url, the event types, the description or the state of a subscription.
+the secret stays the same
+*/
+func UpdateWebhookSubscription(ctx context.Context, subscriptionId string, request WebhookSubscriptionRequestImpl) (*WebhookSubscriptionImpl, error) {
+
+	validateError := validateWebhookSubscriptionRequest(request)
+	if validateError != nil {
+		return nil, validateError
+	}
+	if _, parseError := uuid.Parse(subscriptionId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	subscriptions, getSubscriptionsError := getWebhookSubscriptionsWhere(ctx, db, `subscriptionid=$1`, subscriptionId)
+	if getSubscriptionsError != nil {
+		return nil, getSubscriptionsError
+	}
+	if len(subscriptions) == 0 {
+		return nil, NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+	subscription := subscriptions[0]
+
+	updatedAt := time.Now()
+	subscription.Url = request.Url
+	subscription.EventTypes = request.EventTypes
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+	subscription.Description = request.Description
+	if request.Active != nil {
+		subscription.Active = *request.Active
+	}
+	subscription.UpdatedAt = &updatedAt
+
+	_, dbUpdateError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_WEBHOOKSUBSCRIPTION+` SET url=$1, eventtypes=$2, description=$3, active=$4, updatedat=$5 WHERE subscriptionid=$6;`,
+		subscription.Url, pq.Array(subscription.EventTypes), subscription.Description, subscription.Active, subscription.UpdatedAt, subscriptionId)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not update record in %v Table. %w", DB_TABLE_WEBHOOKSUBSCRIPTION, dbUpdateError)
+	}
+
+	return &subscription, nil
+}
+
+/*
+Implementation method for operators to delete a webhook subscription with its deliveries
+*/
+func DeleteWebhookSubscription(ctx context.Context, subscriptionId string) error {
+
+	if _, parseError := uuid.Parse(subscriptionId); parseError != nil {
+		return NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return dbConnectError
+	}
+
+	result, dbDeleteError := db.ExecContext(ctx, `DELETE FROM `+DB_TABLE_WEBHOOKSUBSCRIPTION+` WHERE subscriptionid=$1;`, subscriptionId)
+	if dbDeleteError != nil {
+		return fmt.Errorf("could not delete record from %v Table. %w", DB_TABLE_WEBHOOKSUBSCRIPTION, dbDeleteError)
+	}
+	if deletedRows, _ := result.RowsAffected(); deletedRows == 0 {
+		return NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+
+	return nil
+}
+
+/*
+Implementation method to retrieve the delivery log of a subscription with the attempts of every delivery, the latest delivery first.
+an empty status returns the deliveries of every status
+*/
+func GetWebhookDeliveries(ctx context.Context, subscriptionId string, status string, limit int) (*[]WebhookDeliveryImpl, error) {
+
+	if status != "" && status != WEBHOOK_DELIVERY_PENDING && status != WEBHOOK_DELIVERY_DELIVERED && status != WEBHOOK_DELIVERY_DEAD {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "unknown status %v", status)
+	}
+	if limit <= 0 {
+		limit = WEBHOOK_DELIVERY_DEFAULT_LIMIT
+	}
+	if limit > WEBHOOK_DELIVERY_MAX_LIMIT {
+		return nil, ValidationError(ERROR_CODE_VALIDATION, "limit must not be greater than %d", WEBHOOK_DELIVERY_MAX_LIMIT)
+	}
+	if _, parseError := uuid.Parse(subscriptionId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	subscriptionExists, subscriptionExistsError := webhookSubscriptionExists(ctx, db, subscriptionId)
+	if subscriptionExistsError != nil {
+		return nil, subscriptionExistsError
+	}
+	if !subscriptionExists {
+		return nil, NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+
+	deliveries, getDeliveriesError := getWebhookDeliveriesWhere(ctx, db, `subscriptionid=$1 AND ($2='' OR status=$2) ORDER BY deliveryid DESC LIMIT $3`,
+		subscriptionId, status, limit)
+	if getDeliveriesError != nil {
+		return nil, getDeliveriesError
+	}
+
+	attachAttemptsError := attachWebhookAttempts(ctx, db, deliveries)
+	if attachAttemptsError != nil {
+		return nil, attachAttemptsError
+	}
+
+	return &deliveries, nil
+}
+
+/*
+Implementation method for operators to send deliveries of a subscription again, e.g. dead deliveries after the receiver was down.
+a replayed delivery is pending with all attempts again, its event id stays the same. Returns the number of replayed deliveries
+*/
+func ReplayWebhookDeliveries(ctx context.Context, subscriptionId string, request WebhookReplayRequestImpl) (int64, error) {
+
+	validator := fieldValidator{}
+	if len(request.DeliveryIds) == 0 && request.Status == "" {
+		validator.add("deliveryIds", "either deliveryIds or status must be provided")
+	}
+	if request.Status != "" {
+		// only delivered or dead deliveries can be replayed
+		validator.oneOf("status", request.Status, WEBHOOK_DELIVERY_DELIVERED, WEBHOOK_DELIVERY_DEAD)
+	}
+	if validationError := validator.err(); validationError != nil {
+		return 0, validationError
+	}
+	if _, parseError := uuid.Parse(subscriptionId); parseError != nil {
+		return 0, NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+
+	// pending deliveries are sent anyway, replaying them would only reset their attempts
+	conditions := []string{"subscriptionid=$1", "status<>$2"}
+	arguments := []interface{}{subscriptionId, WEBHOOK_DELIVERY_PENDING}
+	if len(request.DeliveryIds) > 0 {
+		arguments = append(arguments, pq.Array(request.DeliveryIds))
+		conditions = append(conditions, fmt.Sprintf("deliveryid=ANY($%d)", len(arguments)))
+	}
+	if request.Status != "" {
+		arguments = append(arguments, request.Status)
+		conditions = append(conditions, fmt.Sprintf("status=$%d", len(arguments)))
+	}
+	if request.Since != nil {
+		arguments = append(arguments, *request.Since)
+		conditions = append(conditions, fmt.Sprintf("createdat>=$%d", len(arguments)))
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return 0, dbConnectError
+	}
+
+	subscriptionExists, subscriptionExistsError := webhookSubscriptionExists(ctx, db, subscriptionId)
+	if subscriptionExistsError != nil {
+		return 0, subscriptionExistsError
+	}
+	if !subscriptionExists {
+		return 0, NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+
+	arguments = append(arguments, time.Now())
+	result, dbUpdateError := db.ExecContext(ctx, fmt.Sprintf(`UPDATE `+DB_TABLE_WEBHOOKDELIVERY+` SET status='`+WEBHOOK_DELIVERY_PENDING+`', attemptcount=0,
+		nextattemptat=$%d, deliveredat=NULL WHERE `, len(arguments))+strings.Join(conditions, " AND ")+`;`, arguments...)
+	if dbUpdateError != nil {
+		return 0, fmt.Errorf("could not update records in %v Table. %w", DB_TABLE_WEBHOOKDELIVERY, dbUpdateError)
+	}
+
+	return result.RowsAffected()
+}
+
+/*
+Implementation method for operators to test a subscription. A webhook.ping event is delivered only to this subscription
+*/
+func PingWebhookSubscription(ctx context.Context, subscriptionId string, requestedBy string) (*WebhookDeliveryImpl, error) {
+
+	if _, parseError := uuid.Parse(subscriptionId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	subscriptions, getSubscriptionsError := getWebhookSubscriptionsWhere(ctx, db, `subscriptionid=$1`, subscriptionId)
+	if getSubscriptionsError != nil {
+		return nil, getSubscriptionsError
+	}
+	if len(subscriptions) == 0 {
+		return nil, NotFoundError(ERROR_CODE_WEBHOOK_NOT_FOUND, "webhook subscription %v does not exist", subscriptionId)
+	}
+	if !subscriptions[0].Active {
+		return nil, ConflictError(ERROR_CODE_WEBHOOK_INACTIVE, "webhook subscription %v is not active", subscriptionId)
+	}
+
+	eventId, payload, payloadError := newWebhookPayload(WEBHOOK_EVENT_PING, map[string]string{"subscriptionId": subscriptionId, "requestedBy": requestedBy})
+	if payloadError != nil {
+		return nil, payloadError
+	}
+
+	delivery := WebhookDeliveryImpl{
+		SubscriptionId: subscriptionId,
+		EventId:        eventId,
+		EventType:      WEBHOOK_EVENT_PING,
+		Status:         WEBHOOK_DELIVERY_PENDING,
+		CreatedAt:      time.Now(),
+		Attempts:       []WebhookAttemptImpl{},
+	}
+	delivery.NextAttemptAt = &delivery.CreatedAt
+
+	insertStatement := getInsertStmt(DB_TABLE_WEBHOOKDELIVERY, "subscriptionid", "eventid", "eventtype", "payload", "status", "nextattemptat", "createdat")
+	dbInsertError := db.QueryRowContext(ctx, insertStatement+` RETURNING deliveryid`, delivery.SubscriptionId, delivery.EventId, delivery.EventType, payload,
+		delivery.Status, delivery.NextAttemptAt, delivery.CreatedAt).Scan(&delivery.DeliveryId)
+	if dbInsertError != nil {
+		return nil, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_WEBHOOKDELIVERY, dbInsertError)
+	}
+
+	return &delivery, nil
+}
+
+/*
+Implementation method which sends the deliveries which are due once. Returns the number of attempts.
+the deliveries are claimed before they are sent, so a slow receiver does not hold a transaction open
+*/
+func RunWebhookDispatcher(ctx context.Context) (int, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return 0, dbConnectError
+	}
+
+	dispatches, claimError := claimWebhookDeliveries(ctx, db)
+	if claimError != nil {
+		return 0, claimError
+	}
+
+	client := &http.Client{
+		Timeout: WebhookTimeout(),
+		// a redirect counts as failure, the subscription has to be changed to the new url
+		CheckRedirect: func(request *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	for _, dispatch := range dispatches {
+		attempt := sendWebhook(ctx, client, dispatch)
+		// a delivery which was aborted because the dispatcher stops is not a failed attempt, it is sent again after the claim timeout
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("could not send webhook delivery %v. %w", dispatch.deliveryId, ctx.Err())
+		}
+		recordAttemptError := recordWebhookAttempt(ctx, db, dispatch, attempt)
+		if recordAttemptError != nil {
+			return 0, recordAttemptError
+		}
+	}
+
+	return len(dispatches), nil
+}
+
+/*
+starts the webhook dispatcher in the background, it sends the deliveries which are due once per interval.
+an interval of 0 disables the dispatcher. The returned function stops the dispatcher
+*/
+func StartWebhookDispatcher(interval time.Duration) func() {
+	return startPeriodicJob("webhook dispatcher", interval, func(ctx context.Context) {
+		attempts, runError := RunWebhookDispatcher(ctx)
+		if runError != nil {
+			fmt.Printf("WARNING! webhook dispatcher failed. %v\n", runError)
+			return
+		}
+		if attempts > 0 {
+			fmt.Printf("webhook dispatcher sent %d deliveries\n", attempts)
+		}
+	})
+}
+
+// returns the sink which queues the domain events for the webhook subscriptions
+func NewWebhookEventSink() DomainEventSink {
+	return webhookEventSink{}
+}
+
+func (sink webhookEventSink) Name() string {
+	return "webhooks"
+}
+
+// the event id of the domain event is the event id of the webhook event, so a receiver can ignore an event it received before
+func (sink webhookEventSink) Publish(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error {
+	webhookEventType, isWebhookEvent := webhookEventTypesOfDomainEvents[event.Type]
+	if !isWebhookEvent {
+		return nil
+	}
+	return enqueueWebhookEvent(ctx, tx, WebhookEventImpl{EventId: event.EventId, Type: webhookEventType, OccurredAt: event.OccurredAt.UTC(), Data: event.Payload})
+}
+
+/*
+queues an event for every active subscription which receives its event type. It is called by the webhook sink
+in the transaction of the outbox dispatcher, so the event is queued exactly once
+*/
+func enqueueWebhookEvent(ctx context.Context, tx dbQueryer, event WebhookEventImpl) error {
+	payload, marshalError := json.Marshal(event)
+	if marshalError != nil {
+		return fmt.Errorf("could not marshal webhook event %v. %w", event.Type, marshalError)
+	}
+
+	_, dbInsertError := tx.ExecContext(ctx, `INSERT INTO `+DB_TABLE_WEBHOOKDELIVERY+` (subscriptionid, eventid, eventtype, payload, status, nextattemptat, createdat)
+		SELECT subscriptionid, $1, $2, $3, $4, $5, $5 FROM `+DB_TABLE_WEBHOOKSUBSCRIPTION+` WHERE active AND (cardinality(eventtypes)=0 OR $2=ANY(eventtypes));`,
+		event.EventId, event.Type, string(payload), WEBHOOK_DELIVERY_PENDING, time.Now())
+	if dbInsertError != nil {
+		return fmt.Errorf("could not queue webhook event %v. %w", event.Type, dbInsertError)
+	}
+	return nil
+}
+
+// returns the id and the JSON payload of a new event
+func newWebhookPayload(eventType string, data interface{}) (string, string, error) {
+	event := WebhookEventImpl{EventId: uuid.New().String(), Type: eventType, OccurredAt: time.Now().UTC(), Data: data}
+	payload, marshalError := json.Marshal(event)
+	if marshalError != nil {
+		return "", "", fmt.Errorf("could not marshal webhook event %v. %w", eventType, marshalError)
+	}
+	return event.EventId, string(payload), nil
+}
+
+/*
+claims the deliveries which are due by moving their next attempt behind the claim timeout.
+if the API stops before the attempt is recorded, the delivery is sent again after the timeout
+*/
+func claimWebhookDeliveries(ctx context.Context, db dbQueryer) ([]webhookDispatch, error) {
+	now := time.Now()
+	rows, dbUpdateError := db.QueryContext(ctx, `UPDATE `+DB_TABLE_WEBHOOKDELIVERY+` SET nextattemptat=$1 FROM `+DB_TABLE_WEBHOOKSUBSCRIPTION+`
+		WHERE webhooksubscription.subscriptionid=webhookdelivery.subscriptionid AND webhookdelivery.deliveryid IN (
+			SELECT deliveryid FROM `+DB_TABLE_WEBHOOKDELIVERY+` JOIN `+DB_TABLE_WEBHOOKSUBSCRIPTION+` USING (subscriptionid)
+			WHERE status=$2 AND nextattemptat<=$3 AND active ORDER BY nextattemptat, deliveryid LIMIT $4 FOR UPDATE OF webhookdelivery SKIP LOCKED)
+		RETURNING webhookdelivery.deliveryid, webhookdelivery.eventtype, webhookdelivery.payload, webhookdelivery.attemptcount,
+			webhooksubscription.url, webhooksubscription.secret;`,
+		now.Add(WEBHOOK_CLAIM_TIMEOUT), WEBHOOK_DELIVERY_PENDING, now, WEBHOOK_DISPATCH_BATCH_SIZE)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not claim webhook deliveries. %w", dbUpdateError)
+	}
+	defer rows.Close()
+
+	dispatches := []webhookDispatch{}
+	for rows.Next() {
+		dispatch := webhookDispatch{}
+		scanError := rows.Scan(&dispatch.deliveryId, &dispatch.eventType, &dispatch.payload, &dispatch.attemptCount, &dispatch.url, &dispatch.secret)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v. %w", DB_TABLE_WEBHOOKDELIVERY, scanError)
+		}
+		dispatches = append(dispatches, dispatch)
+	}
+	return dispatches, nil
+}
+
+/*
+sends a delivery to its receiver and returns the attempt, a response with a 2xx status is a successful attempt.
+the request is aborted when the context is done, e.g. because the API shuts down
+*/
+func sendWebhook(ctx context.Context, client *http.Client, dispatch webhookDispatch) WebhookAttemptImpl {
+	attempt := WebhookAttemptImpl{DeliveryId: dispatch.deliveryId, AttemptedAt: time.Now()}
+
+	request, requestError := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.url, strings.NewReader(dispatch.payload))
+	if requestError != nil {
+		attempt.Error = truncateWebhookError(requestError.Error())
+		return attempt
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "eBikeApi-Webhooks")
+	request.Header.Set(WEBHOOK_EVENT_HEADER, dispatch.eventType)
+	request.Header.Set(WEBHOOK_DELIVERY_HEADER, fmt.Sprint(dispatch.deliveryId))
+	request.Header.Set(WEBHOOK_SIGNATURE_HEADER, signWebhookPayload(dispatch.secret, attempt.AttemptedAt.Unix(), []byte(dispatch.payload)))
+
+	response, sendError := client.Do(request)
+	attempt.DurationMillis = int(time.Since(attempt.AttemptedAt).Milliseconds())
+	if sendError != nil {
+		attempt.Error = truncateWebhookError(sendError.Error())
+		return attempt
+	}
+	defer response.Body.Close()
+
+	attempt.StatusCode = &response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, WEBHOOK_MAX_ERROR_LENGTH))
+		attempt.Error = truncateWebhookError(fmt.Sprintf("%v: %s", response.Status, bytes.TrimSpace(responseBody)))
+	}
+	return attempt
+}
+
+/*
+stores an attempt in the log and updates its delivery: a successful delivery is delivered, a failed delivery is retried
+after the backoff or it is dead after the maximal number of attempts
+*/
+func recordWebhookAttempt(ctx context.Context, db *sql.DB, dispatch webhookDispatch, attempt WebhookAttemptImpl) error {
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	insertStatement := getInsertStmt(DB_TABLE_WEBHOOKATTEMPT, "deliveryid", "attemptedat", "statuscode", "error", "durationmillis")
+	_, dbInsertError := tx.ExecContext(ctx, insertStatement, attempt.DeliveryId, attempt.AttemptedAt, attempt.StatusCode, attempt.Error, attempt.DurationMillis)
+	if dbInsertError != nil {
+		return fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_WEBHOOKATTEMPT, dbInsertError)
+	}
+
+	attemptCount := dispatch.attemptCount + 1
+	status := WEBHOOK_DELIVERY_PENDING
+	var nextAttemptAt, deliveredAt *time.Time
+	if attempt.StatusCode != nil && attempt.Error == "" {
+		status = WEBHOOK_DELIVERY_DELIVERED
+		deliveredAt = &attempt.AttemptedAt
+	} else if attemptCount >= WebhookMaxAttempts() {
+		status = WEBHOOK_DELIVERY_DEAD
+	} else {
+		retryAt := attempt.AttemptedAt.Add(webhookRetryDelay(attemptCount))
+		nextAttemptAt = &retryAt
+	}
+
+	_, dbUpdateError := tx.ExecContext(ctx, `UPDATE `+DB_TABLE_WEBHOOKDELIVERY+` SET status=$1, attemptcount=$2, nextattemptat=$3, laststatuscode=$4, lasterror=$5,
+		deliveredat=$6 WHERE deliveryid=$7;`, status, attemptCount, nextAttemptAt, attempt.StatusCode, attempt.Error, deliveredAt, attempt.DeliveryId)
+	if dbUpdateError != nil {
+		return fmt.Errorf("could not update record in %v Table. %w", DB_TABLE_WEBHOOKDELIVERY, dbUpdateError)
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return fmt.Errorf("could not record webhook attempt. %w", commitError)
+	}
+	return nil
+}
+
+// returns the time to wait after the failed attempt, it doubles with every attempt
+func webhookRetryDelay(attemptCount int) time.Duration {
+	delay := WEBHOOK_RETRY_BASE_DELAY
+	for attempt := 1; attempt < attemptCount && delay < WEBHOOK_RETRY_MAX_DELAY; attempt++ {
+		delay *= 2
+	}
+	if delay > WEBHOOK_RETRY_MAX_DELAY {
+		return WEBHOOK_RETRY_MAX_DELAY
+	}
+	return delay
+}
+
+/*
+returns the value of the signature header. The timestamp is signed with the payload, so a receiver can reject old requests
+which are sent again by someone else
+*/
+func signWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return fmt.Sprintf("t=%d,v1=%v", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// shortens an error to the length which is stored
+func truncateWebhookError(errorText string) string {
+	if len(errorText) > WEBHOOK_MAX_ERROR_LENGTH {
+		return errorText[:WEBHOOK_MAX_ERROR_LENGTH]
+	}
+	return errorText
+}
+
+// verifies that the url can receive webhooks and that the event types are known
+func validateWebhookSubscriptionRequest(request WebhookSubscriptionRequestImpl) error {
+	validator := fieldValidator{}
+	if validator.required("url", request.Url != "") {
+		validator.maxLength("url", request.Url, WEBHOOK_MAX_URL_LENGTH)
+		parsedUrl, parseError := url.Parse(request.Url)
+		if parseError != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+			validator.add("url", "%v must be an absolute http or https url", request.Url)
+		}
+	}
+	for index, eventType := range request.EventTypes {
+		if !knownWebhookEventTypes[eventType] {
+			validator.add(fmt.Sprintf("eventTypes[%d]", index), "unknown event type %v", eventType)
+		}
+	}
+	validator.maxLength("description", request.Description, MAX_NOTE_LENGTH)
+	return validator.err()
+}
+
+// returns true if the subscription exists
+func webhookSubscriptionExists(ctx context.Context, db dbQueryer, subscriptionId string) (bool, error) {
+	var subscriptionExists bool
+	dbQueryError := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+DB_TABLE_WEBHOOKSUBSCRIPTION+` WHERE subscriptionid=$1);`, subscriptionId).Scan(&subscriptionExists)
+	if dbQueryError != nil {
+		return false, fmt.Errorf("could not retrieve webhook subscription %v. %w", subscriptionId, dbQueryError)
+	}
+	return subscriptionExists, nil
+}
+
+// returns the subscriptions which match the condition, e.g. `subscriptionid=$1`
+func getWebhookSubscriptionsWhere(ctx context.Context, db dbQueryer, condition string, arguments ...interface{}) ([]WebhookSubscriptionImpl, error) {
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+webhookSubscriptionColumns+` FROM `+DB_TABLE_WEBHOOKSUBSCRIPTION+` WHERE `+condition+`;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_WEBHOOKSUBSCRIPTION, dbQueryError)
+	}
+	defer rows.Close()
+
+	subscriptions := []WebhookSubscriptionImpl{}
+	for rows.Next() {
+		subscription, scanError := scanWebhookSubscription(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+	return subscriptions, nil
+}
+
+// scans a row of the webhooksubscription table, the columns are selected with webhookSubscriptionColumns
+func scanWebhookSubscription(rows *sql.Rows) (*WebhookSubscriptionImpl, error) {
+	subscription := WebhookSubscriptionImpl{}
+	var updatedAt sql.NullTime
+	scanError := rows.Scan(&subscription.SubscriptionId, &subscription.Url, pq.Array(&subscription.EventTypes), &subscription.Description,
+		&subscription.Active, &subscription.CreatedBy, &subscription.CreatedAt, &updatedAt)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into WebhookSubscription Object. %w", DB_TABLE_WEBHOOKSUBSCRIPTION, scanError)
+	}
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+	if updatedAt.Valid {
+		subscription.UpdatedAt = &updatedAt.Time
+	}
+	return &subscription, nil
+}
+
+// returns the deliveries which match the condition, without their attempts
+func getWebhookDeliveriesWhere(ctx context.Context, db dbQueryer, condition string, arguments ...interface{}) ([]WebhookDeliveryImpl, error) {
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+webhookDeliveryColumns+` FROM `+DB_TABLE_WEBHOOKDELIVERY+` WHERE `+condition+`;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_WEBHOOKDELIVERY, dbQueryError)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDeliveryImpl{}
+	for rows.Next() {
+		delivery, scanError := scanWebhookDelivery(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, nil
+}
+
+// adds the attempts to the deliveries, the first attempt first
+func attachWebhookAttempts(ctx context.Context, db dbQueryer, deliveries []WebhookDeliveryImpl) error {
+	deliveryIds := make([]int64, len(deliveries))
+	deliveryIndex := map[int64]int{}
+	for index, delivery := range deliveries {
+		deliveryIds[index] = delivery.DeliveryId
+		deliveryIndex[delivery.DeliveryId] = index
+		deliveries[index].Attempts = []WebhookAttemptImpl{}
+	}
+
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT attemptid, deliveryid, attemptedat, statuscode, error, durationmillis FROM `+DB_TABLE_WEBHOOKATTEMPT+`
+		WHERE deliveryid=ANY($1) ORDER BY attemptid;`, pq.Array(deliveryIds))
+	if dbQueryError != nil {
+		return fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_WEBHOOKATTEMPT, dbQueryError)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attempt := WebhookAttemptImpl{}
+		var statusCode sql.NullInt64
+		scanError := rows.Scan(&attempt.AttemptId, &attempt.DeliveryId, &attempt.AttemptedAt, &statusCode, &attempt.Error, &attempt.DurationMillis)
+		if scanError != nil {
+			return fmt.Errorf("error scanning fields. could not scan rows of %v into WebhookAttempt Object. %w", DB_TABLE_WEBHOOKATTEMPT, scanError)
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			attempt.StatusCode = &code
+		}
+		index := deliveryIndex[attempt.DeliveryId]
+		deliveries[index].Attempts = append(deliveries[index].Attempts, attempt)
+	}
+	return nil
+}
+
+// scans a row of the webhookdelivery table, the columns are selected with webhookDeliveryColumns
+func scanWebhookDelivery(rows *sql.Rows) (*WebhookDeliveryImpl, error) {
+	delivery := WebhookDeliveryImpl{}
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var lastStatusCode sql.NullInt64
+	scanError := rows.Scan(&delivery.DeliveryId, &delivery.SubscriptionId, &delivery.EventId, &delivery.EventType, &delivery.Status,
+		&delivery.AttemptCount, &nextAttemptAt, &lastStatusCode, &delivery.LastError, &delivery.CreatedAt, &deliveredAt)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into WebhookDelivery Object. %w", DB_TABLE_WEBHOOKDELIVERY, scanError)
+	}
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastStatusCode.Valid {
+		code := int(lastStatusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
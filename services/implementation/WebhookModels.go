@@ -0,0 +1,101 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- types of the events which are sent to webhooks ---------
+	WEBHOOK_EVENT_RIDE_STARTED        = "ride.started"
+	WEBHOOK_EVENT_RIDE_FINISHED       = "ride.finished"
+	WEBHOOK_EVENT_BIKE_STATUS_CHANGED = "bike.status_changed"
+	WEBHOOK_EVENT_THEFT_ALERT_RAISED  = "theft_alert.raised"
+	// only sent on request of an operator to test a subscription, it is delivered regardless of the event types
+	WEBHOOK_EVENT_PING = "webhook.ping"
+
+	// ---------- status of webhook deliveries ---------
+	// the delivery waits for its next attempt
+	WEBHOOK_DELIVERY_PENDING   = "pending"
+	WEBHOOK_DELIVERY_DELIVERED = "delivered"
+	// all attempts failed, the delivery is only sent again if it is replayed
+	WEBHOOK_DELIVERY_DEAD = "dead"
+)
+
+/*
+represents the database structure for the table "webhooksubscription" in the DATABASE.
+a partner receives the events of the event types at the url, an empty list of event types receives all events.
+the payloads are signed with the secret, which is only returned when the subscription is created
+*/
+type WebhookSubscriptionImpl struct {
+	SubscriptionId string     `json:"subscriptionId"`
+	Url            string     `json:"url"`
+	EventTypes     []string   `json:"eventTypes"`
+	Description    string     `json:"description"`
+	Active         bool       `json:"active"`
+	Secret         string     `json:"secret,omitempty"`
+	CreatedBy      string     `json:"createdBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      *time.Time `json:"updatedAt"`
+}
+
+/*
+represents the request of an operator to create or change a webhook subscription.
+Active is optional, a new subscription is active by default
+*/
+type WebhookSubscriptionRequestImpl struct {
+	Url         string   `json:"url"`
+	EventTypes  []string `json:"eventTypes"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+/*
+represents the payload which is sent to a webhook. The eventId is the same for every attempt and every subscription,
+so a receiver can ignore an event which it received before
+*/
+type WebhookEventImpl struct {
+	EventId    string      `json:"eventId"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+/*
+represents the database structure for the table "webhookdelivery" in the DATABASE.
+every event is delivered once to every subscription which receives its event type. A failed attempt is retried
+with an exponential backoff until the maximal number of attempts, then the delivery is dead
+*/
+type WebhookDeliveryImpl struct {
+	DeliveryId     int64                `json:"deliveryId"`
+	SubscriptionId string               `json:"subscriptionId"`
+	EventId        string               `json:"eventId"`
+	EventType      string               `json:"eventType"`
+	Status         string               `json:"status"`
+	AttemptCount   int                  `json:"attemptCount"`
+	NextAttemptAt  *time.Time           `json:"nextAttemptAt"`
+	LastStatusCode *int                 `json:"lastStatusCode"`
+	LastError      string               `json:"lastError"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	DeliveredAt    *time.Time           `json:"deliveredAt"`
+	Attempts       []WebhookAttemptImpl `json:"attempts"`
+}
+
+/*
+represents the database structure for the table "webhookattempt" in the DATABASE, the log of the attempts of a delivery
+*/
+type WebhookAttemptImpl struct {
+	AttemptId      int64     `json:"attemptId"`
+	DeliveryId     int64     `json:"deliveryId"`
+	AttemptedAt    time.Time `json:"attemptedAt"`
+	StatusCode     *int      `json:"statusCode"` // null if the receiver could not be reached
+	Error          string    `json:"error"`
+	DurationMillis int       `json:"durationMillis"`
+}
+
+/*
+represents the request of an operator to send deliveries of a subscription again, e.g. after the receiver was down.
+either the deliveries with the ids or all deliveries with the status are replayed, optionally only those created since a time
+*/
+type WebhookReplayRequestImpl struct {
+	DeliveryIds []int64    `json:"deliveryIds"`
+	Status      string     `json:"status"`
+	Since       *time.Time `json:"since"`
+}
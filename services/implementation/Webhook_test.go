@@ -0,0 +1,106 @@
+package implementation
+
+import (
+	"context"
+	"eBikeApi/services/fakedb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookIsAbortedWhenDispatcherStops(t *testing.T) {
+	// a receiver which does not answer
+	receiverDone := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-receiverDone:
+		}
+	}))
+	defer receiver.Close()
+	defer close(receiverDone)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	client := &http.Client{Timeout: time.Minute}
+	dispatch := webhookDispatch{deliveryId: 1, eventType: WEBHOOK_EVENT_BIKE_STATUS_CHANGED, payload: `{}`, url: receiver.URL, secret: "secret"}
+
+	result := make(chan WebhookAttemptImpl, 1)
+	go func() {
+		result <- sendWebhook(ctx, client, dispatch)
+	}()
+	select {
+	case attempt := <-result:
+		if attempt.StatusCode != nil || !strings.Contains(attempt.Error, context.Canceled.Error()) {
+			t.Fatalf("expected the attempt to be cancelled, got status %v and error %q", attempt.StatusCode, attempt.Error)
+		}
+	case <-time.After(testAbortWait):
+		t.Fatalf("the webhook was not aborted within %v", testAbortWait)
+	}
+}
+
+func TestWebhookSignatureMatchesKnownVector(t *testing.T) {
+	// computed independently with HMAC-SHA256 of "1760000000.{"eventId":"evt-1"}" and the secret whsec_test
+	expected := "t=1760000000,v1=2a3f9b0fb2502a84bda2b359e311eb4c6b23a6afc2938e21e6d765f471529ae9"
+	if signature := signWebhookPayload("whsec_test", 1760000000, []byte(`{"eventId":"evt-1"}`)); signature != expected {
+		t.Fatalf("expected the signature %v, got %v", expected, signature)
+	}
+}
+
+// records an attempt of a delivery with the given number of earlier attempts and returns the arguments of the update of the delivery
+func recordTestWebhookAttempt(t *testing.T, attemptCount int, attempt WebhookAttemptImpl) []interface{} {
+	t.Helper()
+	var updateArgs []interface{}
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		if strings.HasPrefix(statement.Query, "UPDATE "+DB_TABLE_WEBHOOKDELIVERY) {
+			for _, arg := range statement.Args {
+				updateArgs = append(updateArgs, arg)
+			}
+		}
+		return &fakedb.Result{RowsAffected: 1}, nil
+	})
+	db, _ := SetupDB()
+
+	dispatch := webhookDispatch{deliveryId: 1, attemptCount: attemptCount}
+	if recordError := recordWebhookAttempt(context.Background(), db, dispatch, attempt); recordError != nil {
+		t.Fatalf("expected the attempt to be recorded, got %v", recordError)
+	}
+	if len(updateArgs) == 0 {
+		t.Fatalf("expected the delivery to be updated")
+	}
+	return updateArgs
+}
+
+func TestFailedWebhookAttemptsBackOffUntilDeliveryIsDead(t *testing.T) {
+	t.Setenv(ENV_WEBHOOK_MAX_ATTEMPTS, "8")
+	attemptedAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	statusCode := http.StatusServiceUnavailable
+	failedAttempt := WebhookAttemptImpl{DeliveryId: 1, AttemptedAt: attemptedAt, StatusCode: &statusCode, Error: "receiver responded with 503"}
+
+	expectedDelays := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute}
+	for earlierAttempts, expectedDelay := range expectedDelays {
+		updateArgs := recordTestWebhookAttempt(t, earlierAttempts, failedAttempt)
+		nextAttemptAt, isScheduled := updateArgs[2].(time.Time)
+		if updateArgs[0] != WEBHOOK_DELIVERY_PENDING || updateArgs[1] != int64(earlierAttempts+1) || !isScheduled || !nextAttemptAt.Equal(attemptedAt.Add(expectedDelay)) {
+			t.Fatalf("expected attempt %v to be retried after %v, got %v", earlierAttempts+1, expectedDelay, updateArgs[:3])
+		}
+	}
+
+	updateArgs := recordTestWebhookAttempt(t, 7, failedAttempt)
+	if updateArgs[0] != WEBHOOK_DELIVERY_DEAD || updateArgs[1] != int64(8) || updateArgs[2] != nil {
+		t.Fatalf("expected the delivery to be dead after 8 attempts, got %v", updateArgs[:3])
+	}
+
+	okStatusCode := http.StatusOK
+	updateArgs = recordTestWebhookAttempt(t, 7, WebhookAttemptImpl{DeliveryId: 1, AttemptedAt: attemptedAt, StatusCode: &okStatusCode})
+	if updateArgs[0] != WEBHOOK_DELIVERY_DELIVERED {
+		t.Fatalf("expected the last attempt to deliver, got %v", updateArgs[0])
+	}
+
+	if delay := webhookRetryDelay(20); delay != WEBHOOK_RETRY_MAX_DELAY {
+		t.Fatalf("expected the delay to stop at %v, got %v", WEBHOOK_RETRY_MAX_DELAY, delay)
+	}
+}
@@ -0,0 +1,630 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DB_TABLE_WORKORDER     = "workorder"
+	DB_TABLE_WORKORDERNOTE = "workordernote"
+
+	// maximal length of the title of a work order
+	WORK_ORDER_MAX_TITLE_LENGTH = 200
+)
+
+// the columns of the workorder table in the order they are scanned by scanWorkOrder. The labour minutes are summed up from the notes
+const workOrderColumns = `workorder.orderid, workorder.bikeid, workorder.source, workorder.damagereportid, workorder.planid, workorder.title,
+	workorder.description, workorder.status, workorder.assignedto, workorder.createdby, workorder.createdat, workorder.updatedat, workorder.closedat,
+	(SELECT coalesce(sum(workordernote.labourminutes), 0) FROM workordernote WHERE workordernote.orderid=workorder.orderid)`
+
+// the columns of the workordernote table in the order they are scanned by getWorkOrderNotes
+const workOrderNoteColumns = `noteid, orderid, kind, text, labourminutes, author, createdat`
+
+// the status which a work order can change to from a status. A done order is closed
+var allowedWorkOrderTransitions = map[string][]string{
+	WORK_ORDER_OPEN:        {WORK_ORDER_IN_PROGRESS, WORK_ORDER_BLOCKED, WORK_ORDER_DONE},
+	WORK_ORDER_IN_PROGRESS: {WORK_ORDER_OPEN, WORK_ORDER_BLOCKED, WORK_ORDER_DONE},
+	WORK_ORDER_BLOCKED:     {WORK_ORDER_OPEN, WORK_ORDER_IN_PROGRESS, WORK_ORDER_DONE},
+	WORK_ORDER_DONE:        {},
+}
+
+/*
+Implementation method for operators to create a work order manually or from a damage report.
+the bike is moved into maintenance, unless it is rented or can not change into maintenance
+*/
+func CreateWorkOrder(ctx context.Context, request WorkOrderRequestImpl, createdBy string) (*WorkOrderImpl, error) {
+
+	// the bike and the title of an order from a damage report are taken from the report
+	validator := fieldValidator{}
+	if request.DamageReportId == "" {
+		validator.required("bikeId", request.BikeId != nil)
+		validator.requiredText("title", request.Title, WORK_ORDER_MAX_TITLE_LENGTH)
+	} else {
+		validator.maxLength("title", request.Title, WORK_ORDER_MAX_TITLE_LENGTH)
+	}
+	validator.maxLength("description", request.Description, MAX_TEXT_LENGTH)
+	validator.maxLength("assignedTo", request.AssignedTo, MAX_USERNAME_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	order := WorkOrderImpl{
+		Source:      WORK_ORDER_SOURCE_MANUAL,
+		Title:       request.Title,
+		Description: request.Description,
+		CreatedBy:   createdBy,
+	}
+	if request.BikeId != nil {
+		order.BikeId = *request.BikeId
+	}
+
+	// an order from a damage report belongs to the bike of the report and is described by the report, if no title is provided
+	if request.DamageReportId != "" {
+		reports, getReportsError := getDamageReportsWhere(ctx, tx, "damagereport.reportid=$1", request.DamageReportId)
+		if getReportsError != nil {
+			return nil, getReportsError
+		}
+		if len(reports) == 0 {
+			return nil, NotFoundError(ERROR_CODE_DAMAGE_REPORT_NOT_FOUND, "damage report %v does not exist", request.DamageReportId)
+		}
+		report := reports[0]
+		if request.BikeId != nil && order.BikeId != report.BikeId {
+			return nil, ValidationError(ERROR_CODE_VALIDATION, "damage report %v belongs to bike %v, not to bike %v", report.ReportId, report.BikeId, order.BikeId)
+		}
+		order.BikeId = report.BikeId
+		order.Source = WORK_ORDER_SOURCE_DAMAGE_REPORT
+		order.DamageReportId = &report.ReportId
+		if order.Title == "" {
+			order.Title = "Damage report: " + report.Category
+		}
+		if order.Description == "" {
+			order.Description = report.Description
+		}
+	}
+
+	bikeIdExistsInBikeTable, bikeIdExistsInDbError := bikeIdExistsInTable(ctx, db, DB_TABLE_BIKE, order.BikeId)
+	if bikeIdExistsInDbError != nil {
+		return nil, bikeIdExistsInDbError
+	}
+	if !bikeIdExistsInBikeTable {
+		return nil, NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "provided bikeId does not exist in database")
+	}
+
+	if request.AssignedTo != "" {
+		assigneeError := validateWorkOrderAssignee(ctx, tx, request.AssignedTo)
+		if assigneeError != nil {
+			return nil, assigneeError
+		}
+		order.AssignedTo = &request.AssignedTo
+	}
+
+	createError := createWorkOrder(ctx, tx, &order)
+	if createError != nil {
+		return nil, createError
+	}
+
+	movedToMaintenance, maintenanceError := moveBikeToMaintenance(ctx, tx, order.BikeId, fmt.Sprintf("work order %d: %v", order.OrderId, order.Title), createdBy)
+	if maintenanceError != nil {
+		return nil, maintenanceError
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not create work order. %w", commitError)
+	}
+
+	if movedToMaintenance {
+		publishBikeEvent(ctx, db, BIKE_EVENT_UPDATED, order.BikeId)
+	}
+
+	return &order, nil
+}
+
+/*
+Implementation method to list work orders by bike, assignee and status, the latest order first
+*/
+func GetWorkOrders(ctx context.Context, filter WorkOrderFilterImpl) (*[]WorkOrderImpl, error) {
+
+	if filter.Status != "" {
+		if _, isKnownStatus := allowedWorkOrderTransitions[filter.Status]; !isKnownStatus {
+			return nil, ValidationError(ERROR_CODE_VALIDATION, "unknown status %v", filter.Status)
+		}
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	conditions := []string{"true"}
+	arguments := []interface{}{}
+	if filter.BikeId != nil {
+		arguments = append(arguments, *filter.BikeId)
+		conditions = append(conditions, fmt.Sprintf("workorder.bikeid=$%d", len(arguments)))
+	}
+	if filter.AssignedTo != "" {
+		arguments = append(arguments, filter.AssignedTo)
+		conditions = append(conditions, fmt.Sprintf("workorder.assignedto=$%d", len(arguments)))
+	}
+	if filter.Status != "" {
+		arguments = append(arguments, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("workorder.status=$%d", len(arguments)))
+	}
+
+	orders, getOrdersError := getWorkOrdersWhere(ctx, db, strings.Join(conditions, " AND ")+" ORDER BY workorder.createdat DESC, workorder.orderid DESC", arguments...)
+	if getOrdersError != nil {
+		return nil, getOrdersError
+	}
+
+	return &orders, nil
+}
+
+/*
+Implementation method to retrieve a work order with its notes
+*/
+func GetWorkOrder(ctx context.Context, orderId int64) (*WorkOrderImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	order, getOrderError := getWorkOrderFromDb(ctx, db, orderId, false)
+	if getOrderError != nil {
+		return nil, getOrderError
+	}
+
+	notes, getNotesError := getWorkOrderNotes(ctx, db, orderId)
+	if getNotesError != nil {
+		return nil, getNotesError
+	}
+	order.Notes = notes
+
+	return order, nil
+}
+
+/*
+Implementation method for operators to change the status and the assignment of a work order.
+when the last unfinished order of a bike is done, the bike returns to available and the damage report of the order is resolved
+*/
+func UpdateWorkOrder(ctx context.Context, orderId int64, update WorkOrderUpdateImpl, updatedBy string) (*WorkOrderImpl, error) {
+
+	validator := fieldValidator{}
+	if update.Status == "" && update.AssignedTo == "" {
+		validator.add("status", "either status or assignedTo must be provided")
+	}
+	if update.Status != "" {
+		if _, isKnownStatus := allowedWorkOrderTransitions[update.Status]; !isKnownStatus {
+			validator.add("status", "unknown status %v", update.Status)
+		}
+	}
+	validator.maxLength("assignedTo", update.AssignedTo, MAX_USERNAME_LENGTH)
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	order, getOrderError := getWorkOrderFromDb(ctx, tx, orderId, true)
+	if getOrderError != nil {
+		return nil, getOrderError
+	}
+	if order.Status == WORK_ORDER_DONE {
+		return nil, ConflictError(ERROR_CODE_WORK_ORDER_DONE, "work order %d is done and can not be changed", orderId)
+	}
+
+	now := time.Now()
+	order.UpdatedAt = now
+
+	if update.AssignedTo != "" {
+		assigneeError := validateWorkOrderAssignee(ctx, tx, update.AssignedTo)
+		if assigneeError != nil {
+			return nil, assigneeError
+		}
+		order.AssignedTo = &update.AssignedTo
+	}
+
+	if update.Status != "" && update.Status != order.Status {
+		if !workOrderTransitionAllowed(order.Status, update.Status) {
+			return nil, ConflictError(ERROR_CODE_STATUS_TRANSITION_NOT_ALLOWED, "the status of work order %d can not change from %v to %v", orderId, order.Status, update.Status)
+		}
+		order.Status = update.Status
+		if order.Status == WORK_ORDER_DONE {
+			order.ClosedAt = &now
+		}
+	}
+
+	updateStatement := `UPDATE ` + DB_TABLE_WORKORDER + ` SET status=$1, assignedto=$2, updatedat=$3, closedat=$4 WHERE orderid=$5;`
+	_, dbUpdateError := tx.ExecContext(ctx, updateStatement, order.Status, order.AssignedTo, order.UpdatedAt, order.ClosedAt, orderId)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not update work order %d. %w", orderId, dbUpdateError)
+	}
+
+	if order.Status == WORK_ORDER_DONE {
+		closeError := closeWorkOrder(ctx, tx, order, updatedBy)
+		if closeError != nil {
+			return nil, closeError
+		}
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not update work order. %w", commitError)
+	}
+
+	// closing the order returns the bike into operation
+	if order.Status == WORK_ORDER_DONE {
+		publishBikeEvent(ctx, db, BIKE_EVENT_UPDATED, order.BikeId)
+	}
+
+	return order, nil
+}
+
+/*
+Implementation method to add a note about the parts or the labour of a work order.
+notes can not be added to done orders
+*/
+func AddWorkOrderNote(ctx context.Context, orderId int64, note WorkOrderNoteImpl, author string) (*WorkOrderNoteImpl, error) {
+
+	validator := fieldValidator{}
+	validator.oneOf("kind", note.Kind, WORK_ORDER_NOTE_PARTS, WORK_ORDER_NOTE_LABOUR, WORK_ORDER_NOTE_COMMENT)
+	validator.requiredText("text", note.Text, MAX_TEXT_LENGTH)
+	validator.atLeast("labourMinutes", note.LabourMinutes, 0)
+	if note.LabourMinutes > 0 && note.Kind != WORK_ORDER_NOTE_LABOUR {
+		validator.add("labourMinutes", "can only be recorded with a %v note", WORK_ORDER_NOTE_LABOUR)
+	}
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	order, getOrderError := getWorkOrderFromDb(ctx, tx, orderId, true)
+	if getOrderError != nil {
+		return nil, getOrderError
+	}
+	if order.Status == WORK_ORDER_DONE {
+		return nil, ConflictError(ERROR_CODE_WORK_ORDER_DONE, "work order %d is done, notes can not be added", orderId)
+	}
+
+	note.OrderId = orderId
+	note.Author = author
+	note.CreatedAt = time.Now()
+
+	insertStatement := getInsertStmt(DB_TABLE_WORKORDERNOTE, "orderid", "kind", "text", "labourminutes", "author", "createdat")
+	dbInsertError := tx.QueryRowContext(ctx, insertStatement+` RETURNING noteid`, note.OrderId, note.Kind, note.Text, note.LabourMinutes, note.Author,
+		note.CreatedAt).Scan(&note.NoteId)
+	if dbInsertError != nil {
+		return nil, fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_WORKORDERNOTE, dbInsertError)
+	}
+
+	_, dbUpdateError := tx.ExecContext(ctx, `UPDATE `+DB_TABLE_WORKORDER+` SET updatedat=$1 WHERE orderid=$2;`, note.CreatedAt, orderId)
+	if dbUpdateError != nil {
+		return nil, fmt.Errorf("could not update work order %d. %w", orderId, dbUpdateError)
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not add note. %w", commitError)
+	}
+
+	return &note, nil
+}
+
+// inserts a work order and sets its orderId
+func createWorkOrder(ctx context.Context, tx dbQueryer, order *WorkOrderImpl) error {
+
+	order.Status = WORK_ORDER_OPEN
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = order.CreatedAt
+
+	insertStatement := getInsertStmt(DB_TABLE_WORKORDER, "bikeid", "source", "damagereportid", "planid", "title", "description", "status",
+		"assignedto", "createdby", "createdat", "updatedat")
+	dbInsertError := tx.QueryRowContext(ctx, insertStatement+` RETURNING orderid`, order.BikeId, order.Source, order.DamageReportId, order.PlanId, order.Title,
+		order.Description, order.Status, order.AssignedTo, order.CreatedBy, order.CreatedAt, order.UpdatedAt).Scan(&order.OrderId)
+	if dbInsertError != nil {
+		return fmt.Errorf("could not insert record into %v Table. %w", DB_TABLE_WORKORDER, dbInsertError)
+	}
+	return nil
+}
+
+// creates a work order for a damage report which moved a bike into maintenance
+func createDamageWorkOrder(ctx context.Context, tx dbQueryer, report DamageReportImpl, createdBy string) error {
+	order := WorkOrderImpl{
+		BikeId:         report.BikeId,
+		Source:         WORK_ORDER_SOURCE_DAMAGE_REPORT,
+		DamageReportId: &report.ReportId,
+		Title:          "Damage report: " + report.Category,
+		Description:    report.Description,
+		CreatedBy:      createdBy,
+	}
+	return createWorkOrder(ctx, tx, &order)
+}
+
+/*
+subscriber of the bike.status_changed events, it creates a work order to charge or swap the battery of a bike which changed
+to low_battery, e.g. by telemetry, after a ride or by an operator
+*/
+func CreateLowBatteryWorkOrders(ctx context.Context, tx *sql.Tx, event DomainEventImpl) error {
+	var change BikeStatusChangeImpl
+	unmarshalError := json.Unmarshal(event.Payload, &change)
+	if unmarshalError != nil {
+		fmt.Printf("WARNING! could not read domain event %v, no low battery work order is created. %v\n", event.Sequence, unmarshalError)
+		return nil
+	}
+	if change.ToStatus != BIKE_STATUS_LOW_BATTERY {
+		return nil
+	}
+
+	bike, getBikeError := getBikeFromDb(ctx, tx, change.BikeId)
+	if getBikeError != nil {
+		return getBikeError
+	}
+	// the bike was deleted or charged before the event was published
+	if bike.BikeId == 0 || bike.Status != BIKE_STATUS_LOW_BATTERY {
+		return nil
+	}
+	return createLowBatteryWorkOrder(ctx, tx, bike.BikeId, bike.BatteryPercent.Int64)
+}
+
+/*
+creates a work order to charge or swap the battery of a bike which changed to low_battery.
+no order is created if the bike already has an unfinished low battery order
+*/
+func createLowBatteryWorkOrder(ctx context.Context, tx dbQueryer, bikeId int, batteryPercent int64) error {
+
+	var unfinishedOrders int
+	dbCountError := tx.QueryRowContext(ctx, `SELECT count(*) FROM `+DB_TABLE_WORKORDER+` WHERE bikeid=$1 AND source=$2 AND status<>$3;`,
+		bikeId, WORK_ORDER_SOURCE_LOW_BATTERY, WORK_ORDER_DONE).Scan(&unfinishedOrders)
+	if dbCountError != nil {
+		return fmt.Errorf("could not count work orders of bike %v. %w", bikeId, dbCountError)
+	}
+	if unfinishedOrders > 0 {
+		return nil
+	}
+
+	order := WorkOrderImpl{
+		BikeId:      bikeId,
+		Source:      WORK_ORDER_SOURCE_LOW_BATTERY,
+		Title:       "Charge or swap battery",
+		Description: fmt.Sprintf("battery at %d%%", batteryPercent),
+		CreatedBy:   BIKE_STATUS_CHANGED_BY_SYSTEM,
+	}
+	return createWorkOrder(ctx, tx, &order)
+}
+
+/*
+finishes a done work order: its damage report is resolved and, if it was the last unfinished order of the bike,
+the bike returns to available. A bike which still has a low battery changes to low_battery instead
+*/
+func closeWorkOrder(ctx context.Context, tx *sql.Tx, order *WorkOrderImpl, closedBy string) error {
+
+	if order.DamageReportId != nil {
+		_, dbUpdateError := tx.ExecContext(ctx, `UPDATE `+DB_TABLE_DAMAGEREPORT+` SET status=$1, triagedby=coalesce(triagedby, $2), triagedat=coalesce(triagedat, $3)
+			WHERE reportid=$4 AND status IN ($5, $6);`, DAMAGE_REPORT_RESOLVED, closedBy, order.ClosedAt, *order.DamageReportId, DAMAGE_REPORT_OPEN, DAMAGE_REPORT_CONFIRMED)
+		if dbUpdateError != nil {
+			return fmt.Errorf("could not resolve damage report %v. %w", *order.DamageReportId, dbUpdateError)
+		}
+	}
+
+	// a preventive order restarts the intervals of its maintenance plan
+	if order.PlanId != nil {
+		recordServiceError := recordMaintenanceService(ctx, tx, order.BikeId, *order.PlanId, *order.ClosedAt)
+		if recordServiceError != nil {
+			return recordServiceError
+		}
+	}
+
+	// the bike is locked before its orders are counted: when the last orders of a bike are closed at the same time,
+	// the second transaction waits for the first one and counts its order as done
+	rows, dbQueryError := tx.QueryContext(ctx, `SELECT `+bikeColumns+` FROM `+DB_TABLE_BIKE+` WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$1 FOR UPDATE;`, order.BikeId)
+	if dbQueryError != nil {
+		return fmt.Errorf("could not retrieve bike %v. %w", order.BikeId, dbQueryError)
+	}
+	if !rows.Next() {
+		rows.Close()
+		return NotFoundError(ERROR_CODE_BIKE_NOT_FOUND, "bike %v does not exist", order.BikeId)
+	}
+	bike, scanError := scanBike(rows)
+	rows.Close()
+	if scanError != nil {
+		return scanError
+	}
+
+	var unfinishedOrders int
+	dbCountError := tx.QueryRowContext(ctx, `SELECT count(*) FROM `+DB_TABLE_WORKORDER+` WHERE bikeid=$1 AND status<>$2;`, order.BikeId, WORK_ORDER_DONE).Scan(&unfinishedOrders)
+	if dbCountError != nil {
+		return fmt.Errorf("could not count work orders of bike %v. %w", order.BikeId, dbCountError)
+	}
+	if unfinishedOrders > 0 {
+		return nil
+	}
+
+	batteryIsLow := bike.BatteryPercent.Valid && bike.BatteryPercent.Int64 < int64(BatteryCriticalPercent())
+	newStatus := bike.Status
+	switch {
+	case bike.Status == BIKE_STATUS_MAINTENANCE && batteryIsLow:
+		newStatus = BIKE_STATUS_LOW_BATTERY
+	case bike.Status == BIKE_STATUS_MAINTENANCE, bike.Status == BIKE_STATUS_LOW_BATTERY && !batteryIsLow:
+		newStatus = BIKE_STATUS_AVAILABLE
+	}
+	if newStatus == bike.Status {
+		return nil
+	}
+
+	_, transitionError := transitionBikeStatus(ctx, tx, order.BikeId, bike.Status, newStatus, fmt.Sprintf("work order %d done", order.OrderId), closedBy)
+	if transitionError != nil {
+		return transitionError
+	}
+	order.BikeStatus = newStatus
+	return nil
+}
+
+/*
+moves a bike into maintenance, e.g. because of damage reports or a work order.
+a bike which can not change into maintenance, e.g. because it is rented or already in maintenance, is not changed and false is returned
+*/
+func moveBikeToMaintenance(ctx context.Context, tx dbQueryer, bikeId int, reason string, changedBy string) (bool, error) {
+
+	var bikeStatus string
+	dbQueryError := tx.QueryRowContext(ctx, `SELECT `+DB_TABLE_BIKE_COLUMN_STATUS+` FROM `+DB_TABLE_BIKE+` WHERE `+DB_TABLE_BIKE_COLUMN_BIKEID+`=$1 FOR UPDATE;`, bikeId).Scan(&bikeStatus)
+	if dbQueryError != nil {
+		return false, fmt.Errorf("could not retrieve status of bike %v. %w", bikeId, dbQueryError)
+	}
+
+	if !bikeStatusTransitionAllowed(bikeStatus, BIKE_STATUS_MAINTENANCE) {
+		return false, nil
+	}
+
+	_, transitionError := transitionBikeStatus(ctx, tx, bikeId, bikeStatus, BIKE_STATUS_MAINTENANCE, reason, changedBy)
+	if transitionError != nil {
+		return false, transitionError
+	}
+	return true, nil
+}
+
+// verifies that a work order is assigned to an operator
+func validateWorkOrderAssignee(ctx context.Context, db dbQueryer, assignee string) error {
+	role, getUserRoleError := getUserRoleFromDb(ctx, db, assignee)
+	if getUserRoleError != nil {
+		return fmt.Errorf("could not assign work order to %v. %w", assignee, getUserRoleError)
+	}
+	if role != ROLE_OPERATOR && role != ROLE_ADMIN {
+		return ValidationError(ERROR_CODE_VALIDATION, "work orders can only be assigned to operators, %v is a %v", assignee, role)
+	}
+	return nil
+}
+
+// returns true, if a work order can change from one status to the other
+func workOrderTransitionAllowed(fromStatus string, toStatus string) bool {
+	for _, allowedStatus := range allowedWorkOrderTransitions[fromStatus] {
+		if allowedStatus == toStatus {
+			return true
+		}
+	}
+	return false
+}
+
+// returns a work order without its notes. forUpdate locks the order until the end of the transaction
+func getWorkOrderFromDb(ctx context.Context, db dbQueryer, orderId int64, forUpdate bool) (*WorkOrderImpl, error) {
+	if forUpdate {
+		_, dbLockError := db.ExecContext(ctx, `SELECT orderid FROM `+DB_TABLE_WORKORDER+` WHERE orderid=$1 FOR UPDATE;`, orderId)
+		if dbLockError != nil {
+			return nil, fmt.Errorf("could not lock work order %d. %w", orderId, dbLockError)
+		}
+	}
+
+	orders, getOrdersError := getWorkOrdersWhere(ctx, db, "workorder.orderid=$1", orderId)
+	if getOrdersError != nil {
+		return nil, getOrdersError
+	}
+	if len(orders) == 0 {
+		return nil, NotFoundError(ERROR_CODE_WORK_ORDER_NOT_FOUND, "work order %d does not exist", orderId)
+	}
+	return &orders[0], nil
+}
+
+/*
+returns the work orders which match the condition. The condition refers to the columns of the workorder table
+and can contain an ORDER BY clause
+*/
+func getWorkOrdersWhere(ctx context.Context, db dbQueryer, condition string, arguments ...interface{}) ([]WorkOrderImpl, error) {
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+workOrderColumns+` FROM `+DB_TABLE_WORKORDER+` WHERE `+condition+`;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve work orders. %w", dbQueryError)
+	}
+	defer rows.Close()
+
+	orders := []WorkOrderImpl{}
+	for rows.Next() {
+		order, scanError := scanWorkOrder(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		orders = append(orders, *order)
+	}
+	return orders, nil
+}
+
+// returns the notes of a work order in the order they were added
+func getWorkOrderNotes(ctx context.Context, db dbQueryer, orderId int64) ([]WorkOrderNoteImpl, error) {
+	rows, dbQueryError := db.QueryContext(ctx, `SELECT `+workOrderNoteColumns+` FROM `+DB_TABLE_WORKORDERNOTE+` WHERE orderid=$1 ORDER BY createdat, noteid;`, orderId)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("could not retrieve notes of work order %d. %w", orderId, dbQueryError)
+	}
+	defer rows.Close()
+
+	notes := []WorkOrderNoteImpl{}
+	for rows.Next() {
+		note := WorkOrderNoteImpl{}
+		scanError := rows.Scan(&note.NoteId, &note.OrderId, &note.Kind, &note.Text, &note.LabourMinutes, &note.Author, &note.CreatedAt)
+		if scanError != nil {
+			return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into WorkOrderNote Object. %w", DB_TABLE_WORKORDERNOTE, scanError)
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+// scans a row of the workorder table, the columns are selected with workOrderColumns
+func scanWorkOrder(rows *sql.Rows) (*WorkOrderImpl, error) {
+	order := WorkOrderImpl{}
+	var damageReportId, planId, assignedTo sql.NullString
+	var closedAt sql.NullTime
+	scanError := rows.Scan(&order.OrderId, &order.BikeId, &order.Source, &damageReportId, &planId, &order.Title, &order.Description,
+		&order.Status, &assignedTo, &order.CreatedBy, &order.CreatedAt, &order.UpdatedAt, &closedAt, &order.LabourMinutes)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into WorkOrder Object. %w", DB_TABLE_WORKORDER, scanError)
+	}
+	if damageReportId.Valid {
+		order.DamageReportId = &damageReportId.String
+	}
+	if planId.Valid {
+		order.PlanId = &planId.String
+	}
+	if assignedTo.Valid {
+		order.AssignedTo = &assignedTo.String
+	}
+	if closedAt.Valid {
+		order.ClosedAt = &closedAt.Time
+	}
+	return &order, nil
+}
@@ -0,0 +1,88 @@
+package implementation
+
+import "time"
+
+const (
+	// ---------- sources of work orders ---------
+	WORK_ORDER_SOURCE_DAMAGE_REPORT = "damage_report"
+	WORK_ORDER_SOURCE_LOW_BATTERY   = "low_battery"
+	WORK_ORDER_SOURCE_MANUAL        = "manual"
+	WORK_ORDER_SOURCE_PREVENTIVE    = "preventive"
+
+	// ---------- status of work orders ---------
+	WORK_ORDER_OPEN        = "open"
+	WORK_ORDER_IN_PROGRESS = "in_progress"
+	WORK_ORDER_BLOCKED     = "blocked"
+	WORK_ORDER_DONE        = "done"
+
+	// ---------- kinds of notes of work orders ---------
+	WORK_ORDER_NOTE_PARTS   = "parts"
+	WORK_ORDER_NOTE_LABOUR  = "labour"
+	WORK_ORDER_NOTE_COMMENT = "comment"
+)
+
+/*
+represents the database structure for the table "workorder" in the DATABASE.
+a work order describes the maintenance of a bike. It is created from a damage report, a low battery, a maintenance plan or manually by an operator
+*/
+type WorkOrderImpl struct {
+	OrderId        int64               `json:"orderId"`
+	BikeId         int                 `json:"bikeId"`
+	Source         string              `json:"source"`
+	DamageReportId *string             `json:"damageReportId"`
+	PlanId         *string             `json:"planId"` // the maintenance plan of preventive orders
+	Title          string              `json:"title"`
+	Description    string              `json:"description"`
+	Status         string              `json:"status"`
+	AssignedTo     *string             `json:"assignedTo"`
+	CreatedBy      string              `json:"createdBy"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt"`
+	ClosedAt       *time.Time          `json:"closedAt"`
+	LabourMinutes  int                 `json:"labourMinutes"` // sum of the labour minutes of the notes
+	Notes          []WorkOrderNoteImpl `json:"notes,omitempty"`
+	// only set when the order is closed: the status of the bike after the order, empty if it was not changed
+	BikeStatus string `json:"bikeStatus,omitempty"`
+}
+
+/*
+represents the database structure for the table "workordernote" in the DATABASE.
+notes record the parts which were used and the labour which was done
+*/
+type WorkOrderNoteImpl struct {
+	NoteId        int64     `json:"noteId"`
+	OrderId       int64     `json:"orderId"`
+	Kind          string    `json:"kind"`
+	Text          string    `json:"text"`
+	LabourMinutes int       `json:"labourMinutes"`
+	Author        string    `json:"author"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+/*
+represents the filter of the list of work orders. Empty values do not filter
+*/
+type WorkOrderFilterImpl struct {
+	BikeId     *int
+	AssignedTo string
+	Status     string
+}
+
+/*
+represents a request to create a work order manually or from a damage report
+*/
+type WorkOrderRequestImpl struct {
+	BikeId         *int   `json:"bikeId"` // not required for an order from a damage report
+	DamageReportId string `json:"damageReportId"`
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	AssignedTo     string `json:"assignedTo"`
+}
+
+/*
+represents a request to change the status or the assignment of a work order
+*/
+type WorkOrderUpdateImpl struct {
+	Status     string `json:"status"`
+	AssignedTo string `json:"assignedTo"`
+}
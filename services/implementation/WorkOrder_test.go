@@ -0,0 +1,48 @@
+package implementation
+
+import (
+	"context"
+	"database/sql/driver"
+	"eBikeApi/services/fakedb"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClosingWorkOrderLocksBikeBeforeCountingOrders(t *testing.T) {
+	var statements []string
+	useFakeDB(t, func(ctx context.Context, statement fakedb.Statement) (*fakedb.Result, error) {
+		statements = append(statements, statement.Query)
+		switch {
+		case strings.HasPrefix(statement.Query, "SELECT "+bikeColumns):
+			columns := strings.Split(strings.Join(strings.Fields(bikeColumns), ""), ",")
+			return &fakedb.Result{Columns: columns, Rows: [][]driver.Value{bikeRow(7, BIKE_STATUS_MAINTENANCE, nil)}}, nil
+		case strings.HasPrefix(statement.Query, "SELECT count(*) FROM "+DB_TABLE_WORKORDER):
+			return &fakedb.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(1)}}}, nil
+		}
+		return &fakedb.Result{}, nil
+	})
+	db, _ := SetupDB()
+	tx, _ := db.BeginTx(context.Background(), nil)
+	defer tx.Rollback()
+
+	closedAt := time.Now()
+	order := &WorkOrderImpl{OrderId: 1, BikeId: 7, Status: WORK_ORDER_DONE, ClosedAt: &closedAt}
+	closeError := closeWorkOrder(context.Background(), tx, order, "operatorOne")
+	if closeError != nil {
+		t.Fatalf("expected the order to be closed, got %v", closeError)
+	}
+
+	lockIndex, countIndex := -1, -1
+	for index, query := range statements {
+		if strings.HasPrefix(query, "SELECT "+bikeColumns) && strings.HasSuffix(query, "FOR UPDATE;") {
+			lockIndex = index
+		}
+		if strings.HasPrefix(query, "SELECT count(*) FROM "+DB_TABLE_WORKORDER) {
+			countIndex = index
+		}
+	}
+	if lockIndex < 0 || countIndex < lockIndex {
+		t.Fatalf("expected the bike to be locked before the orders are counted, got the lock at %v and the count at %v", lockIndex, countIndex)
+	}
+}
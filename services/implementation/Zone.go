@@ -0,0 +1,375 @@
+package implementation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ---------- ZONE TABLE CONSTANTS ---------
+	DB_TABLE_ZONE = "zone"
+)
+
+// the columns of the zone table in the order they are scanned by scanZone
+const zoneColumns = `zoneid, name, kind, speedlimitkmh, polygons, minlatitude, minlongitude, maxlatitude, maxlongitude, active, createdat`
+
+/*
+Implementation method to retrieve the active zones.
+if kind is not empty, only zones of this kind are returned.
+if a bounding box is given, only zones which overlap the bounding box are returned.
+*/
+func GetZones(ctx context.Context, kind string, boundingBox *BoundingBoxImpl) (*[]ZoneImpl, error) {
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	conditions := []string{"active"}
+	arguments := []interface{}{}
+	if kind != "" {
+		arguments = append(arguments, kind)
+		conditions = append(conditions, fmt.Sprintf("kind=$%d", len(arguments)))
+	}
+	if boundingBox != nil {
+		arguments = append(arguments, boundingBox.MaxLatitude, boundingBox.MinLatitude, boundingBox.MaxLongitude, boundingBox.MinLongitude)
+		conditions = append(conditions, fmt.Sprintf("minlatitude<=$%d AND maxlatitude>=$%d AND minlongitude<=$%d AND maxlongitude>=$%d",
+			len(arguments)-3, len(arguments)-2, len(arguments)-1, len(arguments)))
+	}
+
+	zones, getZonesError := getZonesWhere(ctx, db, strings.Join(conditions, " AND "), arguments...)
+	if getZonesError != nil {
+		return nil, getZonesError
+	}
+
+	return &zones, nil
+}
+
+/*
+Implementation method to retrieve a zone by its zoneId
+*/
+func GetZone(ctx context.Context, zoneId string) (*ZoneImpl, error) {
+	if _, parseError := uuid.Parse(zoneId); parseError != nil {
+		return nil, NotFoundError(ERROR_CODE_ZONE_NOT_FOUND, "zone %v does not exist", zoneId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	zones, getZonesError := getZonesWhere(ctx, db, "zoneid=$1", zoneId)
+	if getZonesError != nil {
+		return nil, getZonesError
+	}
+	if len(zones) == 0 {
+		return nil, NotFoundError(ERROR_CODE_ZONE_NOT_FOUND, "zone %v does not exist", zoneId)
+	}
+
+	return &zones[0], nil
+}
+
+/*
+Implementation method to import zones from a GeoJSON FeatureCollection.
+All features are imported in one transaction, so either all zones are created or none.
+*/
+func ImportZones(ctx context.Context, featureCollection GeoJsonFeatureCollectionImpl) (*[]ZoneImpl, error) {
+
+	validator := fieldValidator{}
+	if featureCollection.Type != "FeatureCollection" {
+		validator.add("type", "GeoJSON type must be FeatureCollection, got %v", featureCollection.Type)
+	}
+	if len(featureCollection.Features) == 0 {
+		validator.add("features", "the FeatureCollection has no features")
+	}
+
+	// the errors of all features are returned at once
+	importedZones := []ZoneImpl{}
+	for index, feature := range featureCollection.Features {
+		zone := zoneFromFeature(feature, fmt.Sprintf("features[%d]", index), &validator)
+		if zone != nil {
+			importedZones = append(importedZones, *zone)
+		}
+	}
+	if validationError := validator.err(); validationError != nil {
+		return nil, validationError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	tx, beginError := db.BeginTx(ctx, nil)
+	if beginError != nil {
+		return nil, fmt.Errorf("could not start transaction. %w", beginError)
+	}
+	defer tx.Rollback() // has no effect after a successful commit
+
+	insertStatement := `INSERT INTO ` + DB_TABLE_ZONE + ` (` + zoneColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);`
+	for _, zone := range importedZones {
+		polygonsJson, marshalError := json.Marshal(zone.Polygons)
+		if marshalError != nil {
+			return nil, fmt.Errorf("could not marshal polygons of zone %v. %w", zone.Name, marshalError)
+		}
+
+		_, dbInsertError := tx.ExecContext(ctx, insertStatement, zone.ZoneId, zone.Name, zone.Kind, zone.SpeedLimitKmh, string(polygonsJson),
+			zone.MinLatitude, zone.MinLongitude, zone.MaxLatitude, zone.MaxLongitude, zone.Active, zone.CreatedAt)
+		if dbInsertError != nil {
+			return nil, fmt.Errorf("could not insert record into zone Table. %w", dbInsertError)
+		}
+	}
+
+	commitError := tx.Commit()
+	if commitError != nil {
+		return nil, fmt.Errorf("could not import zones. %w", commitError)
+	}
+
+	return &importedZones, nil
+}
+
+/*
+Implementation method to deactivate a zone. Zones are not deleted, so that it stays traceable why a ride was charged a penalty
+*/
+func DeactivateZone(ctx context.Context, zoneId string) error {
+	if _, parseError := uuid.Parse(zoneId); parseError != nil {
+		return NotFoundError(ERROR_CODE_ZONE_NOT_FOUND, "zone %v does not exist", zoneId)
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return dbConnectError
+	}
+
+	result, dbUpdateError := db.ExecContext(ctx, `UPDATE `+DB_TABLE_ZONE+` SET active=false WHERE zoneid=$1;`, zoneId)
+	if dbUpdateError != nil {
+		return fmt.Errorf("could not deactivate zone %v. %w", zoneId, dbUpdateError)
+	}
+
+	rowsAffected, rowsAffectedError := result.RowsAffected()
+	if rowsAffectedError != nil {
+		return fmt.Errorf("could not deactivate zone %v. %w", zoneId, rowsAffectedError)
+	}
+	if rowsAffected == 0 {
+		return NotFoundError(ERROR_CODE_ZONE_NOT_FOUND, "zone %v does not exist", zoneId)
+	}
+
+	return nil
+}
+
+/*
+Implementation method to evaluate a position against all active zones
+*/
+func EvaluatePosition(ctx context.Context, latitude float64, longitude float64) (*PositionEvaluationImpl, error) {
+
+	coordinateError := validateCoordinates(latitude, longitude)
+	if coordinateError != nil {
+		return nil, coordinateError
+	}
+
+	// connect to database
+	db, dbConnectError := SetupDB()
+	if dbConnectError != nil {
+		return nil, dbConnectError
+	}
+
+	return evaluatePosition(ctx, db, latitude, longitude)
+}
+
+/*
+evaluates a position against all active zones.
+the operating areas are always loaded, because a position outside of every operating area is outside of the operating area.
+all other zones are only loaded if their bounding box contains the position
+*/
+func evaluatePosition(ctx context.Context, database dbQueryer, latitude float64, longitude float64) (*PositionEvaluationImpl, error) {
+
+	zones, getZonesError := getZonesWhere(ctx, database, `active AND (kind=$1 OR (minlatitude<=$2 AND maxlatitude>=$2 AND minlongitude<=$3 AND maxlongitude>=$3))`,
+		ZONE_OPERATING_AREA, latitude, longitude)
+	if getZonesError != nil {
+		return nil, getZonesError
+	}
+
+	evaluation := PositionEvaluationImpl{
+		Latitude:  latitude,
+		Longitude: longitude,
+		Zones:     []ZoneImpl{},
+	}
+
+	operatingAreaDefined := false
+	for _, zone := range zones {
+		if zone.Kind == ZONE_OPERATING_AREA {
+			operatingAreaDefined = true
+		}
+		if !zone.contains(latitude, longitude) {
+			continue
+		}
+
+		evaluation.Zones = append(evaluation.Zones, zone)
+		switch zone.Kind {
+		case ZONE_OPERATING_AREA:
+			evaluation.InsideOperatingArea = true
+		case ZONE_NO_PARKING:
+			evaluation.InNoParkingZone = true
+		case ZONE_PREFERRED_PARKING:
+			evaluation.InPreferredParking = true
+		case ZONE_SLOW_SPEED:
+			// if slow speed zones overlap, the lowest limit applies
+			if zone.SpeedLimitKmh != nil && (evaluation.SpeedLimitKmh == nil || *zone.SpeedLimitKmh < *evaluation.SpeedLimitKmh) {
+				evaluation.SpeedLimitKmh = zone.SpeedLimitKmh
+			}
+		}
+	}
+
+	if !operatingAreaDefined {
+		evaluation.InsideOperatingArea = true
+	}
+
+	return &evaluation, nil
+}
+
+// returns true if one of the polygons of the zone contains the position
+func (zone ZoneImpl) contains(latitude float64, longitude float64) bool {
+	if latitude < zone.MinLatitude || latitude > zone.MaxLatitude || longitude < zone.MinLongitude || longitude > zone.MaxLongitude {
+		return false
+	}
+	for _, polygon := range zone.Polygons {
+		if polygon.contains(latitude, longitude) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+converts a GeoJSON feature into a zone and validates it. The values which are not allowed are added to the validator
+with the path of the feature, e.g. features[0].properties.name
+*/
+func zoneFromFeature(feature GeoJsonFeatureImpl, path string, validator *fieldValidator) *ZoneImpl {
+
+	zone := ZoneImpl{
+		ZoneId:        uuid.New().String(),
+		Name:          strings.TrimSpace(feature.Properties.Name),
+		Kind:          feature.Properties.Kind,
+		SpeedLimitKmh: feature.Properties.SpeedLimitKmh,
+		Active:        true,
+		CreatedAt:     time.Now(),
+	}
+
+	validator.requiredText(path+".properties.name", zone.Name, MAX_NAME_LENGTH)
+
+	switch zone.Kind {
+	case ZONE_SLOW_SPEED:
+		if zone.SpeedLimitKmh == nil || *zone.SpeedLimitKmh <= 0 {
+			validator.add(path+".properties.speedLimitKmh", "a slow speed zone needs a speedLimitKmh greater than 0")
+		}
+	case ZONE_OPERATING_AREA, ZONE_NO_PARKING, ZONE_PREFERRED_PARKING:
+		zone.SpeedLimitKmh = nil
+	default:
+		validator.oneOf(path+".properties.kind", zone.Kind, ZONE_OPERATING_AREA, ZONE_NO_PARKING, ZONE_SLOW_SPEED, ZONE_PREFERRED_PARKING)
+	}
+
+	// a Polygon has the coordinates of one polygon, a MultiPolygon a list of polygons
+	coordinatesPath := path + ".geometry.coordinates"
+	var polygons []GeoPolygonImpl
+	switch feature.Geometry.Type {
+	case "Polygon":
+		var polygon GeoPolygonImpl
+		unmarshalError := json.Unmarshal(feature.Geometry.Coordinates, &polygon)
+		if unmarshalError != nil {
+			validator.add(coordinatesPath, "invalid coordinates of Polygon. %v", unmarshalError)
+			return nil
+		}
+		polygons = []GeoPolygonImpl{polygon}
+	case "MultiPolygon":
+		unmarshalError := json.Unmarshal(feature.Geometry.Coordinates, &polygons)
+		if unmarshalError != nil {
+			validator.add(coordinatesPath, "invalid coordinates of MultiPolygon. %v", unmarshalError)
+			return nil
+		}
+	default:
+		validator.add(path+".geometry.type", "geometry type must be Polygon or MultiPolygon, got %v", feature.Geometry.Type)
+		return nil
+	}
+
+	if len(polygons) == 0 {
+		validator.add(coordinatesPath, "geometry has no polygons")
+		return nil
+	}
+
+	zone.MinLatitude, zone.MinLongitude = math.Inf(1), math.Inf(1)
+	zone.MaxLatitude, zone.MaxLongitude = math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		normalizedPolygon, polygonError := normalizePolygon(polygon)
+		if polygonError != nil {
+			validator.add(coordinatesPath, "%v", polygonError)
+			return nil
+		}
+		zone.Polygons = append(zone.Polygons, normalizedPolygon)
+
+		// the outer ring determines the bounding box
+		for _, point := range normalizedPolygon[0] {
+			zone.MinLongitude = math.Min(zone.MinLongitude, point[0])
+			zone.MaxLongitude = math.Max(zone.MaxLongitude, point[0])
+			zone.MinLatitude = math.Min(zone.MinLatitude, point[1])
+			zone.MaxLatitude = math.Max(zone.MaxLatitude, point[1])
+		}
+	}
+
+	return &zone
+}
+
+// returns all zones which match the condition ordered by kind and name
+func getZonesWhere(ctx context.Context, database dbQueryer, condition string, arguments ...interface{}) ([]ZoneImpl, error) {
+
+	rows, dbQueryError := database.QueryContext(ctx, `SELECT `+zoneColumns+` FROM `+DB_TABLE_ZONE+` WHERE `+condition+` ORDER BY kind, name;`, arguments...)
+	if dbQueryError != nil {
+		return nil, fmt.Errorf("error retrieving records from table %v. %w", DB_TABLE_ZONE, dbQueryError)
+	}
+	defer rows.Close()
+
+	zones := []ZoneImpl{}
+	for rows.Next() {
+		zone, scanError := scanZone(rows)
+		if scanError != nil {
+			return nil, scanError
+		}
+		zones = append(zones, *zone)
+	}
+
+	return zones, nil
+}
+
+// scans a row which was selected with zoneColumns into a zone object
+func scanZone(rows *sql.Rows) (*ZoneImpl, error) {
+	zone := ZoneImpl{}
+	var speedLimitKmh sql.NullInt64
+	var polygonsJson []byte
+
+	scanError := rows.Scan(&zone.ZoneId, &zone.Name, &zone.Kind, &speedLimitKmh, &polygonsJson, &zone.MinLatitude, &zone.MinLongitude,
+		&zone.MaxLatitude, &zone.MaxLongitude, &zone.Active, &zone.CreatedAt)
+	if scanError != nil {
+		return nil, fmt.Errorf("error scanning fields. could not scan rows of %v into zone object", DB_TABLE_ZONE)
+	}
+
+	if speedLimitKmh.Valid {
+		limit := int(speedLimitKmh.Int64)
+		zone.SpeedLimitKmh = &limit
+	}
+
+	unmarshalError := json.Unmarshal(polygonsJson, &zone.Polygons)
+	if unmarshalError != nil {
+		return nil, fmt.Errorf("could not unmarshal polygons of zone %v. %w", zone.ZoneId, unmarshalError)
+	}
+
+	return &zone, nil
+}
@@ -0,0 +1,92 @@
+package implementation
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// ---------- kinds of zones ---------
+	ZONE_OPERATING_AREA    = "operating_area"
+	ZONE_NO_PARKING        = "no_parking"
+	ZONE_SLOW_SPEED        = "slow_speed"
+	ZONE_PREFERRED_PARKING = "preferred_parking"
+)
+
+/*
+represents the database structure for the table "zone" in the DATABASE.
+a zone consists of one or more polygons. The bounding box is stored to find the zones of a position or of a map section quickly.
+SpeedLimitKmh is only used by the kind slow_speed
+*/
+type ZoneImpl struct {
+	ZoneId        string           `json:"zoneId"`
+	Name          string           `json:"name"`
+	Kind          string           `json:"kind"`
+	SpeedLimitKmh *int             `json:"speedLimitKmh"`
+	Polygons      []GeoPolygonImpl `json:"polygons"`
+	MinLatitude   float64          `json:"minLatitude"`
+	MinLongitude  float64          `json:"minLongitude"`
+	MaxLatitude   float64          `json:"maxLatitude"`
+	MaxLongitude  float64          `json:"maxLongitude"`
+	Active        bool             `json:"active"`
+	CreatedAt     time.Time        `json:"createdAt"`
+}
+
+/*
+represents a map section, e.g. the part of the map which is shown by the client
+*/
+type BoundingBoxImpl struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+/*
+represents the result of the evaluation of a position against all active zones.
+If no operating area is defined, every position is inside the operating area.
+*/
+type PositionEvaluationImpl struct {
+	Latitude            float64    `json:"latitude"`
+	Longitude           float64    `json:"longitude"`
+	InsideOperatingArea bool       `json:"insideOperatingArea"`
+	InNoParkingZone     bool       `json:"inNoParkingZone"`
+	InPreferredParking  bool       `json:"inPreferredParking"`
+	SpeedLimitKmh       *int       `json:"speedLimitKmh"`
+	Zones               []ZoneImpl `json:"zones"`
+}
+
+/*
+represents a GeoJSON FeatureCollection which is imported as zones.
+every feature needs a Polygon or MultiPolygon geometry and the properties "name" and "kind"
+*/
+type GeoJsonFeatureCollectionImpl struct {
+	Type     string               `json:"type"`
+	Features []GeoJsonFeatureImpl `json:"features"`
+}
+
+/*
+represents a single GeoJSON Feature
+*/
+type GeoJsonFeatureImpl struct {
+	Type       string                    `json:"type"`
+	Properties GeoJsonZonePropertiesImpl `json:"properties"`
+	Geometry   GeoJsonGeometryImpl       `json:"geometry"`
+}
+
+/*
+represents the properties of a feature which describe the zone
+*/
+type GeoJsonZonePropertiesImpl struct {
+	Name          string `json:"name"`
+	Kind          string `json:"kind"`
+	SpeedLimitKmh *int   `json:"speedLimitKmh"`
+}
+
+/*
+represents a GeoJSON geometry. The coordinates are parsed depending on the type
+*/
+type GeoJsonGeometryImpl struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
@@ -7,7 +7,9 @@ DROP TABLE IF EXISTS public.users;
 CREATE TABLE IF NOT EXISTS public.users
 (
     username character varying(32) COLLATE pg_catalog."default" NOT NULL,
-    CONSTRAINT users_pkey PRIMARY KEY (username)
+    role character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'rider',
+    CONSTRAINT users_pkey PRIMARY KEY (username),
+    CONSTRAINT users_role_check CHECK (role IN ('rider', 'operator', 'admin'))
 )
 
 TABLESPACE pg_default;
@@ -28,8 +30,12 @@ CREATE TABLE IF NOT EXISTS public.reservation
     reservationid uuid NOT NULL,
     bikeid integer NOT NULL,
     username character varying COLLATE pg_catalog."default" NOT NULL,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    startlatitude double precision,
+    startlongitude double precision,
+    promocode character varying(32) COLLATE pg_catalog."default",
+    billingorganizationid uuid,
     CONSTRAINT reservation_pkey PRIMARY KEY (reservationid),
-    CONSTRAINT "username_Unique_contraint" UNIQUE (username),
     CONSTRAINT username_foreign_key FOREIGN KEY (username)
         REFERENCES public.users (username) MATCH SIMPLE
         ON UPDATE CASCADE
@@ -60,6 +66,35 @@ CREATE INDEX IF NOT EXISTS "reservation_FKEY_user_for_username"
 
 
 
+-- Table: public.station
+-- docking stations. A bike which is returned within returnradiusmeters of a station is docked there.
+-- fullpolicy decides whether a return to a full station is refused or accepted next to the station with a warning
+
+DROP TABLE IF EXISTS public.station;
+
+CREATE TABLE IF NOT EXISTS public.station
+(
+    stationid serial NOT NULL,
+    name character varying(100) COLLATE pg_catalog."default" NOT NULL,
+    latitude double precision NOT NULL,
+    longitude double precision NOT NULL,
+    capacity integer NOT NULL,
+    returnradiusmeters integer NOT NULL DEFAULT 30,
+    fullpolicy character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'refuse',
+    active boolean NOT NULL DEFAULT true,
+    CONSTRAINT station_pkey PRIMARY KEY (stationid),
+    CONSTRAINT station_values_check CHECK (capacity > 0 AND returnradiusmeters > 0 AND returnradiusmeters <= 200),
+    CONSTRAINT station_fullpolicy_check CHECK (fullpolicy IN ('refuse', 'warn'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.station
+    OWNER to postgres;
+
+
+
+
 -- Table: public.bike
 
 DROP TABLE IF EXISTS public.bike;
@@ -71,11 +106,29 @@ CREATE TABLE IF NOT EXISTS public.bike
     latitude double precision NOT NULL,
     longitude double precision NOT NULL,
     reservationid uuid,
+    status character varying(20) COLLATE pg_catalog."default" NOT NULL DEFAULT 'available',
+    stationid integer,
+    batterypercent integer,
+    estimatedrangekm double precision,
+    fullrangekm double precision NOT NULL DEFAULT 60,
+    locked boolean,
+    odometermeters bigint NOT NULL DEFAULT 0,
+    lasttelemetryat timestamp with time zone,
+    biketype character varying(32) COLLATE pg_catalog."default" NOT NULL DEFAULT 'standard',
+    maintenanceduesoon boolean NOT NULL DEFAULT false,
+    parkedlatitude double precision,
+    parkedlongitude double precision,
     CONSTRAINT "Bikes_pkey" PRIMARY KEY (bikeid),
     CONSTRAINT "bike_reservationId_fkey" FOREIGN KEY (reservationid)
         REFERENCES public.reservation (reservationid) MATCH SIMPLE
         ON UPDATE NO ACTION -- cascade statt no action
-        ON DELETE SET NULL -- set null statt NO ACTION
+        ON DELETE SET NULL, -- set null statt NO ACTION
+    CONSTRAINT bike_stationid_fkey FOREIGN KEY (stationid)
+        REFERENCES public.station (stationid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE SET NULL,
+    CONSTRAINT bike_batterypercent_check CHECK (batterypercent IS NULL OR (batterypercent >= 0 AND batterypercent <= 100)),
+    CONSTRAINT bike_status_check CHECK (status IN ('available', 'reserved', 'in_use', 'low_battery', 'maintenance', 'out_of_service', 'missing', 'retired'))
 )
 
 TABLESPACE pg_default;
@@ -91,12 +144,1151 @@ CREATE INDEX IF NOT EXISTS "fKey_from_bike_to_reservation_for_reservationId"
     (reservationid ASC NULLS LAST)
     TABLESPACE pg_default;
 
+DROP INDEX IF EXISTS public.bike_stationid_idx;
+
+CREATE INDEX IF NOT EXISTS bike_stationid_idx
+    ON public.bike USING btree
+    (stationid ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+-- Table: public.ride
+-- a ride is written when a reservation ends. It keeps the fare which was computed for the ride.
+
+DROP TABLE IF EXISTS public.ride;
+
+CREATE TABLE IF NOT EXISTS public.ride
+(
+    rideid uuid NOT NULL,
+    bikeid integer NOT NULL,
+    username character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    startedat timestamp with time zone NOT NULL,
+    endedat timestamp with time zone NOT NULL,
+    startlatitude double precision,
+    startlongitude double precision,
+    endlatitude double precision NOT NULL,
+    endlongitude double precision NOT NULL,
+    durationminutes integer NOT NULL,
+    farelines jsonb NOT NULL DEFAULT '[]'::jsonb,
+    subtotalcents integer NOT NULL,
+    discountcents integer NOT NULL DEFAULT 0,
+    totalcents integer NOT NULL,
+    promocode character varying(32) COLLATE pg_catalog."default",
+    organizationid uuid,
+    endstationid integer,
+    CONSTRAINT ride_pkey PRIMARY KEY (rideid),
+    CONSTRAINT ride_username_fkey FOREIGN KEY (username)
+        REFERENCES public.users (username) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.ride
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.ride_username_idx;
+
+CREATE INDEX IF NOT EXISTS ride_username_idx
+    ON public.ride USING btree
+    (username COLLATE pg_catalog."default" ASC NULLS LAST, endedat DESC)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.promotion
+
+DROP TABLE IF EXISTS public.promotion;
+
+CREATE TABLE IF NOT EXISTS public.promotion
+(
+    code character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    description character varying(255) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    discounttype character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'none',
+    discountvalue integer NOT NULL DEFAULT 0,
+    freeminutes integer NOT NULL DEFAULT 0,
+    validfrom timestamp with time zone,
+    validuntil timestamp with time zone,
+    weekendsonly boolean NOT NULL DEFAULT false,
+    peruserlimit integer NOT NULL DEFAULT 0,
+    globallimit integer NOT NULL DEFAULT 0,
+    firstrideonly boolean NOT NULL DEFAULT false,
+    active boolean NOT NULL DEFAULT true,
+    CONSTRAINT promotion_pkey PRIMARY KEY (code),
+    CONSTRAINT promotion_discounttype_check CHECK (discounttype IN ('none', 'percent', 'fixed')),
+    CONSTRAINT promotion_discountvalue_check CHECK (discountvalue >= 0 AND (discounttype <> 'percent' OR discountvalue <= 100))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.promotion
+    OWNER to postgres;
+
+
+
+
+-- Table: public.promotionredemption
+
+DROP TABLE IF EXISTS public.promotionredemption;
+
+CREATE TABLE IF NOT EXISTS public.promotionredemption
+(
+    redemptionid uuid NOT NULL,
+    code character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    username character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    rideid uuid NOT NULL,
+    discountcents integer NOT NULL,
+    redeemedat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT promotionredemption_pkey PRIMARY KEY (redemptionid),
+    CONSTRAINT promotionredemption_rideid_unique UNIQUE (rideid),
+    CONSTRAINT promotionredemption_code_fkey FOREIGN KEY (code)
+        REFERENCES public.promotion (code) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.promotionredemption
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.promotionredemption_code_username_idx;
+
+CREATE INDEX IF NOT EXISTS promotionredemption_code_username_idx
+    ON public.promotionredemption USING btree
+    (code COLLATE pg_catalog."default" ASC NULLS LAST, username COLLATE pg_catalog."default" ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+-- Table: public.plan
+-- subscription plans. a period with 0 included minutes does not limit the allowance, a plan with 0 minutes for both periods includes no free minutes
+
+DROP TABLE IF EXISTS public.plan;
+
+CREATE TABLE IF NOT EXISTS public.plan
+(
+    planid character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    name character varying(100) COLLATE pg_catalog."default" NOT NULL,
+    pricecents integer NOT NULL,
+    includedminutesperday integer NOT NULL DEFAULT 0,
+    includedminutespermonth integer NOT NULL DEFAULT 0,
+    maxconcurrentbikes integer NOT NULL DEFAULT 1,
+    active boolean NOT NULL DEFAULT true,
+    CONSTRAINT plan_pkey PRIMARY KEY (planid),
+    CONSTRAINT plan_values_check CHECK (pricecents >= 0 AND includedminutesperday >= 0 AND includedminutespermonth >= 0 AND maxconcurrentbikes >= 1)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.plan
+    OWNER to postgres;
+
+
+
+
+-- Table: public.subscription
+-- a subscription renews every month at renewsat. A cancelled subscription stays active until renewsat
+
+DROP TABLE IF EXISTS public.subscription;
+
+CREATE TABLE IF NOT EXISTS public.subscription
+(
+    subscriptionid uuid NOT NULL,
+    username character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    planid character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    startedat timestamp with time zone NOT NULL DEFAULT now(),
+    renewsat timestamp with time zone NOT NULL,
+    cancelledat timestamp with time zone,
+    CONSTRAINT subscription_pkey PRIMARY KEY (subscriptionid),
+    CONSTRAINT subscription_username_fkey FOREIGN KEY (username)
+        REFERENCES public.users (username) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT subscription_planid_fkey FOREIGN KEY (planid)
+        REFERENCES public.plan (planid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE NO ACTION
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.subscription
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.subscription_username_idx;
+
+CREATE INDEX IF NOT EXISTS subscription_username_idx
+    ON public.subscription USING btree
+    (username COLLATE pg_catalog."default" ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.subscriptionusage
+-- the included minutes of a subscription which were used by a ride
+
+DROP TABLE IF EXISTS public.subscriptionusage;
+
+CREATE TABLE IF NOT EXISTS public.subscriptionusage
+(
+    rideid uuid NOT NULL,
+    subscriptionid uuid NOT NULL,
+    minutes integer NOT NULL,
+    usedat timestamp with time zone NOT NULL,
+    CONSTRAINT subscriptionusage_pkey PRIMARY KEY (rideid),
+    CONSTRAINT subscriptionusage_subscriptionid_fkey FOREIGN KEY (subscriptionid)
+        REFERENCES public.subscription (subscriptionid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.subscriptionusage
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.subscriptionusage_subscriptionid_idx;
+
+CREATE INDEX IF NOT EXISTS subscriptionusage_subscriptionid_idx
+    ON public.subscriptionusage USING btree
+    (subscriptionid ASC NULLS LAST, usedat DESC)
+    TABLESPACE pg_default;
+
+
+-- Table: public.receipt
+-- every finished ride produces a receipt. Receipts are immutable, updates and deletes are rejected by a trigger.
+-- corrections, e.g. a waived penalty, are issued as additional receipts of the kind credit with a negative total
+
+DROP TABLE IF EXISTS public.receipt;
+
+CREATE TABLE IF NOT EXISTS public.receipt
+(
+    receiptid uuid NOT NULL,
+    receiptnumber bigserial NOT NULL,
+    kind character varying(8) COLLATE pg_catalog."default" NOT NULL DEFAULT 'ride',
+    rideid uuid NOT NULL,
+    username character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    bikeid integer NOT NULL,
+    bikename character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    startedat timestamp with time zone NOT NULL,
+    endedat timestamp with time zone NOT NULL,
+    startlatitude double precision,
+    startlongitude double precision,
+    endlatitude double precision NOT NULL,
+    endlongitude double precision NOT NULL,
+    durationminutes integer NOT NULL,
+    lines jsonb NOT NULL,
+    subtotalcents integer NOT NULL,
+    discountcents integer NOT NULL,
+    totalcents integer NOT NULL,
+    vatratepercent integer NOT NULL,
+    vatcents integer NOT NULL,
+    currency character(3) COLLATE pg_catalog."default" NOT NULL,
+    issuedat timestamp with time zone NOT NULL DEFAULT now(),
+    organizationid uuid,
+    CONSTRAINT receipt_pkey PRIMARY KEY (receiptid),
+    CONSTRAINT receipt_receiptnumber_unique UNIQUE (receiptnumber),
+    CONSTRAINT receipt_kind_check CHECK (kind IN ('ride', 'credit'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.receipt
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.receipt_rideid_unique;
+
+-- there is exactly one ride receipt per ride, but there can be several credit notes
+CREATE UNIQUE INDEX IF NOT EXISTS receipt_rideid_unique
+    ON public.receipt USING btree
+    (rideid ASC NULLS LAST)
+    TABLESPACE pg_default
+    WHERE kind = 'ride';
+
+DROP INDEX IF EXISTS public.receipt_username_issuedat_idx;
+
+CREATE INDEX IF NOT EXISTS receipt_username_issuedat_idx
+    ON public.receipt USING btree
+    (username COLLATE pg_catalog."default" ASC NULLS LAST, issuedat ASC)
+    TABLESPACE pg_default;
+
+CREATE OR REPLACE FUNCTION public.reject_receipt_change()
+    RETURNS trigger
+    LANGUAGE plpgsql
+AS $$
+BEGIN
+    RAISE EXCEPTION 'receipts are immutable';
+END;
+$$;
+
+CREATE TRIGGER receipt_immutable
+    BEFORE UPDATE OR DELETE ON public.receipt
+    FOR EACH ROW EXECUTE FUNCTION public.reject_receipt_change();
+
+
+-- Table: public.organization
+-- companies whose employees ride on the company's tab
+
+DROP TABLE IF EXISTS public.organization;
+
+CREATE TABLE IF NOT EXISTS public.organization
+(
+    organizationid uuid NOT NULL,
+    name character varying(100) COLLATE pg_catalog."default" NOT NULL,
+    billingemail character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    billingreference character varying(100) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT organization_pkey PRIMARY KEY (organizationid),
+    CONSTRAINT organization_name_unique UNIQUE (name)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.organization
+    OWNER to postgres;
+
+
+
+
+-- Table: public.organizationmember
+-- a spending limit of NULL means that the member can ride without limit on the company's tab
+
+DROP TABLE IF EXISTS public.organizationmember;
+
+CREATE TABLE IF NOT EXISTS public.organizationmember
+(
+    organizationid uuid NOT NULL,
+    username character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    memberrole character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'member',
+    monthlyspendinglimitcents integer,
+    joinedat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT organizationmember_pkey PRIMARY KEY (organizationid, username),
+    CONSTRAINT organizationmember_memberrole_check CHECK (memberrole IN ('member', 'manager')),
+    CONSTRAINT organizationmember_limit_check CHECK (monthlyspendinglimitcents IS NULL OR monthlyspendinglimitcents >= 0),
+    CONSTRAINT organizationmember_organizationid_fkey FOREIGN KEY (organizationid)
+        REFERENCES public.organization (organizationid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT organizationmember_username_fkey FOREIGN KEY (username)
+        REFERENCES public.users (username) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.organizationmember
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.ride_organizationid_idx;
+
+CREATE INDEX IF NOT EXISTS ride_organizationid_idx
+    ON public.ride USING btree
+    (organizationid ASC NULLS LAST, endedat ASC)
+    TABLESPACE pg_default;
+
+
+-- Table: public.penaltyrule
+-- configurable penalties which are evaluated when a ride ends.
+-- thresholdminutes and additionalcentsperhour are only used by the kind over_duration
+
+DROP TABLE IF EXISTS public.penaltyrule;
+
+CREATE TABLE IF NOT EXISTS public.penaltyrule
+(
+    ruleid character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    kind character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    description character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    feecents integer NOT NULL,
+    thresholdminutes integer NOT NULL DEFAULT 0,
+    additionalcentsperhour integer NOT NULL DEFAULT 0,
+    active boolean NOT NULL DEFAULT true,
+    CONSTRAINT penaltyrule_pkey PRIMARY KEY (ruleid),
+    CONSTRAINT penaltyrule_kind_check CHECK (kind IN ('over_duration', 'outside_operating_zone', 'no_parking_zone', 'reported_damage')),
+    CONSTRAINT penaltyrule_values_check CHECK (feecents >= 0 AND thresholdminutes >= 0 AND additionalcentsperhour >= 0)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.penaltyrule
+    OWNER to postgres;
+
+
+
+
+-- Table: public.ridepenalty
+-- the itemized penalties of a ride. A waived penalty keeps who waived it, when and why
+
+DROP TABLE IF EXISTS public.ridepenalty;
+
+CREATE TABLE IF NOT EXISTS public.ridepenalty
+(
+    penaltyid uuid NOT NULL,
+    rideid uuid NOT NULL,
+    ruleid character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    kind character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    description character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    amountcents integer NOT NULL,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    waivedat timestamp with time zone,
+    waivedby character varying(32) COLLATE pg_catalog."default",
+    waivedreason text COLLATE pg_catalog."default",
+    CONSTRAINT ridepenalty_pkey PRIMARY KEY (penaltyid),
+    CONSTRAINT ridepenalty_rideid_fkey FOREIGN KEY (rideid)
+        REFERENCES public.ride (rideid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT ridepenalty_waiver_check CHECK (waivedat IS NULL OR (waivedby IS NOT NULL AND waivedreason IS NOT NULL))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.ridepenalty
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.ridepenalty_rideid_idx;
+
+CREATE INDEX IF NOT EXISTS ridepenalty_rideid_idx
+    ON public.ridepenalty USING btree
+    (rideid ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+-- Table: public.zone
+-- geofenced zones. polygons is a list of GeoJSON polygon coordinates, the bounding box is used to find the zones of a position quickly.
+-- speedlimitkmh is only used by the kind slow_speed
+
+DROP TABLE IF EXISTS public.zone;
+
+CREATE TABLE IF NOT EXISTS public.zone
+(
+    zoneid uuid NOT NULL,
+    name character varying(100) COLLATE pg_catalog."default" NOT NULL,
+    kind character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    speedlimitkmh integer,
+    polygons jsonb NOT NULL,
+    minlatitude double precision NOT NULL,
+    minlongitude double precision NOT NULL,
+    maxlatitude double precision NOT NULL,
+    maxlongitude double precision NOT NULL,
+    active boolean NOT NULL DEFAULT true,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT zone_pkey PRIMARY KEY (zoneid),
+    CONSTRAINT zone_kind_check CHECK (kind IN ('operating_area', 'no_parking', 'slow_speed', 'preferred_parking')),
+    CONSTRAINT zone_speedlimit_check CHECK (speedlimitkmh IS NULL OR speedlimitkmh > 0)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.zone
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.zone_boundingbox_idx;
+
+CREATE INDEX IF NOT EXISTS zone_boundingbox_idx
+    ON public.zone USING btree
+    (minlatitude ASC NULLS LAST, maxlatitude ASC NULLS LAST, minlongitude ASC NULLS LAST, maxlongitude ASC NULLS LAST)
+    TABLESPACE pg_default
+    WHERE active;
+
+
+-- Table: public.device
+-- the device of a bike which reports telemetry. Requests are signed with the secret (HMAC-SHA256)
+
+DROP TABLE IF EXISTS public.device;
+
+CREATE TABLE IF NOT EXISTS public.device
+(
+    deviceid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    bikeid integer NOT NULL,
+    secret character varying(128) COLLATE pg_catalog."default" NOT NULL,
+    active boolean NOT NULL DEFAULT true,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    lastseenat timestamp with time zone,
+    CONSTRAINT device_pkey PRIMARY KEY (deviceid),
+    CONSTRAINT device_bikeid_unique UNIQUE (bikeid),
+    CONSTRAINT device_bikeid_fkey FOREIGN KEY (bikeid)
+        REFERENCES public.bike (bikeid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.device
+    OWNER to postgres;
+
+
+
+
+-- Table: public.telemetry
+-- history of all accepted telemetry records. recordedat is the time of the measurement, receivedat the time of the request
+
+DROP TABLE IF EXISTS public.telemetry;
+
+CREATE TABLE IF NOT EXISTS public.telemetry
+(
+    telemetryid bigserial NOT NULL,
+    bikeid integer NOT NULL,
+    deviceid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    recordedat timestamp with time zone NOT NULL,
+    receivedat timestamp with time zone NOT NULL DEFAULT now(),
+    latitude double precision NOT NULL,
+    longitude double precision NOT NULL,
+    batterypercent integer NOT NULL,
+    locked boolean NOT NULL,
+    odometermeters bigint NOT NULL,
+    CONSTRAINT telemetry_pkey PRIMARY KEY (telemetryid),
+    CONSTRAINT telemetry_bikeid_fkey FOREIGN KEY (bikeid)
+        REFERENCES public.bike (bikeid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.telemetry
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.telemetry_bikeid_recordedat_idx;
+
+CREATE INDEX IF NOT EXISTS telemetry_bikeid_recordedat_idx
+    ON public.telemetry USING btree
+    (bikeid ASC NULLS LAST, recordedat DESC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.bikestatuschange
+-- history of the operational status of the bikes. changedby is the user or "system" for automatic changes
+
+DROP TABLE IF EXISTS public.bikestatuschange;
+
+CREATE TABLE IF NOT EXISTS public.bikestatuschange
+(
+    changeid bigserial NOT NULL,
+    bikeid integer NOT NULL,
+    fromstatus character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    tostatus character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    reason character varying(500) COLLATE pg_catalog."default" NOT NULL,
+    changedby character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    changedat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT bikestatuschange_pkey PRIMARY KEY (changeid),
+    CONSTRAINT bikestatuschange_bikeid_fkey FOREIGN KEY (bikeid)
+        REFERENCES public.bike (bikeid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.bikestatuschange
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.bikestatuschange_bikeid_changedat_idx;
+
+CREATE INDEX IF NOT EXISTS bikestatuschange_bikeid_changedat_idx
+    ON public.bikestatuschange USING btree
+    (bikeid ASC NULLS LAST, changedat DESC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.damagereport
+-- damages which riders report. Open and confirmed reports count towards the automatic maintenance of a bike
+
+DROP TABLE IF EXISTS public.damagereport;
+
+CREATE TABLE IF NOT EXISTS public.damagereport
+(
+    reportid uuid NOT NULL,
+    bikeid integer NOT NULL,
+    username character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    category character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    description character varying(2000) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    status character varying(20) COLLATE pg_catalog."default" NOT NULL DEFAULT 'open',
+    triagenote character varying(2000) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    triagedby character varying(50) COLLATE pg_catalog."default",
+    triagedat timestamp with time zone,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT damagereport_pkey PRIMARY KEY (reportid),
+    CONSTRAINT damagereport_bikeid_fkey FOREIGN KEY (bikeid)
+        REFERENCES public.bike (bikeid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT damagereport_username_fkey FOREIGN KEY (username)
+        REFERENCES public.users (username) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT damagereport_status_check CHECK (status IN ('open', 'confirmed', 'rejected', 'resolved'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.damagereport
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.damagereport_bikeid_status_idx;
+
+CREATE INDEX IF NOT EXISTS damagereport_bikeid_status_idx
+    ON public.damagereport USING btree
+    (bikeid ASC NULLS LAST, status ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.damagephoto
+-- photos of damage reports. The photo is stored in the blob store under the blobkey
+
+DROP TABLE IF EXISTS public.damagephoto;
+
+CREATE TABLE IF NOT EXISTS public.damagephoto
+(
+    photoid uuid NOT NULL,
+    reportid uuid NOT NULL,
+    filename character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    contenttype character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    sizebytes bigint NOT NULL,
+    blobkey character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT damagephoto_pkey PRIMARY KEY (photoid),
+    CONSTRAINT damagephoto_reportid_fkey FOREIGN KEY (reportid)
+        REFERENCES public.damagereport (reportid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.damagephoto
+    OWNER to postgres;
+
+
+
+
+-- Table: public.maintenanceplan
+-- preventive maintenance plans. a bike of the bike type is serviced every intervalkm kilometers or intervaldays days
+
+DROP TABLE IF EXISTS public.maintenanceplan;
+
+CREATE TABLE IF NOT EXISTS public.maintenanceplan
+(
+    planid character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    biketype character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    name character varying(100) COLLATE pg_catalog."default" NOT NULL,
+    intervalkm integer NOT NULL,
+    intervaldays integer NOT NULL,
+    duesoonkm integer NOT NULL DEFAULT 0,
+    duesoondays integer NOT NULL DEFAULT 0,
+    active boolean NOT NULL DEFAULT true,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT maintenanceplan_pkey PRIMARY KEY (planid),
+    CONSTRAINT maintenanceplan_interval_check CHECK (intervalkm > 0 AND intervaldays > 0),
+    CONSTRAINT maintenanceplan_duesoon_check CHECK (duesoonkm >= 0 AND duesoonkm < intervalkm AND duesoondays >= 0 AND duesoondays < intervaldays)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.maintenanceplan
+    OWNER to postgres;
+
+
+
+
+-- Table: public.maintenanceservice
+-- the last service of a bike for a maintenance plan, the usage of the plan is counted from the odometer at this service
+
+DROP TABLE IF EXISTS public.maintenanceservice;
+
+CREATE TABLE IF NOT EXISTS public.maintenanceservice
+(
+    bikeid integer NOT NULL,
+    planid character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    lastservicedat timestamp with time zone NOT NULL,
+    lastserviceodometermeters bigint NOT NULL DEFAULT 0,
+    CONSTRAINT maintenanceservice_pkey PRIMARY KEY (bikeid, planid),
+    CONSTRAINT maintenanceservice_bikeid_fkey FOREIGN KEY (bikeid)
+        REFERENCES public.bike (bikeid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT maintenanceservice_planid_fkey FOREIGN KEY (planid)
+        REFERENCES public.maintenanceplan (planid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.maintenanceservice
+    OWNER to postgres;
+
+
+
+
+-- Table: public.workorder
+-- maintenance work orders of the bikes. source is damage_report, low_battery, preventive or manual
+
+DROP TABLE IF EXISTS public.workorder;
+
+CREATE TABLE IF NOT EXISTS public.workorder
+(
+    orderid bigserial NOT NULL,
+    bikeid integer NOT NULL,
+    source character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    damagereportid uuid,
+    planid character varying(32) COLLATE pg_catalog."default",
+    title character varying(200) COLLATE pg_catalog."default" NOT NULL,
+    description character varying(2000) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    status character varying(20) COLLATE pg_catalog."default" NOT NULL DEFAULT 'open',
+    assignedto character varying(50) COLLATE pg_catalog."default",
+    createdby character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    updatedat timestamp with time zone NOT NULL DEFAULT now(),
+    closedat timestamp with time zone,
+    CONSTRAINT workorder_pkey PRIMARY KEY (orderid),
+    CONSTRAINT workorder_bikeid_fkey FOREIGN KEY (bikeid)
+        REFERENCES public.bike (bikeid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT workorder_damagereportid_fkey FOREIGN KEY (damagereportid)
+        REFERENCES public.damagereport (reportid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE SET NULL,
+    CONSTRAINT workorder_planid_fkey FOREIGN KEY (planid)
+        REFERENCES public.maintenanceplan (planid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE SET NULL,
+    CONSTRAINT workorder_assignedto_fkey FOREIGN KEY (assignedto)
+        REFERENCES public.users (username) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE SET NULL,
+    CONSTRAINT workorder_status_check CHECK (status IN ('open', 'in_progress', 'blocked', 'done'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.workorder
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.workorder_bikeid_status_idx;
+
+CREATE INDEX IF NOT EXISTS workorder_bikeid_status_idx
+    ON public.workorder USING btree
+    (bikeid ASC NULLS LAST, status ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+DROP INDEX IF EXISTS public.workorder_assignedto_idx;
+
+CREATE INDEX IF NOT EXISTS workorder_assignedto_idx
+    ON public.workorder USING btree
+    (assignedto ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.workordernote
+-- parts and labour notes of work orders
+
+DROP TABLE IF EXISTS public.workordernote;
+
+CREATE TABLE IF NOT EXISTS public.workordernote
+(
+    noteid bigserial NOT NULL,
+    orderid bigint NOT NULL,
+    kind character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    text character varying(2000) COLLATE pg_catalog."default" NOT NULL,
+    labourminutes integer NOT NULL DEFAULT 0,
+    author character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT workordernote_pkey PRIMARY KEY (noteid),
+    CONSTRAINT workordernote_orderid_fkey FOREIGN KEY (orderid)
+        REFERENCES public.workorder (orderid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT workordernote_kind_check CHECK (kind IN ('parts', 'labour', 'comment')),
+    CONSTRAINT workordernote_labourminutes_check CHECK (labourminutes >= 0)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.workordernote
+    OWNER to postgres;
+
+
+
+
+-- Table: public.bikecommand
+-- commands for the devices of the bikes. A command is queued, delivered with the long poll of the device and acknowledged or timed out
+
+DROP TABLE IF EXISTS public.bikecommand;
+
+CREATE TABLE IF NOT EXISTS public.bikecommand
+(
+    commandid bigserial NOT NULL,
+    bikeid integer NOT NULL,
+    deviceid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    command character varying(16) COLLATE pg_catalog."default" NOT NULL,
+    status character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'queued',
+    reservationid uuid,
+    requestedby character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    deliveredat timestamp with time zone,
+    completedat timestamp with time zone,
+    expiresat timestamp with time zone NOT NULL,
+    message character varying(500) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    latitude double precision,
+    longitude double precision,
+    CONSTRAINT bikecommand_pkey PRIMARY KEY (commandid),
+    CONSTRAINT bikecommand_bikeid_fkey FOREIGN KEY (bikeid)
+        REFERENCES public.bike (bikeid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT bikecommand_command_check CHECK (command IN ('unlock', 'lock', 'ring', 'locate')),
+    CONSTRAINT bikecommand_status_check CHECK (status IN ('queued', 'delivered', 'acknowledged', 'failed', 'timed_out', 'superseded'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.bikecommand
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.bikecommand_bikeid_status_idx;
+
+CREATE INDEX IF NOT EXISTS bikecommand_bikeid_status_idx
+    ON public.bikecommand USING btree
+    (bikeid ASC NULLS LAST, status ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+DROP INDEX IF EXISTS public.bikecommand_reservationid_idx;
+
+CREATE INDEX IF NOT EXISTS bikecommand_reservationid_idx
+    ON public.bikecommand USING btree
+    (reservationid ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.theftalert
+-- alerts of the theft detection. kind is moved_while_parked, left_operating_area or silent
+
+DROP TABLE IF EXISTS public.theftalert;
+
+CREATE TABLE IF NOT EXISTS public.theftalert
+(
+    alertid bigserial NOT NULL,
+    bikeid integer NOT NULL,
+    kind character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    status character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'open',
+    detail character varying(500) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    latitude double precision,
+    longitude double precision,
+    distancemeters integer,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    updatedby character varying(50) COLLATE pg_catalog."default",
+    updatedat timestamp with time zone,
+    note character varying(500) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    CONSTRAINT theftalert_pkey PRIMARY KEY (alertid),
+    CONSTRAINT theftalert_bikeid_fkey FOREIGN KEY (bikeid)
+        REFERENCES public.bike (bikeid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT theftalert_kind_check CHECK (kind IN ('moved_while_parked', 'left_operating_area', 'silent')),
+    CONSTRAINT theftalert_status_check CHECK (status IN ('open', 'acknowledged', 'resolved'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.theftalert
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.theftalert_bikeid_kind_idx;
+
+CREATE INDEX IF NOT EXISTS theftalert_bikeid_kind_idx
+    ON public.theftalert USING btree
+    (bikeid ASC NULLS LAST, kind ASC NULLS LAST)
+    TABLESPACE pg_default
+    WHERE status <> 'resolved';
+
+
+
+
+-- Table: public.webhooksubscription
+-- partners which receive events at their url. An empty list of event types receives all events
+
+DROP TABLE IF EXISTS public.webhooksubscription;
+
+CREATE TABLE IF NOT EXISTS public.webhooksubscription
+(
+    subscriptionid uuid NOT NULL,
+    url character varying(2048) COLLATE pg_catalog."default" NOT NULL,
+    eventtypes text[] COLLATE pg_catalog."default" NOT NULL DEFAULT '{}',
+    description character varying(500) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    active boolean NOT NULL DEFAULT true,
+    secret character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    createdby character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    updatedat timestamp with time zone,
+    CONSTRAINT webhooksubscription_pkey PRIMARY KEY (subscriptionid)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.webhooksubscription
+    OWNER to postgres;
+
+
+
+
+-- Table: public.webhookdelivery
+-- the queue of the webhooks: one delivery per event and subscription. A pending delivery is sent at nextattemptat,
+-- it is delivered after a successful attempt or dead after the maximal number of attempts
+
+DROP TABLE IF EXISTS public.webhookdelivery;
+
+CREATE TABLE IF NOT EXISTS public.webhookdelivery
+(
+    deliveryid bigserial NOT NULL,
+    subscriptionid uuid NOT NULL,
+    eventid uuid NOT NULL,
+    eventtype character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    payload text COLLATE pg_catalog."default" NOT NULL,
+    status character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'pending',
+    attemptcount integer NOT NULL DEFAULT 0,
+    nextattemptat timestamp with time zone,
+    laststatuscode integer,
+    lasterror character varying(500) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    deliveredat timestamp with time zone,
+    CONSTRAINT webhookdelivery_pkey PRIMARY KEY (deliveryid),
+    CONSTRAINT webhookdelivery_subscriptionid_fkey FOREIGN KEY (subscriptionid)
+        REFERENCES public.webhooksubscription (subscriptionid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT webhookdelivery_status_check CHECK (status IN ('pending', 'delivered', 'dead'))
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.webhookdelivery
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.webhookdelivery_nextattemptat_idx;
+
+CREATE INDEX IF NOT EXISTS webhookdelivery_nextattemptat_idx
+    ON public.webhookdelivery USING btree
+    (nextattemptat ASC NULLS LAST)
+    TABLESPACE pg_default
+    WHERE status = 'pending';
+
+DROP INDEX IF EXISTS public.webhookdelivery_subscriptionid_idx;
+
+CREATE INDEX IF NOT EXISTS webhookdelivery_subscriptionid_idx
+    ON public.webhookdelivery USING btree
+    (subscriptionid ASC NULLS LAST, deliveryid DESC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.webhookattempt
+-- the log of the attempts of the webhook deliveries. statuscode is null if the receiver could not be reached
+
+DROP TABLE IF EXISTS public.webhookattempt;
+
+CREATE TABLE IF NOT EXISTS public.webhookattempt
+(
+    attemptid bigserial NOT NULL,
+    deliveryid bigint NOT NULL,
+    attemptedat timestamp with time zone NOT NULL DEFAULT now(),
+    statuscode integer,
+    error character varying(500) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    durationmillis integer NOT NULL DEFAULT 0,
+    CONSTRAINT webhookattempt_pkey PRIMARY KEY (attemptid),
+    CONSTRAINT webhookattempt_deliveryid_fkey FOREIGN KEY (deliveryid)
+        REFERENCES public.webhookdelivery (deliveryid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.webhookattempt
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.webhookattempt_deliveryid_idx;
+
+CREATE INDEX IF NOT EXISTS webhookattempt_deliveryid_idx
+    ON public.webhookattempt USING btree
+    (deliveryid ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.outboxevent
+-- the domain events which are written in the transaction of the change. The dispatcher publishes them in the order of
+-- the sequence and sets publishedat, a pending event has publishedat null and the error of the sink which failed
+
+DROP TABLE IF EXISTS public.outboxevent;
+
+CREATE TABLE IF NOT EXISTS public.outboxevent
+(
+    sequence bigserial NOT NULL,
+    eventid uuid NOT NULL,
+    eventtype character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    aggregatetype character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    aggregateid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    occurredat timestamp with time zone NOT NULL DEFAULT now(),
+    payload text COLLATE pg_catalog."default" NOT NULL,
+    publishedat timestamp with time zone,
+    attemptcount integer NOT NULL DEFAULT 0,
+    lasterror character varying(500) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    CONSTRAINT outboxevent_pkey PRIMARY KEY (sequence),
+    CONSTRAINT outboxevent_eventid_key UNIQUE (eventid)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.outboxevent
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.outboxevent_pending_idx;
+
+CREATE INDEX IF NOT EXISTS outboxevent_pending_idx
+    ON public.outboxevent USING btree
+    (sequence ASC NULLS LAST)
+    TABLESPACE pg_default
+    WHERE publishedat IS NULL;
+
+DROP INDEX IF EXISTS public.outboxevent_aggregate_idx;
+
+CREATE INDEX IF NOT EXISTS outboxevent_aggregate_idx
+    ON public.outboxevent USING btree
+    (aggregatetype ASC NULLS LAST, aggregateid ASC NULLS LAST, sequence ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+
+
+
+-- Table: public.auditlog
+-- the audit log of the state-changing requests. It is append-only, the trigger rejects every change and deletion of an entry.
+-- hash is the SHA-256 of the fields and previoushash, the hash of the previous entry, so a changed entry breaks the chain
+
+DROP TABLE IF EXISTS public.auditlog;
+
+CREATE TABLE IF NOT EXISTS public.auditlog
+(
+    entryid bigserial NOT NULL,
+    requestid character varying(128) COLLATE pg_catalog."default" NOT NULL,
+    actor character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    sourceip character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    forwardedfor character varying(500) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    action character varying(200) COLLATE pg_catalog."default" NOT NULL,
+    targettype character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    targetid character varying(100) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    statuscode integer NOT NULL,
+    before text COLLATE pg_catalog."default" NOT NULL DEFAULT 'null',
+    after text COLLATE pg_catalog."default" NOT NULL DEFAULT 'null',
+    createdat timestamp with time zone NOT NULL,
+    previoushash character(64) COLLATE pg_catalog."default" NOT NULL,
+    hash character(64) COLLATE pg_catalog."default" NOT NULL,
+    CONSTRAINT auditlog_pkey PRIMARY KEY (entryid),
+    CONSTRAINT auditlog_hash_key UNIQUE (hash)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.auditlog
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.auditlog_target_idx;
+
+CREATE INDEX IF NOT EXISTS auditlog_target_idx
+    ON public.auditlog USING btree
+    (targettype ASC NULLS LAST, targetid ASC NULLS LAST, entryid DESC NULLS LAST)
+    TABLESPACE pg_default;
+
+DROP INDEX IF EXISTS public.auditlog_actor_idx;
+
+CREATE INDEX IF NOT EXISTS auditlog_actor_idx
+    ON public.auditlog USING btree
+    (actor ASC NULLS LAST, entryid DESC NULLS LAST)
+    TABLESPACE pg_default;
+
+CREATE OR REPLACE FUNCTION public.reject_auditlog_change()
+    RETURNS trigger
+    LANGUAGE plpgsql
+AS $$
+BEGIN
+    RAISE EXCEPTION 'the audit log is append-only';
+END;
+$$;
+
+CREATE TRIGGER auditlog_append_only
+    BEFORE UPDATE OR DELETE ON public.auditlog
+    FOR EACH ROW EXECUTE FUNCTION public.reject_auditlog_change();
+
+CREATE TRIGGER auditlog_no_truncate
+    BEFORE TRUNCATE ON public.auditlog
+    FOR EACH STATEMENT EXECUTE FUNCTION public.reject_auditlog_change();
+
+
+
+
+-- Table: public.idempotencykey
+-- the first response of a request with an Idempotency-Key header per user, it is replayed for the retries of the request.
+-- statuscode is null while the first request is in progress
+
+DROP TABLE IF EXISTS public.idempotencykey;
+
+CREATE TABLE IF NOT EXISTS public.idempotencykey
+(
+    username text COLLATE pg_catalog."default" NOT NULL,
+    idempotencykey character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    fingerprint character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    statuscode integer,
+    headers jsonb NOT NULL DEFAULT '{}',
+    responsebody bytea,
+    createdat timestamp with time zone NOT NULL DEFAULT now(),
+    expiresat timestamp with time zone NOT NULL,
+    CONSTRAINT idempotencykey_pkey PRIMARY KEY (username, idempotencykey)
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS public.idempotencykey
+    OWNER to postgres;
+
+DROP INDEX IF EXISTS public.idempotencykey_expiresat_idx;
+
+CREATE INDEX IF NOT EXISTS idempotencykey_expiresat_idx
+    ON public.idempotencykey USING btree
+    (expiresat ASC NULLS LAST)
+    TABLESPACE pg_default;
+
+-- Insert Data into station Table
+
+INSERT INTO public.station(
+	name, latitude, longitude, capacity, returnradiusmeters, fullpolicy)
+	VALUES ('Campus Westend', 50.119400, 8.638200, 10, 30, 'refuse');
+
+INSERT INTO public.station(
+	name, latitude, longitude, capacity, returnradiusmeters, fullpolicy)
+	VALUES ('Hauptbahnhof', 50.107100, 8.663200, 4, 40, 'warn');
 
 -- Insert Data into bike Table
 
 INSERT INTO public.bike(
-	bikeid, name, latitude, longitude, reservationid)
-	VALUES (0, 'Henry', 50.119504, 8.638137, NULL);
+	bikeid, name, latitude, longitude, reservationid, stationid)
+	VALUES (0, 'Henry', 50.119504, 8.638137, NULL, 1);
 
 INSERT INTO public.bike(
 	bikeid, name, latitude, longitude, reservationid)
@@ -118,4 +1310,69 @@ INSERT INTO public.users(
 
 INSERT INTO public.users(
 	username)
-	VALUES ('userTwo');
\ No newline at end of file
+	VALUES ('userTwo');
+
+INSERT INTO public.users(
+	username, role)
+	VALUES ('operatorOne', 'operator');
+
+-- Insert Data into promotion Table
+
+INSERT INTO public.promotion(
+	code, description, discounttype, discountvalue, freeminutes, firstrideonly, peruserlimit)
+	VALUES ('FIRSTRIDE', '10 free minutes on your first ride', 'none', 0, 10, true, 1);
+
+INSERT INTO public.promotion(
+	code, description, discounttype, discountvalue, weekendsonly)
+	VALUES ('WEEKEND10', '10% off rides started on weekends', 'percent', 10, true);
+
+-- Insert Data into plan Table
+
+INSERT INTO public.plan(
+	planid, name, pricecents, includedminutesperday, includedminutespermonth, maxconcurrentbikes)
+	VALUES ('daily30', '30 minutes every day', 1990, 30, 0, 1);
+
+INSERT INTO public.plan(
+	planid, name, pricecents, includedminutesperday, includedminutespermonth, maxconcurrentbikes)
+	VALUES ('family', '600 minutes per month for up to 3 bikes', 3990, 0, 600, 3);
+
+-- Insert Data into penaltyrule Table
+
+INSERT INTO public.penaltyrule(
+	ruleid, kind, description, feecents, thresholdminutes, additionalcentsperhour)
+	VALUES ('overDuration', 'over_duration', 'Bike held longer than 24 hours', 2000, 1440, 500);
+
+INSERT INTO public.penaltyrule(
+	ruleid, kind, description, feecents)
+	VALUES ('outsideZone', 'outside_operating_zone', 'Bike returned outside the operating zone', 2500);
+
+INSERT INTO public.penaltyrule(
+	ruleid, kind, description, feecents)
+	VALUES ('noParking', 'no_parking_zone', 'Bike returned in a no-parking zone', 1500);
+
+INSERT INTO public.penaltyrule(
+	ruleid, kind, description, feecents)
+	VALUES ('damage', 'reported_damage', 'Damage reported during the ride', 5000);
+
+-- Insert Data into maintenanceplan Table
+
+INSERT INTO public.maintenanceplan(
+	planid, biketype, name, intervalkm, intervaldays, duesoonkm, duesoondays)
+	VALUES ('standard-service', 'standard', 'Standard service', 500, 90, 50, 7);
+
+-- Insert Data into zone Table
+
+INSERT INTO public.zone(
+	zoneid, name, kind, speedlimitkmh, polygons, minlatitude, minlongitude, maxlatitude, maxlongitude)
+	VALUES ('6f0c4c55-2b1a-4a53-9d51-3f6f1b0b7a01', 'Frankfurt city', 'operating_area', NULL,
+	'[[[[8.60,50.08],[8.72,50.08],[8.72,50.16],[8.60,50.16],[8.60,50.08]]]]', 50.08, 8.60, 50.16, 8.72);
+
+INSERT INTO public.zone(
+	zoneid, name, kind, speedlimitkmh, polygons, minlatitude, minlongitude, maxlatitude, maxlongitude)
+	VALUES ('6f0c4c55-2b1a-4a53-9d51-3f6f1b0b7a02', 'Roemerberg', 'no_parking', NULL,
+	'[[[[8.680,50.109],[8.684,50.109],[8.684,50.112],[8.680,50.112],[8.680,50.109]]]]', 50.109, 8.680, 50.112, 8.684);
+
+INSERT INTO public.zone(
+	zoneid, name, kind, speedlimitkmh, polygons, minlatitude, minlongitude, maxlatitude, maxlongitude)
+	VALUES ('6f0c4c55-2b1a-4a53-9d51-3f6f1b0b7a03', 'Campus', 'slow_speed', 10,
+	'[[[[8.636,50.118],[8.652,50.118],[8.652,50.122],[8.636,50.122],[8.636,50.118]]]]', 50.118, 8.636, 50.122, 8.652);